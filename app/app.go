@@ -1,37 +1,112 @@
 package app
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
 
+	"github.com/Edestus789/sql-migrator/declarative"
 	"github.com/Edestus789/sql-migrator/logger"
 	"github.com/Edestus789/sql-migrator/processes"
 	"github.com/Edestus789/sql-migrator/storage"
 )
 
 type App interface {
-	Create(name, path string, migrationType string)
-	Up(path string)
-	Down(path string)
-	Redo(path string)
-	Status()
-	DBVersion()
+	Create(name, path string, migrationType string) error
+	CreateMulti(name, path string, count int) error
+	Up(path string) error
+	Down(path string) error
+	DownTo(path string, version int) error
+	Redo(path string, out io.Writer) error
+	RedoNamed(path, name string, out io.Writer) error
+	Nuke(path string, force bool) error
+	Status(path string) error
+	Verify(path string) error
+	StatusTemplate(text string, out io.Writer) error
+	Events(since int, out io.Writer) error
+	DumpApplied(out io.Writer) error
+	DBVersion(path string) error
+	CurrentVersion() (int, error)
+	Plan(path string, out io.Writer) error
+	Apply(path, planPath string) error
+	SchemaDump(out io.Writer) error
+	ReportDrift(expectedPath string, out io.Writer) error
+	Rename(path string, from, to int) error
+	Pending(path string) ([]storage.Migration, error)
+	Applied(path string) ([]storage.Migration, error)
+	Tag(label string) error
+	Lock(key string, ttl time.Duration) error
+	Unlock(key string) error
 }
 
 type Application struct {
 	logger     logger.Logger
 	SQLStorage storage.SQLStorage
+	Options    processes.Options
+	// ReadOnly запрещает любые изменения в директории миграций:
+	// допускаются только команды применения и просмотра статуса.
+	ReadOnly bool
+	// StorePlan — файл журнала аудита, в который после успешного up или apply
+	// дописывается запись о применённых миграциях. Пустая строка — не писать.
+	StorePlan string
+	// FromGit — диапазон коммитов base..head: up и verify работают только
+	// с миграциями, файлы которых добавлены в этом диапазоне.
+	FromGit string
+	// AssumeYes подтверждает разрушительные команды без вопросов.
+	AssumeYes bool
+	// ReportNoOp заставляет up и down возвращать ErrNoOp, если они не
+	// применили и не откатили ни одной миграции.
+	ReportNoOp bool
+	// Reporter, если задан, получает итог redo для -output json вместо
+	// строки в out.
+	Reporter *Reporter
+	// Preview, если задан, получает SQL отката перед down, downto и reset;
+	// откат выполняется только после подтверждения.
+	Preview io.Writer
+	// AfterRun — наблюдатели, получающие итог up, down, downto и redo
+	// после завершения команды, успешного или нет.
+	AfterRun []func(RunReport)
+	// AllowMissing разрешает up с -from/-to, когда версии ниже диапазона
+	// ещё не применены: этот запуск их не применяет.
+	AllowMissing bool
+
+	// upRange — диапазон версий, которым UpRange ограничивает up.
+	upRange     *versionRange
+	interactive *interactive
+	prompt      *prompt
 }
 
 var (
 	ErrInvalidMigrationName = errors.New("invalid migration name")
+	ErrVersionNotFound      = errors.New("migration version not found")
+	ErrVersionTaken         = errors.New("migration version already taken")
+	ErrVersionApplied       = errors.New("migration version already applied")
+	ErrReadOnly             = errors.New("migrations directory is read-only")
+	ErrInvalidTemplate      = errors.New("invalid status template")
+	ErrInvalidFormat        = errors.New("invalid status format")
+	ErrNoMigrations         = errors.New("no migration files found")
+	ErrInvalidEncoding      = errors.New("migration file is not valid UTF-8")
+	ErrInvalidCount         = errors.New("migration count must be positive")
+	ErrGoToolchainMissing   = errors.New("Go toolchain required to run Go migrations; install Go or use the registry mode")
+	ErrNoOp                 = errors.New("no migrations were applied or rolled back")
+	ErrAuditLogRequired     = errors.New("retention requires an audit log (-store-plan)")
+	ErrRetentionRequired    = errors.New("compact requires a positive -retention")
 
 	regGetVersion         = regexp.MustCompile(`^\d+`)
 	regGetUpMigration     = regexp.MustCompile(`^.+_up\.sql$`)
@@ -47,92 +122,861 @@ func New(logger logger.Logger, SQLStorage storage.SQLStorage) *Application {
 	}
 }
 
-func (app *Application) Create(name, filePath, migrationType string) {
-	files, err := os.ReadDir(filePath)
+func (app *Application) Create(name, filePath, migrationType string) error {
+	if err := app.checkWritable(filePath); err != nil {
+		return err
+	}
+
+	files, err := readMigrationDir(filePath)
 	if err != nil {
-		app.logger.Fatal("Failed to read directory: ", err)
-		return
+		app.logger.Error("Failed to read directory: %v", err)
+		return err
 	}
 
 	lastVersion := getLastVersion(files, app.logger)
 	if lastVersion < 0 {
-		return
+		return ErrInvalidMigrationName
 	}
 
 	lastVersion++
 
 	if err := createMigrationFiles(filePath, lastVersion, name, app.logger, migrationType); err != nil {
-		app.logger.Fatal("Failed to create migration files: ", err)
+		app.logger.Error("Failed to create migration files: %v", err)
+		return err
 	}
+	return nil
 }
 
-func (app *Application) Up(filePath string) {
-	app.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
-		return migrator.Up(ctx)
+// CreateMulti резервирует count последовательных версий для запланированной
+// серии миграций: создаются пустые файлы name_1 ... name_count с версиями,
+// следующими за последней версией в директории.
+func (app *Application) CreateMulti(name, filePath string, count int) error {
+	if count < 1 {
+		return fmt.Errorf("%w: %d", ErrInvalidCount, count)
+	}
+	if err := app.checkWritable(filePath); err != nil {
+		return err
+	}
+
+	files, err := readMigrationDir(filePath)
+	if err != nil {
+		app.logger.Error("Failed to read directory: %v", err)
+		return err
+	}
+
+	lastVersion := getLastVersion(files, app.logger)
+	if lastVersion < 0 {
+		return ErrInvalidMigrationName
+	}
+
+	for i := 1; i <= count; i++ {
+		stepName := fmt.Sprintf("%s_%d", name, i)
+		if err := createMigrationFiles(filePath, lastVersion+i, stepName, app.logger, "sql"); err != nil {
+			app.logger.Error("Failed to create migration files: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (app *Application) Up(filePath string) error {
+	return app.runObserved("up", filePath, app.applyPending)
+}
+
+// applyPending выполняет up для подготовленного мигратора и записывает
+// результат в журнал аудита.
+func (app *Application) applyPending(migrator *processes.Migrator, ctx context.Context) error {
+	if err := migrator.Up(ctx); err != nil {
+		return err
+	}
+	if err := app.storePlan("up", migrator.Result()); err != nil {
+		return err
+	}
+	return app.noOp(migrator.Result())
+}
+
+func (app *Application) Down(filePath string) error {
+	return app.runObserved("down", filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		if err := app.previewRollback(ctx, migrator, 0, 1); err != nil {
+			return err
+		}
+		if err := migrator.Down(ctx); err != nil {
+			return err
+		}
+		return app.noOp(migrator.Result())
 	})
 }
 
-func (app *Application) Down(filePath string) {
-	app.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
-		return migrator.Down(ctx)
+// noOp возвращает ErrNoOp, если включён ReportNoOp и мигратор ничего не изменил.
+func (app *Application) noOp(result processes.Result) error {
+	if app.ReportNoOp && result.NoOp() {
+		app.logger.Info("Nothing to apply")
+		return ErrNoOp
+	}
+	return nil
+}
+
+// DownTo откатывает миграции новее version. Откат всех миграций (version 0)
+// требует подтверждения; с Preview его заменяет подтверждение после показа SQL.
+func (app *Application) DownTo(filePath string, version int) error {
+	if version == 0 && app.Preview == nil {
+		if err := app.confirm("rolling back every applied migration"); err != nil {
+			return err
+		}
+	}
+	return app.runObserved("downto", filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		if err := app.previewRollback(ctx, migrator, version, 0); err != nil {
+			return err
+		}
+		return migrator.DownTo(ctx, version)
 	})
 }
 
-func (app *Application) Redo(filePath string) {
-	app.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
-		return migrator.Redo(ctx)
+// previewRollback выводит в Preview SQL миграций, которые откатит команда,
+// и спрашивает подтверждение. limit > 0 ограничивает число миграций:
+// down откатывает только одну. Без Preview ничего не делает.
+func (app *Application) previewRollback(ctx context.Context, migrator *processes.Migrator, version, limit int) error {
+	if app.Preview == nil {
+		return nil
+	}
+
+	migrations, err := migrator.RollbackPreview(ctx, version)
+	if err != nil {
+		return err
+	}
+	if limit > 0 && len(migrations) > limit {
+		migrations = migrations[:limit]
+	}
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	if err := writeRollbackPreview(app.Preview, migrations); err != nil {
+		return err
+	}
+	return app.confirm(fmt.Sprintf("rolling back %d migration(s) shown above", len(migrations)))
+}
+
+// writeRollbackPreview выводит SQL отката каждой миграции под заголовком
+// "-- Version N: name (down)". Для Go-отката выводится только пометка,
+// что его выполняет код.
+func writeRollbackPreview(out io.Writer, migrations []storage.Migration) error {
+	var b strings.Builder
+	for _, migration := range migrations {
+		fmt.Fprintf(&b, "-- Version %d: %s (down)\n", migration.Version, migration.Name)
+		if sql := strings.TrimSpace(migration.Down); sql != "" {
+			b.WriteString(sql + "\n")
+		}
+		if migration.DownGo != nil {
+			b.WriteString("-- Go migration: rolled back by code, no SQL to show\n")
+		} else if strings.TrimSpace(migration.Down) == "" {
+			b.WriteString("-- (no down SQL)\n")
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// Nuke откатывает все миграции и удаляет служебную таблицу, возвращая
+// тестовую базу в исходное состояние. Без force требует подтверждения.
+func (app *Application) Nuke(filePath string, force bool) error {
+	if !force {
+		if err := app.confirm("nuke rolls back every migration and drops the tracking table"); err != nil {
+			return err
+		}
+	}
+	return app.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		return migrator.Nuke(ctx)
+	})
+}
+
+// Compact удаляет из журнала аудита StorePlan записи старше retention.
+// Служебная таблица хранит одну запись на версию и не сжимается, база
+// данных не затрагивается.
+func (app *Application) Compact(retention time.Duration) error {
+	if retention <= 0 {
+		app.logger.Error("Compact needs a positive -retention")
+		return ErrRetentionRequired
+	}
+	if app.StorePlan == "" {
+		app.logger.Error("Retention %s given without an audit log", retention)
+		return ErrAuditLogRequired
+	}
+	return app.compactAudit(time.Now().Add(-retention))
+}
+
+// Redo откатывает и заново применяет последнюю применённую миграцию и
+// выводит в out, какая миграция выполнена повторно.
+func (app *Application) Redo(filePath string, out io.Writer) error {
+	return app.runObserved("redo", filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		result, err := migrator.Redo(ctx)
+		if err != nil {
+			return err
+		}
+		return app.writeRedo(out, result)
 	})
 }
 
-func (app *Application) Status() {
-	app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+// RedoNamed откатывает и заново применяет миграцию с именем name.
+func (app *Application) RedoNamed(filePath, name string, out io.Writer) error {
+	return app.runObserved("redo", filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		result, err := migrator.RedoNamed(ctx, name)
+		if err != nil {
+			return err
+		}
+		return app.writeRedo(out, result)
+	})
+}
+
+// RedoSummary — итог redo в объекте Outcome.
+type RedoSummary struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+}
+
+// writeRedo выводит итог redo строкой "redid version N: name" или, при
+// -output json, передаёт его Reporter для объекта Outcome.
+func (app *Application) writeRedo(out io.Writer, result processes.RedoResult) error {
+	if app.Reporter != nil && app.Reporter.Redone(RedoSummary{Version: result.Version, Name: result.Name}) {
+		return nil
+	}
+	if out == nil {
+		return nil
+	}
+	_, err := fmt.Fprintf(out, "redid version %d: %s\n", result.Version, result.Name)
+	return err
+}
+
+// Status выводит статус записанных миграций. Если указана директория
+// миграций, отмечаются также применённые миграции, изменённые после применения.
+func (app *Application) Status(filePath string) error {
+	command := func(migrator *processes.Migrator, ctx context.Context) error {
 		return migrator.Status(ctx)
+	}
+	var err error
+	if filePath == "" {
+		err = app.runSingleCommand(command)
+	} else {
+		err = app.runMigrations(filePath, command)
+	}
+	// До первого up служебной таблицы может не быть: это не ошибка статуса.
+	if storage.IsTrackingTableAbsent(err) {
+		app.logger.Info("No migrations applied yet (tracking table not initialized)")
+		return nil
+	}
+	return err
+}
+
+// Verify сверяет контрольные суммы применённых миграций с файлами в директории.
+func (app *Application) Verify(filePath string) error {
+	return app.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		return migrator.Verify(ctx)
+	})
+}
+
+// StatusTemplate выводит статус миграций в out по пользовательскому шаблону
+// text/template. Шаблон выполняется над срезом storage.Migration,
+// отсортированным по версии, и проверяется до обращения к базе данных.
+func (app *Application) StatusTemplate(text string, out io.Writer) error {
+	tmpl, err := template.New("status").Parse(text)
+	if err != nil {
+		app.logger.Error("Invalid status template: %v", err)
+		return fmt.Errorf("%w: %w", ErrInvalidTemplate, err)
+	}
+
+	return app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+		records, err := migrator.StatusRecords(ctx)
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(out, records)
 	})
 }
 
-// DbVersion выводит текущую версию базы данных.
-func (app *Application) DBVersion() {
-	app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+// Форматы вывода команды status.
+const (
+	StatusFormatTable = "table"
+	StatusFormatCSV   = "csv"
+)
+
+// ParseStatusFormat разбирает значение флага -format.
+func ParseStatusFormat(s string) (string, error) {
+	switch s {
+	case "", StatusFormatTable:
+		return StatusFormatTable, nil
+	case StatusFormatCSV:
+		return StatusFormatCSV, nil
+	default:
+		return "", fmt.Errorf("%w: %q, expected %s or %s", ErrInvalidFormat, s, StatusFormatTable, StatusFormatCSV)
+	}
+}
+
+// StatusCSV выводит в out всю историю миграций в формате CSV с заголовком
+// version,name,status,status_change_time,checksum, отсортированную по версии.
+// Поля с запятыми и кавычками экранируются по RFC 4180.
+func (app *Application) StatusCSV(out io.Writer) error {
+	return app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+		records, err := migrator.StatusRecords(ctx)
+		if err != nil {
+			return err
+		}
+		return writeStatusCSV(out, records)
+	})
+}
+
+func writeStatusCSV(out io.Writer, records []storage.Migration) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"version", "name", "status", "status_change_time", "checksum"}); err != nil {
+		return err
+	}
+	for _, record := range records {
+		row := []string{
+			strconv.Itoa(record.Version),
+			record.Name,
+			record.Status,
+			record.StatusChangeTime.UTC().Format(time.RFC3339),
+			record.Checksum,
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Event — запись о миграции в потоке изменений схемы.
+type Event struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DumpApplied выводит в out единый SQL-скрипт из текста миграций вверх,
+// записанных в служебной таблице как успешно применённые, в порядке версий.
+// В отличие от файлов в директории скрипт отражает то, что действительно
+// выполнялось в этой базе. Для миграций без записанного SQL (Go-миграции
+// и записи, сделанные до появления колонки up_sql) выводится только заголовок.
+func (app *Application) DumpApplied(out io.Writer) error {
+	return app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+		records, err := migrator.StatusRecords(ctx)
+		if err != nil {
+			return err
+		}
+		return writeAppliedScript(out, records)
+	})
+}
+
+func writeAppliedScript(out io.Writer, records []storage.Migration) error {
+	for _, record := range records {
+		if record.Status != storage.StatusSuccess {
+			continue
+		}
+
+		header := fmt.Sprintf("-- Version %d: %s", record.Version, record.Name)
+		body := strings.TrimSpace(record.Up)
+		if body == "" {
+			header += " (up SQL not recorded)"
+		}
+		if _, err := fmt.Fprintln(out, header); err != nil {
+			return err
+		}
+		if body != "" {
+			if _, err := fmt.Fprintln(out, body); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Events выводит в out записи о миграциях с версией выше since в формате
+// JSON Lines, по одной записи на строку в порядке версий. Команда только
+// читает данные и предназначена для инкрементальной выгрузки изменений схемы.
+func (app *Application) Events(since int, out io.Writer) error {
+	return app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+		records, err := migrator.StatusRecords(ctx)
+		if err != nil {
+			return err
+		}
+		return writeEvents(out, records, since)
+	})
+}
+
+func writeEvents(out io.Writer, records []storage.Migration, since int) error {
+	encoder := json.NewEncoder(out)
+	for _, record := range records {
+		if record.Version <= since {
+			continue
+		}
+		err := encoder.Encode(Event{
+			Version:   record.Version,
+			Name:      record.Name,
+			Status:    record.Status,
+			Timestamp: record.StatusChangeTime.UTC(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Plan записывает в out план: миграции из директории, которые применит up,
+// с версиями, именами и контрольными суммами, в формате JSON.
+func (app *Application) Plan(filePath string, out io.Writer) error {
+	return app.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		plan, err := migrator.Plan(ctx)
+		if err != nil {
+			return err
+		}
+		app.logger.Info("Plan contains %d migrations", len(plan.Migrations))
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
+	})
+}
+
+// Apply применяет ровно миграции из плана planPath, созданного командой plan.
+// Если файлы миграций изменились после создания плана, ничего не применяется.
+func (app *Application) Apply(filePath, planPath string) error {
+	if planPath == "" {
+		return errors.New("plan file must be provided with -plan")
+	}
+	plan, err := app.readPlan(planPath)
+	if err != nil {
+		return err
+	}
+
+	return app.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		if err := migrator.ApplyPlan(ctx, plan); err != nil {
+			return err
+		}
+		return app.storePlan("apply", migrator.Result())
+	})
+}
+
+// UpManifest применяет ожидающие миграции, кроме уже перечисленных
+// в manifestPath — манифесте применённых миграций другого окружения,
+// созданном командой export-applied. Миграция пропускается, только если
+// в манифесте совпадают её версия и контрольная сумма; пропущенные версии
+// записываются со статусом skipped.
+func (app *Application) UpManifest(filePath, manifestPath string) error {
+	manifest, err := app.readPlan(manifestPath)
+	if err != nil {
+		return err
+	}
+	app.logger.Info("Manifest %s lists %d applied migrations", manifestPath, len(manifest.Migrations))
+
+	return app.runObserved("up", filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		if err := migrator.UpExcept(ctx, manifest); err != nil {
+			return err
+		}
+		if err := app.storePlan("up", migrator.Result()); err != nil {
+			return err
+		}
+		return app.noOp(migrator.Result())
+	})
+}
+
+// ExportApplied записывает в out манифест применённых миграций: версии,
+// имена и записанные контрольные суммы успешно применённых миграций
+// в формате плана. Манифест передаётся другому окружению в -applied-manifest.
+func (app *Application) ExportApplied(out io.Writer) error {
+	return app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+		records, err := migrator.StatusRecords(ctx)
+		if err != nil {
+			return err
+		}
+
+		manifest := processes.Plan{Migrations: []processes.PlanStep{}}
+		for _, record := range records {
+			if record.Status != storage.StatusSuccess {
+				continue
+			}
+			manifest.Migrations = append(manifest.Migrations, processes.PlanStep{
+				Version:  record.Version,
+				Name:     record.Name,
+				Checksum: record.Checksum,
+			})
+		}
+		app.logger.Info("Manifest contains %d applied migrations", len(manifest.Migrations))
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(manifest)
+	})
+}
+
+// readPlan читает план или манифест в формате команды plan.
+func (app *Application) readPlan(path string) (processes.Plan, error) {
+	var plan processes.Plan
+	data, err := os.ReadFile(path)
+	if err != nil {
+		app.logger.Error("Failed to read plan: %v", err)
+		return plan, err
+	}
+	if err := json.Unmarshal(data, &plan); err != nil {
+		app.logger.Error("Failed to parse plan %s: %v", path, err)
+		return plan, fmt.Errorf("%s: %w", path, err)
+	}
+	return plan, nil
+}
+
+// SchemaDump записывает в out снимок текущей схемы базы данных в формате JSON.
+// Снимок служит ожидаемой схемой для команды report-drift.
+func (app *Application) SchemaDump(out io.Writer) error {
+	return app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+		snapshot, err := migrator.SchemaSnapshot(ctx)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(snapshot)
+	})
+}
+
+// ReportDrift сравнивает текущую схему базы данных со снимком из файла
+// expectedPath, созданным schema-dump, и выводит в out добавленные, удалённые
+// и изменённые таблицы и колонки. Команда только читает базу данных.
+func (app *Application) ReportDrift(expectedPath string, out io.Writer) error {
+	if expectedPath == "" {
+		return errors.New("expected schema snapshot must be provided with -expected")
+	}
+	expected, err := app.readSchemaSnapshot(expectedPath)
+	if err != nil {
+		return err
+	}
+
+	return app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+		changes, driftErr := migrator.ReportDrift(ctx, expected)
+		for _, change := range changes {
+			if _, err := fmt.Fprintln(out, change); err != nil {
+				return err
+			}
+		}
+		return driftErr
+	})
+}
+
+// DbVersion выводит текущую версию базы данных. Если указана директория
+// миграций, выводится также последняя доступная версия и число ожидающих.
+func (app *Application) DBVersion(filePath string) error {
+	command := func(migrator *processes.Migrator, ctx context.Context) error {
 		return migrator.DBVersion(ctx)
+	}
+	if filePath == "" {
+		return app.runSingleCommand(command)
+	}
+	return app.runMigrations(filePath, command)
+}
+
+// CurrentVersion возвращает последнюю успешно применённую версию или 0 для
+// пустой базы данных, не читая директорию миграций. Подходит для проверок
+// готовности приложения.
+func (app *Application) CurrentVersion() (int, error) {
+	var version int
+	err := app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+		var err error
+		version, err = migrator.CurrentVersion(ctx)
+		return err
+	})
+	return version, err
+}
+
+// Pending возвращает миграции из директории, ещё не применённые в базе данных.
+func (app *Application) Pending(filePath string) ([]storage.Migration, error) {
+	var pending []storage.Migration
+	err := app.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		var err error
+		pending, err = migrator.Pending(ctx)
+		return err
+	})
+	return pending, err
+}
+
+// Applied возвращает миграции из директории, успешно применённые в базе данных.
+func (app *Application) Applied(filePath string) ([]storage.Migration, error) {
+	var applied []storage.Migration
+	err := app.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		var err error
+		applied, err = migrator.Applied(ctx)
+		return err
 	})
+	return applied, err
 }
 
-func (app *Application) runMigrations(filePath string, migrationFunc func(*processes.Migrator, context.Context) error) {
-	migrator := processes.New(app.SQLStorage, app.logger)
-	migrations, err := getMigrations(filePath)
+// Tag записывает тег релиза label с текущей версией схемы.
+func (app *Application) Tag(label string) error {
+	return app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+		_, err := migrator.Tag(ctx, label)
+		return err
+	})
+}
+
+// CheckLock подключается к базе, берёт блокировку мигратора и сразу
+// снимает её. Схема и служебная таблица не меняются.
+func (app *Application) CheckLock() error {
+	return app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+		return migrator.CheckLock(ctx)
+	})
+}
+
+// persistentLockPoll — интервал повторных попыток захвата занятой блокировки.
+var persistentLockPoll = time.Second
+
+// Lock захватывает постоянную блокировку key, которая переживает завершение
+// процесса: так несколько запусков CLI (например, up и затем seed) выполняются
+// под одной блокировкой. Если блокировка занята, команда ждёт её освобождения
+// или истечения TTL владельца. ttl == 0 — блокировка без срока действия.
+func (app *Application) Lock(key string, ttl time.Duration) error {
+	return app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+		return migrator.AcquirePersistentLock(ctx, key, lockOwner(), ttl, persistentLockPoll)
+	})
+}
+
+// Unlock снимает постоянную блокировку key.
+func (app *Application) Unlock(key string) error {
+	return app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+		return migrator.ReleasePersistentLock(ctx, key)
+	})
+}
+
+// lockOwner возвращает идентификатор текущего процесса для записи блокировки.
+func lockOwner() string {
+	return fmt.Sprintf("%s:%d", hostname(), os.Getpid())
+}
+
+// checkWritable проверяет, что в директорию миграций можно писать,
+// до того как команда начнёт что-либо вычислять или создавать.
+func (app *Application) checkWritable(filePath string) error {
+	if app.ReadOnly {
+		app.logger.Error("Migrations directory %s is read-only; only apply and status commands are allowed", filePath)
+		return fmt.Errorf("%w: %s", ErrReadOnly, filePath)
+	}
+
+	probe, err := os.CreateTemp(filePath, ".gomigrator-*")
 	if err != nil {
-		app.logger.Fatal("Failed to get migrations: ", err)
-		return
+		app.logger.Error("Migrations directory %s is not writable: %v", filePath, err)
+		return fmt.Errorf("%w: %s: %w", ErrReadOnly, filePath, err)
 	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
 
-	for _, migration := range migrations {
-		migrator.Create(migration.Name, migration.Up, migration.Down, migration.UpGo, migration.DownGo)
+// Rename перенумеровывает все файлы миграции с версии from на версию to.
+// Переименование запрещено, если версия to уже занята или уже выполнялась
+// версия from либо любая версия, чей порядковый номер сдвигается при
+// переименовании: служебная таблица хранит статусы по порядковым номерам,
+// см. Migrator.Add. Непустой shards перечисляет базы многобазового запуска:
+// статус проверяется в каждой, а файлы переименовываются один раз.
+func (app *Application) Rename(filePath string, from, to int, shards ...Shard) error {
+	if from <= 0 || to <= 0 {
+		return fmt.Errorf("%w: %d -> %d", ErrVersionNotFound, from, to)
+	}
+
+	if err := app.checkWritable(filePath); err != nil {
+		return err
+	}
+
+	files, err := readMigrationDir(filePath)
+	if err != nil {
+		app.logger.Error("Failed to read directory: %v", err)
+		return err
+	}
+
+	var toRename []string
+	for _, file := range files {
+		version, _, err := parseFileName(file.Name())
+		if err != nil {
+			continue
+		}
+
+		switch version {
+		case from:
+			toRename = append(toRename, file.Name())
+		case to:
+			app.logger.Error("Version %d is already taken by %s", to, file.Name())
+			return fmt.Errorf("%w: %d", ErrVersionTaken, to)
+		}
+	}
+
+	if len(toRename) == 0 {
+		app.logger.Error("No migration files found for version %d", from)
+		return fmt.Errorf("%w: %d", ErrVersionNotFound, from)
+	}
+
+	migrations, err := getMigrations(DirSource(filePath))
+	if err != nil {
+		app.logger.Error("Failed to get migrations: %v", err)
+		return err
+	}
+	versions := make([]int, 0, len(migrations))
+	for version := range migrations {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	moved := renamedPositions(versions, from, to)
+	checkStatus := func(migrator *processes.Migrator, ctx context.Context) error {
+		for _, position := range moved {
+			status, err := migrator.VersionStatus(ctx, position)
+			if err != nil {
+				return err
+			}
+
+			switch status {
+			case storage.StatusSuccess, storage.StatusProcess, storage.StatusError, storage.StatusCancelled:
+				return fmt.Errorf("%w: version %d has status %s", ErrVersionApplied, versions[position-1], status)
+			}
+		}
+		return nil
+	}
+	if len(shards) == 0 {
+		err = app.runSingleCommand(checkStatus)
+	} else {
+		_, err = RunShards(app.logger, shards, app.Options, len(shards), func(shard *Application) error {
+			return shard.runSingleCommand(checkStatus)
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, name := range toRename {
+		newName := fmt.Sprintf("%05d", to) + strings.TrimLeft(name, "0123456789")
+		if err := os.Rename(path.Join(filePath, name), path.Join(filePath, newName)); err != nil {
+			app.logger.Error("Failed to rename %s: %v", name, err)
+			return err
+		}
+		app.logger.Info("%s renamed to %s", name, newName)
+	}
+
+	return nil
+}
+
+// renamedPositions возвращает порядковые номера (с 1), которые меняются при
+// переименовании версии from в to: номер самой from и номера версий между
+// её старым и новым местом в отсортированном списке versions.
+func renamedPositions(versions []int, from, to int) []int {
+	oldPosition := sort.SearchInts(versions, from) + 1
+	newPosition := sort.SearchInts(versions, to) + 1
+	if to > from {
+		// Сама версия from больше не стоит перед to.
+		newPosition--
+	}
+
+	first, last := oldPosition, newPosition
+	if first > last {
+		first, last = last, first
+	}
+	positions := make([]int, 0, last-first+1)
+	for position := first; position <= last; position++ {
+		positions = append(positions, position)
+	}
+	return positions
+}
+
+func (app *Application) runMigrations(filePath string, migrationFunc func(*processes.Migrator, context.Context) error) error {
+	migrations, err := getMigrations(DirSource(filePath))
+	if err != nil {
+		app.logger.Error("Failed to get migrations: %v", err)
+		return err
+	}
+	if len(migrations) == 0 {
+		return app.noMigrations(filePath)
+	}
+
+	versions := make([]int, 0, len(migrations))
+	for version := range migrations {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	selected, err := app.gitSelection(filePath, versions)
+	if err != nil {
+		return err
+	}
+	migrator := app.newMigrator(versions, app.rangeSelection(versions, selected))
+
+	for _, version := range versions {
+		migrator.Add(*migrations[version])
 	}
 
 	ctx := context.Background()
 	if err := migrator.Connect(ctx); err != nil {
-		app.logger.Fatal("Failed to connect to database: ", err)
-		return
+		app.logger.Error("Failed to connect to database: %v", err)
+		return err
 	}
 	defer migrator.Close(ctx)
 
 	if err := migrationFunc(migrator, ctx); err != nil {
-		app.logger.Error("Migration failed: ", err)
+		if !errors.Is(err, ErrNoOp) {
+			app.logger.Error("Migration failed: %v", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// checkMigrationFiles возвращает ErrNoMigrations, если в директории нет файлов миграций.
+func (app *Application) checkMigrationFiles(filePath string) error {
+	migrations, err := getMigrations(DirSource(filePath))
+	if err != nil {
+		app.logger.Error("Failed to get migrations: %v", err)
+		return err
 	}
+	if len(migrations) == 0 {
+		return app.noMigrations(filePath)
+	}
+	return nil
+}
+
+// noMigrations сообщает об отсутствии файлов миграций. Это не сбой,
+// но CI должен отличать «нечего делать» от «всё уже применено».
+func (app *Application) noMigrations(filePath string) error {
+	app.logger.Info("No migration files found in %s", filePath)
+	return fmt.Errorf("%w in %s", ErrNoMigrations, filePath)
 }
 
-func (app *Application) runSingleCommand(commandFunc func(*processes.Migrator, context.Context) error) {
-	migrator := processes.New(app.SQLStorage, app.logger)
+// runSingleCommand выполняет команду только для чтения. Такие команды
+// не должны брать advisory lock, чтобы не ждать идущую миграцию.
+func (app *Application) runSingleCommand(commandFunc func(*processes.Migrator, context.Context) error) error {
+	migrator := app.newMigrator(nil, nil)
 	ctx := context.Background()
 	if err := migrator.Connect(ctx); err != nil {
-		app.logger.Fatal("Failed to connect to database: ", err)
-		return
+		app.logger.Error("Failed to connect to database: %v", err)
+		return err
 	}
 	defer migrator.Close(ctx)
 
 	if err := commandFunc(migrator, ctx); err != nil {
-		app.logger.Error("Command failed: ", err)
+		app.logger.Error("Command failed: %v", err)
+		return err
 	}
+	return nil
+}
+
+// newMigrator создаёт мигратор с параметрами приложения. versions —
+// отсортированные версии файлов миграций: мигратор нумерует миграции по
+// порядку, поэтому версии -skip переводятся в порядковые номера, см.
+// skipPositions. Непустой only ограничивает up и verify этими порядковыми
+// номерами миграций.
+func (app *Application) newMigrator(versions []int, only map[int]bool) *processes.Migrator {
+	opts := app.Options
+	opts.SkipVersions = app.skipPositions(versions, opts.SkipVersions)
+	if only != nil {
+		opts.OnlyVersions = only
+	}
+	if app.interactive != nil {
+		opts.BeforeMigration = app.interactive.confirm
+	}
+	return processes.New(app.SQLStorage, app.logger).WithOptions(opts)
 }
 
 func getLastVersion(files []os.DirEntry, logger logger.Logger) int {
@@ -161,7 +1005,11 @@ func createMigrationFiles(filePath string, version int, name string, logger logg
 	switch migrationType {
 	case "sql":
 		upFile := path.Join(filePath, fmt.Sprintf("%05d_%s_up.sql", version, name))
-		err := os.WriteFile(upFile, []byte(""), 0o600)
+		upContent := ""
+		if author := gitAuthor(filePath); author != "" {
+			upContent = "-- migrate:author " + author + "\n"
+		}
+		err := os.WriteFile(upFile, []byte(upContent), 0o600)
 		if err != nil {
 			return err
 		}
@@ -196,7 +1044,7 @@ func Up(ctx context.Context) error {
 		created_at TIMESTAMP NOT NULL DEFAULT NOW()
 		);"
 
-	if err := db.Migrate(ctx, sql); err != nil {
+	if _, err := db.Migrate(ctx, sql); err != nil {
 		return fmt.Errorf("could not execute migration: %v", err)
 	}
 
@@ -226,7 +1074,7 @@ func Down(ctx context.Context) error {
 
 	sql := "DROP TABLE IF EXISTS users;""
 
-	if err := db.Migrate(ctx, sql); err != nil {
+	if _, err := db.Migrate(ctx, sql); err != nil {
 		return fmt.Errorf("could not execute migration: %v", err)
 	}
 
@@ -245,21 +1093,25 @@ func Down(ctx context.Context) error {
 	return nil
 }
 
-func getMigrations(filePath string) (map[int]*storage.Migration, error) {
-	files, err := os.ReadDir(filePath)
+// getMigrations загружает миграции из source, объединяя файлы одной версии.
+func getMigrations(source Source) (map[int]*storage.Migration, error) {
+	files, err := source.List()
 	if err != nil {
 		return nil, err
 	}
+	if err := checkVersionFiles(files); err != nil {
+		return nil, err
+	}
 
 	migrations := make(map[int]*storage.Migration)
 
 	for _, file := range files {
-		version, migrationName, err := parseFileName(file.Name())
+		version, migrationName, err := parseFileName(file.Name)
 		if err != nil {
 			return nil, err
 		}
 
-		migration, err := processMigrationFile(filePath, file, version, migrationName)
+		migration, err := processMigrationFile(source, file.Name, version, migrationName)
 		if err != nil {
 			return nil, err
 		}
@@ -288,6 +1140,12 @@ func parseFileName(fileName string) (int, string, error) {
 	}
 
 	parts := strings.Split(fileName, "_")
+	if declarative.IsSpecFile(fileName) && len(parts) >= 2 {
+		// Декларативная миграция не делится на _up/_down: имя — всё после версии.
+		migrationName := strings.Join(parts[1:], "_")
+		return version, strings.TrimSuffix(migrationName, path.Ext(migrationName)), nil
+	}
+
 	if len(parts) < 3 {
 		return 0, "", ErrInvalidMigrationName
 	}
@@ -296,56 +1154,160 @@ func parseFileName(fileName string) (int, string, error) {
 	return version, migrationName, nil
 }
 
-func processMigrationFile(filePath string, file os.DirEntry, version int, migrationName string) (*storage.Migration, error) {
-	filePathFull := path.Join(filePath, file.Name())
+func processMigrationFile(source Source, fileName string, version int, migrationName string) (*storage.Migration, error) {
+	filePathFull := sourcePath(source, fileName)
 
 	switch {
-	case regGetUpMigration.MatchString(file.Name()):
-		sql, err := os.ReadFile(filePathFull)
+	case regGetUpMigration.MatchString(fileName):
+		sql, err := readMigrationFile(source, fileName)
 		if err != nil {
 			return nil, err
 		}
+		if _, err := processes.DelimiterDirective(string(sql)); err != nil {
+			return nil, fmt.Errorf("%s: %w", filePathFull, err)
+		}
 		return &storage.Migration{
-			Version: version,
-			Name:    migrationName,
-			Up:      string(sql),
+			Version:    version,
+			Name:       migrationName,
+			Up:         string(sql),
+			SourceFile: filePathFull,
+			Checksum:   checksum(sql),
 		}, nil
 
-	case regGetDownMigration.MatchString(file.Name()):
-		sql, err := os.ReadFile(filePathFull)
+	case regGetDownMigration.MatchString(fileName):
+		sql, err := readMigrationFile(source, fileName)
 		if err != nil {
 			return nil, err
 		}
+		if _, err := processes.DelimiterDirective(string(sql)); err != nil {
+			return nil, fmt.Errorf("%s: %w", filePathFull, err)
+		}
 		return &storage.Migration{
-			Version: version,
-			Name:    migrationName,
-			Down:    string(sql),
+			Version:    version,
+			Name:       migrationName,
+			Down:       string(sql),
+			SourceFile: filePathFull,
 		}, nil
 
-	case regGetUpGoMigration.MatchString(file.Name()):
+	case regGetUpGoMigration.MatchString(fileName):
+		dir, ok := source.(DirSource)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrGoMigrationSource, fileName)
+		}
+		data, err := source.Read(fileName)
+		if err != nil {
+			return nil, err
+		}
 		return &storage.Migration{
 			Version: version,
 			Name:    migrationName,
 			UpGo: func(ctx context.Context) error {
-				return runGoMigration(filePath, file.Name())
+				return goMigrationRunner(ctx, string(dir), fileName)
 			},
+			GoProcess:  true,
+			SourceFile: filePathFull,
+			Checksum:   checksum(data),
 		}, nil
 
-	case regGetDownGoMigration.MatchString(file.Name()):
+	case regGetDownGoMigration.MatchString(fileName):
+		dir, ok := source.(DirSource)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrGoMigrationSource, fileName)
+		}
 		return &storage.Migration{
 			Version: version,
 			Name:    migrationName,
 			DownGo: func(ctx context.Context) error {
-				return runGoMigration(filePath, file.Name())
+				return goMigrationRunner(ctx, string(dir), fileName)
 			},
+			GoProcess:  true,
+			SourceFile: filePathFull,
 		}, nil
 
+	case declarative.IsSpecFile(fileName):
+		return processDeclarativeFile(source, fileName, version, migrationName)
+
 	default:
 		return nil, ErrInvalidMigrationName
 	}
 }
 
+// utf8BOM — метка порядка байтов, которую некоторые редакторы добавляют в начало файла.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// readMigrationFile читает файл миграции, удаляя ведущую метку BOM. Файл
+// в кодировке, отличной от UTF-8, отклоняется сразу: иначе Postgres сообщит
+// о непонятной ошибке на первом же байте.
+func readMigrationFile(source Source, fileName string) ([]byte, error) {
+	data, err := source.Read(fileName)
+	if err != nil {
+		return nil, err
+	}
+	filePathFull := sourcePath(source, fileName)
+
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if !utf8.Valid(data) {
+		for i := 0; i < len(data); {
+			r, size := utf8.DecodeRune(data[i:])
+			if r == utf8.RuneError && size == 1 {
+				return nil, fmt.Errorf("%w: %s: invalid byte 0x%02X at offset %d",
+					ErrInvalidEncoding, filePathFull, data[i], i)
+			}
+			i += size
+		}
+	}
+	return data, nil
+}
+
+// processDeclarativeFile преобразует YAML/JSON-описание миграции в SQL для Postgres.
+func processDeclarativeFile(source Source, fileName string, version int, migrationName string) (*storage.Migration, error) {
+	data, err := readMigrationFile(source, fileName)
+	if err != nil {
+		return nil, err
+	}
+	filePathFull := sourcePath(source, fileName)
+
+	spec, err := declarative.Parse(filePathFull, data)
+	if err != nil {
+		return nil, err
+	}
+
+	generator, err := declarative.GeneratorFor("postgres")
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage.Migration{
+		Version:    version,
+		Name:       migrationName,
+		Up:         generator.Up(spec),
+		Down:       generator.Down(spec),
+		SourceFile: filePathFull,
+		Checksum:   checksum(data),
+	}, nil
+}
+
+// checksum возвращает SHA-256 содержимого файла миграции. Контрольная сумма
+// считается одинаково для SQL и Go, чтобы правка любого применённого файла
+// обнаруживалась командами status и verify.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 func mergeMigrations(existing, new *storage.Migration) {
+	// Источником миграции считается файл up, а файл down — только если up нет.
+	if new.Up != "" || new.UpGo != nil || existing.SourceFile == "" {
+		existing.SourceFile = new.SourceFile
+	}
+	// Версия может состоять из SQL- и Go-файла up: сумма учитывает оба.
+	if new.Checksum != "" {
+		if existing.Checksum == "" {
+			existing.Checksum = new.Checksum
+		} else {
+			existing.Checksum = checksum([]byte(existing.Checksum + new.Checksum))
+		}
+	}
 	if new.Up != "" {
 		existing.Up = new.Up
 	}
@@ -358,10 +1320,42 @@ func mergeMigrations(existing, new *storage.Migration) {
 	if new.DownGo != nil {
 		existing.DownGo = new.DownGo
 	}
+	if new.GoProcess {
+		existing.GoProcess = true
+	}
 }
 
-func runGoMigration(filePath, fileName string) error {
-	cmd := exec.Command("go", "run", path.Join(filePath, fileName))
+// goMigrationRunner запускает Go-миграцию из файла; тесты подменяют его,
+// чтобы проверить порядок версий без сборки.
+var goMigrationRunner = runGoMigration
+
+// runGoMigration собирает Go-миграцию и выполняет её отдельным процессом,
+// возвращаясь только после завершения процесса: следующая версия начинается
+// строго после Go-миграции. go run здесь не подходит: при отмене контекста
+// он завершается, не дожидаясь запущенной им программы, и миграция
+// продолжила бы работу параллельно со следующей версией.
+func runGoMigration(ctx context.Context, filePath, fileName string) error {
+	// Без этой проверки exec сообщает лишь «executable file not found».
+	goBinary, err := exec.LookPath("go")
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrGoToolchainMissing, fileName)
+	}
+
+	buildDir, err := os.MkdirTemp("", "gomigration")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(buildDir)
+
+	binary := path.Join(buildDir, strings.TrimSuffix(fileName, ".go"))
+	build := exec.CommandContext(ctx, goBinary, "build", "-o", binary, path.Join(filePath, fileName))
+	build.Stdout = os.Stdout
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		return fmt.Errorf("build %s: %w", fileName, err)
+	}
+
+	cmd := exec.CommandContext(ctx, binary)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -1,15 +1,22 @@
 package app
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/processes"
 	"github.com/Edestus789/sql-migrator/storage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreateMigrationFiles(t *testing.T) {
@@ -84,3 +91,899 @@ func TestUpDownMigration(t *testing.T) {
 	os.Remove(fmt.Sprintf("%s/%05d_%s_up.sql", migrationDir, latestVersion, migrationName))
 	os.Remove(fmt.Sprintf("%s/%05d_%s_down.sql", migrationDir, latestVersion, migrationName))
 }
+
+func TestReadCommandsNeverLock(t *testing.T) {
+	logger := logger.New()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger, mockStorage)
+
+	migration := storage.CreateMigration("create_users", storage.StatusSuccess, 1, time.Now())
+	if err := mockStorage.InsertMigration(context.Background(), migration); err != nil {
+		t.Fatalf("Failed to insert migration: %v", err)
+	}
+
+	assert.NoError(t, app.Status(""))
+	assert.NoError(t, app.DBVersion(""))
+
+	assert.Equal(t, 0, mockStorage.LockCalls(), "Read-only commands must not acquire the lock")
+	assert.Equal(t, 0, mockStorage.UnlockCalls(), "Read-only commands must not release the lock")
+}
+
+func TestMutatingCommandsLock(t *testing.T) {
+	logger := logger.New()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger, mockStorage)
+
+	migrationDir := t.TempDir()
+	assert.NoError(t, app.Create("create_users", migrationDir, "sql"))
+	assert.NoError(t, app.Up(migrationDir))
+
+	assert.Equal(t, 1, mockStorage.LockCalls())
+	assert.Equal(t, 1, mockStorage.UnlockCalls())
+}
+
+func TestCheckLockAcquiresAndReleases(t *testing.T) {
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+
+	assert.NoError(t, app.CheckLock())
+	assert.Equal(t, 1, mockStorage.LockCalls())
+	assert.Equal(t, 1, mockStorage.UnlockCalls())
+	assert.Empty(t, mockStorage.ExecutedSQL())
+}
+
+func TestCheckLockReportsBusyLock(t *testing.T) {
+	mockStorage := storage.NewMockSQLStorage()
+	mockStorage.SetLockBusy(1)
+	app := New(logger.New(), mockStorage)
+
+	assert.ErrorIs(t, app.CheckLock(), storage.ErrLockBusy)
+	assert.Equal(t, 0, mockStorage.LockCalls())
+	assert.Equal(t, 0, mockStorage.UnlockCalls())
+}
+
+func TestRenameMigration(t *testing.T) {
+	logger := logger.New()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger, mockStorage)
+
+	migrationDir := t.TempDir()
+	for _, name := range []string{"00005_add_orders_up.sql", "00005_add_orders_down.sql", "00005_add_orders_up.go"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(migrationDir, name), []byte(""), 0o600))
+	}
+
+	assert.NoError(t, app.Rename(migrationDir, 5, 6))
+
+	for _, name := range []string{"00006_add_orders_up.sql", "00006_add_orders_down.sql", "00006_add_orders_up.go"} {
+		assert.FileExists(t, filepath.Join(migrationDir, name))
+	}
+	assert.NoFileExists(t, filepath.Join(migrationDir, "00005_add_orders_up.sql"))
+}
+
+func TestRenameRefusesAppliedVersion(t *testing.T) {
+	logger := logger.New()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger, mockStorage)
+
+	migrationDir := t.TempDir()
+	upFile := filepath.Join(migrationDir, "00005_add_orders_up.sql")
+	assert.NoError(t, os.WriteFile(upFile, []byte(""), 0o600))
+
+	// Служебная таблица хранит статус по порядковому номеру: версия 5 — первая.
+	applied := storage.CreateMigration("add_orders", storage.StatusSuccess, 1, time.Now())
+	assert.NoError(t, mockStorage.InsertMigration(context.Background(), applied))
+
+	err := app.Rename(migrationDir, 5, 6)
+	assert.ErrorIs(t, err, ErrVersionApplied)
+	assert.FileExists(t, upFile)
+	assert.NoFileExists(t, filepath.Join(migrationDir, "00006_add_orders_up.sql"))
+}
+
+func TestRenameMapsVersionsToPositions(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+
+	migrationDir := t.TempDir()
+	for _, version := range []int{1, 3, 5} {
+		writeMigration(t, migrationDir, version, fmt.Sprintf("step_%d", version), "SELECT 1;", "")
+	}
+	for position := 1; position <= 2; position++ {
+		applied := storage.CreateMigration(fmt.Sprintf("step_%d", position), storage.StatusSuccess, position, time.Now())
+		assert.NoError(t, mockStorage.InsertMigration(ctx, applied))
+	}
+
+	err := app.Rename(migrationDir, 5, 2)
+	assert.ErrorIs(t, err, ErrVersionApplied, "Renaming 5 to 2 would move applied version 3")
+	assert.FileExists(t, filepath.Join(migrationDir, "00005_step_5_up.sql"))
+
+	assert.NoError(t, app.Rename(migrationDir, 5, 4), "Pending version 5 keeps its position")
+	assert.FileExists(t, filepath.Join(migrationDir, "00004_step_5_up.sql"))
+}
+
+func TestRenameChecksEveryShard(t *testing.T) {
+	migrationDir := t.TempDir()
+	upFile := filepath.Join(migrationDir, "00005_add_orders_up.sql")
+	assert.NoError(t, os.WriteFile(upFile, []byte(""), 0o600))
+
+	clean := storage.NewMockSQLStorage()
+	applied := storage.NewMockSQLStorage()
+	migration := storage.CreateMigration("add_orders", storage.StatusSuccess, 1, time.Now())
+	assert.NoError(t, applied.InsertMigration(context.Background(), migration))
+	shards := []Shard{
+		{ID: "shard-1", Open: func(logger.Logger) storage.SQLStorage { return clean }},
+		{ID: "shard-2", Open: func(logger.Logger) storage.SQLStorage { return applied }},
+	}
+
+	app := New(logger.New(), clean)
+	err := app.Rename(migrationDir, 5, 6, shards...)
+	assert.ErrorIs(t, err, ErrVersionApplied)
+	assert.FileExists(t, upFile, "Files are not renamed while any shard has the version applied")
+
+	shards[1].Open = func(logger.Logger) storage.SQLStorage { return storage.NewMockSQLStorage() }
+	assert.NoError(t, app.Rename(migrationDir, 5, 6, shards...))
+	assert.FileExists(t, filepath.Join(migrationDir, "00006_add_orders_up.sql"))
+}
+
+func TestRenamedPositions(t *testing.T) {
+	versions := []int{1, 3, 5, 7}
+	assert.Equal(t, []int{3}, renamedPositions(versions, 5, 6))
+	assert.Equal(t, []int{2, 3}, renamedPositions(versions, 5, 2))
+	assert.Equal(t, []int{1, 2}, renamedPositions(versions, 1, 4))
+	assert.Equal(t, []int{2, 3, 4}, renamedPositions(versions, 3, 9))
+}
+
+func TestRenameRefusesTakenVersion(t *testing.T) {
+	logger := logger.New()
+	app := New(logger, storage.NewMockSQLStorage())
+
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(migrationDir, "00005_add_orders_up.sql"), []byte(""), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(migrationDir, "00006_add_users_up.sql"), []byte(""), 0o600))
+
+	assert.ErrorIs(t, app.Rename(migrationDir, 5, 6), ErrVersionTaken)
+	assert.FileExists(t, filepath.Join(migrationDir, "00005_add_orders_up.sql"))
+}
+
+func TestCreateInReadOnlyMode(t *testing.T) {
+	logger := logger.New()
+	app := New(logger, storage.NewMockSQLStorage())
+	app.ReadOnly = true
+
+	migrationDir := t.TempDir()
+	err := app.Create("create_users", migrationDir, "sql")
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	files, _ := os.ReadDir(migrationDir)
+	assert.Empty(t, files, "Read-only mode must not write anything")
+}
+
+func TestCreateInNonWritableDir(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root ignores directory permissions")
+	}
+
+	logger := logger.New()
+	app := New(logger, storage.NewMockSQLStorage())
+
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.Chmod(migrationDir, 0o555))
+	t.Cleanup(func() { os.Chmod(migrationDir, 0o755) })
+
+	err := app.Create("create_users", migrationDir, "sql")
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
+
+func TestCreateMultiReservesSequentialVersions(t *testing.T) {
+	logger := logger.New()
+	app := New(logger, storage.NewMockSQLStorage())
+
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(migrationDir, "00004_add_orders_up.sql"), []byte(""), 0o600))
+
+	assert.NoError(t, app.CreateMulti("feature_x", migrationDir, 3))
+
+	files, err := os.ReadDir(migrationDir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 7)
+	for i, version := range []int{5, 6, 7} {
+		for _, direction := range []string{"up", "down"} {
+			name := fmt.Sprintf("%05d_feature_x_%d_%s.sql", version, i+1, direction)
+			assert.FileExists(t, filepath.Join(migrationDir, name))
+		}
+	}
+}
+
+func TestCreateMultiRejectsInvalidCount(t *testing.T) {
+	logger := logger.New()
+	app := New(logger, storage.NewMockSQLStorage())
+
+	migrationDir := t.TempDir()
+	assert.ErrorIs(t, app.CreateMulti("feature_x", migrationDir, 0), ErrInvalidCount)
+
+	files, _ := os.ReadDir(migrationDir)
+	assert.Empty(t, files)
+}
+
+func TestGetMigrationsLoadsDeclarativeFiles(t *testing.T) {
+	migrationDir := t.TempDir()
+	spec := "create_table:\n  name: orders\n  columns:\n    - name: id\n      type: serial\n      primary_key: true\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(migrationDir, "00008_add_orders.yaml"), []byte(spec), 0o600))
+
+	migrations, err := getMigrations(DirSource(migrationDir))
+	assert.NoError(t, err)
+	if assert.Contains(t, migrations, 8) {
+		assert.Equal(t, "add_orders", migrations[8].Name)
+		assert.Equal(t, "CREATE TABLE orders (\n\tid SERIAL PRIMARY KEY\n);", migrations[8].Up)
+		assert.Equal(t, "DROP TABLE orders;", migrations[8].Down)
+	}
+}
+
+func TestPendingAndAppliedThroughApp(t *testing.T) {
+	logger := logger.New()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger, mockStorage)
+
+	migrationDir := t.TempDir()
+	assert.NoError(t, app.Create("create_users", migrationDir, "sql"))
+	assert.NoError(t, app.Up(migrationDir))
+	assert.NoError(t, app.Create("create_orders", migrationDir, "sql"))
+
+	pending, err := app.Pending(migrationDir)
+	assert.NoError(t, err)
+	applied, err := app.Applied(migrationDir)
+	assert.NoError(t, err)
+
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, "create_orders", pending[0].Name)
+	}
+	if assert.Len(t, applied, 1) {
+		assert.Equal(t, "create_users", applied[0].Name)
+	}
+}
+
+func TestStatusTemplate(t *testing.T) {
+	logger := logger.New()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger, mockStorage)
+
+	ctx := context.Background()
+	assert.NoError(t, mockStorage.InsertMigration(ctx, storage.CreateMigration("create_orders", storage.StatusCancel, 2, time.Now())))
+	assert.NoError(t, mockStorage.InsertMigration(ctx, storage.CreateMigration("create_users", storage.StatusSuccess, 1, time.Now())))
+
+	var out bytes.Buffer
+	assert.NoError(t, app.StatusTemplate("{{range .}}{{.Version}} {{.Name}} {{.Status}}\n{{end}}", &out))
+	assert.Equal(t, "1 create_users success\n2 create_orders cancel\n", out.String())
+}
+
+func TestStatusCSVEscapesFields(t *testing.T) {
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+
+	ctx := context.Background()
+	changed := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	orders := storage.CreateMigration(`orders, "legacy"`, storage.StatusError, 2, changed)
+	users := storage.CreateMigration("create_users", storage.StatusSuccess, 1, changed)
+	users.SetChecksum("abc123")
+	require.NoError(t, mockStorage.InsertMigration(ctx, orders))
+	require.NoError(t, mockStorage.InsertMigration(ctx, users))
+
+	var out bytes.Buffer
+	cmd, ok := LookupCommand("status")
+	require.True(t, ok)
+	require.NoError(t, cmd.Run(app, CommandArgs{Format: StatusFormatCSV, Out: &out}))
+	assert.Equal(t, "version,name,status,status_change_time,checksum\n"+
+		"1,create_users,success,2024-03-01T12:30:00Z,abc123\n"+
+		"2,\"orders, \"\"legacy\"\"\",error,2024-03-01T12:30:00Z,\n", out.String())
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, `orders, "legacy"`, rows[2][1])
+}
+
+func TestParseStatusFormat(t *testing.T) {
+	format, err := ParseStatusFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, StatusFormatTable, format)
+
+	format, err = ParseStatusFormat("csv")
+	require.NoError(t, err)
+	assert.Equal(t, StatusFormatCSV, format)
+
+	_, err = ParseStatusFormat("xml")
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestStatusTemplateValidatedBeforeQuery(t *testing.T) {
+	logger := logger.New()
+	app := New(logger, storage.NewMockSQLStorage())
+
+	var out bytes.Buffer
+	err := app.StatusTemplate("{{range .}}{{.Version}", &out)
+	assert.ErrorIs(t, err, ErrInvalidTemplate)
+	assert.Empty(t, out.String())
+}
+
+// infoRecorder сохраняет информационные сообщения, чтобы тест мог их проверить.
+type infoRecorder struct {
+	*logger.ZeroLogger
+	infos []string
+}
+
+func (l *infoRecorder) Info(msg string, v ...interface{}) {
+	l.infos = append(l.infos, fmt.Sprintf(msg, v...))
+}
+
+func TestEmptyMigrationsDirectory(t *testing.T) {
+	migrationDir := t.TempDir()
+
+	for _, command := range []string{"up", "down", "status"} {
+		recorder := &infoRecorder{ZeroLogger: logger.New()}
+		mockStorage := storage.NewMockSQLStorage()
+		app := New(recorder, mockStorage)
+
+		cmd, ok := LookupCommand(command)
+		assert.True(t, ok)
+
+		err := cmd.Run(app, CommandArgs{Path: migrationDir})
+		assert.ErrorIs(t, err, ErrNoMigrations, command)
+		assert.Contains(t, recorder.infos, "No migration files found in "+migrationDir, command)
+		assert.Equal(t, 0, mockStorage.LockCalls(), command)
+	}
+}
+
+func TestPersistentLockWaitsForExpiredHolder(t *testing.T) {
+	logger := logger.New()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger, mockStorage)
+
+	persistentLockPoll = 10 * time.Millisecond
+	t.Cleanup(func() { persistentLockPoll = time.Second })
+
+	// Владелец упал, не сняв блокировку: она освободится по TTL.
+	ctx := context.Background()
+	assert.NoError(t, mockStorage.AcquirePersistentLock(ctx, "deploy", "crashed:1", 50*time.Millisecond))
+
+	start := time.Now()
+	assert.NoError(t, app.Lock("deploy", time.Minute))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	err := mockStorage.AcquirePersistentLock(ctx, "deploy", "other:2", time.Minute)
+	assert.ErrorIs(t, err, storage.ErrLockHeld)
+
+	assert.NoError(t, app.Unlock("deploy"))
+	assert.NoError(t, mockStorage.AcquirePersistentLock(ctx, "deploy", "other:2", time.Minute))
+}
+
+func TestPersistentLockReacquireBySameOwner(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := storage.NewMockSQLStorage()
+
+	assert.NoError(t, mockStorage.AcquirePersistentLock(ctx, "deploy", "ci:1", 0))
+	assert.NoError(t, mockStorage.AcquirePersistentLock(ctx, "deploy", "ci:1", time.Minute))
+
+	err := mockStorage.AcquirePersistentLock(ctx, "deploy", "ci:2", time.Minute)
+	assert.ErrorIs(t, err, storage.ErrLockHeld)
+	assert.Contains(t, err.Error(), "deploy held by ci:1")
+}
+
+func TestUpRecordsSourceFile(t *testing.T) {
+	logger := logger.New()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger, mockStorage)
+
+	migrationDir := t.TempDir()
+	// Файл down читается первым, но источником считается файл up.
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	assert.NoError(t, app.Up(migrationDir))
+
+	migrations, err := mockStorage.SelectMigrations(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, migrations, 1) {
+		assert.Equal(t, filepath.Join(migrationDir, "00001_create_users_up.sql"), migrations[0].GetSourceFile())
+	}
+}
+
+func TestEventsSinceVersion(t *testing.T) {
+	logger := logger.New()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger, mockStorage)
+
+	ctx := context.Background()
+	changed := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	assert.NoError(t, mockStorage.InsertMigration(ctx, storage.CreateMigration("create_items", storage.StatusError, 3, changed)))
+	assert.NoError(t, mockStorage.InsertMigration(ctx, storage.CreateMigration("create_users", storage.StatusSuccess, 1, changed)))
+	assert.NoError(t, mockStorage.InsertMigration(ctx, storage.CreateMigration("create_orders", storage.StatusSuccess, 2, changed)))
+
+	var out bytes.Buffer
+	assert.NoError(t, app.Events(1, &out))
+	assert.Equal(t,
+		`{"version":2,"name":"create_orders","status":"success","timestamp":"2024-05-01T12:30:00Z"}`+"\n"+
+			`{"version":3,"name":"create_items","status":"error","timestamp":"2024-05-01T12:30:00Z"}`+"\n",
+		out.String())
+
+	out.Reset()
+	assert.NoError(t, app.Events(3, &out))
+	assert.Empty(t, out.String())
+}
+
+func TestDBVersionReportsPending(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;")
+	writeMigration(t, migrationDir, 3, "create_items", "CREATE TABLE items (id serial);", "DROP TABLE items;")
+
+	recorder := &infoRecorder{ZeroLogger: logger.New()}
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(recorder, mockStorage)
+
+	ctx := context.Background()
+	assert.NoError(t, mockStorage.InsertMigration(ctx, storage.CreateMigration("create_users", storage.StatusSuccess, 1, time.Now())))
+
+	assert.NoError(t, app.DBVersion(migrationDir))
+	assert.Contains(t, recorder.infos, "Применённая версия: 1, последняя на диске: 3, ожидают применения: 2")
+
+	app.Options.PlainVersion = true
+	assert.NoError(t, app.DBVersion(migrationDir))
+	assert.Contains(t, recorder.infos, "Версия: 1")
+}
+
+func TestDBVersionCountsGapsBelowCurrentVersion(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;")
+	writeMigration(t, migrationDir, 3, "create_items", "CREATE TABLE items (id serial);", "DROP TABLE items;")
+
+	recorder := &infoRecorder{ZeroLogger: logger.New()}
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(recorder, mockStorage)
+
+	ctx := context.Background()
+	assert.NoError(t, mockStorage.InsertMigration(ctx, storage.CreateMigration("create_users", storage.StatusSuccess, 1, time.Now())))
+	assert.NoError(t, mockStorage.InsertMigration(ctx, storage.CreateMigration("create_orders", storage.StatusError, 2, time.Now())))
+	assert.NoError(t, mockStorage.InsertMigration(ctx, storage.CreateMigration("create_items", storage.StatusSuccess, 3, time.Now())))
+
+	assert.NoError(t, app.DBVersion(migrationDir))
+	assert.Contains(t, recorder.infos, "Применённая версия: 3, последняя на диске: 3, ожидают применения: 1")
+}
+
+func TestDBVersionUpToDate(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+
+	recorder := &infoRecorder{ZeroLogger: logger.New()}
+	app := New(recorder, storage.NewMockSQLStorage())
+
+	assert.NoError(t, app.Up(migrationDir))
+	assert.NoError(t, app.DBVersion(migrationDir))
+	assert.Contains(t, recorder.infos, "Применённая версия: 1, последняя на диске: 1, ожидают применения: 0")
+}
+
+func TestLoadStripsBOM(t *testing.T) {
+	migrationDir := t.TempDir()
+	upFile := filepath.Join(migrationDir, "00001_create_users_up.sql")
+	assert.NoError(t, os.WriteFile(upFile, append([]byte{0xEF, 0xBB, 0xBF}, "CREATE TABLE users (id serial);"...), 0o600))
+
+	migrations, err := getMigrations(DirSource(migrationDir))
+	assert.NoError(t, err)
+	if assert.Contains(t, migrations, 1) {
+		assert.Equal(t, "CREATE TABLE users (id serial);", migrations[1].Up)
+	}
+}
+
+func TestLoadRejectsInvalidUTF8(t *testing.T) {
+	migrationDir := t.TempDir()
+	upFile := filepath.Join(migrationDir, "00001_create_users_up.sql")
+	assert.NoError(t, os.WriteFile(upFile, []byte("CREATE TABLE caf\xe9 (id serial);"), 0o600))
+
+	_, err := getMigrations(DirSource(migrationDir))
+	assert.ErrorIs(t, err, ErrInvalidEncoding)
+	assert.Contains(t, err.Error(), upFile)
+	assert.Contains(t, err.Error(), "invalid byte 0xE9 at offset 16")
+}
+
+func TestLoadRejectsMalformedDelimiterDirective(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "-- migrator:delimiter / /\nCREATE TABLE users (id serial)\n/\n", "DROP TABLE users;")
+
+	_, err := getMigrations(DirSource(migrationDir))
+	assert.ErrorIs(t, err, processes.ErrInvalidDelimiter)
+	assert.Contains(t, err.Error(), filepath.Join(migrationDir, "00001_create_users_up.sql"))
+}
+
+func TestVerifyFlagsEditedGoMigration(t *testing.T) {
+	logger := logger.New()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger, mockStorage)
+
+	migrationDir := t.TempDir()
+	goFile := filepath.Join(migrationDir, "00001_seed_users_up.go")
+	assert.NoError(t, os.WriteFile(goFile, []byte("package main\n\nfunc main() {}\n"), 0o600))
+
+	migrations, err := getMigrations(DirSource(migrationDir))
+	assert.NoError(t, err)
+	applied := storage.CreateMigration("seed_users", storage.StatusSuccess, 1, time.Now())
+	applied.SetChecksum(migrations[1].Checksum)
+	assert.NoError(t, mockStorage.InsertMigration(context.Background(), applied))
+
+	assert.NoError(t, app.Verify(migrationDir))
+
+	assert.NoError(t, os.WriteFile(goFile, []byte("package main\n\nfunc main() { println(1) }\n"), 0o600))
+	err = app.Verify(migrationDir)
+	assert.ErrorIs(t, err, processes.ErrChecksumMismatch)
+	assert.NoError(t, app.Status(migrationDir), "Status only warns about edited migrations")
+}
+
+func TestChecksumCoversSQLAndGoUpFiles(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+
+	sqlOnly, err := getMigrations(DirSource(migrationDir))
+	assert.NoError(t, err)
+
+	goFile := filepath.Join(migrationDir, "00001_create_users_up.go")
+	assert.NoError(t, os.WriteFile(goFile, []byte("package main\n\nfunc main() {}\n"), 0o600))
+	combined, err := getMigrations(DirSource(migrationDir))
+	assert.NoError(t, err)
+
+	assert.Len(t, sqlOnly[1].Checksum, 64)
+	assert.NotEqual(t, sqlOnly[1].Checksum, combined[1].Checksum)
+	assert.False(t, sqlOnly[1].GoProcess)
+	assert.True(t, combined[1].GoProcess, "Go files run as a separate process after the SQL step commits")
+}
+
+func TestGoMigrationWithoutToolchain(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	err := runGoMigration(context.Background(), t.TempDir(), "00001_seed_users_up.go")
+	assert.ErrorIs(t, err, ErrGoToolchainMissing)
+	assert.Contains(t, err.Error(), "install Go or use the registry mode")
+}
+
+// orderStorage записывает выполненный SQL в общий с Go-миграциями журнал.
+// Если задан marker, перед SQL записывается, создан ли уже этот файл.
+type orderStorage struct {
+	*storage.MockSQLStorage
+	log    *[]string
+	marker string
+}
+
+func (s orderStorage) Migrate(ctx context.Context, sql string) (int64, error) {
+	if s.marker != "" {
+		_, err := os.Stat(s.marker)
+		*s.log = append(*s.log, fmt.Sprintf("marker present: %t", err == nil))
+	}
+	*s.log = append(*s.log, "sql "+sql)
+	return s.MockSQLStorage.Migrate(ctx, sql)
+}
+
+func TestInterleavedGoAndSQLMigrationsRunInVersionOrder(t *testing.T) {
+	dir := t.TempDir()
+	var log []string
+	for version := 1; version <= 5; version++ {
+		if version%2 == 0 {
+			goFile := filepath.Join(dir, fmt.Sprintf("%05d_seed_%d_up.go", version, version))
+			require.NoError(t, os.WriteFile(goFile, []byte("package main\n\nfunc main() {}\n"), 0o600))
+			continue
+		}
+		writeMigration(t, dir, version, fmt.Sprintf("step_%d", version), fmt.Sprintf("SELECT %d;", version), "")
+	}
+
+	original := goMigrationRunner
+	defer func() { goMigrationRunner = original }()
+	goMigrationRunner = func(_ context.Context, _, fileName string) error {
+		// Медленная Go-миграция не должна пересекаться со следующей версией.
+		time.Sleep(10 * time.Millisecond)
+		log = append(log, "go "+fileName)
+		return nil
+	}
+
+	app := New(logger.New(), orderStorage{MockSQLStorage: storage.NewMockSQLStorage(), log: &log})
+	require.NoError(t, app.Up(dir))
+	assert.Equal(t, []string{
+		"sql SELECT 1;",
+		"go 00002_seed_2_up.go",
+		"sql SELECT 3;",
+		"go 00004_seed_4_up.go",
+		"sql SELECT 5;",
+	}, log)
+}
+
+func TestGoMigrationSubprocessFinishesBeforeNextVersion(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("Go toolchain is not available")
+	}
+	// Файл миграции собирается вне модуля.
+	t.Setenv("GOFLAGS", "")
+
+	dir := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "go_migration_done")
+	source := fmt.Sprintf(`package main
+
+import (
+	"os"
+	"time"
+)
+
+func main() {
+	time.Sleep(200 * time.Millisecond)
+	if err := os.WriteFile(%q, nil, 0o600); err != nil {
+		panic(err)
+	}
+}
+`, marker)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00001_seed_users_up.go"), []byte(source), 0o600))
+	writeMigration(t, dir, 2, "create_orders", "CREATE TABLE orders (id INT);", "")
+
+	var log []string
+	st := orderStorage{MockSQLStorage: storage.NewMockSQLStorage(), log: &log, marker: marker}
+	require.NoError(t, New(logger.New(), st).Up(dir))
+
+	assert.Equal(t, []string{"marker present: true", "sql CREATE TABLE orders (id INT);"}, log,
+		"Go migration must finish before the next version starts")
+}
+
+func TestOnlySQLSkipsGoMigrations(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	logger := logger.New()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger, mockStorage)
+	app.Options.OnlySQL = true
+
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	goFile := filepath.Join(migrationDir, "00002_seed_users_up.go")
+	assert.NoError(t, os.WriteFile(goFile, []byte("package main\n\nfunc main() {}\n"), 0o600))
+	writeMigration(t, migrationDir, 3, "create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;")
+
+	assert.NoError(t, app.Up(migrationDir))
+	assert.Equal(t, []string{"CREATE TABLE users (id serial);", "CREATE TABLE orders (id serial);"}, mockStorage.ExecutedSQL())
+
+	statuses, err := mockStorage.SelectAppliedVersions(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess, 3: storage.StatusSuccess}, statuses,
+		"The Go migration is left pending instead of being recorded as skipped")
+
+	pending, err := app.Pending(migrationDir)
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, 2, pending[0].Version)
+	}
+}
+
+func TestReportDriftAgainstFixture(t *testing.T) {
+	logger := logger.New()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger, mockStorage)
+
+	// Вручную расширили email, добавили колонку и удалили таблицу orders.
+	mockStorage.SetSchemaSnapshot(storage.SchemaSnapshot{Tables: map[string]map[string]string{
+		"users": {"id": "integer NOT NULL", "email": "text NOT NULL", "nickname": "text"},
+	}})
+
+	var out bytes.Buffer
+	err := app.ReportDrift(filepath.Join("testdata", "expected_schema.json"), &out)
+	assert.ErrorIs(t, err, processes.ErrSchemaDrift)
+	assert.Equal(t, "removed table orders\n"+
+		"changed column users.email: character varying(255) NOT NULL -> text NOT NULL\n"+
+		"added column users.nickname: text\n", out.String())
+}
+
+func TestSchemaDumpRoundTrip(t *testing.T) {
+	logger := logger.New()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger, mockStorage)
+
+	expected, err := os.ReadFile(filepath.Join("testdata", "expected_schema.json"))
+	assert.NoError(t, err)
+	var snapshot storage.SchemaSnapshot
+	assert.NoError(t, json.Unmarshal(expected, &snapshot))
+	mockStorage.SetSchemaSnapshot(snapshot)
+
+	var out bytes.Buffer
+	assert.NoError(t, app.SchemaDump(&out))
+	assert.JSONEq(t, string(expected), out.String())
+
+	dump := filepath.Join(t.TempDir(), "schema.json")
+	assert.NoError(t, os.WriteFile(dump, out.Bytes(), 0o600))
+	out.Reset()
+	assert.NoError(t, app.ReportDrift(dump, &out))
+	assert.Empty(t, out.String())
+}
+
+func TestPlanAndApply(t *testing.T) {
+	logger := logger.New()
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger, mockStorage)
+
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;")
+
+	var out bytes.Buffer
+	assert.NoError(t, app.Plan(migrationDir, &out))
+	var plan processes.Plan
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &plan))
+	if assert.Len(t, plan.Migrations, 2) {
+		assert.Equal(t, "create_orders", plan.Migrations[1].Name)
+		assert.Len(t, plan.Migrations[1].Checksum, 64)
+	}
+
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	assert.NoError(t, os.WriteFile(planFile, out.Bytes(), 0o600))
+
+	// Файл изменили после утверждения плана: apply отказывается.
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id bigserial);", "DROP TABLE orders;")
+	assert.ErrorIs(t, app.Apply(migrationDir, planFile), processes.ErrPlanMismatch)
+	assert.Empty(t, mockStorage.ExecutedSQL())
+
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;")
+	assert.NoError(t, app.Apply(migrationDir, planFile))
+	assert.Equal(t, []string{"CREATE TABLE users (id serial);", "CREATE TABLE orders (id serial);"}, mockStorage.ExecutedSQL())
+}
+
+func TestUpManifestSkipsMigrationsAppliedElsewhere(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;")
+
+	// Окружение-источник применило версии 1 и 2 и выгрузило манифест.
+	source := storage.NewMockSQLStorage()
+	assert.NoError(t, New(logger.New(), source).Up(migrationDir))
+	var out bytes.Buffer
+	assert.NoError(t, New(logger.New(), source).ExportApplied(&out))
+	var exported processes.Plan
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &exported))
+	if assert.Len(t, exported.Migrations, 2) {
+		assert.Equal(t, "create_users", exported.Migrations[0].Name)
+		assert.Len(t, exported.Migrations[0].Checksum, 64)
+	}
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+	assert.NoError(t, os.WriteFile(manifest, out.Bytes(), 0o600))
+
+	// Версия 2 изменилась после выгрузки манифеста, версия 3 в нём отсутствует.
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id bigserial);", "DROP TABLE orders;")
+	writeMigration(t, migrationDir, 3, "create_items", "CREATE TABLE items (id serial);", "DROP TABLE items;")
+
+	target := storage.NewMockSQLStorage()
+	app := New(logger.New(), target)
+	assert.NoError(t, app.UpManifest(migrationDir, manifest))
+	assert.Equal(t, []string{"CREATE TABLE orders (id bigserial);", "CREATE TABLE items (id serial);"}, target.ExecutedSQL())
+
+	statuses, err := target.SelectAppliedVersions(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]string{1: storage.StatusSkipped, 2: storage.StatusSuccess, 3: storage.StatusSuccess}, statuses,
+		"The excluded version is recorded, not silently left below applied ones")
+
+	// Пропущенная версия применяется позже явно, через -apply-skipped.
+	app.Options.ApplySkipped = true
+	assert.NoError(t, app.Up(migrationDir))
+	assert.Equal(t, "CREATE TABLE users (id serial);", target.ExecutedSQL()[2])
+}
+
+func TestUpManifestMissingFile(t *testing.T) {
+	app := New(logger.New(), storage.NewMockSQLStorage())
+	err := app.UpManifest(t.TempDir(), filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestNukeRequiresForce(t *testing.T) {
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	assert.NoError(t, app.Up(migrationDir))
+
+	assert.ErrorIs(t, app.Nuke(migrationDir, false), ErrConfirmationRequired)
+	assert.False(t, mockStorage.TrackingTableDropped())
+
+	assert.NoError(t, app.Nuke(migrationDir, true))
+	assert.True(t, mockStorage.TrackingTableDropped())
+	assert.Equal(t, []string{"CREATE TABLE users (id serial);", "DROP TABLE users;"}, mockStorage.ExecutedSQL())
+}
+
+func TestCurrentVersion(t *testing.T) {
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+
+	version, err := app.CurrentVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	ctx := context.Background()
+	assert.NoError(t, mockStorage.InsertMigration(ctx, storage.CreateMigration("create_users", storage.StatusSuccess, 1, time.Now())))
+	assert.NoError(t, mockStorage.InsertMigration(ctx, storage.CreateMigration("create_orders", storage.StatusSuccess, 2, time.Now())))
+	assert.NoError(t, mockStorage.InsertMigration(ctx, storage.CreateMigration("create_items", storage.StatusError, 3, time.Now())))
+
+	version, err = app.CurrentVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+}
+
+func TestUpAndDownReportNoOp(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+	app.ReportNoOp = true
+
+	require.NoError(t, app.Up(migrationDir))
+	assert.ErrorIs(t, app.Up(migrationDir), ErrNoOp)
+
+	require.NoError(t, app.Down(migrationDir))
+	assert.ErrorIs(t, app.Down(migrationDir), ErrNoOp)
+	assert.Equal(t, []string{"CREATE TABLE users (id serial);", "DROP TABLE users;"}, mockStorage.ExecutedSQL())
+}
+
+func TestNoOpIsSuccessByDefault(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	app := New(logger.New(), storage.NewMockSQLStorage())
+
+	require.NoError(t, app.Up(migrationDir))
+	assert.NoError(t, app.Up(migrationDir))
+}
+
+func TestRedoWritesSummary(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	writeMigration(t, migrationDir, 2, "add_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;")
+	app := New(logger.New(), storage.NewMockSQLStorage())
+	require.NoError(t, app.Up(migrationDir))
+
+	var out bytes.Buffer
+	require.NoError(t, app.Redo(migrationDir, &out))
+	assert.Equal(t, "redid version 2: add_orders\n", out.String())
+
+	out.Reset()
+	var stdout, stderr bytes.Buffer
+	app.Reporter = NewReporter(OutputJSON, &stdout, &stderr)
+	require.NoError(t, app.RedoNamed(migrationDir, "create_users", &out))
+	assert.Empty(t, out.String(), "With -output json the summary goes to the outcome object")
+
+	app.Reporter.Finish("redo", 0, nil)
+	assert.Equal(t, map[string]interface{}{
+		"command":   "redo",
+		"status":    "success",
+		"exit_code": float64(0),
+		"redone":    []interface{}{map[string]interface{}{"version": float64(1), "name": "create_users"}},
+	}, decodeOutcome(t, &stdout))
+}
+
+func TestDumpAppliedWritesRecordedSuccessfulMigrationsInOrder(t *testing.T) {
+	mockStorage := storage.NewMockSQLStorage()
+	for _, migration := range []*storage.Migration{
+		{Version: 3, Name: "create_items", Status: storage.StatusSuccess, Up: "CREATE TABLE items (id serial);"},
+		{Version: 1, Name: "create_users", Status: storage.StatusSuccess, Up: "CREATE TABLE users (id serial);\n"},
+		{Version: 2, Name: "create_orders", Status: storage.StatusCancel, Up: "CREATE TABLE orders (id serial);"},
+		{Version: 4, Name: "broken", Status: storage.StatusError, Up: "CREATE TABL broken;"},
+		{Version: 5, Name: "seed_users", Status: storage.StatusSuccess},
+	} {
+		require.NoError(t, mockStorage.InsertMigration(context.Background(), migration))
+	}
+	app := New(logger.New(), mockStorage)
+
+	var out bytes.Buffer
+	require.NoError(t, app.DumpApplied(&out))
+	assert.Equal(t, "-- Version 1: create_users\nCREATE TABLE users (id serial);\n\n"+
+		"-- Version 3: create_items\nCREATE TABLE items (id serial);\n\n"+
+		"-- Version 5: seed_users (up SQL not recorded)\n\n", out.String())
+}
+
+func TestDumpAppliedUsesSQLRecordedByUp(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;")
+	app := New(logger.New(), storage.NewMockSQLStorage())
+	require.NoError(t, app.Up(migrationDir))
+
+	// Файл изменён после применения: выгружается то, что выполнялось.
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id bigserial);", "DROP TABLE orders;")
+
+	var out bytes.Buffer
+	require.NoError(t, app.DumpApplied(&out))
+	assert.Equal(t, "-- Version 1: create_users\nCREATE TABLE users (id serial);\n\n"+
+		"-- Version 2: create_orders\nCREATE TABLE orders (id serial);\n\n", out.String())
+}
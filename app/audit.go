@@ -0,0 +1,162 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/processes"
+)
+
+// auditMu упорядочивает дописывание журнала аудита при параллельном запуске
+// на нескольких базах.
+var auditMu sync.Mutex
+
+// auditRecord — запись журнала аудита об одном успешном запуске.
+type auditRecord struct {
+	Time       time.Time        `json:"time"`
+	Command    string           `json:"command"`
+	Operator   string           `json:"operator"`
+	Host       string           `json:"host"`
+	Migrations []auditMigration `json:"migrations"`
+}
+
+type auditMigration struct {
+	Version    int    `json:"version"`
+	Name       string `json:"name"`
+	Checksum   string `json:"checksum"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// storePlan дописывает в журнал аудита StorePlan одну строку JSON
+// с миграциями, применёнными командой. Журнал только дополняется.
+func (app *Application) storePlan(command string, result processes.Result) error {
+	if app.StorePlan == "" {
+		return nil
+	}
+
+	record := auditRecord{
+		Time:       time.Now().UTC(),
+		Command:    command,
+		Operator:   operator(),
+		Host:       hostname(),
+		Migrations: make([]auditMigration, 0, len(result.Applied)),
+	}
+	for _, applied := range result.Applied {
+		record.Migrations = append(record.Migrations, auditMigration{
+			Version:    applied.Version,
+			Name:       applied.Name,
+			Checksum:   applied.Checksum,
+			DurationMS: applied.Duration.Milliseconds(),
+		})
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	file, err := os.OpenFile(app.StorePlan, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		app.logger.Error("Failed to open audit log %s: %v", app.StorePlan, err)
+		return err
+	}
+	if err := json.NewEncoder(file).Encode(record); err != nil {
+		file.Close()
+		app.logger.Error("Failed to write audit log %s: %v", app.StorePlan, err)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		app.logger.Error("Failed to write audit log %s: %v", app.StorePlan, err)
+		return err
+	}
+
+	app.logger.Info("Appended %d applied migrations to audit log %s", len(record.Migrations), app.StorePlan)
+	return nil
+}
+
+// operator возвращает имя пользователя, запустившего мигратор.
+func operator() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+func hostname() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return host
+}
+
+// compactAudit удаляет из журнала аудита StorePlan записи старше cutoff.
+// Строки, которые не удалось разобрать, сохраняются: журнал не должен
+// терять данные из-за записи в неизвестном формате. Журнал переписывается
+// через временный файл и переименование, поэтому сбой не оставит его
+// обрезанным.
+func (app *Application) compactAudit(cutoff time.Time) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	data, err := os.ReadFile(app.StorePlan)
+	if errors.Is(err, os.ErrNotExist) {
+		app.logger.Info("Audit log %s does not exist, nothing to compact", app.StorePlan)
+		return nil
+	}
+	if err != nil {
+		app.logger.Error("Failed to read audit log %s: %v", app.StorePlan, err)
+		return err
+	}
+
+	var kept bytes.Buffer
+	removed := 0
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var record auditRecord
+		if err := json.Unmarshal(line, &record); err == nil && record.Time.Before(cutoff) {
+			removed++
+			continue
+		}
+		kept.Write(line)
+		if !bytes.HasSuffix(line, []byte("\n")) {
+			kept.WriteByte('\n')
+		}
+	}
+	if removed == 0 {
+		app.logger.Info("No audit log records older than %s", cutoff.Format(time.RFC3339))
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(app.StorePlan), ".audit-*")
+	if err != nil {
+		app.logger.Error("Failed to rewrite audit log %s: %v", app.StorePlan, err)
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(kept.Bytes()); err != nil {
+		tmp.Close()
+		app.logger.Error("Failed to rewrite audit log %s: %v", app.StorePlan, err)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		app.logger.Error("Failed to rewrite audit log %s: %v", app.StorePlan, err)
+		return err
+	}
+	if err := os.Rename(tmp.Name(), app.StorePlan); err != nil {
+		app.logger.Error("Failed to rewrite audit log %s: %v", app.StorePlan, err)
+		return err
+	}
+
+	app.logger.Info("Removed %d audit log records older than %s from %s", removed, cutoff.Format(time.RFC3339), app.StorePlan)
+	return nil
+}
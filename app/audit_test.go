@@ -0,0 +1,67 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAuditLog(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func TestUpAppendsAuditRecord(t *testing.T) {
+	app := New(logger.New(), storage.NewMockSQLStorage())
+	app.StorePlan = filepath.Join(t.TempDir(), "audit.jsonl")
+
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;")
+	require.NoError(t, app.Up(migrationDir))
+
+	writeMigration(t, migrationDir, 3, "create_items", "CREATE TABLE items (id serial);", "DROP TABLE items;")
+	require.NoError(t, app.Up(migrationDir))
+
+	records := readAuditLog(t, app.StorePlan)
+	require.Len(t, records, 2)
+
+	first := records[0]
+	assert.Equal(t, "up", first["command"])
+	assert.NotEmpty(t, first["operator"])
+	assert.NotEmpty(t, first["host"])
+	assert.NotEmpty(t, first["time"])
+
+	migrations := first["migrations"].([]interface{})
+	require.Len(t, migrations, 2)
+	users := migrations[0].(map[string]interface{})
+	assert.Equal(t, float64(1), users["version"])
+	assert.Equal(t, "create_users", users["name"])
+	assert.Len(t, users["checksum"], 64)
+	assert.Contains(t, users, "duration_ms")
+	assert.Equal(t, "create_orders", migrations[1].(map[string]interface{})["name"])
+
+	second := records[1]["migrations"].([]interface{})
+	require.Len(t, second, 1)
+	assert.Equal(t, "create_items", second[0].(map[string]interface{})["name"])
+}
@@ -0,0 +1,412 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+// CommandArgs содержит аргументы командной строки, которые используют команды.
+type CommandArgs struct {
+	Path    string
+	Name    string
+	Version int
+	// From и To — границы диапазона версий для up; To также новая версия для rename.
+	From int
+	To   int
+	// Count — сколько версий резервирует create-multi.
+	Count int
+	// Force подтверждает разрушительные команды, например nuke.
+	Force bool
+	// Template — пользовательский шаблон text/template для вывода статуса.
+	Template string
+	// Format — формат вывода статуса: StatusFormatTable или StatusFormatCSV.
+	Format string
+	// Label — имя тега релиза для команды tag.
+	Label string
+	// Since — версия, после которой выводятся события.
+	Since int
+	// Retention — сколько хранить записи журнала аудита при compact.
+	Retention time.Duration
+	// LockKey и LockTTL — ключ и срок действия постоянной блокировки.
+	LockKey string
+	LockTTL time.Duration
+	// Plan — файл плана, созданного командой plan, для команды apply.
+	Plan string
+	// AppliedManifest — план другого окружения; up пропускает перечисленные в нём миграции.
+	AppliedManifest string
+	// Expected — файл со снимком ожидаемой схемы для report-drift.
+	Expected string
+	// Golden — эталонный снимок схемы для schema-compare.
+	Golden string
+	// Scratch — одноразовая база, к которой schema-compare применяет миграции.
+	Scratch storage.SQLStorage
+	// TestDatabase — подключения для временной копии базы test-migrate.
+	TestDatabase TestDatabase
+	// Shards — все базы многобазового запуска команды с AllShards; при одной
+	// базе пуст.
+	Shards []Shard
+	// Out — куда выводится результат команд с пользовательским форматом.
+	Out io.Writer
+}
+
+// Command описывает команду CLI: её обработчик, описание и принимаемые флаги.
+type Command struct {
+	Name        string
+	Description string
+	// Flags — флаги, которые влияют на команду, помимо общих -config, -dsn и -table.
+	Flags []string
+	// FilesOnly означает, что команда работает только с файлами миграций
+	// и выполняется один раз даже при нескольких базах данных.
+	FilesOnly bool
+	// AllShards означает, что команда меняет файлы миграций и потому тоже
+	// выполняется один раз, но сама обращается ко всем базам через
+	// CommandArgs.Shards, например rename проверяет статус версии в каждой.
+	AllShards bool
+	// PathOptional означает, что команда принимает -path, но работает
+	// и без директории миграций, например status только по базе данных.
+	PathOptional bool
+	// Standalone означает, что команде не нужны ни база данных, ни файлы
+	// миграций: она выполняется сразу после разбора флагов, а app равен nil.
+	Standalone bool
+	Run        func(app *Application, args CommandArgs) error
+}
+
+// RequiresPath сообщает, нужна ли команде директория миграций.
+func (cmd Command) RequiresPath() bool {
+	if cmd.PathOptional {
+		return false
+	}
+	for _, flag := range cmd.Flags {
+		if flag == "path" {
+			return true
+		}
+	}
+	return false
+}
+
+var commands = map[string]Command{}
+
+// RegisterCommand добавляет команду в реестр. Повторная регистрация имени
+// считается ошибкой программиста и приводит к панике.
+func RegisterCommand(cmd Command) {
+	if _, ok := commands[cmd.Name]; ok {
+		panic("app: command " + cmd.Name + " registered twice")
+	}
+	commands[cmd.Name] = cmd
+}
+
+// LookupCommand возвращает зарегистрированную команду по имени.
+func LookupCommand(name string) (Command, bool) {
+	cmd, ok := commands[name]
+	return cmd, ok
+}
+
+// Commands возвращает все зарегистрированные команды, отсортированные по имени.
+func Commands() []Command {
+	list := make([]Command, 0, len(commands))
+	for _, cmd := range commands {
+		list = append(list, cmd)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// CommandNames возвращает имена зарегистрированных команд через запятую.
+func CommandNames() string {
+	names := make([]string, 0, len(commands))
+	for _, cmd := range Commands() {
+		names = append(names, cmd.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// WriteDriverList выводит зарегистрированные драйверы баз данных
+// с описанием и возможностями, по одному на строку.
+func WriteDriverList(w io.Writer) error {
+	for _, driver := range storage.Drivers() {
+		if _, err := fmt.Fprintf(w, "%-10s %s (%s)\n", driver.Name, driver.Description, driver.Capabilities); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCommandHelp выводит список команд с описаниями и принимаемыми флагами.
+func WriteCommandHelp(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "Commands:"); err != nil {
+		return err
+	}
+	for _, cmd := range Commands() {
+		if _, err := fmt.Fprintf(w, "  %-10s %s\n", cmd.Name, cmd.Description); err != nil {
+			return err
+		}
+		if len(cmd.Flags) == 0 {
+			continue
+		}
+
+		flags := make([]string, 0, len(cmd.Flags))
+		for _, flag := range cmd.Flags {
+			flags = append(flags, "-"+flag)
+		}
+		if _, err := fmt.Fprintf(w, "  %-10s flags: %s\n", "", strings.Join(flags, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var upFlags = []string{"path", "from", "to", "allow-missing", "applied-manifest", "from-git", "run-as", "check-perms", "heartbeat-interval", "statement-timeout", "pre-lock-statement", "deadlock-retries", "delimiter", "max-parallel-statements", "skip", "apply-skipped", "on-dirty", "only-sql", "post-up-analyze", "post-up-vacuum", "gate", "lock-scope", "abort-lock-wait", "lock-wait", "lock-retry-interval", "lock-jitter", "interactive", "store-plan", "exit-code-on-noop", "diagnose-lock", "notify-url", "notify-on", "notify-timeout"}
+
+func init() {
+	RegisterCommand(Command{
+		Name:        "create",
+		Description: "Create empty up and down SQL files for a new migration",
+		Flags:       []string{"name", "path", "read-only"},
+		FilesOnly:   true,
+		Run: func(app *Application, args CommandArgs) error {
+			return app.Create(args.Name, args.Path, "sql")
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "create-multi",
+		Description: "Reserve several sequential versions with empty up and down SQL files",
+		Flags:       []string{"name", "count", "path", "read-only"},
+		FilesOnly:   true,
+		Run: func(app *Application, args CommandArgs) error {
+			return app.CreateMulti(args.Name, args.Path, args.Count)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "up",
+		Description: "Apply all pending migrations",
+		Flags:       upFlags,
+		Run: func(app *Application, args CommandArgs) error {
+			if args.From != 0 || args.To != 0 {
+				return app.UpRange(args.Path, args.From, args.To)
+			}
+			if args.AppliedManifest != "" {
+				return app.UpManifest(args.Path, args.AppliedManifest)
+			}
+			return app.Up(args.Path)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "plan",
+		Description: "Write the migrations up would apply, with their checksums, as a JSON plan for approval",
+		Flags:       []string{"path", "skip", "apply-skipped", "only-sql", "gate", "out"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.Plan(args.Path, args.Out)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "apply",
+		Description: "Apply exactly the migrations of an approved -plan, refusing files changed since",
+		Flags:       []string{"path", "plan", "store-plan", "run-as", "check-perms", "heartbeat-interval", "statement-timeout", "pre-lock-statement", "deadlock-retries", "delimiter", "lock-scope", "abort-lock-wait", "diagnose-lock"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.Apply(args.Path, args.Plan)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "down",
+		Description: "Roll back the last applied migration",
+		Flags:       []string{"path", "run-as", "check-perms", "heartbeat-interval", "statement-timeout", "pre-lock-statement", "deadlock-retries", "delimiter", "lock-scope", "abort-lock-wait", "interactive", "preview", "exit-code-on-noop", "diagnose-lock", "notify-url", "notify-on", "notify-timeout"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.Down(args.Path)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "downto",
+		Description: "Roll back applied migrations above -version, newest first",
+		Flags:       []string{"path", "version", "run-as", "check-perms", "heartbeat-interval", "statement-timeout", "pre-lock-statement", "deadlock-retries", "delimiter", "commit-every", "lock-scope", "abort-lock-wait", "interactive", "preview", "diagnose-lock", "notify-url", "notify-on", "notify-timeout"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.DownTo(args.Path, args.Version)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "reset",
+		Description: "Roll back all applied migrations (asks for confirmation unless -y)",
+		Flags:       []string{"path", "run-as", "check-perms", "heartbeat-interval", "statement-timeout", "pre-lock-statement", "deadlock-retries", "delimiter", "commit-every", "lock-scope", "abort-lock-wait", "interactive", "preview", "diagnose-lock"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.DownTo(args.Path, 0)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "nuke",
+		Description: "Roll back all migrations and drop the tracking table, leaving a pristine database (asks for confirmation unless -force or -y)",
+		Flags:       []string{"path", "force", "run-as", "statement-timeout", "pre-lock-statement", "delimiter", "commit-every", "lock-scope", "abort-lock-wait", "diagnose-lock"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.Nuke(args.Path, args.Force)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "compact",
+		Description: "Drop audit log (-store-plan) records older than -retention",
+		Flags:       []string{"store-plan", "retention"},
+		FilesOnly:   true,
+		Run: func(app *Application, args CommandArgs) error {
+			return app.Compact(args.Retention)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "redo",
+		Description: "Roll back and re-apply the last applied migration, or the one given by -name",
+		Flags:       []string{"path", "name", "continue-on-missing-down", "out", "run-as", "check-perms", "heartbeat-interval", "statement-timeout", "pre-lock-statement", "deadlock-retries", "delimiter", "lock-scope", "abort-lock-wait", "diagnose-lock", "notify-url", "notify-on", "notify-timeout"},
+		Run: func(app *Application, args CommandArgs) error {
+			if args.Name != "" {
+				return app.RedoNamed(args.Path, args.Name, args.Out)
+			}
+			return app.Redo(args.Path, args.Out)
+		},
+	})
+	RegisterCommand(Command{
+		Name:         "status",
+		Description:  "Print the status of every recorded migration",
+		Flags:        []string{"path", "full", "verify-checksums", "label", "verbose", "wide", "status-icons", "template", "format", "out"},
+		PathOptional: true,
+		Run: func(app *Application, args CommandArgs) error {
+			if args.Format == StatusFormatCSV {
+				if args.Template != "" {
+					return fmt.Errorf("%w: -template cannot be combined with -format %s", ErrInvalidFormat, StatusFormatCSV)
+				}
+				return app.StatusCSV(args.Out)
+			}
+			if args.Template != "" {
+				if args.Path != "" {
+					if err := app.checkMigrationFiles(args.Path); err != nil {
+						return err
+					}
+				}
+				return app.StatusTemplate(args.Template, args.Out)
+			}
+			return app.Status(args.Path)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "verify",
+		Description: "Check that applied SQL and Go migration files have not changed since they were applied",
+		Flags:       []string{"path", "from-git"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.Verify(args.Path)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "events",
+		Description: "Print migrations above -since as JSON lines for change data capture",
+		Flags:       []string{"since", "out"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.Events(args.Since, args.Out)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "dump-applied",
+		Description: "Write the recorded up SQL of every successfully applied migration, in version order, as one script",
+		Flags:       []string{"label", "out"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.DumpApplied(args.Out)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "export-applied",
+		Description: "Write the versions, names and checksums of applied migrations as a JSON manifest for -applied-manifest",
+		Flags:       []string{"out"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.ExportApplied(args.Out)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "dbversion",
+		Description: "Print the applied version, the latest version on disk and the pending count",
+		Flags:       []string{"path", "plain"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.DBVersion(args.Path)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "schema-dump",
+		Description: "Write a JSON snapshot of the live tables and columns, the expected schema for report-drift",
+		Flags:       []string{"out"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.SchemaDump(args.Out)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "report-drift",
+		Description: "Compare the live schema to the -expected snapshot and list added, removed and changed objects",
+		Flags:       []string{"expected", "out"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.ReportDrift(args.Expected, args.Out)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "schema-compare",
+		Description: "Apply every migration to the -scratch-dsn database and fail if the schema differs from the -schema-compare-to golden snapshot",
+		Flags:       []string{"path", "schema-compare-to", "scratch-dsn", "out"},
+		FilesOnly:   true,
+		Run: func(app *Application, args CommandArgs) error {
+			return app.SchemaCompare(args.Path, args.Golden, args.Scratch, args.Out)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "test-migrate",
+		Description: "Clone the database into a temporary one, run up, down and up again there, then drop the clone",
+		Flags:       []string{"path", "run-as", "statement-timeout", "pre-lock-statement", "delimiter", "out"},
+		FilesOnly:   true,
+		Run: func(app *Application, args CommandArgs) error {
+			return app.TestMigrate(args.Path, args.TestDatabase, args.Out)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "tag",
+		Description: "Record a release tag with the current schema version and migration checksums",
+		Flags:       []string{"label"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.Tag(args.Label)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "check-lock",
+		Description: "Acquire and immediately release the migrator lock to check connectivity and that no lock is stuck",
+		Flags:       []string{"statement-timeout", "pre-lock-statement", "lock-wait", "lock-retry-interval", "lock-jitter"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.CheckLock()
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "lock",
+		Description: "Acquire a persistent lock that outlives this process, waiting while another owner holds it",
+		Flags:       []string{"lock-key", "lock-ttl"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.Lock(args.LockKey, args.LockTTL)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "unlock",
+		Description: "Release a persistent lock taken with the lock command",
+		Flags:       []string{"lock-key"},
+		Run: func(app *Application, args CommandArgs) error {
+			return app.Unlock(args.LockKey)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "rename",
+		Description: "Renumber the files of migration -version to -to",
+		Flags:       []string{"path", "version", "to", "read-only"},
+		AllShards:   true,
+		Run: func(app *Application, args CommandArgs) error {
+			return app.Rename(args.Path, args.Version, args.To, args.Shards...)
+		},
+	})
+	RegisterCommand(Command{
+		Name:        "drivers",
+		Description: "List registered database drivers with their capabilities",
+		Standalone:  true,
+		Run: func(_ *Application, args CommandArgs) error {
+			return WriteDriverList(args.Out)
+		},
+	})
+}
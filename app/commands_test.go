@@ -0,0 +1,116 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/config"
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEveryCommandHasHelp(t *testing.T) {
+	assert.NotEmpty(t, Commands())
+
+	for _, cmd := range Commands() {
+		assert.NotEmpty(t, cmd.Description, "command "+cmd.Name+" has no description")
+		assert.NotNil(t, cmd.Run, "command "+cmd.Name+" has no handler")
+	}
+}
+
+func TestWriteCommandHelpListsAllCommands(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCommandHelp(&buf))
+
+	for _, cmd := range Commands() {
+		assert.Contains(t, buf.String(), "  "+cmd.Name+" ")
+		assert.Contains(t, buf.String(), cmd.Description)
+	}
+	assert.Contains(t, buf.String(), "flags: -path -version -to -read-only")
+}
+
+func TestWriteDriverListShowsBuiltInDrivers(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteDriverList(&buf))
+
+	assert.Contains(t, buf.String(), "postgres")
+	assert.Contains(t, buf.String(), "(supports-transactions=yes supports-advisory-lock=yes)")
+}
+
+func TestDriversCommandRunsStandalone(t *testing.T) {
+	cmd, ok := LookupCommand("drivers")
+	require.True(t, ok)
+	assert.True(t, cmd.Standalone)
+
+	var buf bytes.Buffer
+	require.NoError(t, cmd.Run(nil, CommandArgs{Out: &buf}))
+	assert.Contains(t, buf.String(), "(supports-transactions=yes supports-advisory-lock=yes)")
+}
+
+func TestLookupCommand(t *testing.T) {
+	cmd, ok := LookupCommand("downto")
+	assert.True(t, ok)
+	assert.Equal(t, "downto", cmd.Name)
+
+	_, ok = LookupCommand("unknown")
+	assert.False(t, ok)
+}
+
+func TestRegisterCommandTwicePanics(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterCommand(Command{Name: "up", Description: "duplicate"})
+	})
+}
+
+func TestStatusRunsWithoutConfigOrPath(t *testing.T) {
+	cfg, err := config.Load(filepath.Join(t.TempDir(), "config.yaml"), false)
+	require.NoError(t, err)
+	assert.Equal(t, "custom_migrations", config.Resolve("custom_migrations", cfg.MigratorOpt.TableName))
+	assert.NoError(t, storage.ValidateTrackingTable("custom_migrations"))
+
+	cmd, ok := LookupCommand("status")
+	require.True(t, ok)
+	assert.False(t, cmd.RequiresPath())
+
+	mockStorage := storage.NewMockSQLStorage()
+	applied := storage.CreateMigration("create_users", storage.StatusSuccess, 1, time.Now())
+	require.NoError(t, mockStorage.InsertMigration(context.Background(), applied))
+
+	app := New(logger.New(), mockStorage)
+	assert.NoError(t, cmd.Run(app, CommandArgs{Out: &bytes.Buffer{}}))
+}
+
+func TestRequiresPath(t *testing.T) {
+	for name, want := range map[string]bool{"up": true, "create": true, "status": false, "events": false} {
+		cmd, ok := LookupCommand(name)
+		require.True(t, ok, name)
+		assert.Equal(t, want, cmd.RequiresPath(), name)
+	}
+}
+
+// absentTableStorage отказывает в подключении, как база без служебной
+// таблицы при отключённом автосоздании.
+type absentTableStorage struct {
+	*storage.MockSQLStorage
+}
+
+func (absentTableStorage) Connect(context.Context) error {
+	return fmt.Errorf("%w: schema_migrations must be provisioned", storage.ErrTrackingTableAbsent)
+}
+
+func TestStatusBeforeTrackingTableExists(t *testing.T) {
+	app := New(logger.New(), absentTableStorage{MockSQLStorage: storage.NewMockSQLStorage()})
+	assert.NoError(t, app.Status(""))
+
+	dir := t.TempDir()
+	writeMigration(t, dir, 1, "create_users", "CREATE TABLE users (id INT);", "DROP TABLE users;")
+	assert.NoError(t, app.Status(dir))
+
+	assert.ErrorIs(t, app.Up(dir), storage.ErrTrackingTableAbsent)
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactDropsAuditRecordsOlderThanRetention(t *testing.T) {
+	auditLog := filepath.Join(t.TempDir(), "audit.jsonl")
+	now := time.Now().UTC()
+	var lines []string
+	for _, record := range []auditRecord{
+		{Time: now.Add(-48 * time.Hour), Command: "up"},
+		{Time: now.Add(-time.Hour), Command: "apply"},
+	} {
+		line, err := json.Marshal(record)
+		require.NoError(t, err)
+		lines = append(lines, string(line))
+	}
+	lines = append(lines, "not json")
+	require.NoError(t, os.WriteFile(auditLog, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+	app.StorePlan = auditLog
+	require.NoError(t, app.Compact(24*time.Hour))
+
+	data, err := os.ReadFile(auditLog)
+	require.NoError(t, err)
+	assert.Equal(t, lines[1]+"\nnot json\n", string(data))
+}
+
+func TestCompactRetentionRequiresAuditLog(t *testing.T) {
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+
+	assert.ErrorIs(t, app.Compact(time.Hour), ErrAuditLogRequired)
+	assert.Equal(t, 0, mockStorage.LockCalls())
+}
+
+func TestCompactRequiresRetention(t *testing.T) {
+	app := New(logger.New(), storage.NewMockSQLStorage())
+	app.StorePlan = filepath.Join(t.TempDir(), "audit.jsonl")
+
+	cmd, ok := LookupCommand("compact")
+	require.True(t, ok)
+	assert.ErrorIs(t, cmd.Run(app, CommandArgs{}), ErrRetentionRequired)
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	ErrConfirmationRequired = errors.New("confirmation required")
+	ErrNotConfirmed         = errors.New("operation not confirmed")
+)
+
+// prompt — терминал, в котором разрушительные команды спрашивают подтверждение.
+type prompt struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// EnablePrompts разрешает спрашивать подтверждение разрушительных команд
+// через in и out. Если in не терминал, вопросы не задаются и возвращается
+// false: без -assume-yes такие команды тогда отказываются выполняться.
+func (app *Application) EnablePrompts(in *os.File, out io.Writer) bool {
+	info, err := in.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	app.setPrompt(in, out)
+	return true
+}
+
+func (app *Application) setPrompt(in io.Reader, out io.Writer) {
+	app.prompt = &prompt{in: bufio.NewReader(in), out: out}
+}
+
+// confirm — единая точка подтверждения разрушительных команд. С AssumeYes
+// подтверждение не спрашивается. Без терминала спросить некого, и команда
+// завершается с ErrConfirmationRequired; в терминале ответ, отличный от
+// "y" или "yes", даёт ErrNotConfirmed.
+func (app *Application) confirm(action string) error {
+	if app.AssumeYes {
+		app.logger.Info("Confirmed by -assume-yes: %s", action)
+		return nil
+	}
+	if app.prompt == nil {
+		err := fmt.Errorf("%w: %s; pass -y to confirm", ErrConfirmationRequired, action)
+		app.logger.Error("%v", err)
+		return err
+	}
+
+	if _, err := fmt.Fprintf(app.prompt.out, "%s. Continue? [y/N] ", action); err != nil {
+		return err
+	}
+	line, err := app.prompt.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return nil
+	}
+	app.logger.Warn("Not confirmed: %s", action)
+	return fmt.Errorf("%w: %s", ErrNotConfirmed, action)
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmAssumeYesSkipsPrompt(t *testing.T) {
+	app := New(logger.New(), storage.NewMockSQLStorage())
+	app.AssumeYes = true
+	var out bytes.Buffer
+	app.setPrompt(strings.NewReader("n\n"), &out)
+
+	assert.NoError(t, app.confirm("drop everything"))
+	assert.Empty(t, out.String())
+}
+
+func TestConfirmWithoutTerminalRequiresAssumeYes(t *testing.T) {
+	app := New(logger.New(), storage.NewMockSQLStorage())
+
+	err := app.confirm("drop everything")
+	assert.ErrorIs(t, err, ErrConfirmationRequired)
+	assert.Contains(t, err.Error(), "pass -y")
+}
+
+func TestConfirmAsksInTerminal(t *testing.T) {
+	cases := []struct {
+		answer string
+		err    error
+	}{
+		{"y\n", nil},
+		{"YES\n", nil},
+		{"n\n", ErrNotConfirmed},
+		{"\n", ErrNotConfirmed},
+		{"", ErrNotConfirmed},
+	}
+	for _, c := range cases {
+		app := New(logger.New(), storage.NewMockSQLStorage())
+		var out bytes.Buffer
+		app.setPrompt(strings.NewReader(c.answer), &out)
+
+		err := app.confirm("drop everything")
+		if c.err == nil {
+			assert.NoError(t, err, c.answer)
+		} else {
+			assert.ErrorIs(t, err, c.err, c.answer)
+		}
+		assert.Equal(t, "drop everything. Continue? [y/N] ", out.String())
+	}
+}
+
+func TestEnablePromptsIgnoresNonTerminal(t *testing.T) {
+	in, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer in.Close()
+	defer w.Close()
+
+	app := New(logger.New(), storage.NewMockSQLStorage())
+	assert.False(t, app.EnablePrompts(in, &bytes.Buffer{}))
+	assert.ErrorIs(t, app.confirm("drop everything"), ErrConfirmationRequired)
+}
+
+func TestResetAsksForConfirmation(t *testing.T) {
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	require.NoError(t, app.Up(migrationDir))
+
+	app.setPrompt(strings.NewReader("n\n"), &bytes.Buffer{})
+	assert.ErrorIs(t, app.DownTo(migrationDir, 0), ErrNotConfirmed)
+	assert.Equal(t, []string{"CREATE TABLE users (id serial);"}, mockStorage.ExecutedSQL())
+
+	app.AssumeYes = true
+	assert.NoError(t, app.DownTo(migrationDir, 0))
+	assert.Equal(t, []string{"CREATE TABLE users (id serial);", "DROP TABLE users;"}, mockStorage.ExecutedSQL())
+}
+
+func TestPreviewRollbackRequiresConfirmation(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, 1, "create_users", "CREATE TABLE users (id INT);", "DROP TABLE users;")
+	writeMigration(t, dir, 2, "create_orders", "CREATE TABLE orders (id INT);", "DROP TABLE orders;")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00003_seed_users_up.sql"), []byte("INSERT INTO users VALUES (1);"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00003_seed_users_down.go"), []byte("package main\n\nfunc main() {}\n"), 0o600))
+
+	st := storage.NewMockSQLStorage()
+	app := New(logger.New(), st)
+	require.NoError(t, app.Up(dir))
+
+	var preview, prompt bytes.Buffer
+	app.Preview = &preview
+	app.setPrompt(strings.NewReader("n\n"), &prompt)
+
+	assert.ErrorIs(t, app.DownTo(dir, 0), ErrNotConfirmed)
+	assert.Equal(t, "-- Version 3: seed_users (down)\n"+
+		"-- Go migration: rolled back by code, no SQL to show\n\n"+
+		"-- Version 2: create_orders (down)\nDROP TABLE orders;\n\n"+
+		"-- Version 1: create_users (down)\nDROP TABLE users;\n\n", preview.String())
+	assert.Equal(t, "rolling back 3 migration(s) shown above. Continue? [y/N] ", prompt.String(),
+		"preview replaces the generic reset confirmation")
+	assert.Len(t, st.ExecutedSQL(), 3, "nothing is rolled back without confirmation")
+}
+
+func TestPreviewDownShowsOnlyLastMigration(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, 1, "create_users", "CREATE TABLE users (id INT);", "DROP TABLE users;")
+	writeMigration(t, dir, 2, "create_orders", "CREATE TABLE orders (id INT);", "DROP TABLE orders;")
+
+	st := storage.NewMockSQLStorage()
+	app := New(logger.New(), st)
+	require.NoError(t, app.Up(dir))
+
+	var preview bytes.Buffer
+	app.Preview = &preview
+	app.AssumeYes = true
+
+	require.NoError(t, app.Down(dir))
+	assert.Equal(t, "-- Version 2: create_orders (down)\nDROP TABLE orders;\n\n", preview.String())
+	assert.Equal(t, "DROP TABLE orders;", st.ExecutedSQL()[len(st.ExecutedSQL())-1])
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+var (
+	ErrNotGitRepo      = errors.New("not a git repository")
+	ErrInvalidGitRange = errors.New("invalid git range, expected base..head")
+)
+
+// gitDiff возвращает вывод git diff --name-only для файлов, добавленных
+// в диапазоне revRange, с путями относительно dir. Заменяется в тестах.
+var gitDiff = func(dir, revRange string) ([]byte, error) {
+	gitBinary, err := exec.LookPath("git")
+	if err != nil {
+		return nil, fmt.Errorf("%w: git is not installed", ErrNotGitRepo)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(gitBinary, "-C", dir, "diff", "--name-only", "--relative", "--diff-filter=A", revRange, "--", ".")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "not a git repository") {
+			return nil, fmt.Errorf("%w: %s", ErrNotGitRepo, dir)
+		}
+		return nil, fmt.Errorf("git diff %s: %w: %s", revRange, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// gitAuthor возвращает автора для директивы "-- migrate:author" из git config
+// (user.name и user.email) репозитория dir или пустую строку, если имя
+// не настроено. Заменяется в тестах.
+var gitAuthor = func(dir string) string {
+	gitBinary, err := exec.LookPath("git")
+	if err != nil {
+		return ""
+	}
+	value := func(key string) string {
+		out, err := exec.Command(gitBinary, "-C", dir, "config", key).Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	name := value("user.name")
+	if name == "" {
+		return ""
+	}
+	if email := value("user.email"); email != "" {
+		return fmt.Sprintf("%s <%s>", name, email)
+	}
+	return name
+}
+
+// gitAddedVersions возвращает версии миграций, файлы которых добавлены
+// в директорию dir в диапазоне коммитов revRange (base..head).
+func gitAddedVersions(dir, revRange string) (map[int]bool, error) {
+	base, head, ok := strings.Cut(revRange, "..")
+	if !ok || base == "" || head == "" || strings.HasPrefix(head, ".") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidGitRange, revRange)
+	}
+
+	out, err := gitDiff(dir, revRange)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[int]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		// Файлы во вложенных директориях миграциями не считаются.
+		if line == "" || path.Base(line) != line {
+			continue
+		}
+		version, _, err := parseFileName(line)
+		if err != nil {
+			continue
+		}
+		versions[version] = true
+	}
+	return versions, nil
+}
+
+// gitSelection переводит версии файлов, добавленных в диапазоне FromGit,
+// в порядковые номера миграций мигратора. versions — версии файлов
+// директории по возрастанию. nil означает, что ограничения нет: FromGit не
+// задан или директория не в репозитории git, и тогда выполняются все миграции.
+func (app *Application) gitSelection(filePath string, versions []int) (map[int]bool, error) {
+	if app.FromGit == "" {
+		return nil, nil
+	}
+
+	added, err := gitAddedVersions(filePath, app.FromGit)
+	if errors.Is(err, ErrNotGitRepo) {
+		app.logger.Warn("Ignoring -from-git, running all migrations: %v", err)
+		return nil, nil
+	}
+	if err != nil {
+		app.logger.Error("Failed to list migrations added in %s: %v", app.FromGit, err)
+		return nil, err
+	}
+
+	selected := make(map[int]bool, len(added))
+	for i, version := range versions {
+		if added[version] {
+			selected[i+1] = true
+		}
+	}
+	app.logger.Info("Limiting the run to %d migration(s) added in %s", len(selected), app.FromGit)
+	return selected, nil
+}
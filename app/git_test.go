@@ -0,0 +1,141 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubGitDiff подменяет вывод git diff на время теста.
+func stubGitDiff(t *testing.T, out string, err error) *[]string {
+	t.Helper()
+	var calls []string
+	original := gitDiff
+	gitDiff = func(dir, revRange string) ([]byte, error) {
+		calls = append(calls, revRange)
+		return []byte(out), err
+	}
+	t.Cleanup(func() { gitDiff = original })
+	return &calls
+}
+
+func TestCreatePrefillsAuthorFromGitConfig(t *testing.T) {
+	original := gitAuthor
+	gitAuthor = func(string) string { return "Jane Doe <jane@example.com>" }
+	t.Cleanup(func() { gitAuthor = original })
+
+	dir := t.TempDir()
+	app := New(logger.New(), storage.NewMockSQLStorage())
+	require.NoError(t, app.Create("create_users", dir, "sql"))
+
+	up, err := os.ReadFile(filepath.Join(dir, "00001_create_users_up.sql"))
+	require.NoError(t, err)
+	assert.Equal(t, "-- migrate:author Jane Doe <jane@example.com>\n", string(up))
+	down, err := os.ReadFile(filepath.Join(dir, "00001_create_users_down.sql"))
+	require.NoError(t, err)
+	assert.Empty(t, down)
+}
+
+func TestCreateWithoutGitAuthorLeavesFileEmpty(t *testing.T) {
+	original := gitAuthor
+	gitAuthor = func(string) string { return "" }
+	t.Cleanup(func() { gitAuthor = original })
+
+	dir := t.TempDir()
+	app := New(logger.New(), storage.NewMockSQLStorage())
+	require.NoError(t, app.Create("create_users", dir, "sql"))
+
+	up, err := os.ReadFile(filepath.Join(dir, "00001_create_users_up.sql"))
+	require.NoError(t, err)
+	assert.Empty(t, up)
+}
+
+func TestGitAddedVersions(t *testing.T) {
+	stubGitDiff(t, "00003_create_items_up.sql\n00003_create_items_down.sql\n00004_seed_go.go\nREADME.md\nold/00001_legacy_up.sql\n", nil)
+
+	versions, err := gitAddedVersions(t.TempDir(), "origin/main..HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, map[int]bool{3: true, 4: true}, versions)
+}
+
+func TestGitAddedVersionsRejectsInvalidRange(t *testing.T) {
+	calls := stubGitDiff(t, "", nil)
+	for _, revRange := range []string{"HEAD", "..HEAD", "main..", "main...HEAD"} {
+		_, err := gitAddedVersions(t.TempDir(), revRange)
+		assert.ErrorIs(t, err, ErrInvalidGitRange, revRange)
+	}
+	assert.Empty(t, *calls)
+}
+
+func TestUpFromGitAppliesOnlyAddedMigrations(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;")
+	writeMigration(t, migrationDir, 3, "create_items", "CREATE TABLE items (id serial);", "DROP TABLE items;")
+	calls := stubGitDiff(t, "00003_create_items_up.sql\n00003_create_items_down.sql\n", nil)
+
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+	app.FromGit = "origin/main..HEAD"
+
+	require.NoError(t, app.Up(migrationDir))
+	assert.Equal(t, []string{"origin/main..HEAD"}, *calls)
+	assert.Equal(t, []string{"CREATE TABLE items (id serial);"}, mockStorage.ExecutedSQL())
+
+	applied, err := mockStorage.SelectAppliedVersions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{3: storage.StatusSuccess}, applied)
+}
+
+func TestUpFromGitOutsideRepoRunsEverything(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;")
+	stubGitDiff(t, "", ErrNotGitRepo)
+
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+	app.FromGit = "origin/main..HEAD"
+
+	require.NoError(t, app.Up(migrationDir))
+	assert.Len(t, mockStorage.ExecutedSQL(), 2)
+}
+
+func TestUpFromGitFailsOnGitError(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	gitErr := errors.New("unknown revision origin/main")
+	stubGitDiff(t, "", gitErr)
+
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+	app.FromGit = "origin/main..HEAD"
+
+	assert.ErrorIs(t, app.Up(migrationDir), gitErr)
+	assert.Empty(t, mockStorage.ExecutedSQL())
+}
+
+func TestVerifyFromGitChecksOnlyAddedMigrations(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;")
+
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+	require.NoError(t, app.Up(migrationDir))
+
+	// Старая миграция изменена после применения, новая — нет.
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id bigserial);", "DROP TABLE users;")
+	require.Error(t, app.Verify(migrationDir))
+
+	stubGitDiff(t, "00002_create_orders_up.sql\n", nil)
+	app.FromGit = "origin/main..HEAD"
+	assert.NoError(t, app.Verify(migrationDir))
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileName — файл в директории миграций со списком файлов, которые
+// не являются миграциями (документация, фикстуры).
+const ignoreFileName = ".migratorignore"
+
+// ignoreRule — строка .migratorignore.
+type ignoreRule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// loadIgnoreRules читает .migratorignore в стиле .gitignore: пустые строки
+// и строки с # пропускаются, "!" в начале возвращает ранее исключённые
+// файлы, "/" в конце относит шаблон только к директориям. Директория
+// миграций плоская, поэтому шаблоны сравниваются с именем файла, а "/"
+// в начале шаблона ничего не меняет. Если файла нет, правил нет.
+func loadIgnoreRules(dir string) ([]ignoreRule, error) {
+	file, err := os.Open(filepath.Join(dir, ignoreFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseIgnoreRules(file)
+}
+
+// parseIgnoreRules разбирает содержимое .migratorignore.
+func parseIgnoreRules(r io.Reader) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(text, "!") {
+			rule.negate = true
+			text = text[1:]
+		}
+		if strings.HasSuffix(text, "/") {
+			rule.dirOnly = true
+			text = strings.TrimSuffix(text, "/")
+		}
+		rule.pattern = strings.TrimPrefix(text, "/")
+		if _, err := path.Match(rule.pattern, ""); err != nil || rule.pattern == "" {
+			return nil, fmt.Errorf("%s:%d: invalid pattern %q", ignoreFileName, line, scanner.Text())
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ignored сообщает, исключён ли файл: решает последнее подходящее правило.
+func ignored(rules []ignoreRule, name string, isDir bool) bool {
+	result := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matched, _ := path.Match(rule.pattern, name); matched {
+			result = !rule.negate
+		}
+	}
+	return result
+}
+
+// readMigrationDir возвращает содержимое директории миграций без самого
+// .migratorignore и файлов, исключённых им.
+func readMigrationDir(dir string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := loadIgnoreRules(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := entries[:0]
+	for _, entry := range entries {
+		if entry.Name() == ignoreFileName || ignored(rules, entry.Name(), entry.IsDir()) {
+			continue
+		}
+		files = append(files, entry)
+	}
+	return files, nil
+}
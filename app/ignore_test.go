@@ -0,0 +1,50 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMigrationsSkipsIgnoredFiles(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	require.NoError(t, os.WriteFile(filepath.Join(migrationDir, "seed_notes.sql"), []byte("SELECT 1;"), 0o600))
+	require.NoError(t, os.Mkdir(filepath.Join(migrationDir, "fixtures"), 0o700))
+
+	_, err := getMigrations(DirSource(migrationDir))
+	assert.ErrorIs(t, err, ErrInvalidMigrationName)
+
+	ignore := "# not migrations\n\nseed_*.sql\nfixtures/\n"
+	require.NoError(t, os.WriteFile(filepath.Join(migrationDir, ignoreFileName), []byte(ignore), 0o600))
+
+	migrations, err := getMigrations(DirSource(migrationDir))
+	require.NoError(t, err)
+	assert.Len(t, migrations, 1)
+	assert.Contains(t, migrations, 1)
+}
+
+func TestIgnoreRules(t *testing.T) {
+	migrationDir := t.TempDir()
+	ignore := "*.sql\n!00002_*.sql\n/docs/\n"
+	require.NoError(t, os.WriteFile(filepath.Join(migrationDir, ignoreFileName), []byte(ignore), 0o600))
+
+	rules, err := loadIgnoreRules(migrationDir)
+	require.NoError(t, err)
+	assert.True(t, ignored(rules, "00001_old_up.sql", false))
+	assert.False(t, ignored(rules, "00002_new_up.sql", false))
+	assert.True(t, ignored(rules, "docs", true))
+	assert.False(t, ignored(rules, "docs", false))
+	assert.False(t, ignored(rules, "00003_seed.go", false))
+}
+
+func TestIgnoreRulesRejectInvalidPattern(t *testing.T) {
+	migrationDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(migrationDir, ignoreFileName), []byte("[broken\n"), 0o600))
+
+	_, err := getMigrations(DirSource(migrationDir))
+	assert.Error(t, err)
+}
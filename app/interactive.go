@@ -0,0 +1,77 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Edestus789/sql-migrator/processes"
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+// interactive — терминал пошагового режима: перед каждой миграцией up/down
+// оператор видит её SQL и выбирает, применить её, пропустить или остановиться.
+type interactive struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// EnableInteractive включает пошаговый режим с вводом из in и выводом в out.
+// Если in не терминал (например, запуск из CI), режим не включается
+// и возвращается false: отвечать на вопросы было бы некому.
+func (app *Application) EnableInteractive(in *os.File, out io.Writer) bool {
+	info, err := in.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	app.setInteractive(in, out)
+	return true
+}
+
+func (app *Application) setInteractive(in io.Reader, out io.Writer) {
+	app.interactive = &interactive{in: bufio.NewReader(in), out: out}
+}
+
+// confirm выводит миграцию и спрашивает, что с ней делать. Конец ввода
+// равносилен выходу.
+func (i *interactive) confirm(migration storage.Migration, direction string) (processes.StepDecision, error) {
+	sql, goFunc := migration.Up, migration.UpGo
+	if direction == processes.DirectionDown {
+		sql, goFunc = migration.Down, migration.DownGo
+	}
+	body := strings.TrimSpace(sql)
+	if goFunc != nil {
+		body = strings.TrimSpace(body + "\n(Go migration " + migration.SourceFile + ")")
+	}
+	if _, err := fmt.Fprintf(i.out, "\nMigration %d (%s) %s:\n%s\n", migration.Version, migration.Name, direction, body); err != nil {
+		return processes.StepQuit, err
+	}
+
+	for {
+		if _, err := fmt.Fprint(i.out, "[a]pply / [s]kip / [q]uit? "); err != nil {
+			return processes.StepQuit, err
+		}
+
+		line, err := i.in.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "a", "apply":
+			return processes.StepApply, nil
+		case "s", "skip":
+			return processes.StepSkip, nil
+		case "q", "quit":
+			return processes.StepQuit, nil
+		}
+		if errors.Is(err, io.EOF) {
+			return processes.StepQuit, nil
+		}
+		if err != nil {
+			return processes.StepQuit, err
+		}
+		if _, err := fmt.Fprintln(i.out, "Please answer a, s or q."); err != nil {
+			return processes.StepQuit, err
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInteractiveApplySkipQuit(t *testing.T) {
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;")
+	writeMigration(t, migrationDir, 3, "create_items", "CREATE TABLE items (id serial);", "DROP TABLE items;")
+
+	var out bytes.Buffer
+	app.setInteractive(strings.NewReader("a\nmaybe\ns\nq\n"), &out)
+	assert.NoError(t, app.Up(migrationDir))
+
+	assert.Equal(t, []string{"CREATE TABLE users (id serial);"}, mockStorage.ExecutedSQL())
+	statuses, err := mockStorage.SelectAppliedVersions(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess, 2: storage.StatusSkipped}, statuses)
+	assert.Equal(t, 1, mockStorage.UnlockCalls(), "Quitting releases the lock")
+
+	assert.Contains(t, out.String(), "Migration 2 (create_orders) up:\nCREATE TABLE orders (id serial);\n")
+	assert.Contains(t, out.String(), "Please answer a, s or q.")
+}
+
+func TestInteractiveEndOfInputQuits(t *testing.T) {
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+
+	app.setInteractive(strings.NewReader(""), &bytes.Buffer{})
+	assert.NoError(t, app.Up(migrationDir))
+	assert.Empty(t, mockStorage.ExecutedSQL())
+}
+
+func TestEnableInteractiveRequiresTerminal(t *testing.T) {
+	app := New(logger.New(), storage.NewMockSQLStorage())
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "answers"))
+	assert.NoError(t, err)
+	defer file.Close()
+
+	assert.False(t, app.EnableInteractive(file, &bytes.Buffer{}))
+	assert.Nil(t, app.interactive)
+}
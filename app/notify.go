@@ -0,0 +1,159 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/processes"
+)
+
+// Значения флага -notify-on: о каких запусках отправляется уведомление.
+const (
+	NotifyOnSuccess = "success"
+	NotifyOnFailure = "failure"
+	NotifyOnAlways  = "always"
+)
+
+// DefaultNotifyTimeout — сколько ждать ответа на уведомление по умолчанию.
+const DefaultNotifyTimeout = 5 * time.Second
+
+var (
+	ErrInvalidNotifyOn = errors.New("invalid notify-on value")
+	ErrNotifyRejected  = errors.New("notification rejected")
+)
+
+// RunReport — итог запуска команды, изменяющей схему, передаваемый
+// наблюдателям AfterRun.
+type RunReport struct {
+	Command    string  `json:"command"`
+	Status     string  `json:"status"`
+	Applied    int     `json:"applied"`
+	RolledBack int     `json:"rolled_back"`
+	Failed     int     `json:"failed"`
+	Version    int     `json:"version"`
+	Duration   float64 `json:"duration"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Success сообщает, завершился ли запуск без ошибки.
+func (r RunReport) Success() bool {
+	return r.Status == NotifyOnSuccess
+}
+
+// ParseNotifyOn разбирает значение флага -notify-on.
+func ParseNotifyOn(s string) (string, error) {
+	switch s {
+	case "", NotifyOnAlways:
+		return NotifyOnAlways, nil
+	case NotifyOnSuccess, NotifyOnFailure:
+		return s, nil
+	default:
+		return "", fmt.Errorf("%w: %q, expected %s, %s or %s",
+			ErrInvalidNotifyOn, s, NotifyOnSuccess, NotifyOnFailure, NotifyOnAlways)
+	}
+}
+
+// Notifier отправляет итог запуска POST-запросом с JSON RunReport.
+// Ошибка уведомления только попадает в журнал: недоступный сервис
+// оповещений не должен менять код завершения миграции.
+type Notifier struct {
+	url    string
+	on     string
+	client *http.Client
+	logger logger.Logger
+}
+
+// NewNotifier создаёт уведомитель для url. on — значение -notify-on,
+// timeout ограничивает время запроса; 0 — DefaultNotifyTimeout.
+func NewNotifier(url, on string, timeout time.Duration, l logger.Logger) (*Notifier, error) {
+	on, err := ParseNotifyOn(on)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = DefaultNotifyTimeout
+	}
+	return &Notifier{url: url, on: on, client: &http.Client{Timeout: timeout}, logger: l}, nil
+}
+
+// Notify отправляет report, если он подходит под фильтр -notify-on.
+// Метод подходит для Application.AfterRun.
+func (n *Notifier) Notify(report RunReport) {
+	if n.on == NotifyOnSuccess && !report.Success() || n.on == NotifyOnFailure && report.Success() {
+		return
+	}
+	if err := n.send(context.Background(), report); err != nil {
+		n.logger.Warn("Failed to send run notification to %s: %v", n.url, err)
+		return
+	}
+	n.logger.Info("Sent %s notification to %s", report.Status, n.url)
+}
+
+func (n *Notifier) send(ctx context.Context, report RunReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: %s", ErrNotifyRejected, resp.Status)
+	}
+	return nil
+}
+
+// runObserved выполняет команду, изменяющую схему, и передаёт её итог
+// наблюдателям AfterRun. Без наблюдателей это обычный runMigrations.
+func (app *Application) runObserved(command, filePath string, migrationFunc func(*processes.Migrator, context.Context) error) error {
+	if len(app.AfterRun) == 0 {
+		return app.runMigrations(filePath, migrationFunc)
+	}
+
+	start := time.Now()
+	var (
+		result  processes.Result
+		version int
+	)
+	err := app.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		err := migrationFunc(migrator, ctx)
+		result = migrator.Result()
+		// Ошибка чтения версии уже записана мигратором, итог отправляется без неё.
+		version, _ = migrator.CurrentVersion(ctx)
+		return err
+	})
+
+	report := RunReport{
+		Command:    command,
+		Status:     NotifyOnSuccess,
+		Applied:    len(result.Applied),
+		RolledBack: len(result.RolledBack),
+		Failed:     len(result.Failed),
+		Version:    version,
+		Duration:   time.Since(start).Seconds(),
+	}
+	if err != nil && !errors.Is(err, ErrNoOp) {
+		report.Status = NotifyOnFailure
+		report.Error = err.Error()
+	}
+	for _, observer := range app.AfterRun {
+		observer(report)
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// notifyServer принимает уведомления и сохраняет их разобранными.
+type notifyServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	reports []RunReport
+}
+
+func newNotifyServer(t *testing.T) *notifyServer {
+	t.Helper()
+	s := &notifyServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var report RunReport
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&report))
+		s.mu.Lock()
+		s.reports = append(s.reports, report)
+		s.mu.Unlock()
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newNotifyingApp(t *testing.T, st storage.SQLStorage, url, on string) *Application {
+	t.Helper()
+	notifier, err := NewNotifier(url, on, time.Second, logger.New())
+	require.NoError(t, err)
+
+	app := New(logger.New(), st)
+	app.AfterRun = append(app.AfterRun, notifier.Notify)
+	return app
+}
+
+func TestNotifyOnSuccessfulRun(t *testing.T) {
+	server := newNotifyServer(t)
+	dir := t.TempDir()
+	writeMigration(t, dir, 1, "create_users", "CREATE TABLE users (id INT);", "DROP TABLE users;")
+	writeMigration(t, dir, 2, "create_orders", "CREATE TABLE orders (id INT);", "DROP TABLE orders;")
+
+	app := newNotifyingApp(t, storage.NewMockSQLStorage(), server.URL, NotifyOnAlways)
+	require.NoError(t, app.Up(dir))
+
+	require.Len(t, server.reports, 1)
+	report := server.reports[0]
+	assert.Equal(t, "up", report.Command)
+	assert.Equal(t, NotifyOnSuccess, report.Status)
+	assert.Equal(t, 2, report.Applied)
+	assert.Equal(t, 0, report.Failed)
+	assert.Equal(t, 2, report.Version)
+	assert.Empty(t, report.Error)
+}
+
+func TestNotifyOnFailedRun(t *testing.T) {
+	server := newNotifyServer(t)
+	dir := t.TempDir()
+	writeMigration(t, dir, 1, "create_users", "CREATE TABLE users (id INT);", "DROP TABLE users;")
+
+	st := failingMigrateStorage{MockSQLStorage: storage.NewMockSQLStorage()}
+	app := newNotifyingApp(t, st, server.URL, NotifyOnAlways)
+	require.Error(t, app.Up(dir))
+
+	require.Len(t, server.reports, 1)
+	report := server.reports[0]
+	assert.Equal(t, NotifyOnFailure, report.Status)
+	assert.Equal(t, 0, report.Applied)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 0, report.Version)
+	assert.Contains(t, report.Error, "syntax error")
+}
+
+func TestNotifyCountsOnlyAttemptedMigrationsAsFailed(t *testing.T) {
+	server := newNotifyServer(t)
+	dir := t.TempDir()
+	writeMigration(t, dir, 1, "create_users", "CREATE TABLE users (id INT);", "DROP TABLE users;")
+
+	st := storage.NewMockSQLStorage()
+	st.SetLockBusy(1)
+	app := newNotifyingApp(t, st, server.URL, NotifyOnAlways)
+	app.Options.AbortLockWait = true
+	require.ErrorIs(t, app.Up(dir), storage.ErrLockBusy)
+
+	require.Len(t, server.reports, 1)
+	report := server.reports[0]
+	assert.Equal(t, NotifyOnFailure, report.Status)
+	assert.Equal(t, 0, report.Failed, "No migration ran while the lock was busy")
+	assert.Contains(t, report.Error, storage.ErrLockBusy.Error())
+}
+
+func TestNotifyOnFilter(t *testing.T) {
+	server := newNotifyServer(t)
+	dir := t.TempDir()
+	writeMigration(t, dir, 1, "create_users", "CREATE TABLE users (id INT);", "DROP TABLE users;")
+
+	app := newNotifyingApp(t, storage.NewMockSQLStorage(), server.URL, NotifyOnFailure)
+	require.NoError(t, app.Up(dir))
+	assert.Empty(t, server.reports, "successful run is filtered out")
+
+	app = newNotifyingApp(t, failingMigrateStorage{MockSQLStorage: storage.NewMockSQLStorage()}, server.URL, NotifyOnSuccess)
+	require.Error(t, app.Up(dir))
+	assert.Empty(t, server.reports, "failed run is filtered out")
+}
+
+func TestNotifyFailureDoesNotFailRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	writeMigration(t, dir, 1, "create_users", "CREATE TABLE users (id INT);", "DROP TABLE users;")
+
+	app := newNotifyingApp(t, storage.NewMockSQLStorage(), server.URL, NotifyOnAlways)
+	assert.NoError(t, app.Up(dir))
+}
+
+func TestParseNotifyOn(t *testing.T) {
+	on, err := ParseNotifyOn("")
+	require.NoError(t, err)
+	assert.Equal(t, NotifyOnAlways, on)
+
+	on, err = ParseNotifyOn(NotifyOnFailure)
+	require.NoError(t, err)
+	assert.Equal(t, NotifyOnFailure, on)
+
+	_, err = ParseNotifyOn("never")
+	assert.ErrorIs(t, err, ErrInvalidNotifyOn)
+}
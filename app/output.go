@@ -0,0 +1,119 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Значения флага -output: как CLI сообщает итог запуска.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// Статусы итога запуска в Outcome.
+const (
+	OutcomeSuccess = "success"
+	OutcomeNoOp    = "noop"
+	OutcomeFailure = "failure"
+)
+
+var ErrInvalidOutput = errors.New("invalid output format")
+
+// ParseOutput разбирает значение флага -output.
+func ParseOutput(s string) (string, error) {
+	switch s {
+	case "", OutputText:
+		return OutputText, nil
+	case OutputJSON:
+		return OutputJSON, nil
+	default:
+		return "", fmt.Errorf("%w: %q, expected %s or %s", ErrInvalidOutput, s, OutputText, OutputJSON)
+	}
+}
+
+// Outcome — итог запуска CLI в режиме -output json. Схема одна для всех
+// команд и для ошибок разбора флагов.
+type Outcome struct {
+	Command  string `json:"command"`
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+	// Redone — миграции, выполненные redo повторно, по одной на базу.
+	Redone []RedoSummary `json:"redone,omitempty"`
+}
+
+// Reporter сообщает итог запуска. В текстовом режиме ошибки подготовки
+// запуска выводятся строкой в stdout, а итог команды не выводится: ход
+// выполнения уже записан в журнал. В режиме JSON каждый запуск завершается
+// одним объектом Outcome: в stdout при коде 0, иначе в stderr.
+type Reporter struct {
+	format string
+	stdout io.Writer
+	stderr io.Writer
+
+	// mu защищает redone: шарды сообщают итог redo одновременно.
+	mu     sync.Mutex
+	redone []RedoSummary
+}
+
+// NewReporter создаёт Reporter для значения -output, уже проверенного ParseOutput.
+func NewReporter(format string, stdout, stderr io.Writer) *Reporter {
+	return &Reporter{format: format, stdout: stdout, stderr: stderr}
+}
+
+// Fail сообщает об ошибке до выполнения команды: неверный флаг,
+// недоступный файл конфигурации и т. п.
+func (r *Reporter) Fail(command string, exitCode int, err error) {
+	if r.format != OutputJSON {
+		fmt.Fprintln(r.stdout, err)
+		return
+	}
+	r.write(Outcome{Command: command, Status: OutcomeFailure, ExitCode: exitCode, Error: err.Error()})
+}
+
+// Redone запоминает итог redo для объекта Outcome. В текстовом режиме
+// возвращает false: итог выводит сама команда.
+func (r *Reporter) Redone(summary RedoSummary) bool {
+	if r.format != OutputJSON {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.redone = append(r.redone, summary)
+	return true
+}
+
+// Finish сообщает итог выполненной команды. err == nil — успех; ErrNoOp
+// означает, что команда ничего не изменила.
+func (r *Reporter) Finish(command string, exitCode int, err error) {
+	if r.format != OutputJSON {
+		return
+	}
+
+	r.mu.Lock()
+	outcome := Outcome{Command: command, Status: OutcomeSuccess, ExitCode: exitCode, Redone: r.redone}
+	r.mu.Unlock()
+	switch {
+	case errors.Is(err, ErrNoOp):
+		outcome.Status = OutcomeNoOp
+	case err != nil:
+		outcome.Status = OutcomeFailure
+	}
+	if err != nil {
+		outcome.Error = err.Error()
+	}
+	r.write(outcome)
+}
+
+func (r *Reporter) write(outcome Outcome) {
+	out := r.stdout
+	if outcome.ExitCode != 0 {
+		out = r.stderr
+	}
+	// Ошибку записи итога сообщить уже некуда.
+	_ = json.NewEncoder(out).Encode(outcome)
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutput(t *testing.T) {
+	for in, want := range map[string]string{"": OutputText, "text": OutputText, "json": OutputJSON} {
+		got, err := ParseOutput(in)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParseOutput("yaml")
+	assert.ErrorIs(t, err, ErrInvalidOutput)
+}
+
+// decodeOutcome разбирает единственный объект Outcome из out.
+func decodeOutcome(t *testing.T, out *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+	var outcome map[string]interface{}
+	decoder := json.NewDecoder(out)
+	require.NoError(t, decoder.Decode(&outcome))
+	assert.False(t, decoder.More(), "exactly one outcome is written")
+	return outcome
+}
+
+func TestReporterJSONSuccessGoesToStdout(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, 1, "create_users", "CREATE TABLE users (id INT);", "DROP TABLE users;")
+	cmd, ok := LookupCommand("up")
+	require.True(t, ok)
+	err := cmd.Run(New(logger.New(), storage.NewMockSQLStorage()), CommandArgs{Path: dir})
+	require.NoError(t, err)
+
+	var stdout, stderr bytes.Buffer
+	NewReporter(OutputJSON, &stdout, &stderr).Finish("up", 0, err)
+
+	assert.Empty(t, stderr.String())
+	assert.Equal(t, map[string]interface{}{
+		"command":   "up",
+		"status":    "success",
+		"exit_code": float64(0),
+	}, decodeOutcome(t, &stdout))
+}
+
+func TestReporterJSONFailureGoesToStderr(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, 1, "create_users", "CREATE TABLE users (id INT);", "DROP TABLE users;")
+	cmd, ok := LookupCommand("up")
+	require.True(t, ok)
+	err := cmd.Run(New(logger.New(), failingMigrateStorage{storage.NewMockSQLStorage()}), CommandArgs{Path: dir})
+	require.Error(t, err)
+
+	var stdout, stderr bytes.Buffer
+	NewReporter(OutputJSON, &stdout, &stderr).Finish("up", 1, err)
+
+	assert.Empty(t, stdout.String())
+	assert.Equal(t, map[string]interface{}{
+		"command":   "up",
+		"status":    "failure",
+		"exit_code": float64(1),
+		"error":     err.Error(),
+	}, decodeOutcome(t, &stderr))
+}
+
+func TestReporterJSONFailSharesSchema(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	NewReporter(OutputJSON, &stdout, &stderr).Fail("up", 1, errors.New("Invalid -skip value: bad"))
+
+	assert.Empty(t, stdout.String())
+	assert.Equal(t, map[string]interface{}{
+		"command":   "up",
+		"status":    "failure",
+		"exit_code": float64(1),
+		"error":     "Invalid -skip value: bad",
+	}, decodeOutcome(t, &stderr))
+}
+
+func TestReporterJSONNoOp(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	NewReporter(OutputJSON, &stdout, &stderr).Finish("up", 2, ErrNoOp)
+
+	outcome := decodeOutcome(t, &stderr)
+	assert.Equal(t, "noop", outcome["status"])
+	assert.Equal(t, float64(2), outcome["exit_code"])
+}
+
+func TestReporterText(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	reporter := NewReporter(OutputText, &stdout, &stderr)
+
+	reporter.Finish("up", 1, errors.New("migration failed"))
+	assert.Empty(t, stdout.String(), "command errors are already logged")
+
+	reporter.Fail("", 1, errors.New("Command must be provided."))
+	assert.Equal(t, "Command must be provided.\n", stdout.String())
+	assert.Empty(t, stderr.String())
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Edestus789/sql-migrator/processes"
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+var (
+	ErrInvalidRange = errors.New("invalid version range")
+	ErrRangeGap     = errors.New("migrations below the range are not applied")
+)
+
+// versionRange — границы -from и -to включительно.
+type versionRange struct {
+	from, to int
+}
+
+// UpRange применяет только миграции с версиями от from до to включительно.
+// Обе версии должны существовать на диске. Если ниже from остались
+// неприменённые версии, команда отказывается работать без AllowMissing;
+// с ним эти версии не применяются и остаются ожидающими для следующего up.
+func (app *Application) UpRange(filePath string, from, to int) error {
+	if from <= 0 || to <= 0 || from > to {
+		app.logger.Error("Invalid range %d..%d: -from and -to must be positive and -from must not exceed -to", from, to)
+		return fmt.Errorf("%w: %d..%d", ErrInvalidRange, from, to)
+	}
+
+	migrations, err := getMigrations(DirSource(filePath))
+	if err != nil {
+		app.logger.Error("Failed to get migrations: %v", err)
+		return err
+	}
+	for _, version := range []int{from, to} {
+		if migrations[version] == nil {
+			app.logger.Error("No migration files found for version %d", version)
+			return fmt.Errorf("%w: %d", ErrVersionNotFound, version)
+		}
+	}
+
+	versions := make([]int, 0, len(migrations))
+	for version := range migrations {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	app.upRange = &versionRange{from: from, to: to}
+	defer func() { app.upRange = nil }()
+
+	return app.runObserved("up", filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		if err := app.checkRangeGap(ctx, migrator, versions, from); err != nil {
+			return err
+		}
+		return app.applyPending(migrator, ctx)
+	})
+}
+
+// checkRangeGap проверяет, что все версии ниже from уже применены
+// или пропущены. AllowMissing отключает проверку.
+func (app *Application) checkRangeGap(ctx context.Context, migrator *processes.Migrator, versions []int, from int) error {
+	if app.AllowMissing {
+		return nil
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, migration := range pending {
+		version := versions[migration.Version-1]
+		if version < from && migration.Status != storage.StatusSkipped {
+			missing = append(missing, strconv.Itoa(version))
+		}
+	}
+	if len(missing) > 0 {
+		app.logger.Error("Versions %s below %d are not applied; use -allow-missing to apply the range anyway",
+			strings.Join(missing, ", "), from)
+		return fmt.Errorf("%w: %s", ErrRangeGap, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// rangeSelection сужает выбор версий до диапазона UpRange. versions —
+// версии файлов по порядку, selected — выбор -from-git или nil.
+func (app *Application) rangeSelection(versions []int, selected map[int]bool) map[int]bool {
+	if app.upRange == nil {
+		return selected
+	}
+
+	inRange := make(map[int]bool)
+	for i, version := range versions {
+		position := i + 1
+		if version < app.upRange.from || version > app.upRange.to {
+			continue
+		}
+		if selected == nil || selected[position] {
+			inRange[position] = true
+		}
+	}
+	return inRange
+}
+
+// skipPositions переводит версии файлов из -skip в порядковые номера
+// миграций, как rangeSelection переводит -from и -to. Версии без файлов
+// миграций не пропускают ничего и только записываются в журнал.
+func (app *Application) skipPositions(versions []int, skip []int) []int {
+	if len(skip) == 0 {
+		return nil
+	}
+
+	positions := make([]int, 0, len(skip))
+	for _, version := range skip {
+		i := sort.SearchInts(versions, version)
+		if i == len(versions) || versions[i] != version {
+			app.logger.Warn("No migration files found for -skip version %d", version)
+			continue
+		}
+		positions = append(positions, i+1)
+	}
+	return positions
+}
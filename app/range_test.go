@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeRangeMigrations создаёт миграции 1..4 с таблицами t1..t4.
+func writeRangeMigrations(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeMigration(t, dir, 1, "create_t1", "CREATE TABLE t1 (id INT);", "DROP TABLE t1;")
+	writeMigration(t, dir, 2, "create_t2", "CREATE TABLE t2 (id INT);", "DROP TABLE t2;")
+	writeMigration(t, dir, 3, "create_t3", "CREATE TABLE t3 (id INT);", "DROP TABLE t3;")
+	writeMigration(t, dir, 4, "create_t4", "CREATE TABLE t4 (id INT);", "DROP TABLE t4;")
+	return dir
+}
+
+func TestUpRangeAppliesOnlyVersionsInRange(t *testing.T) {
+	dir := writeRangeMigrations(t)
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+	app.AllowMissing = true
+
+	require.NoError(t, app.UpRange(dir, 2, 3))
+	assert.Equal(t, []string{"CREATE TABLE t2 (id INT);", "CREATE TABLE t3 (id INT);"}, mockStorage.ExecutedSQL())
+
+	applied, err := mockStorage.SelectAppliedVersions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{2: storage.StatusSuccess, 3: storage.StatusSuccess}, applied)
+}
+
+func TestUpAfterRangeWithAllowMissingAppliesSkippedVersions(t *testing.T) {
+	dir := writeRangeMigrations(t)
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+	app.AllowMissing = true
+
+	require.NoError(t, app.UpRange(dir, 2, 3))
+	require.NoError(t, app.Up(dir))
+	assert.Equal(t, []string{
+		"CREATE TABLE t2 (id INT);", "CREATE TABLE t3 (id INT);",
+		"CREATE TABLE t1 (id INT);", "CREATE TABLE t4 (id INT);",
+	}, mockStorage.ExecutedSQL())
+
+	applied, err := mockStorage.SelectAppliedVersions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{
+		1: storage.StatusSuccess, 2: storage.StatusSuccess, 3: storage.StatusSuccess, 4: storage.StatusSuccess,
+	}, applied)
+}
+
+func TestUpRangeAfterEarlierVersionsApplied(t *testing.T) {
+	dir := writeRangeMigrations(t)
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+
+	require.NoError(t, app.UpRange(dir, 1, 1))
+	require.NoError(t, app.UpRange(dir, 2, 3))
+	assert.Equal(t, []string{"CREATE TABLE t1 (id INT);", "CREATE TABLE t2 (id INT);", "CREATE TABLE t3 (id INT);"},
+		mockStorage.ExecutedSQL())
+}
+
+func TestUpRangeRejectsInvertedRange(t *testing.T) {
+	dir := writeRangeMigrations(t)
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+
+	err := app.UpRange(dir, 3, 2)
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	assert.Empty(t, mockStorage.ExecutedSQL())
+}
+
+func TestUpRangeRejectsVersionMissingOnDisk(t *testing.T) {
+	dir := writeRangeMigrations(t)
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+
+	err := app.UpRange(dir, 2, 7)
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+	assert.Empty(t, mockStorage.ExecutedSQL())
+}
+
+func TestUpRangeRequiresAllowMissingForUnappliedVersions(t *testing.T) {
+	dir := writeRangeMigrations(t)
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+
+	err := app.UpRange(dir, 3, 4)
+	assert.ErrorIs(t, err, ErrRangeGap)
+	assert.Contains(t, err.Error(), "1, 2")
+	assert.Empty(t, mockStorage.ExecutedSQL())
+}
+
+func TestUpCommandUsesRange(t *testing.T) {
+	dir := writeRangeMigrations(t)
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+	app.AllowMissing = true
+
+	cmd, ok := LookupCommand("up")
+	require.True(t, ok)
+	require.NoError(t, cmd.Run(app, CommandArgs{Path: dir, From: 4, To: 4}))
+	assert.Equal(t, []string{"CREATE TABLE t4 (id INT);"}, mockStorage.ExecutedSQL())
+}
+
+func TestUpSkipTranslatesVersionsToPositions(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, 10, "create_t1", "CREATE TABLE t1 (id INT);", "DROP TABLE t1;")
+	writeMigration(t, dir, 20, "create_t2", "CREATE TABLE t2 (id INT);", "DROP TABLE t2;")
+	writeMigration(t, dir, 30, "create_t3", "CREATE TABLE t3 (id INT);", "DROP TABLE t3;")
+	mockStorage := storage.NewMockSQLStorage()
+	app := New(logger.New(), mockStorage)
+	app.Options.SkipVersions = []int{20, 2}
+
+	require.NoError(t, app.Up(dir))
+	assert.Equal(t, []string{"CREATE TABLE t1 (id INT);", "CREATE TABLE t3 (id INT);"}, mockStorage.ExecutedSQL(),
+		"-skip 20 skips the file version, and 2 matches no file")
+
+	applied, err := mockStorage.SelectAppliedVersions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess, 2: storage.StatusSkipped, 3: storage.StatusSuccess}, applied)
+}
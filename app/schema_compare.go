@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Edestus789/sql-migrator/processes"
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+var (
+	ErrGoldenRequired  = errors.New("golden schema snapshot must be provided with -schema-compare-to")
+	ErrScratchRequired = errors.New("scratch database must be provided with -scratch-dsn")
+)
+
+// SchemaCompare применяет все миграции из filePath к одноразовой базе
+// scratch и сравнивает получившуюся схему с эталонным снимком goldenPath
+// (в формате schema-dump), выводя расхождения в out. Если схемы различаются,
+// возвращается processes.ErrSchemaDrift: набор миграций и эталон разошлись.
+// Рабочая база приложения при этом не используется.
+func (app *Application) SchemaCompare(filePath, goldenPath string, scratch storage.SQLStorage, out io.Writer) error {
+	if goldenPath == "" {
+		return ErrGoldenRequired
+	}
+	if scratch == nil {
+		return ErrScratchRequired
+	}
+	golden, err := app.readSchemaSnapshot(goldenPath)
+	if err != nil {
+		return err
+	}
+
+	scratchApp := New(app.logger, scratch)
+	scratchApp.Options = app.Options
+	return scratchApp.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		if err := migrator.Up(ctx); err != nil {
+			return err
+		}
+		changes, driftErr := migrator.ReportDrift(ctx, golden)
+		for _, change := range changes {
+			if _, err := fmt.Fprintln(out, change); err != nil {
+				return err
+			}
+		}
+		return driftErr
+	})
+}
+
+// readSchemaSnapshot читает снимок схемы, записанный schema-dump.
+func (app *Application) readSchemaSnapshot(path string) (storage.SchemaSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		app.logger.Error("Failed to read expected schema: %v", err)
+		return storage.SchemaSnapshot{}, err
+	}
+	var snapshot storage.SchemaSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		app.logger.Error("Failed to parse expected schema %s: %v", path, err)
+		return storage.SchemaSnapshot{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return snapshot, nil
+}
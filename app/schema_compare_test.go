@@ -0,0 +1,57 @@
+package app
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/processes"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaCompareAppliesToScratchDatabase(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id int NOT NULL, email varchar(255) NOT NULL);", "DROP TABLE users;")
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id int NOT NULL, user_id int NOT NULL);", "DROP TABLE orders;")
+
+	target := storage.NewMockSQLStorage()
+	scratch := storage.NewMockSQLStorage()
+	scratch.SetSchemaSnapshot(storage.SchemaSnapshot{Tables: map[string]map[string]string{
+		"users":  {"id": "integer NOT NULL", "email": "character varying(255) NOT NULL"},
+		"orders": {"id": "integer NOT NULL", "user_id": "integer NOT NULL"},
+	}})
+	app := New(logger.New(), target)
+
+	var out bytes.Buffer
+	require.NoError(t, app.SchemaCompare(migrationDir, filepath.Join("testdata", "expected_schema.json"), scratch, &out))
+	assert.Empty(t, out.String())
+	assert.Len(t, scratch.ExecutedSQL(), 2)
+	assert.Empty(t, target.ExecutedSQL())
+}
+
+func TestSchemaCompareReportsDifferences(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id int NOT NULL, email text NOT NULL);", "DROP TABLE users;")
+
+	scratch := storage.NewMockSQLStorage()
+	scratch.SetSchemaSnapshot(storage.SchemaSnapshot{Tables: map[string]map[string]string{
+		"users": {"id": "integer NOT NULL", "email": "text NOT NULL"},
+	}})
+	app := New(logger.New(), storage.NewMockSQLStorage())
+
+	var out bytes.Buffer
+	err := app.SchemaCompare(migrationDir, filepath.Join("testdata", "expected_schema.json"), scratch, &out)
+	assert.ErrorIs(t, err, processes.ErrSchemaDrift)
+	assert.Equal(t, "removed table orders\n"+
+		"changed column users.email: character varying(255) NOT NULL -> text NOT NULL\n", out.String())
+}
+
+func TestSchemaCompareRequiresGoldenAndScratch(t *testing.T) {
+	app := New(logger.New(), storage.NewMockSQLStorage())
+
+	assert.ErrorIs(t, app.SchemaCompare(t.TempDir(), "", storage.NewMockSQLStorage(), nil), ErrGoldenRequired)
+	assert.ErrorIs(t, app.SchemaCompare(t.TempDir(), "golden.json", nil, nil), ErrScratchRequired)
+}
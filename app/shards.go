@@ -0,0 +1,83 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/processes"
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+// Shard описывает одну из баз данных, участвующих в многобазовом запуске.
+// Open создаёт хранилище базы, пишущее в журнал l.
+type Shard struct {
+	ID   string
+	Open func(l logger.Logger) storage.SQLStorage
+}
+
+// ShardResult содержит результат выполнения команды на одной базе данных.
+type ShardResult struct {
+	ID  string
+	Err error
+}
+
+// RunShards выполняет command для каждой базы данных, обрабатывая не более
+// parallel баз одновременно. Каждая база использует собственное подключение
+// и собственную блокировку, а сообщения лога — и мигратора, и хранилища —
+// помечаются идентификатором базы.
+// Возвращаемая ошибка объединяет ошибки всех неуспешных баз; ErrNoOp базы
+// ошибкой не считается и возвращается, только если ничего не изменилось ни
+// в одной базе.
+func RunShards(
+	l logger.Logger,
+	shards []Shard,
+	opts processes.Options,
+	parallel int,
+	command func(*Application) error,
+) ([]ShardResult, error) {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]ShardResult, len(shards))
+	sem := make(chan struct{}, parallel)
+
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard Shard) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			shardLogger := logger.WithPrefix(l, "["+shard.ID+"] ")
+			application := New(shardLogger, shard.Open(shardLogger))
+			application.Options = opts
+			results[i] = ShardResult{ID: shard.ID, Err: command(application)}
+		}(i, shard)
+	}
+	wg.Wait()
+
+	var errs []error
+	noOps := 0
+	for _, result := range results {
+		if errors.Is(result.Err, ErrNoOp) {
+			noOps++
+			l.Info("[%s] Done, nothing changed", result.ID)
+		} else if result.Err != nil {
+			l.Error("[%s] Failed: %v", result.ID, result.Err)
+			errs = append(errs, fmt.Errorf("%s: %w", result.ID, result.Err))
+		} else {
+			l.Info("[%s] Done", result.ID)
+		}
+	}
+
+	// Без изменений завершился весь запуск, только если ни одна база не изменилась.
+	if len(errs) == 0 && noOps > 0 && noOps == len(results) {
+		return results, ErrNoOp
+	}
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/processes"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errConnectRefused = errors.New("connection refused")
+
+// concurrencyStorage считает, сколько баз одновременно находятся в работе.
+type concurrencyStorage struct {
+	*storage.MockSQLStorage
+	active    *int32
+	maxActive *int32
+	mu        *sync.Mutex
+	fail      bool
+}
+
+func (s *concurrencyStorage) Connect(ctx context.Context) error {
+	if s.fail {
+		return errConnectRefused
+	}
+
+	current := atomic.AddInt32(s.active, 1)
+	s.mu.Lock()
+	if current > *s.maxActive {
+		*s.maxActive = current
+	}
+	s.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+	return s.MockSQLStorage.Connect(ctx)
+}
+
+func (s *concurrencyStorage) Close() error {
+	atomic.AddInt32(s.active, -1)
+	return s.MockSQLStorage.Close()
+}
+
+// openStorage возвращает Open шарда, отдающий готовое хранилище.
+func openStorage(st storage.SQLStorage) func(logger.Logger) storage.SQLStorage {
+	return func(logger.Logger) storage.SQLStorage { return st }
+}
+
+func writeMigration(t *testing.T, dir string, version int, name, up, down string) {
+	t.Helper()
+
+	upFile := filepath.Join(dir, fmt.Sprintf("%05d_%s_up.sql", version, name))
+	downFile := filepath.Join(dir, fmt.Sprintf("%05d_%s_down.sql", version, name))
+	require.NoError(t, os.WriteFile(upFile, []byte(up), 0o600))
+	require.NoError(t, os.WriteFile(downFile, []byte(down), 0o600))
+}
+
+func TestRunShardsInParallel(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, 1, "create_users", "CREATE TABLE users (id INT);", "DROP TABLE users;")
+
+	var (
+		active    int32
+		maxActive int32
+		mu        sync.Mutex
+	)
+
+	mocks := make([]*storage.MockSQLStorage, 5)
+	shards := make([]Shard, 0, len(mocks))
+	for i := range mocks {
+		mocks[i] = storage.NewMockSQLStorage()
+		shards = append(shards, Shard{
+			ID: fmt.Sprintf("shard-%d", i+1),
+			Open: openStorage(&concurrencyStorage{
+				MockSQLStorage: mocks[i],
+				active:         &active,
+				maxActive:      &maxActive,
+				mu:             &mu,
+			}),
+		})
+	}
+
+	results, err := RunShards(logger.New(), shards, processes.Options{}, 2, func(application *Application) error {
+		return application.Up(dir)
+	})
+	require.NoError(t, err)
+	require.Len(t, results, len(shards))
+
+	assert.LessOrEqual(t, maxActive, int32(2), "no more than 2 databases should be migrated at once")
+	assert.Equal(t, int32(2), maxActive, "databases should be migrated concurrently")
+
+	for i, mock := range mocks {
+		assert.Equal(t, shards[i].ID, results[i].ID)
+		assert.NoError(t, results[i].Err)
+		assert.Equal(t, []string{"CREATE TABLE users (id INT);"}, mock.ExecutedSQL())
+	}
+}
+
+func TestRunShardsReportsPerShardErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, 1, "create_users", "CREATE TABLE users (id INT);", "DROP TABLE users;")
+
+	var (
+		active    int32
+		maxActive int32
+		mu        sync.Mutex
+	)
+
+	shards := make([]Shard, 0, 3)
+	for i := 1; i <= 3; i++ {
+		shards = append(shards, Shard{
+			ID: fmt.Sprintf("shard-%d", i),
+			Open: openStorage(&concurrencyStorage{
+				MockSQLStorage: storage.NewMockSQLStorage(),
+				active:         &active,
+				maxActive:      &maxActive,
+				mu:             &mu,
+				fail:           i == 2,
+			}),
+		})
+	}
+
+	results, err := RunShards(logger.New(), shards, processes.Options{}, 3, func(application *Application) error {
+		return application.Up(dir)
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errConnectRefused)
+	assert.Contains(t, err.Error(), "shard-2")
+	assert.NotContains(t, err.Error(), "shard-1")
+
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, errConnectRefused)
+	assert.NoError(t, results[2].Err)
+}
+
+func TestRunShardsNoOpOnlyWhenNothingChanged(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, 1, "create_users", "CREATE TABLE users (id INT);", "DROP TABLE users;")
+
+	upToDate := storage.NewMockSQLStorage()
+	require.NoError(t, New(logger.New(), upToDate).Up(dir))
+	run := func(application *Application) error {
+		application.ReportNoOp = true
+		return application.Up(dir)
+	}
+
+	shards := []Shard{{ID: "shard-1", Open: openStorage(upToDate)}, {ID: "shard-2", Open: openStorage(storage.NewMockSQLStorage())}}
+	_, err := RunShards(logger.New(), shards, processes.Options{}, 1, run)
+	assert.NoError(t, err)
+
+	_, err = RunShards(logger.New(), shards, processes.Options{}, 1, run)
+	assert.ErrorIs(t, err, ErrNoOp)
+}
+
+func TestRunShardsPrefixesStorageLog(t *testing.T) {
+	recorder := &infoRecorder{ZeroLogger: logger.New()}
+	shards := []Shard{{ID: "shard-1", Open: func(l logger.Logger) storage.SQLStorage {
+		l.Info("Connecting to the database")
+		return storage.NewMockSQLStorage()
+	}}}
+
+	_, err := RunShards(recorder, shards, processes.Options{}, 1, func(*Application) error { return nil })
+	require.NoError(t, err)
+	assert.Contains(t, recorder.infos, "[shard-1] Connecting to the database")
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+var ErrGoMigrationSource = errors.New("go migrations can only be loaded from a directory on disk")
+
+// FileEntry — файл источника миграций.
+type FileEntry struct {
+	Name  string
+	IsDir bool
+}
+
+// Source — откуда загружаются файлы миграций: директория на диске,
+// встроенная файловая система или другое хранилище. List возвращает
+// файлы без исключённых .migratorignore, Read — содержимое файла по имени
+// из List.
+type Source interface {
+	List() ([]FileEntry, error)
+	Read(name string) ([]byte, error)
+}
+
+// DirSource — директория миграций на диске.
+type DirSource string
+
+func (s DirSource) List() ([]FileEntry, error) {
+	entries, err := readMigrationDir(string(s))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]FileEntry, 0, len(entries))
+	for _, entry := range entries {
+		files = append(files, FileEntry{Name: entry.Name(), IsDir: entry.IsDir()})
+	}
+	return files, nil
+}
+
+func (s DirSource) Read(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(string(s), name))
+}
+
+// FSSource — директория Dir в файловой системе FS, например в embed.FS.
+// Go-миграции из неё не загружаются: их нужно собрать из файла на диске.
+type FSSource struct {
+	FS  fs.FS
+	Dir string
+}
+
+func (s FSSource) List() ([]FileEntry, error) {
+	dir := s.dir()
+	entries, err := fs.ReadDir(s.FS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []ignoreRule
+	data, err := fs.ReadFile(s.FS, path.Join(dir, ignoreFileName))
+	switch {
+	case err == nil:
+		if rules, err = parseIgnoreRules(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+
+	var files []FileEntry
+	for _, entry := range entries {
+		if entry.Name() == ignoreFileName || ignored(rules, entry.Name(), entry.IsDir()) {
+			continue
+		}
+		files = append(files, FileEntry{Name: entry.Name(), IsDir: entry.IsDir()})
+	}
+	return files, nil
+}
+
+func (s FSSource) Read(name string) ([]byte, error) {
+	return fs.ReadFile(s.FS, path.Join(s.dir(), name))
+}
+
+func (s FSSource) dir() string {
+	if s.Dir == "" {
+		return "."
+	}
+	return s.Dir
+}
+
+// sourcePath возвращает путь к файлу name источника для SourceFile миграции.
+func sourcePath(source Source, name string) string {
+	switch s := source.(type) {
+	case DirSource:
+		return path.Join(string(s), name)
+	case FSSource:
+		return path.Join(s.Dir, name)
+	default:
+		return name
+	}
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySource — источник миграций в памяти.
+type memorySource map[string]string
+
+func (s memorySource) List() ([]FileEntry, error) {
+	files := make([]FileEntry, 0, len(s))
+	for name := range s {
+		files = append(files, FileEntry{Name: name})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+func (s memorySource) Read(name string) ([]byte, error) {
+	data, ok := s[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return []byte(data), nil
+}
+
+var sourceFiles = map[string]string{
+	"00001_create_users_up.sql":    "CREATE TABLE users (id serial);",
+	"00001_create_users_down.sql":  "DROP TABLE users;",
+	"00002_create_orders_up.sql":   "\xEF\xBB\xBFCREATE TABLE orders (id serial);",
+	"00002_create_orders_down.sql": "DROP TABLE orders;",
+}
+
+// withoutSourceFiles убирает пути к файлам, которые у источников различаются.
+func withoutSourceFiles(migrations map[int]*storage.Migration) map[int]storage.Migration {
+	result := make(map[int]storage.Migration, len(migrations))
+	for version, migration := range migrations {
+		copied := *migration
+		copied.SourceFile = ""
+		result[version] = copied
+	}
+	return result
+}
+
+func TestDirSource(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range sourceFiles {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("docs"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ignoreFileName), []byte("*.md\n"), 0o600))
+
+	source := DirSource(dir)
+	files, err := source.List()
+	require.NoError(t, err)
+	assert.Len(t, files, 4)
+
+	data, err := source.Read("00001_create_users_up.sql")
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE users (id serial);", string(data))
+
+	migrations, err := getMigrations(source)
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, filepath.Join(dir, "00002_create_orders_up.sql"), migrations[2].SourceFile)
+	assert.Equal(t, "CREATE TABLE orders (id serial);", migrations[2].Up)
+}
+
+func TestSourcesProduceIdenticalMigrations(t *testing.T) {
+	dir := t.TempDir()
+	mapFS := fstest.MapFS{}
+	for name, data := range sourceFiles {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600))
+		mapFS["migrations/"+name] = &fstest.MapFile{Data: []byte(data)}
+	}
+	mapFS["migrations/notes.txt"] = &fstest.MapFile{Data: []byte("not a migration")}
+	mapFS["migrations/"+ignoreFileName] = &fstest.MapFile{Data: []byte("*.txt\n")}
+
+	fromDir, err := getMigrations(DirSource(dir))
+	require.NoError(t, err)
+	fromMemory, err := getMigrations(memorySource(sourceFiles))
+	require.NoError(t, err)
+	fromFS, err := getMigrations(FSSource{FS: mapFS, Dir: "migrations"})
+	require.NoError(t, err)
+
+	require.Len(t, fromDir, 2)
+	assert.Equal(t, withoutSourceFiles(fromDir), withoutSourceFiles(fromMemory))
+	assert.Equal(t, withoutSourceFiles(fromDir), withoutSourceFiles(fromFS))
+	assert.Equal(t, "migrations/00001_create_users_up.sql", fromFS[1].SourceFile)
+}
+
+func TestGoMigrationsRequireDirSource(t *testing.T) {
+	source := memorySource{"00001_seed_users_up.go": "package main"}
+	_, err := getMigrations(source)
+	assert.ErrorIs(t, err, ErrGoMigrationSource)
+}
+
+func TestMemorySourceRejectsInvalidEncoding(t *testing.T) {
+	source := memorySource{"00001_create_users_up.sql": "SELECT '\xFF';"}
+	_, err := getMigrations(source)
+	assert.ErrorIs(t, err, ErrInvalidEncoding)
+}
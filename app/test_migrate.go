@@ -0,0 +1,144 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/config"
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/processes"
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+// testDatabasePrefix — префикс имён временных баз test-migrate. Удалить
+// разрешено только базу с этим префиксом, поэтому ошибка в имени не может
+// привести к удалению рабочей базы.
+const testDatabasePrefix = "migrator_test_"
+
+// maintenanceDatabase — сервисная база, через которую создаются и
+// удаляются временные базы.
+const maintenanceDatabase = "postgres"
+
+var (
+	ErrUnsafeDrop     = errors.New("refusing to drop a database that test-migrate did not create")
+	ErrNoTestDatabase = errors.New("test-migrate needs a single database connection string")
+)
+
+// AdminExecutor создаёт и удаляет базы данных на сервере.
+type AdminExecutor interface {
+	CreateDatabase(ctx context.Context, name, template string) error
+	DropDatabase(ctx context.Context, name string) error
+	Close() error
+}
+
+// TestDatabase описывает подключения, нужные test-migrate.
+type TestDatabase struct {
+	// Admin создаёт и удаляет временную базу.
+	Admin AdminExecutor
+	// Template — база, копией которой создаётся временная.
+	Template string
+	// Open возвращает хранилище для базы name на том же сервере.
+	Open func(name string) (storage.SQLStorage, error)
+}
+
+// NewPostgresTestDatabase готовит TestDatabase для сервера из dsn: шаблоном
+// служит база из dsn, а временные базы открываются через open с тем же
+// dsn, в котором заменено имя базы.
+func NewPostgresTestDatabase(dsn string, l logger.Logger, open func(dsn string) storage.SQLStorage) (TestDatabase, error) {
+	parsed, err := config.ParseDSN(dsn)
+	if err != nil {
+		return TestDatabase{}, err
+	}
+	adminDSN, err := config.WithDatabase(dsn, maintenanceDatabase)
+	if err != nil {
+		return TestDatabase{}, err
+	}
+	return TestDatabase{
+		Admin:    storage.NewAdminConn(adminDSN, l),
+		Template: parsed.Database,
+		Open: func(name string) (storage.SQLStorage, error) {
+			databaseDSN, err := config.WithDatabase(dsn, name)
+			if err != nil {
+				return nil, err
+			}
+			return open(databaseDSN), nil
+		},
+	}, nil
+}
+
+// TestMigrate проверяет миграции на временной копии базы: создаёт её через
+// CREATE DATABASE ... TEMPLATE, применяет ожидающие миграции, откатывает их
+// до исходной версии, применяет снова и удаляет копию. Рабочая база не
+// изменяется, но Postgres копирует шаблон, только если к нему нет других
+// подключений. Итог каждого шага выводится в out.
+func (app *Application) TestMigrate(filePath string, db TestDatabase, out io.Writer) error {
+	if db.Admin == nil || db.Open == nil || db.Template == "" {
+		return ErrNoTestDatabase
+	}
+	ctx := context.Background()
+	defer db.Admin.Close()
+
+	name := fmt.Sprintf("%s%d", testDatabasePrefix, time.Now().UnixNano())
+	if err := db.Admin.CreateDatabase(ctx, name, db.Template); err != nil {
+		app.logger.Error("Failed to create temporary database: %v", err)
+		return err
+	}
+	fmt.Fprintf(out, "created temporary database %s from %s\n", name, db.Template)
+
+	runErr := app.testMigrate(filePath, db, name, out)
+	if err := app.dropTestDatabase(ctx, db, name); err != nil {
+		return errors.Join(runErr, err)
+	}
+	fmt.Fprintf(out, "dropped temporary database %s\n", name)
+	return runErr
+}
+
+func (app *Application) testMigrate(filePath string, db TestDatabase, name string, out io.Writer) error {
+	scratch, err := db.Open(name)
+	if err != nil {
+		return err
+	}
+	scratchApp := New(app.logger, scratch)
+	scratchApp.Options = app.Options
+	return scratchApp.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		start, err := migrator.CurrentVersion(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := migrator.Up(ctx); err != nil {
+			return fmt.Errorf("up: %w", err)
+		}
+		applied := len(migrator.Result().Applied)
+		fmt.Fprintf(out, "up: applied %d migrations\n", applied)
+
+		if err := migrator.DownTo(ctx, start); err != nil {
+			return fmt.Errorf("down to %d: %w", start, err)
+		}
+		fmt.Fprintf(out, "down: rolled back %d migrations to version %d\n", len(migrator.Result().RolledBack), start)
+
+		if err := migrator.Up(ctx); err != nil {
+			return fmt.Errorf("up again: %w", err)
+		}
+		fmt.Fprintf(out, "up again: applied %d migrations\n", len(migrator.Result().Applied)-applied)
+		return nil
+	})
+}
+
+// dropTestDatabase удаляет временную базу, отказываясь удалять базу-шаблон
+// и любую базу без префикса testDatabasePrefix.
+func (app *Application) dropTestDatabase(ctx context.Context, db TestDatabase, name string) error {
+	if name == db.Template || !strings.HasPrefix(name, testDatabasePrefix) {
+		app.logger.Error("Refusing to drop database %s", name)
+		return fmt.Errorf("%w: %s", ErrUnsafeDrop, name)
+	}
+	if err := db.Admin.DropDatabase(ctx, name); err != nil {
+		app.logger.Error("Failed to drop temporary database %s: %v", name, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAdmin записывает команды создания и удаления баз.
+type fakeAdmin struct {
+	calls     []string
+	createErr error
+	closed    bool
+}
+
+func (a *fakeAdmin) CreateDatabase(_ context.Context, name, template string) error {
+	a.calls = append(a.calls, "create "+name+" from "+template)
+	return a.createErr
+}
+
+func (a *fakeAdmin) DropDatabase(_ context.Context, name string) error {
+	a.calls = append(a.calls, "drop "+name)
+	return nil
+}
+
+func (a *fakeAdmin) Close() error {
+	a.closed = true
+	return nil
+}
+
+// failingMigrateStorage отклоняет любой SQL миграции.
+type failingMigrateStorage struct {
+	*storage.MockSQLStorage
+}
+
+func (failingMigrateStorage) Migrate(context.Context, string) (int64, error) {
+	return 0, errors.New("syntax error")
+}
+
+func newFakeTestDatabase(admin *fakeAdmin, scratch storage.SQLStorage, opened *[]string) TestDatabase {
+	return TestDatabase{
+		Admin:    admin,
+		Template: "app",
+		Open: func(name string) (storage.SQLStorage, error) {
+			*opened = append(*opened, name)
+			return scratch, nil
+		},
+	}
+}
+
+func TestTestMigrateRunsCycleOnTemporaryDatabase(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+	writeMigration(t, migrationDir, 2, "create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;")
+
+	target := storage.NewMockSQLStorage()
+	scratch := storage.NewMockSQLStorage()
+	admin := &fakeAdmin{}
+	var opened []string
+	app := New(logger.New(), target)
+
+	var out bytes.Buffer
+	require.NoError(t, app.TestMigrate(migrationDir, newFakeTestDatabase(admin, scratch, &opened), &out))
+
+	require.Len(t, opened, 1)
+	name := opened[0]
+	assert.True(t, strings.HasPrefix(name, testDatabasePrefix))
+	assert.Equal(t, []string{"create " + name + " from app", "drop " + name}, admin.calls)
+	assert.True(t, admin.closed)
+	assert.Equal(t, []string{
+		"CREATE TABLE users (id serial);", "CREATE TABLE orders (id serial);",
+		"DROP TABLE orders;", "DROP TABLE users;",
+		"CREATE TABLE users (id serial);", "CREATE TABLE orders (id serial);",
+	}, scratch.ExecutedSQL())
+	assert.Empty(t, target.ExecutedSQL())
+	assert.Contains(t, out.String(), "up: applied 2 migrations\ndown: rolled back 2 migrations to version 0\nup again: applied 2 migrations\n")
+}
+
+func TestTestMigrateDropsDatabaseAfterFailure(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigration(t, migrationDir, 1, "create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;")
+
+	scratch := failingMigrateStorage{storage.NewMockSQLStorage()}
+	admin := &fakeAdmin{}
+	var opened []string
+	app := New(logger.New(), storage.NewMockSQLStorage())
+
+	err := app.TestMigrate(migrationDir, newFakeTestDatabase(admin, scratch, &opened), &bytes.Buffer{})
+	assert.Error(t, err)
+	require.Len(t, admin.calls, 2)
+	assert.Equal(t, "drop "+opened[0], admin.calls[1])
+}
+
+func TestTestMigrateStopsWhenCreateFails(t *testing.T) {
+	admin := &fakeAdmin{createErr: errors.New("source database is being accessed by other users")}
+	var opened []string
+	app := New(logger.New(), storage.NewMockSQLStorage())
+
+	err := app.TestMigrate(t.TempDir(), newFakeTestDatabase(admin, storage.NewMockSQLStorage(), &opened), &bytes.Buffer{})
+	assert.ErrorIs(t, err, admin.createErr)
+	assert.Len(t, admin.calls, 1)
+	assert.Empty(t, opened)
+}
+
+func TestDropTestDatabaseRefusesForeignDatabases(t *testing.T) {
+	admin := &fakeAdmin{}
+	var opened []string
+	db := newFakeTestDatabase(admin, storage.NewMockSQLStorage(), &opened)
+	app := New(logger.New(), storage.NewMockSQLStorage())
+
+	for _, name := range []string{"app", "production", "test_app"} {
+		assert.ErrorIs(t, app.dropTestDatabase(context.Background(), db, name), ErrUnsafeDrop)
+	}
+	db.Template = testDatabasePrefix + "1"
+	assert.ErrorIs(t, app.dropTestDatabase(context.Background(), db, db.Template), ErrUnsafeDrop)
+	assert.Empty(t, admin.calls)
+}
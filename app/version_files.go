@@ -0,0 +1,81 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Edestus789/sql-migrator/declarative"
+)
+
+var ErrMalformedMigration = errors.New("malformed migration files")
+
+// versionFiles — файлы одной версии: имя миграции и файл каждой части.
+type versionFiles struct {
+	name  string
+	file  string
+	parts map[string]string
+}
+
+// fileParts возвращает части миграции, которые задаёт файл. Декларативное
+// описание задаёт и up, и down.
+func fileParts(fileName string) []string {
+	switch {
+	case regGetUpMigration.MatchString(fileName):
+		return []string{"up SQL"}
+	case regGetDownMigration.MatchString(fileName):
+		return []string{"down SQL"}
+	case regGetUpGoMigration.MatchString(fileName):
+		return []string{"up Go"}
+	case regGetDownGoMigration.MatchString(fileName):
+		return []string{"down Go"}
+	case declarative.IsSpecFile(fileName):
+		return []string{"up SQL", "down SQL"}
+	default:
+		return nil
+	}
+}
+
+// checkVersionFiles проверяет, что файлы каждой версии согласованы: у всех
+// одно имя миграции, каждая часть задана не более чем одним файлом и есть
+// хотя бы одна часть up. Ошибка называет файл и проблему.
+func checkVersionFiles(files []FileEntry) error {
+	versions := make(map[int]*versionFiles)
+	var order []int
+	for _, file := range files {
+		version, name, err := parseFileName(file.Name)
+		if err != nil {
+			return err
+		}
+		parts := fileParts(file.Name)
+		if len(parts) == 0 {
+			return ErrInvalidMigrationName
+		}
+
+		seen, ok := versions[version]
+		if !ok {
+			seen = &versionFiles{name: name, file: file.Name, parts: make(map[string]string)}
+			versions[version] = seen
+			order = append(order, version)
+		}
+		if name != seen.name {
+			return fmt.Errorf("%w: %s: version %d is named %q here but %q in %s",
+				ErrMalformedMigration, file.Name, version, name, seen.name, seen.file)
+		}
+		for _, part := range parts {
+			if other, ok := seen.parts[part]; ok {
+				return fmt.Errorf("%w: %s: duplicate %s section of version %d, already defined in %s",
+					ErrMalformedMigration, file.Name, part, version, other)
+			}
+			seen.parts[part] = file.Name
+		}
+	}
+
+	for _, version := range order {
+		seen := versions[version]
+		if seen.parts["up SQL"] == "" && seen.parts["up Go"] == "" {
+			return fmt.Errorf("%w: %s: version %d has no up section",
+				ErrMalformedMigration, seen.file, version)
+		}
+	}
+	return nil
+}
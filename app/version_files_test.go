@@ -0,0 +1,59 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckVersionFilesAcceptsConsistentVersions(t *testing.T) {
+	migrations, err := getMigrations(memorySource{
+		"00001_create_users_up.sql":   "CREATE TABLE users (id serial);",
+		"00001_create_users_down.sql": "DROP TABLE users;",
+		"00002_seed_users_up.sql":     "INSERT INTO users DEFAULT VALUES;",
+	})
+	require.NoError(t, err)
+	assert.Len(t, migrations, 2)
+}
+
+func TestCheckVersionFilesMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  memorySource
+		problem string
+	}{
+		{
+			name: "missing up section",
+			source: memorySource{
+				"00001_create_users_up.sql":    "CREATE TABLE users (id serial);",
+				"00002_create_orders_down.sql": "DROP TABLE orders;",
+			},
+			problem: "00002_create_orders_down.sql: version 2 has no up section",
+		},
+		{
+			name: "duplicate section",
+			source: memorySource{
+				"00001_create_users_up.sql": "CREATE TABLE users (id serial);",
+				"00001_create_users.yaml":   "table: users",
+			},
+			problem: "duplicate up SQL section of version 1",
+		},
+		{
+			name: "version mismatch",
+			source: memorySource{
+				"00001_create_users_up.sql":  "CREATE TABLE users (id serial);",
+				"00001_create_user_down.sql": "DROP TABLE users;",
+			},
+			problem: `00001_create_users_up.sql: version 1 is named "create_users" here but "create_user" in 00001_create_user_down.sql`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := getMigrations(test.source)
+			assert.ErrorIs(t, err, ErrMalformedMigration)
+			assert.ErrorContains(t, err, test.problem)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/app"
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/processes"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnlyAppliesToEveryShard(t *testing.T) {
+	readOnly = true
+	defer func() { readOnly = false }()
+
+	shards := []app.Shard{
+		{ID: "shard-1", Open: func(logger.Logger) storage.SQLStorage { return storage.NewMockSQLStorage() }},
+		{ID: "shard-2", Open: func(logger.Logger) storage.SQLStorage { return storage.NewMockSQLStorage() }},
+	}
+	dir := t.TempDir()
+	results, err := app.RunShards(logger.New(), shards, processes.Options{}, 2, func(application *app.Application) error {
+		prepareApplication(application, nil, nil)
+		return application.Create("add_users", dir, "sql")
+	})
+	assert.ErrorIs(t, err, app.ErrReadOnly)
+	for _, result := range results {
+		assert.ErrorIs(t, result.Err, app.ErrReadOnly, result.ID)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Empty(t, files)
+}
@@ -1,39 +1,105 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/viper"
 )
 
+// DefaultPath — путь к файлу конфигурации, который используется, если -config не задан.
+const DefaultPath = "config.yaml"
+
 type Config struct {
-	MigratorOpt *Migrator
-	LoggerOpt   *Logger
+	MigratorOpt *Migrator `mapstructure:"migrator"`
+	LoggerOpt   *Logger   `mapstructure:"logger"`
 }
 
 type Migrator struct {
-	DSN       string
-	Dir       string
-	Type      string
-	TableName string
+	DSN       string `mapstructure:"dsn"`
+	Dir       string `mapstructure:"dir"`
+	Type      string `mapstructure:"type"`
+	TableName string `mapstructure:"table_name"`
 }
 
 type Logger struct {
-	Level string
+	Level string `mapstructure:"level"`
 }
 
+// LoadConfig читает конфигурацию из одного или нескольких файлов, перечисленных
+// через запятую. Каждый следующий файл переопределяет ключи предыдущих,
+// а отсутствующие в нём ключи берутся из более ранних файлов.
 func LoadConfig(configPath string) (*Config, error) {
-	viper.SetConfigFile(configPath)
+	v := viper.New()
+
+	for i, path := range splitConfigPaths(configPath) {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("error reading config file %s: %w", path, err)
+		}
 
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("error reading config file: %w", err)
+		// Без явного типа MergeInConfig не определяет формат по расширению.
+		v.SetConfigFile(path)
+		v.SetConfigType(strings.TrimPrefix(filepath.Ext(path), "."))
+
+		read := v.MergeInConfig
+		if i == 0 {
+			read = v.ReadInConfig
+		}
+		if err := read(); err != nil {
+			return nil, fmt.Errorf("error reading config file %s: %w", path, err)
+		}
 	}
 
 	var config Config
 
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := v.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
-	return &config, nil
+	return withDefaults(&config), nil
+}
+
+// Load читает конфигурацию так же, как LoadConfig. Если required == false
+// и какого-либо из файлов нет, возвращается пустая конфигурация: все значения
+// тогда берутся из флагов и переменных окружения. Ошибки разбора существующего
+// файла возвращаются всегда.
+func Load(configPath string, required bool) (*Config, error) {
+	config, err := LoadConfig(configPath)
+	if err != nil && !required && errors.Is(err, fs.ErrNotExist) {
+		return withDefaults(&Config{}), nil
+	}
+	return config, err
+}
+
+// Resolve выбирает значение параметра по приоритету: флаг командной строки
+// (с подстановкой переменных окружения), затем значение из конфигурации.
+func Resolve(flagValue, configValue string) string {
+	if flagValue != "" {
+		return os.ExpandEnv(flagValue)
+	}
+	return configValue
+}
+
+func withDefaults(config *Config) *Config {
+	if config.MigratorOpt == nil {
+		config.MigratorOpt = &Migrator{}
+	}
+	if config.LoggerOpt == nil {
+		config.LoggerOpt = &Logger{}
+	}
+	return config
+}
+
+func splitConfigPaths(configPath string) []string {
+	var paths []string
+	for _, path := range strings.Split(configPath, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
 }
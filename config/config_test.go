@@ -0,0 +1,129 @@
+package config
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfigSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "config.yaml", `
+migrator:
+  dsn: postgres://localhost/base
+  dir: ./migrations
+  type: sql
+  table_name: migrations
+logger:
+  level: INFO
+`)
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://localhost/base", config.MigratorOpt.DSN)
+	assert.Equal(t, "migrations", config.MigratorOpt.TableName)
+	assert.Equal(t, "INFO", config.LoggerOpt.Level)
+}
+
+func TestLoadConfigOverlayOverridesBase(t *testing.T) {
+	dir := t.TempDir()
+	base := writeConfig(t, dir, "base.yaml", `
+migrator:
+  dsn: postgres://localhost/base
+  dir: ./migrations
+  type: sql
+logger:
+  level: INFO
+`)
+	prod := writeConfig(t, dir, "prod.yaml", `
+migrator:
+  dsn: postgres://prod/db
+logger:
+  level: WARN
+`)
+
+	config, err := LoadConfig(base + "," + prod)
+	require.NoError(t, err)
+
+	assert.Equal(t, "postgres://prod/db", config.MigratorOpt.DSN)
+	assert.Equal(t, "WARN", config.LoggerOpt.Level)
+	// Ключи, отсутствующие в prod.yaml, берутся из base.yaml.
+	assert.Equal(t, "./migrations", config.MigratorOpt.Dir)
+	assert.Equal(t, "sql", config.MigratorOpt.Type)
+}
+
+func TestLoadConfigLaterFileWins(t *testing.T) {
+	dir := t.TempDir()
+	first := writeConfig(t, dir, "a.yaml", "migrator:\n  dir: ./a\n")
+	second := writeConfig(t, dir, "b.yaml", "migrator:\n  dir: ./b\n")
+
+	config, err := LoadConfig(first + ", " + second)
+	require.NoError(t, err)
+	assert.Equal(t, "./b", config.MigratorOpt.Dir)
+
+	config, err = LoadConfig(second + "," + first)
+	require.NoError(t, err)
+	assert.Equal(t, "./a", config.MigratorOpt.Dir)
+}
+
+func TestLoadConfigMissingOverlay(t *testing.T) {
+	dir := t.TempDir()
+	base := writeConfig(t, dir, "base.yaml", "migrator:\n  dir: ./a\n")
+	missing := filepath.Join(dir, "missing.yaml")
+
+	_, err := LoadConfig(base + "," + missing)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Contains(t, err.Error(), missing)
+}
+
+func TestLoadMissingConfigWithoutRequire(t *testing.T) {
+	config, err := Load(filepath.Join(t.TempDir(), DefaultPath), false)
+	require.NoError(t, err)
+	assert.Equal(t, "", config.MigratorOpt.DSN)
+	assert.Equal(t, "", config.LoggerOpt.Level)
+}
+
+func TestLoadMissingConfigWithRequire(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), DefaultPath), true)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestLoadPresentConfigInBothModes(t *testing.T) {
+	path := writeConfig(t, t.TempDir(), DefaultPath, "migrator:\n  dsn: postgres://localhost/base\n")
+
+	for _, required := range []bool{false, true} {
+		config, err := Load(path, required)
+		require.NoError(t, err)
+		assert.Equal(t, "postgres://localhost/base", config.MigratorOpt.DSN)
+	}
+}
+
+func TestLoadReportsBrokenConfigEvenWithoutRequire(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, DefaultPath)
+	require.NoError(t, os.Mkdir(path, 0o755))
+
+	_, err := Load(path, false)
+	assert.Error(t, err)
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	t.Setenv("MIGRATOR_TEST_HOST", "flag-host")
+
+	assert.Equal(t, "postgres://flag-host/db", Resolve("postgres://${MIGRATOR_TEST_HOST}/db", "postgres://config/db"))
+	assert.Equal(t, "postgres://config/db", Resolve("", "postgres://config/db"))
+	assert.Equal(t, "", Resolve("", ""))
+}
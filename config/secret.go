@@ -0,0 +1,104 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrSecretNotFound      = errors.New("secret not found")
+	ErrUnknownSecretScheme = errors.New("unknown secret scheme")
+)
+
+// SecretResolver получает значение секрета по ссылке без схемы: для
+// "vault://db/orders" резолвер схемы vault получает "db/orders".
+type SecretResolver interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// SecretResolverFunc позволяет использовать функцию как SecretResolver.
+type SecretResolverFunc func(ctx context.Context, ref string) (string, error)
+
+func (f SecretResolverFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	secretResolversMu sync.RWMutex
+	secretResolvers   = map[string]SecretResolver{
+		"env":  SecretResolverFunc(resolveEnvSecret),
+		"file": SecretResolverFunc(resolveFileSecret),
+	}
+)
+
+// RegisterSecretResolver добавляет резолвер для схемы, например aws-sm или
+// vault. Облачные резолверы подключаются сборкой со своим пакетом, который
+// вызывает эту функцию в init; повторная регистрация схемы заменяет резолвер.
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolversMu.Lock()
+	defer secretResolversMu.Unlock()
+	secretResolvers[scheme] = resolver
+}
+
+// SecretSchemes возвращает зарегистрированные схемы в алфавитном порядке.
+func SecretSchemes() []string {
+	secretResolversMu.RLock()
+	defer secretResolversMu.RUnlock()
+	schemes := make([]string, 0, len(secretResolvers))
+	for scheme := range secretResolvers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// ResolveSecret получает секрет по ссылке вида "scheme://ref", например
+// env://DB_DSN или file:///run/secrets/dsn. Концевые пробелы и перевод
+// строки отбрасываются; пустой секрет считается ненайденным.
+func ResolveSecret(ctx context.Context, source string) (string, error) {
+	scheme, ref, ok := strings.Cut(source, "://")
+	if !ok || scheme == "" || ref == "" {
+		return "", fmt.Errorf("%w: %q, expected scheme://reference", ErrUnknownSecretScheme, source)
+	}
+
+	secretResolversMu.RLock()
+	resolver, ok := secretResolvers[scheme]
+	secretResolversMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w %q, available: %s", ErrUnknownSecretScheme, scheme, strings.Join(SecretSchemes(), ", "))
+	}
+
+	value, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("%w: %s is empty", ErrSecretNotFound, source)
+	}
+	return value, nil
+}
+
+func resolveEnvSecret(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrSecretNotFound, name)
+	}
+	return value, nil
+}
+
+func resolveFileSecret(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: file %s does not exist", ErrSecretNotFound, path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading secret file: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSecretFromEnv(t *testing.T) {
+	t.Setenv("MIGRATOR_TEST_DSN", "postgres://app@localhost/orders\n")
+
+	dsn, err := ResolveSecret(context.Background(), "env://MIGRATOR_TEST_DSN")
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://app@localhost/orders", dsn)
+}
+
+func TestResolveSecretFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dsn")
+	require.NoError(t, os.WriteFile(path, []byte("postgres://app@localhost/orders\n"), 0o600))
+
+	dsn, err := ResolveSecret(context.Background(), "file://"+path)
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://app@localhost/orders", dsn)
+}
+
+func TestResolveSecretMissing(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("MIGRATOR_TEST_EMPTY", " ")
+
+	for _, source := range []string{
+		"env://MIGRATOR_TEST_UNSET_DSN",
+		"env://MIGRATOR_TEST_EMPTY",
+		"file://" + filepath.Join(t.TempDir(), "missing"),
+	} {
+		_, err := ResolveSecret(ctx, source)
+		assert.ErrorIs(t, err, ErrSecretNotFound, source)
+	}
+}
+
+func TestResolveSecretUnknownScheme(t *testing.T) {
+	ctx := context.Background()
+	for _, source := range []string{"vault-unknown://db/orders", "DB_DSN", "env://"} {
+		_, err := ResolveSecret(ctx, source)
+		assert.ErrorIs(t, err, ErrUnknownSecretScheme, source)
+	}
+}
+
+func TestRegisterSecretResolver(t *testing.T) {
+	RegisterSecretResolver("vault-test", SecretResolverFunc(func(_ context.Context, ref string) (string, error) {
+		return "postgres://app@vault/" + ref, nil
+	}))
+
+	dsn, err := ResolveSecret(context.Background(), "vault-test://orders")
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://app@vault/orders", dsn)
+	assert.Contains(t, SecretSchemes(), "vault-test")
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidShard = errors.New("invalid shard")
+
+// Shard — значение флага -shard I/N: группа Index из Count, Index от 1.
+type Shard struct {
+	Index int
+	Count int
+}
+
+func (s Shard) String() string {
+	return fmt.Sprintf("%d/%d", s.Index, s.Count)
+}
+
+// ParseShard разбирает значение флага -shard вида "2/5".
+func ParseShard(s string) (Shard, error) {
+	index, count, found := strings.Cut(strings.TrimSpace(s), "/")
+	if !found {
+		return Shard{}, fmt.Errorf("%w: %q, expected I/N", ErrInvalidShard, s)
+	}
+
+	var shard Shard
+	var err1, err2 error
+	shard.Index, err1 = strconv.Atoi(index)
+	shard.Count, err2 = strconv.Atoi(count)
+	if err1 != nil || err2 != nil || shard.Count < 1 || shard.Index < 1 || shard.Index > shard.Count {
+		return Shard{}, fmt.Errorf("%w: %q, expected I/N with 1 <= I <= N", ErrInvalidShard, s)
+	}
+	return shard, nil
+}
+
+// Select возвращает позиции строк подключения, попадающих в группу:
+// хеш FNV-1a строки подключения по модулю Count определяет её группу.
+// Группа зависит только от самой строки, поэтому состав групп не меняется
+// между запусками и при перестановке строк в файле. Позиции идут
+// в исходном порядке.
+func (s Shard) Select(dsns []string) []int {
+	var selected []int
+	for i, dsn := range dsns {
+		if shardBucket(dsn, s.Count) == s.Index-1 {
+			selected = append(selected, i)
+		}
+	}
+	return selected
+}
+
+func shardBucket(dsn string, count int) int {
+	h := fnv.New64a()
+	h.Write([]byte(dsn))
+	return int(h.Sum64() % uint64(count))
+}
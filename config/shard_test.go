@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseShard(t *testing.T) {
+	shard, err := ParseShard("2/5")
+	require.NoError(t, err)
+	assert.Equal(t, Shard{Index: 2, Count: 5}, shard)
+	assert.Equal(t, "2/5", shard.String())
+
+	for _, value := range []string{"", "2", "0/5", "6/5", "1/0", "a/5", "2/b", "-1/3"} {
+		_, err := ParseShard(value)
+		assert.ErrorIs(t, err, ErrInvalidShard, value)
+	}
+}
+
+func tenantDSNs(n int) []string {
+	dsns := make([]string, n)
+	for i := range dsns {
+		dsns[i] = fmt.Sprintf("postgres://app@db%d.example.com:5432/tenant_%d", i%7, i)
+	}
+	return dsns
+}
+
+func TestShardSelectPartitionsDSNs(t *testing.T) {
+	dsns := tenantDSNs(500)
+	const count = 5
+
+	seen := make(map[int]bool)
+	for index := 1; index <= count; index++ {
+		selected := Shard{Index: index, Count: count}.Select(dsns)
+		// Равномерность: каждая группа близка к 1/N списка.
+		assert.InDelta(t, len(dsns)/count, len(selected), 25, fmt.Sprintf("shard %d/%d", index, count))
+		for _, position := range selected {
+			assert.False(t, seen[position], fmt.Sprintf("DSN #%d is in more than one shard", position))
+			seen[position] = true
+		}
+	}
+	assert.Len(t, seen, len(dsns), "Every DSN belongs to exactly one shard")
+}
+
+func TestShardSelectIsStable(t *testing.T) {
+	dsns := tenantDSNs(50)
+	shard := Shard{Index: 3, Count: 4}
+
+	selected := shard.Select(dsns)
+	assert.Equal(t, selected, shard.Select(dsns))
+
+	// Группа DSN не зависит от его позиции и от остальных строк списка.
+	reversed := make([]string, len(dsns))
+	for i, dsn := range dsns {
+		reversed[len(dsns)-1-i] = dsn
+	}
+	var want, got []string
+	for _, position := range selected {
+		want = append(want, dsns[position])
+	}
+	for _, position := range shard.Select(reversed) {
+		got = append(got, reversed[position])
+	}
+	assert.ElementsMatch(t, want, got)
+
+	for _, position := range shard.Select(dsns[:10]) {
+		assert.Contains(t, want, dsns[position])
+	}
+}
+
+func TestShardSelectSingleShardTakesAll(t *testing.T) {
+	dsns := tenantDSNs(3)
+	assert.Equal(t, []int{0, 1, 2}, Shard{Index: 1, Count: 1}.Select(dsns))
+}
@@ -0,0 +1,83 @@
+package declarative
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ordersSpec = `
+create_table:
+  name: orders
+  columns:
+    - name: id
+      type: serial
+      primary_key: true
+    - name: user_id
+      type: integer
+      not_null: true
+    - name: total
+      type: numeric(10,2)
+      default: "0"
+  indexes:
+    - columns: [user_id]
+    - name: orders_total_uidx
+      columns: [user_id, total]
+      unique: true
+`
+
+func TestPostgresCreateTableFromYAML(t *testing.T) {
+	spec, err := Parse("00008_add_orders.yaml", []byte(ordersSpec))
+	require.NoError(t, err)
+
+	generator, err := GeneratorFor("postgres")
+	require.NoError(t, err)
+
+	assert.Equal(t, `CREATE TABLE orders (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	total NUMERIC(10,2) DEFAULT 0
+);
+CREATE INDEX orders_user_id_idx ON orders (user_id);
+CREATE UNIQUE INDEX orders_total_uidx ON orders (user_id, total);`, generator.Up(spec))
+	assert.Equal(t, "DROP TABLE orders;", generator.Down(spec))
+}
+
+func TestPostgresAddColumnsFromJSON(t *testing.T) {
+	spec, err := Parse("00009_add_user_age.json", []byte(`{
+		"add_columns": {
+			"table": "users",
+			"columns": [
+				{"name": "age", "type": "integer"},
+				{"name": "nickname", "type": "varchar(50)", "unique": true}
+			]
+		}
+	}`))
+	require.NoError(t, err)
+
+	generator := PostgresGenerator{}
+	assert.Equal(t, "ALTER TABLE users ADD COLUMN age INTEGER;\n"+
+		"ALTER TABLE users ADD COLUMN nickname VARCHAR(50) UNIQUE;", generator.Up(spec))
+	assert.Equal(t, "ALTER TABLE users DROP COLUMN nickname;\n"+
+		"ALTER TABLE users DROP COLUMN age;", generator.Down(spec))
+}
+
+func TestParseRejectsInvalidSpecs(t *testing.T) {
+	_, err := Parse("00001_empty.json", []byte("{}"))
+	assert.ErrorIs(t, err, ErrEmptySpec)
+
+	_, err = Parse("00001_bad.json", []byte(`{"create_table": {"name": "orders; DROP TABLE users", "columns": [{"name": "id", "type": "int"}]}}`))
+	assert.ErrorIs(t, err, ErrInvalidIdentifier)
+
+	_, err = Parse("00001_bad.json", []byte(`{"add_columns": {"table": "users", "columns": [{"name": "age", "type": "int; DROP TABLE users"}]}}`))
+	assert.ErrorIs(t, err, ErrInvalidType)
+
+	_, err = Parse("00001_bad.toml", []byte(""))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestGeneratorForUnknownDriver(t *testing.T) {
+	_, err := GeneratorFor("oracle")
+	assert.ErrorIs(t, err, ErrUnknownDriver)
+}
@@ -0,0 +1,102 @@
+package declarative
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnknownDriver = errors.New("no DDL generator for driver")
+
+// Generator преобразует декларативную миграцию в DDL конкретной СУБД.
+type Generator interface {
+	Up(spec *Spec) string
+	Down(spec *Spec) string
+}
+
+// generators — генераторы DDL по имени драйвера.
+var generators = map[string]Generator{
+	"postgres": PostgresGenerator{},
+}
+
+// GeneratorFor возвращает генератор DDL для драйвера.
+func GeneratorFor(driver string) (Generator, error) {
+	generator, ok := generators[driver]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, driver)
+	}
+	return generator, nil
+}
+
+// PostgresGenerator генерирует DDL для PostgreSQL.
+type PostgresGenerator struct{}
+
+func (PostgresGenerator) Up(spec *Spec) string {
+	var statements []string
+
+	if t := spec.CreateTable; t != nil {
+		columns := make([]string, 0, len(t.Columns))
+		for _, column := range t.Columns {
+			columns = append(columns, "\t"+postgresColumn(column))
+		}
+		statements = append(statements,
+			fmt.Sprintf("CREATE TABLE %s (\n%s\n);", t.Name, strings.Join(columns, ",\n")))
+
+		for _, index := range t.Indexes {
+			name := index.Name
+			if name == "" {
+				name = t.Name + "_" + strings.Join(index.Columns, "_") + "_idx"
+			}
+			unique := ""
+			if index.Unique {
+				unique = "UNIQUE "
+			}
+			statements = append(statements, fmt.Sprintf("CREATE %sINDEX %s ON %s (%s);",
+				unique, name, t.Name, strings.Join(index.Columns, ", ")))
+		}
+	}
+
+	if a := spec.AddColumns; a != nil {
+		for _, column := range a.Columns {
+			statements = append(statements,
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", a.Table, postgresColumn(column)))
+		}
+	}
+
+	return strings.Join(statements, "\n")
+}
+
+func (PostgresGenerator) Down(spec *Spec) string {
+	var statements []string
+
+	// Откат выполняется в порядке, обратном применению.
+	if a := spec.AddColumns; a != nil {
+		for i := len(a.Columns) - 1; i >= 0; i-- {
+			statements = append(statements,
+				fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", a.Table, a.Columns[i].Name))
+		}
+	}
+
+	if t := spec.CreateTable; t != nil {
+		statements = append(statements, fmt.Sprintf("DROP TABLE %s;", t.Name))
+	}
+
+	return strings.Join(statements, "\n")
+}
+
+func postgresColumn(column Column) string {
+	parts := []string{column.Name, strings.ToUpper(column.Type)}
+	if column.PrimaryKey {
+		parts = append(parts, "PRIMARY KEY")
+	}
+	if column.NotNull {
+		parts = append(parts, "NOT NULL")
+	}
+	if column.Unique {
+		parts = append(parts, "UNIQUE")
+	}
+	if column.Default != "" {
+		parts = append(parts, "DEFAULT "+column.Default)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,156 @@
+// Package declarative описывает миграции, заданные структурой (YAML/JSON),
+// и преобразует их в DDL конкретной СУБД.
+package declarative
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported declarative migration format")
+	ErrEmptySpec         = errors.New("declarative migration has no operations")
+	ErrInvalidIdentifier = errors.New("invalid identifier")
+	ErrInvalidType       = errors.New("invalid column type")
+
+	regIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+	regColumnType = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_ ]*(\(\d+(,\s*\d+)?\))?(\[\])?$`)
+)
+
+// Spec — декларативное описание миграции. Заполняется хотя бы одна операция.
+type Spec struct {
+	CreateTable *CreateTable `yaml:"create_table" json:"create_table"`
+	AddColumns  *AddColumns  `yaml:"add_columns" json:"add_columns"`
+}
+
+// CreateTable описывает создание таблицы вместе с индексами.
+type CreateTable struct {
+	Name    string   `yaml:"name" json:"name"`
+	Columns []Column `yaml:"columns" json:"columns"`
+	Indexes []Index  `yaml:"indexes" json:"indexes"`
+}
+
+// AddColumns описывает добавление колонок в существующую таблицу.
+type AddColumns struct {
+	Table   string   `yaml:"table" json:"table"`
+	Columns []Column `yaml:"columns" json:"columns"`
+}
+
+// Column описывает колонку таблицы.
+type Column struct {
+	Name       string `yaml:"name" json:"name"`
+	Type       string `yaml:"type" json:"type"`
+	PrimaryKey bool   `yaml:"primary_key" json:"primary_key"`
+	NotNull    bool   `yaml:"not_null" json:"not_null"`
+	Unique     bool   `yaml:"unique" json:"unique"`
+	Default    string `yaml:"default" json:"default"`
+}
+
+// Index описывает индекс по одной или нескольким колонкам.
+type Index struct {
+	Name    string   `yaml:"name" json:"name"`
+	Columns []string `yaml:"columns" json:"columns"`
+	Unique  bool     `yaml:"unique" json:"unique"`
+}
+
+// IsSpecFile сообщает, является ли файл декларативной миграцией.
+func IsSpecFile(fileName string) bool {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	default:
+		return false
+	}
+}
+
+// Parse разбирает декларативную миграцию; формат определяется по расширению файла.
+func Parse(fileName string, data []byte) (*Spec, error) {
+	var spec Spec
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &spec); err != nil {
+			return nil, fmt.Errorf("%s: %w", fileName, err)
+		}
+	case ".json":
+		if err := json.Unmarshal(data, &spec); err != nil {
+			return nil, fmt.Errorf("%s: %w", fileName, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, fileName)
+	}
+
+	if err := spec.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return &spec, nil
+}
+
+// Validate проверяет имена и типы, которые попадут в генерируемый DDL.
+func (s *Spec) Validate() error {
+	if s.CreateTable == nil && s.AddColumns == nil {
+		return ErrEmptySpec
+	}
+
+	if t := s.CreateTable; t != nil {
+		if err := validateIdentifier(t.Name); err != nil {
+			return err
+		}
+		if err := validateColumns(t.Columns); err != nil {
+			return err
+		}
+		for _, index := range t.Indexes {
+			if index.Name != "" {
+				if err := validateIdentifier(index.Name); err != nil {
+					return err
+				}
+			}
+			if len(index.Columns) == 0 {
+				return fmt.Errorf("%w: index on %s has no columns", ErrInvalidIdentifier, t.Name)
+			}
+			for _, column := range index.Columns {
+				if err := validateIdentifier(column); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if a := s.AddColumns; a != nil {
+		if err := validateIdentifier(a.Table); err != nil {
+			return err
+		}
+		if err := validateColumns(a.Columns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateColumns(columns []Column) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("%w: no columns", ErrInvalidIdentifier)
+	}
+	for _, column := range columns {
+		if err := validateIdentifier(column.Name); err != nil {
+			return err
+		}
+		if !regColumnType.MatchString(column.Type) {
+			return fmt.Errorf("%w: %q", ErrInvalidType, column.Type)
+		}
+	}
+	return nil
+}
+
+func validateIdentifier(name string) error {
+	if !regIdentifier.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidIdentifier, name)
+	}
+	return nil
+}
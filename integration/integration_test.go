@@ -6,10 +6,14 @@ package integration
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/Edestus789/sql-migrator/app"
 	"github.com/Edestus789/sql-migrator/logger"
@@ -40,7 +44,7 @@ func setup() *storage.PostgresStorage {
 	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
 		dbUser, dbPassword, dbHost, dbPort, dbName)
 
-	storage := storage.New(connStr, logger)
+	storage := storage.NewPostgresStorage(connStr, logger)
 	ctx := context.Background()
 	if err := storage.Connect(ctx); err != nil {
 		log.Fatal(err)
@@ -94,3 +98,649 @@ func TestMigrations(t *testing.T) {
 	os.Remove(fmt.Sprintf("%s/00001_%s_up.sql", migrationDir, "create_users"))
 	os.Remove(fmt.Sprintf("%s/00001_%s_down.sql", migrationDir, "create_users"))
 }
+
+// recordingLogger сохраняет предупреждения, чтобы тест мог их проверить.
+type recordingLogger struct {
+	*logger.ZeroLogger
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (l *recordingLogger) Warn(msg string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, fmt.Sprintf(msg, v...))
+}
+
+func (l *recordingLogger) hasWarning(substr string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, w := range l.warnings {
+		if strings.Contains(w, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLockDiagnostics(t *testing.T) {
+	ctx := context.Background()
+
+	holder := getDBConnection()
+	defer holder.Close()
+
+	conn, err := holder.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get holder connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET application_name = 'lock_holder'"); err != nil {
+		t.Fatalf("Failed to set application_name: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(123456)"); err != nil {
+		t.Fatalf("Failed to take advisory lock: %v", err)
+	}
+
+	rec := &recordingLogger{ZeroLogger: logger.New()}
+	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		dbUser, dbPassword, dbHost, dbPort, dbName)
+	waiter := storage.NewPostgresStorage(connStr, rec)
+	waiter.EnableLockDiagnostics(100 * time.Millisecond)
+	if err := waiter.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer waiter.Close()
+
+	locked := make(chan error, 1)
+	go func() {
+		locked <- waiter.Lock(ctx)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !rec.hasWarning(`application_name="lock_holder"`) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected lock diagnostics naming the holder, got: %v", rec.warnings)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock(123456)"); err != nil {
+		t.Fatalf("Failed to release advisory lock: %v", err)
+	}
+	if err := <-locked; err != nil {
+		t.Fatalf("Expected lock to be acquired after release: %v", err)
+	}
+	if err := waiter.Unlock(ctx); err != nil {
+		t.Fatalf("Failed to unlock: %v", err)
+	}
+}
+
+func TestNoAutoCreateTable(t *testing.T) {
+	db := getDBConnection()
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE SCHEMA IF NOT EXISTS strict_mode"); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	defer db.Exec("DROP SCHEMA strict_mode CASCADE")
+
+	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable&search_path=strict_mode",
+		dbUser, dbPassword, dbHost, dbPort, dbName)
+	strict := storage.NewPostgresStorage(connStr, logger.New())
+	strict.SetAutoCreateTable(false)
+
+	err := strict.Connect(context.Background())
+	if !errors.Is(err, storage.ErrTrackingTableMissing) {
+		t.Fatalf("Expected ErrTrackingTableMissing, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), `CREATE TABLE IF NOT EXISTS "schema_migrations"`) {
+		t.Fatalf("Expected the error to contain the DDL, got: %v", err)
+	}
+
+	var exists bool
+	err = db.QueryRow("SELECT to_regclass('strict_mode.schema_migrations') IS NOT NULL").Scan(&exists)
+	if err != nil || exists {
+		t.Fatalf("Expected schema_migrations not to be created in strict mode (err: %v)", err)
+	}
+}
+
+func TestPersistentLockTable(t *testing.T) {
+	ctx := context.Background()
+	first := setup()
+	defer teardown(first)
+	second := setup()
+	defer second.Close()
+
+	if err := first.AcquirePersistentLock(ctx, "integration", "first", 200*time.Millisecond); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	if err := second.AcquirePersistentLock(ctx, "integration", "second", time.Minute); !errors.Is(err, storage.ErrLockHeld) {
+		t.Fatalf("Expected ErrLockHeld, got: %v", err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if err := second.AcquirePersistentLock(ctx, "integration", "second", time.Minute); err != nil {
+		t.Fatalf("Expected expired lock to be taken over, got: %v", err)
+	}
+	if err := first.ReleasePersistentLock(ctx, "integration"); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+}
+
+func TestMigrateSumsRowsOfAllStatements(t *testing.T) {
+	ctx := context.Background()
+	db := setup()
+	defer teardown(db)
+
+	rows, err := db.Migrate(ctx, `CREATE TEMPORARY TABLE rows_affected (id int);
+	INSERT INTO rows_affected SELECT generate_series(1, 3);
+	UPDATE rows_affected SET id = id + 10 WHERE id < 3;
+	CREATE INDEX ON rows_affected (id);`)
+	if err != nil {
+		t.Fatalf("Failed to execute migration SQL: %v", err)
+	}
+	if rows != 5 {
+		t.Fatalf("Expected 5 rows affected by all statements, got %d", rows)
+	}
+}
+
+func TestXactLock(t *testing.T) {
+	ctx := context.Background()
+	db := setup()
+	defer teardown(db)
+
+	// Миграция падает, если в её транзакции не удерживается advisory lock мигратора.
+	assertLocked := `DO $$
+	BEGIN
+		IF NOT EXISTS (
+			SELECT 1 FROM pg_locks
+			WHERE locktype = 'advisory' AND objid = 123456 AND pid = pg_backend_pid() AND granted
+		) THEN
+			RAISE EXCEPTION 'advisory lock is not held inside the migration transaction';
+		END IF;
+	END $$;`
+	if err := db.Begin(ctx); err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if err := db.XactLock(ctx); err != nil {
+		t.Fatalf("Failed to acquire transaction-level lock: %v", err)
+	}
+	if _, err := db.Migrate(ctx, assertLocked); err != nil {
+		t.Fatalf("Expected lock inside transaction, got: %v", err)
+	}
+	if err := db.Commit(ctx); err != nil {
+		t.Fatalf("Failed to commit transaction: %v", err)
+	}
+
+	// После фиксации транзакции блокировка снята.
+	assertUnlocked := `DO $$
+	BEGIN
+		IF EXISTS (
+			SELECT 1 FROM pg_locks
+			WHERE locktype = 'advisory' AND objid = 123456 AND pid = pg_backend_pid()
+		) THEN
+			RAISE EXCEPTION 'advisory lock is still held after commit';
+		END IF;
+	END $$;`
+	if _, err := db.Migrate(ctx, assertUnlocked); err != nil {
+		t.Fatalf("Expected lock to be released after commit, got: %v", err)
+	}
+}
+
+func TestRollbackToSavepoint(t *testing.T) {
+	ctx := context.Background()
+	db := setup()
+	defer teardown(db)
+
+	if err := db.Begin(ctx); err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if err := db.Savepoint(ctx, "migration_sql"); err != nil {
+		t.Fatalf("Failed to create savepoint: %v", err)
+	}
+	if _, err := db.Migrate(ctx, "CREATE TABLE savepoint_test (id INT);"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if err := db.RollbackToSavepoint(ctx, "migration_sql"); err != nil {
+		t.Fatalf("Failed to roll back to savepoint: %v", err)
+	}
+	if err := db.Commit(ctx); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	var exists bool
+	err := getDBConnection().QueryRow("SELECT to_regclass('savepoint_test') IS NOT NULL").Scan(&exists)
+	if err != nil {
+		t.Fatalf("Failed to check table: %v", err)
+	}
+	if exists {
+		t.Fatal("Expected table created after the savepoint to be rolled back")
+	}
+}
+
+func TestWithTxRollsBackOnError(t *testing.T) {
+	ctx := context.Background()
+	db := setup()
+	defer teardown(db)
+
+	errStep := errors.New("go step failed")
+	err := db.WithTx(ctx, func(ctx context.Context) error {
+		executor, ok := storage.ExecutorFromContext(ctx)
+		if !ok {
+			t.Fatal("Expected transaction executor in context")
+		}
+		if _, err := executor.Exec(ctx, "CREATE TABLE with_tx_test (id INT);"); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		return errStep
+	})
+	if !errors.Is(err, errStep) {
+		t.Fatalf("Expected step error, got: %v", err)
+	}
+
+	var exists bool
+	err = getDBConnection().QueryRow("SELECT to_regclass('with_tx_test') IS NOT NULL").Scan(&exists)
+	if err != nil {
+		t.Fatalf("Failed to check table: %v", err)
+	}
+	if exists {
+		t.Fatal("Expected table created in the failed transaction to be rolled back")
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	db := setup()
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close storage: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Expected second Close to be a no-op, got: %v", err)
+	}
+}
+
+func TestTryLockReportsBusyLock(t *testing.T) {
+	ctx := context.Background()
+	holder := setup()
+	defer teardown(holder)
+	waiter := setup()
+	defer waiter.Close()
+
+	if err := holder.Lock(ctx); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	if err := waiter.TryLock(ctx); !errors.Is(err, storage.ErrLockBusy) {
+		t.Fatalf("Expected ErrLockBusy while another session holds the lock, got: %v", err)
+	}
+
+	if err := holder.Unlock(ctx); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+	if err := waiter.TryLock(ctx); err != nil {
+		t.Fatalf("Expected lock to be acquired after release, got: %v", err)
+	}
+	if err := waiter.Unlock(ctx); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+}
+
+func TestTryXactLockReportsBusyLock(t *testing.T) {
+	ctx := context.Background()
+	holder := setup()
+	defer teardown(holder)
+	waiter := setup()
+	defer waiter.Close()
+
+	if err := holder.Lock(ctx); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	if err := waiter.Begin(ctx); err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if err := waiter.TryXactLock(ctx); !errors.Is(err, storage.ErrLockBusy) {
+		t.Fatalf("Expected ErrLockBusy while another session holds the lock, got: %v", err)
+	}
+
+	if err := holder.Unlock(ctx); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+	if err := waiter.TryXactLock(ctx); err != nil {
+		t.Fatalf("Expected lock to be acquired after release, got: %v", err)
+	}
+	if err := waiter.Commit(ctx); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+}
+
+func TestLockWaitIgnoresStatementTimeout(t *testing.T) {
+	ctx := context.Background()
+	holder := setup()
+	defer teardown(holder)
+	waiter := setup()
+	defer waiter.Close()
+
+	if err := waiter.SetStatementTimeout(ctx, 100*time.Millisecond); err != nil {
+		t.Fatalf("Failed to set statement timeout: %v", err)
+	}
+	if err := holder.Lock(ctx); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	locked := make(chan error, 1)
+	go func() {
+		locked <- waiter.Lock(ctx)
+	}()
+	time.Sleep(300 * time.Millisecond)
+	if err := holder.Unlock(ctx); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+	if err := <-locked; err != nil {
+		t.Fatalf("Expected lock wait to outlast statement_timeout, got: %v", err)
+	}
+
+	if _, err := waiter.Migrate(ctx, "SELECT pg_sleep(0.3);"); err == nil {
+		t.Fatalf("Expected statement_timeout to apply again after the lock")
+	}
+	if err := waiter.Unlock(ctx); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+}
+
+func TestRestoreStatementTimeoutKeepsSessionValue(t *testing.T) {
+	ctx := context.Background()
+	db := setup()
+	defer teardown(db)
+
+	if err := db.ExecSessionStatement(ctx, "SET statement_timeout = '2min'"); err != nil {
+		t.Fatalf("Failed to configure session: %v", err)
+	}
+	previous, err := db.StatementTimeout(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read statement timeout: %v", err)
+	}
+	if err := db.SetStatementTimeout(ctx, time.Second); err != nil {
+		t.Fatalf("Failed to set statement timeout: %v", err)
+	}
+	if err := db.RestoreStatementTimeout(ctx, previous); err != nil {
+		t.Fatalf("Failed to restore statement timeout: %v", err)
+	}
+
+	restored, err := db.StatementTimeout(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read statement timeout: %v", err)
+	}
+	if restored != "2min" {
+		t.Fatalf("Expected statement_timeout 2min after restore, got %s", restored)
+	}
+}
+
+func TestMigrateParallelAppliesSessionSettings(t *testing.T) {
+	ctx := context.Background()
+	storage := setup()
+	defer teardown(storage)
+
+	if err := storage.SetStatementTimeout(ctx, 100*time.Millisecond); err != nil {
+		t.Fatalf("Failed to set statement timeout: %v", err)
+	}
+	err := storage.MigrateParallel(ctx, []string{"SELECT pg_sleep(0.3);", "SELECT pg_sleep(0.3);"}, 2)
+	if err == nil || !strings.Contains(err.Error(), "statement timeout") {
+		t.Fatalf("Expected statement_timeout on every worker connection, got: %v", err)
+	}
+}
+
+func TestMissingPrivilegesForOwner(t *testing.T) {
+	ctx := context.Background()
+	db := setup()
+	defer teardown(db)
+
+	missing, err := db.MissingPrivileges(ctx)
+	if err != nil {
+		t.Fatalf("Failed to check privileges: %v", err)
+	}
+	if len(missing) != 0 {
+		t.Fatalf("Expected the database owner to have all privileges, missing: %v", missing)
+	}
+}
+
+func TestCustomTrackingTable(t *testing.T) {
+	ctx := context.Background()
+	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		dbUser, dbPassword, dbHost, dbPort, dbName)
+	db := storage.NewPostgresStorage(connStr, logger.New())
+	if err := db.SetTrackingTable("custom_migrations"); err != nil {
+		t.Fatalf("Failed to set tracking table: %v", err)
+	}
+	if err := db.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer db.Close()
+	defer db.Migrate(ctx, "DROP TABLE IF EXISTS custom_migrations;")
+
+	migration := storage.CreateMigration("create_users", storage.StatusSuccess, 1, time.Now())
+	if err := db.InsertMigration(ctx, migration); err != nil {
+		t.Fatalf("Failed to insert migration: %v", err)
+	}
+
+	var count int
+	if err := getDBConnection().QueryRow("SELECT count(*) FROM custom_migrations").Scan(&count); err != nil {
+		t.Fatalf("Failed to query custom tracking table: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected 1 record in custom_migrations, got %d", count)
+	}
+}
+
+func TestRoleSurvivesReconnect(t *testing.T) {
+	ctx := context.Background()
+	admin := getDBConnection()
+	defer admin.Close()
+	for _, sql := range []string{
+		"DROP ROLE IF EXISTS reconnect_owner",
+		"CREATE ROLE reconnect_owner",
+		"GRANT CREATE ON SCHEMA public TO reconnect_owner",
+	} {
+		if _, err := admin.Exec(sql); err != nil {
+			t.Fatalf("Failed to prepare role: %v", err)
+		}
+	}
+	defer admin.Exec("DROP ROLE IF EXISTS reconnect_owner")
+	defer admin.Exec("REVOKE CREATE ON SCHEMA public FROM reconnect_owner")
+	defer admin.Exec("DROP TABLE IF EXISTS reconnect_migrations, reconnect_check")
+
+	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		dbUser, dbPassword, dbHost, dbPort, dbName)
+	db := storage.NewPostgresStorage(connStr, logger.New())
+	db.SetApplicationName("sql-migrator-reconnect")
+	if err := db.SetTrackingTable("reconnect_migrations"); err != nil {
+		t.Fatalf("Failed to set tracking table: %v", err)
+	}
+	if err := db.SetRole(ctx, "reconnect_owner"); err != nil {
+		t.Fatalf("Failed to set role: %v", err)
+	}
+	if err := db.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	// Обрыв соединения: пул заменит его новым, как после MaxConnLifetime.
+	if _, err := admin.Exec("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE application_name = 'sql-migrator-reconnect'"); err != nil {
+		t.Fatalf("Failed to terminate the connection: %v", err)
+	}
+	_, _ = db.Migrate(ctx, "SELECT 1;")
+
+	if _, err := db.Migrate(ctx, "CREATE TABLE reconnect_check (id int);"); err != nil {
+		t.Fatalf("Failed to create table after reconnect: %v", err)
+	}
+	var owner string
+	if err := admin.QueryRow("SELECT tableowner FROM pg_tables WHERE tablename = 'reconnect_check'").Scan(&owner); err != nil {
+		t.Fatalf("Failed to read table owner: %v", err)
+	}
+	if owner != "reconnect_owner" {
+		t.Fatalf("Expected the new connection to run as reconnect_owner, got %s", owner)
+	}
+}
+
+func TestDropTrackingTable(t *testing.T) {
+	ctx := context.Background()
+	db := setup()
+	defer db.Close()
+
+	if err := db.DropTrackingTable(ctx); err != nil {
+		t.Fatalf("Failed to drop tracking table: %v", err)
+	}
+
+	var exists bool
+	err := getDBConnection().QueryRow("SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists)
+	if err != nil {
+		t.Fatalf("Failed to check tracking table: %v", err)
+	}
+	if exists {
+		t.Fatal("Expected schema_migrations to be dropped")
+	}
+}
+
+func TestApplicationNameIsSet(t *testing.T) {
+	ctx := context.Background()
+	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		dbUser, dbPassword, dbHost, dbPort, dbName)
+	db := storage.NewPostgresStorage(connStr, logger.New())
+	db.SetApplicationName("sql-migrator-integration")
+	if err := db.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	assertName := `DO $$
+	BEGIN
+		IF current_setting('application_name') <> 'sql-migrator-integration' THEN
+			RAISE EXCEPTION 'unexpected application_name %', current_setting('application_name');
+		END IF;
+	END $$;`
+	if _, err := db.Migrate(ctx, assertName); err != nil {
+		t.Fatalf("Expected application_name to be set: %v", err)
+	}
+}
+
+func TestCurrentVersion(t *testing.T) {
+	ctx := context.Background()
+	db := setup()
+	defer db.Close()
+
+	if err := db.DeleteMigrations(ctx); err != nil {
+		t.Fatalf("Failed to clear migrations: %v", err)
+	}
+	version, err := db.CurrentVersion(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get current version: %v", err)
+	}
+	if version != 0 {
+		t.Fatalf("Expected version 0 for an empty table, got %d", version)
+	}
+
+	for _, migration := range []storage.IMigration{
+		storage.CreateMigration("create_users", storage.StatusSuccess, 1, time.Now()),
+		storage.CreateMigration("create_orders", storage.StatusError, 2, time.Now()),
+	} {
+		if err := db.InsertMigration(ctx, migration); err != nil {
+			t.Fatalf("Failed to insert migration: %v", err)
+		}
+	}
+	version, err = db.CurrentVersion(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get current version: %v", err)
+	}
+	if version != 1 {
+		t.Fatalf("Expected version 1, got %d", version)
+	}
+}
+
+func TestReleaseTags(t *testing.T) {
+	ctx := context.Background()
+	db := setup()
+	defer db.Close()
+	defer db.Migrate(ctx, "DROP TABLE IF EXISTS schema_migrations_tags;")
+
+	if _, err := db.LatestTag(ctx); !errors.Is(err, storage.ErrTagNotFound) {
+		t.Fatalf("Expected ErrTagNotFound before any tag, got %v", err)
+	}
+
+	tag := storage.ReleaseTag{Label: "v2.3.0", Version: 2, Checksums: map[int]string{1: "abc", 2: "def"}}
+	if err := db.InsertTag(ctx, tag); err != nil {
+		t.Fatalf("Failed to insert tag: %v", err)
+	}
+	latest, err := db.LatestTag(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read tag: %v", err)
+	}
+	if latest.Label != "v2.3.0" || latest.Version != 2 || latest.Checksums[2] != "def" {
+		t.Fatalf("Unexpected tag: %+v", latest)
+	}
+}
+
+func TestQueryReport(t *testing.T) {
+	ctx := context.Background()
+	db := setup()
+	defer db.Close()
+
+	report, err := db.QueryReport(ctx, "SELECT 'users' AS name, 3 AS total, NULL AS note UNION ALL SELECT 'orders', 5, NULL;")
+	if err != nil {
+		t.Fatalf("Failed to run report query: %v", err)
+	}
+	if strings.Join(report.Columns, ",") != "name,total,note" {
+		t.Fatalf("Unexpected report columns: %v", report.Columns)
+	}
+	if len(report.Rows) != 2 || strings.Join(report.Rows[0], ",") != "users,3,NULL" {
+		t.Fatalf("Unexpected report rows: %v", report.Rows)
+	}
+}
+
+func TestObjectExists(t *testing.T) {
+	ctx := context.Background()
+	db := setup()
+	defer db.Close()
+	defer db.Migrate(ctx, "DROP TABLE IF EXISTS adopted_users;")
+
+	if _, err := db.Migrate(ctx, "CREATE TABLE adopted_users (id serial, email text);"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	for _, c := range []struct {
+		object storage.SchemaObject
+		exists bool
+	}{
+		{storage.SchemaObject{Table: "adopted_users"}, true},
+		{storage.SchemaObject{Schema: "public", Table: "adopted_users", Column: "email"}, true},
+		{storage.SchemaObject{Table: "adopted_users", Column: "phone"}, false},
+		{storage.SchemaObject{Table: "missing_users"}, false},
+	} {
+		exists, err := db.ObjectExists(ctx, c.object)
+		if err != nil {
+			t.Fatalf("Failed to check %s: %v", c.object, err)
+		}
+		if exists != c.exists {
+			t.Fatalf("Expected %s exists=%v, got %v", c.object, c.exists, exists)
+		}
+	}
+}
+
+func TestSchemaCompareAgainstGoldenFile(t *testing.T) {
+	db := getDBConnection()
+	defer db.Close()
+	if _, err := db.Exec("DROP DATABASE IF EXISTS golden_scratch;"); err != nil {
+		t.Fatalf("Failed to drop scratch database: %v", err)
+	}
+	if _, err := db.Exec("CREATE DATABASE golden_scratch;"); err != nil {
+		t.Fatalf("Failed to create scratch database: %v", err)
+	}
+	defer db.Exec("DROP DATABASE IF EXISTS golden_scratch;")
+
+	scratch := storage.NewPostgresStorage(fmt.Sprintf("postgres://%s:%s@%s:%s/golden_scratch?sslmode=disable",
+		dbUser, dbPassword, dbHost, dbPort), logger.New())
+	target := setup()
+	defer target.Close()
+	application := app.New(logger.New(), target)
+
+	var out strings.Builder
+	if err := application.SchemaCompare("testdata/golden_migrations", "testdata/golden_schema.json", scratch, &out); err != nil {
+		t.Fatalf("Expected migrations to reproduce the golden schema, got %v:\n%s", err, out.String())
+	}
+}
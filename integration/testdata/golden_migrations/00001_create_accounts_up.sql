@@ -0,0 +1,4 @@
+CREATE TABLE accounts (
+	id serial PRIMARY KEY,
+	email varchar(255) NOT NULL
+);
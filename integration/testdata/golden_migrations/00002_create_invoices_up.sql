@@ -0,0 +1,5 @@
+CREATE TABLE invoices (
+	id serial PRIMARY KEY,
+	account_id integer NOT NULL REFERENCES accounts (id),
+	note text
+);
@@ -68,3 +68,36 @@ func (l *ZeroLogger) Info(msg string, v ...interface{}) {
 func (l *ZeroLogger) Debug(msg string, v ...interface{}) {
 	log.Debug().Msgf(msg, v...)
 }
+
+type prefixLogger struct {
+	logger Logger
+	prefix string
+}
+
+// WithPrefix возвращает логгер, добавляющий prefix к каждому сообщению.
+func WithPrefix(l Logger, prefix string) Logger {
+	return &prefixLogger{
+		logger: l,
+		prefix: strings.ReplaceAll(prefix, "%", "%%"),
+	}
+}
+
+func (l *prefixLogger) Fatal(msg string, v ...interface{}) {
+	l.logger.Fatal(l.prefix+msg, v...)
+}
+
+func (l *prefixLogger) Error(msg string, v ...interface{}) {
+	l.logger.Error(l.prefix+msg, v...)
+}
+
+func (l *prefixLogger) Warn(msg string, v ...interface{}) {
+	l.logger.Warn(l.prefix+msg, v...)
+}
+
+func (l *prefixLogger) Info(msg string, v ...interface{}) {
+	l.logger.Info(l.prefix+msg, v...)
+}
+
+func (l *prefixLogger) Debug(msg string, v ...interface{}) {
+	l.logger.Debug(l.prefix+msg, v...)
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/Edestus789/sql-migrator/app"
 	"github.com/Edestus789/sql-migrator/config"
 	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/processes"
 	"github.com/Edestus789/sql-migrator/storage"
 )
 
@@ -17,6 +23,85 @@ var (
 	database      string
 	migrationName string
 	command       string
+	version       int
+	runAs         string
+	dsnFile       string
+	shardSpec     string
+	dsnFrom       string
+	fromGit       string
+	parallel      int
+	skip          string
+	applySkipped  bool
+	renameTo      int
+	fromVersion   int
+	allowMissing  bool
+	count         int
+	diagnoseLock  bool
+	readOnly      bool
+	forceRecreate bool
+	requireConfig bool
+	postUpAnalyze bool
+	postUpVacuum  bool
+	listCommands  bool
+	listDrivers   bool
+	outputFormat  string
+	retention     time.Duration
+	wide          bool
+	// reporter сообщает итог запуска в формате -output.
+	reporter      *app.Reporter
+	statusTmpl    string
+	statusFormat  string
+	outPath       string
+	statusLabel   string
+	verbose       bool
+	since         int
+	plainVersion  bool
+	noAutoCreate  bool
+	simulateFail  string
+	lockKey       string
+	lockTTL       time.Duration
+	lockScope     string
+	lockWait      time.Duration
+	lockRetry     time.Duration
+	heartbeat     time.Duration
+	maxParallel   int
+	lockJitter    time.Duration
+	checkPerms    bool
+	delimiter     string
+	trackingTable string
+	force         bool
+	appName       string
+	onDirty       string
+	continueRedo  bool
+	statusFull    bool
+	verifySums    bool
+	statusIcons   string
+	onlySQL       bool
+	expectedPath  string
+	stmtTimeout   time.Duration
+	preLock       stringList
+	deadlockRetry int
+	planPath      string
+	manifestPath  string
+	storePlan     string
+	interactive   bool
+	assumeYes     bool
+	noopExitCode  int
+	goldenPath    string
+	scratchDSN    string
+	notifyURL     string
+	abortLockWait bool
+	commitEvery   int
+	preview       bool
+	dbHost        string
+	dbPort        string
+	dbUser        string
+	dbPassword    string
+	dbName        string
+	sslMode       string
+	notifyOn      string
+	notifyTimeout time.Duration
+	gates         = map[string]bool{}
 )
 
 // var (
@@ -29,67 +114,515 @@ var (
 // 	command       string
 // )
 
+const lockDiagnosticsInterval = 10 * time.Second
+
+// hiddenFlags — служебные флаги, которые не показываются в справке.
+var hiddenFlags = map[string]bool{"simulate-failure": true}
+
+// exitNoMigrations — код завершения, когда в директории нет файлов миграций.
+const exitNoMigrations = 3
+
 func init() {
-	flag.StringVar(&configPath, "config", "config.yaml", "Path to config file")
+	flag.StringVar(&configPath, "config", config.DefaultPath, "Path to config file (comma-separated list to merge several files)")
 	flag.StringVar(&path, "path", "", "Path to migrations file")
 	flag.StringVar(&database, "dsn", "", "Database connection string")
+	flag.StringVar(&appName, "app-name", "", "Postgres application_name shown in pg_stat_activity (default sql-migrator/<version>, or the DSN's application_name)")
+	flag.StringVar(&trackingTable, "table", "", "Tracking table of applied migrations, optionally schema-qualified (default schema_migrations)")
 	flag.StringVar(&migrationName, "name", "", "Migration name")
-	flag.StringVar(&command, "command", "", "Command to run: create, up, down, redo, status, dbversion")
+	flag.StringVar(&command, "command", "", "Command to run (see -list-commands)")
+	flag.IntVar(&version, "version", 0, "Target version for downto, source version for rename")
+	flag.IntVar(&renameTo, "to", 0, "New version number for rename, or the last version of the range to apply (up -from N -to M)")
+	flag.IntVar(&fromVersion, "from", 0, "First version of the range to apply (up, together with -to)")
+	flag.BoolVar(&allowMissing, "allow-missing", false, "Apply the -from/-to range even if versions below it are not applied (up)")
+	flag.IntVar(&count, "count", 1, "Number of sequential versions to create (create-multi)")
+	flag.StringVar(&runAs, "run-as", "", "Role to switch to (SET ROLE) right after connecting, before the tracking table is created; the name is folded to lower case like an unquoted identifier")
+	flag.IntVar(&maxParallel, "max-parallel-statements", processes.DefaultParallelStatements, "How many statements of a migration marked -- migrate:parallel run at once, each on its own connection (up)")
+	flag.DurationVar(&heartbeat, "heartbeat-interval", 0, "While a migration runs, log that it is still running this often (0 = off)")
+	flag.BoolVar(&checkPerms, "check-perms", false, "Before migrating, verify the role has CREATE on the schema and write access to schema_migrations, failing early otherwise")
+	flag.Var(&preLock, "pre-lock-statement", "SET statement to run on the migration session after connecting and before taking the lock, e.g. \"SET lock_timeout = '5s'\" (repeatable)")
+	flag.DurationVar(&stmtTimeout, "statement-timeout", 0, "Set the Postgres statement_timeout for the session so the server aborts runaway statements (0 = server default)")
+	flag.StringVar(&delimiter, "delimiter", processes.DefaultDelimiter, "Statement terminator used in migration files, e.g. / for PL/SQL blocks (overridden per file by -- migrator:delimiter)")
+	flag.IntVar(&noopExitCode, "exit-code-on-noop", 0, "Exit code for up or down when no migration was applied or rolled back, so CI can tell whether the schema changed")
+	flag.IntVar(&deadlockRetry, "deadlock-retries", 0, "Retry a migration's SQL up to this many times after a deadlock or serialization failure")
+	flag.StringVar(&dbHost, "host", "", "Database host, comma-separated for several; with -port, -user, -password, -dbname and -sslmode builds the connection string when -dsn is not given")
+	flag.StringVar(&dbPort, "port", "", "Database port (used when -dsn is not given)")
+	flag.StringVar(&dbUser, "user", "", "Database user (used when -dsn is not given)")
+	flag.StringVar(&dbPassword, "password", "", "Database password (used when -dsn is not given)")
+	flag.StringVar(&dbName, "dbname", "", "Database name (used when -dsn is not given)")
+	flag.StringVar(&sslMode, "sslmode", "", "Postgres sslmode, e.g. disable or require (used when -dsn is not given)")
+	flag.StringVar(&dsnFrom, "dsn-from", "", "Read the connection string from a secret: env://VAR or file:///path (overrides -dsn)")
+	flag.StringVar(&fromGit, "from-git", "", "Only apply or verify migrations whose files were added in this git range, e.g. origin/main..HEAD (up, verify)")
+	flag.StringVar(&dsnFile, "dsn-file", "", "File with database connection strings, one per line")
+	flag.StringVar(&shardSpec, "shard", "", "Migrate only shard I of N of the databases (e.g. 2/5), chosen by hashing each connection string")
+	flag.IntVar(&parallel, "parallel", 1, "Number of databases from -dsn-file to migrate at once")
+	flag.StringVar(&skip, "skip", "", "Comma-separated versions that up must skip and record as skipped")
+	flag.BoolVar(&applySkipped, "apply-skipped", false, "Apply versions previously recorded as skipped")
+	flag.BoolVar(&readOnly, "read-only", false, "Treat the migrations directory as read-only (create and rename are refused)")
+	flag.StringVar(&onDirty, "on-dirty", processes.OnDirtyHalt, "What up does with versions left in process or error state by a crash: halt (refuse) or clean (delete the row and retry the version)")
+	flag.BoolVar(&continueRedo, "continue-on-missing-down", false, "Let redo re-apply a migration that has no down instead of failing (redo)")
+	flag.StringVar(&goldenPath, "schema-compare-to", "", "Golden schema snapshot, as written by schema-dump, that the migrations must reproduce (schema-compare)")
+	flag.StringVar(&scratchDSN, "scratch-dsn", "", "Throwaway database that schema-compare applies every migration to")
+	flag.StringVar(&notifyURL, "notify-url", "", "POST a JSON summary {status, applied, failed, version, duration} to this URL when up, down, downto or redo finishes")
+	flag.StringVar(&notifyOn, "notify-on", app.NotifyOnAlways, "Which runs -notify-url reports: success, failure or always")
+	flag.DurationVar(&notifyTimeout, "notify-timeout", app.DefaultNotifyTimeout, "How long to wait for the -notify-url endpoint")
+	flag.BoolVar(&force, "force", false, "Confirm a destructive command such as nuke")
+	flag.BoolVar(&forceRecreate, "force-recreate-table", false, "Rebuild the schema_migrations table from its current rows on connect")
+	flag.BoolVar(&requireConfig, "require-config", false, "Fail if the config file is missing instead of using flags and environment only")
+	flag.BoolVar(&postUpAnalyze, "post-up-analyze", false, "Run ANALYZE after a successful up (declared tables, or the whole database)")
+	flag.BoolVar(&postUpVacuum, "post-up-vacuum", false, "Use VACUUM ANALYZE instead of ANALYZE after up")
+	flag.BoolVar(&onlySQL, "only-sql", false, "Apply only SQL migrations; versions with a Go step stay pending for a later up (up)")
+	flag.BoolVar(&noAutoCreate, "no-auto-create-table", false, "Do not create or upgrade schema_migrations; fail with the required DDL instead")
+	flag.StringVar(&lockScope, "lock-scope", processes.LockScopeRun, "Advisory lock scope: run (session lock around the whole run) or migration (pg_advisory_xact_lock inside each migration's transaction)")
+	flag.DurationVar(&lockRetry, "lock-retry-interval", time.Second, "Pause between attempts to take a busy migration lock while -lock-wait runs (up)")
+	flag.DurationVar(&lockJitter, "lock-jitter", 0, "Add a random delay of up to this long to each lock retry so that many waiting instances do not retry in step (up)")
+	flag.IntVar(&commitEvery, "commit-every", 0, "Commit after every N rolled-back migrations during downto, reset and nuke (0 or 1 = after each migration)")
+	flag.BoolVar(&abortLockWait, "abort-lock-wait", false, "Fail at once with a lock-busy error if another process holds the migration lock, instead of waiting (overrides -lock-wait)")
+	flag.DurationVar(&lockWait, "lock-wait", 0, "If another process holds the migration lock, keep retrying for up to this long, then apply only what is still pending (up; 0 = block until released)")
+	flag.BoolVar(&diagnoseLock, "diagnose-lock", false, "Periodically report which session holds the migration lock while waiting")
+	flag.Func("gate", "Enable or disable a migration gate, e.g. -gate NEW_BILLING=true (repeatable)", func(s string) error {
+		name, enabled, err := processes.ParseGate(s)
+		if err != nil {
+			return err
+		}
+		gates[name] = enabled
+		return nil
+	})
+	flag.StringVar(&statusFormat, "format", app.StatusFormatTable, "Status output format: table, or csv with the full history (version, name, status, status_change_time, checksum)")
+	flag.StringVar(&statusTmpl, "template", "", "Go text/template applied to the status records, e.g. '{{range .}}{{.Version}} {{.Status}}\\n{{end}}'")
+	flag.StringVar(&outPath, "out", "", "Write templated or exported output to this file instead of stdout")
+	flag.StringVar(&planPath, "plan", "", "Plan file written by the plan command to execute (apply)")
+	flag.StringVar(&manifestPath, "applied-manifest", "", "Manifest written by export-applied in another environment; up skips and records as skipped the migrations listed in it with the same checksum")
+	flag.StringVar(&storePlan, "store-plan", "", "After a successful up or apply, append the applied migrations, checksums, durations, operator and host as one JSON line to this audit file")
+	flag.StringVar(&expectedPath, "expected", "", "Schema snapshot written by schema-dump to compare against (report-drift)")
+	flag.IntVar(&since, "since", 0, "Export events only for versions above this one (events)")
+	flag.StringVar(&statusLabel, "label", "", "Show only migrations with this label (status), or the release tag to record (tag)")
+	flag.StringVar(&simulateFail, "simulate-failure", "", "Testing hook: fail up at version=N (requires MIGRATOR_ALLOW_SIMULATE=1)")
+	flag.StringVar(&lockKey, "lock-key", "default", "Key of the persistent lock used by the lock and unlock commands")
+	flag.DurationVar(&lockTTL, "lock-ttl", time.Hour, "How long a persistent lock stays valid if its holder never unlocks (0 = forever)")
+	flag.StringVar(&statusIcons, "status-icons", processes.StatusIconsNone, "Prefix statuses with icons so they do not rely on color: none, unicode or ascii (status)")
+	flag.BoolVar(&verifySums, "verify-checksums", false, "Compare applied migrations with the files on disk, mark changed ones DRIFT and fail (status)")
+	flag.BoolVar(&statusFull, "full", false, "Print applied and pending migrations in separate sections with a summary (status)")
+	flag.BoolVar(&verbose, "verbose", false, "Show the source file of each migration in status")
+	flag.BoolVar(&wide, "wide", false, "Show the author of each migration (-- migrate:author) in status")
+	flag.BoolVar(&plainVersion, "plain", false, "Print only the applied version number (dbversion)")
+	flag.BoolVar(&preview, "preview", false, "Print the down SQL that will run, with version headers, and ask for confirmation before rolling back unless -y (down, downto, reset)")
+	flag.BoolVar(&assumeYes, "assume-yes", false, "Answer yes to every confirmation prompt of destructive commands such as reset and nuke")
+	flag.BoolVar(&assumeYes, "y", false, "Shorthand for -assume-yes")
+	flag.BoolVar(&interactive, "interactive", false, "Before each migration of up/down, show it and ask to apply, skip or quit (requires a terminal)")
+	flag.BoolVar(&listCommands, "list-commands", false, "List available commands with their flags and exit")
+	flag.DurationVar(&retention, "retention", 0, "Drop audit log (-store-plan) records older than this (compact)")
+	flag.StringVar(&outputFormat, "output", app.OutputText, "How to report the final result: text, or json (one object on stdout on success, on stderr on failure; redo includes the redone migration)")
+	flag.BoolVar(&listDrivers, "list-drivers", false, "List registered database drivers with their capabilities and exit (same as -command drivers)")
+
+	flag.Usage = usage
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s -command <command> [flags]\n\n", os.Args[0])
+	if err := app.WriteCommandHelp(out); err != nil {
+		return
+	}
+	fmt.Fprintln(out, "\nFlags:")
+
+	visible := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	visible.SetOutput(out)
+	flag.VisitAll(func(f *flag.Flag) {
+		if !hiddenFlags[f.Name] {
+			visible.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	visible.PrintDefaults()
 }
 
 func main() {
 	flag.Parse()
 
-	config, err := config.LoadConfig(configPath)
+	format, err := app.ParseOutput(outputFormat)
 	if err != nil {
-		fmt.Printf("Error loading config file: %v\n", err)
+		fmt.Printf("Invalid -output value: %v\n", err)
+		os.Exit(1)
+	}
+	reporter = app.NewReporter(format, os.Stdout, os.Stderr)
+
+	if listCommands || command == "help" {
+		if err := app.WriteCommandHelp(os.Stdout); err != nil {
+			fail("Error writing command list: %v", err)
+		}
+		reporter.Finish(command, 0, nil)
+		return
+	}
+	if listDrivers {
+		command = "drivers"
+	}
+	if cmd, ok := app.LookupCommand(command); ok && cmd.Standalone {
+		if err := cmd.Run(nil, app.CommandArgs{Out: os.Stdout}); err != nil {
+			fail("Error running %s: %v", command, err)
+		}
+		reporter.Finish(command, 0, nil)
 		return
 	}
 
-	if path == "" {
-		path = config.MigratorOpt.Dir
-	} else {
-		path = os.ExpandEnv(path)
+	// Явно указанный -config должен существовать: молча игнорировать его нельзя.
+	cfg, err := config.Load(configPath, requireConfig || isFlagSet("config"))
+	if err != nil {
+		fail("Error loading config file: %v", err)
 	}
 
-	if database == "" {
-		database = config.MigratorOpt.DSN
-	} else {
-		database = os.ExpandEnv(database)
+	path = config.Resolve(path, cfg.MigratorOpt.Dir)
+	database = config.ResolveDSN(database, dsnComponents(), cfg.MigratorOpt.DSN)
+	if dsnFrom != "" {
+		database, err = config.ResolveSecret(context.Background(), dsnFrom)
+		if err != nil {
+			fail("Error resolving -dsn-from: %v", err)
+		}
+	}
+	trackingTable = config.Resolve(trackingTable, cfg.MigratorOpt.TableName)
+	if trackingTable != "" {
+		if err := storage.ValidateTrackingTable(trackingTable); err != nil {
+			fail("Invalid -table value: %v", err)
+		}
 	}
 
 	if migrationName == "" {
 		migrationName = os.Getenv("NAME")
 	}
 
-	if path == "" || database == "" {
-		fmt.Println("Path to migrations and database connection string must be provided.")
-		return
+	dsns := []string{database}
+	if dsnFile != "" {
+		dsns, err = config.ReadDSNFile(dsnFile)
+		if err != nil {
+			fail("Error loading DSN file: %v", err)
+		}
+	}
+	// positions — номера строк подключения в исходном списке для меток баз.
+	positions := make([]int, len(dsns))
+	for i := range positions {
+		positions[i] = i
+	}
+	totalDSNs := len(dsns)
+	if shardSpec != "" {
+		shard, err := config.ParseShard(shardSpec)
+		if err != nil {
+			fail("Error: %v", err)
+		}
+		positions = shard.Select(dsns)
+		if len(positions) == 0 {
+			fail("Shard %s selects none of the %d databases.", shard, len(dsns))
+		}
+		selected := make([]string, 0, len(positions))
+		for _, position := range positions {
+			selected = append(selected, dsns[position])
+		}
+		dsns = selected
 	}
 
 	if command == "" {
-		fmt.Println("Command must be provided.")
-		return
+		fail("Command must be provided.")
+	}
+
+	cmd, ok := app.LookupCommand(command)
+	if !ok {
+		fail("Invalid operation. Use one of the following: %s.", app.CommandNames())
+	}
+
+	if (path == "" && cmd.RequiresPath()) || dsns[0] == "" {
+		fail("Path to migrations and database connection string must be provided.")
+	}
+	if !cmd.FilesOnly {
+		for i, dsn := range dsns {
+			if err := config.ValidateDSN(dsn); err != nil {
+				fail("Invalid connection string #%d: %v", i+1, err)
+			}
+		}
+	}
+
+	statusFormat, err = app.ParseStatusFormat(statusFormat)
+	if err != nil {
+		fail("Invalid -format value: %v", err)
+	}
+
+	args := app.CommandArgs{
+		Path:            path,
+		Name:            migrationName,
+		Version:         version,
+		From:            fromVersion,
+		To:              renameTo,
+		Count:           count,
+		Force:           force,
+		Label:           statusLabel,
+		Template:        statusTmpl,
+		Format:          statusFormat,
+		Since:           since,
+		Expected:        expectedPath,
+		Golden:          goldenPath,
+		Plan:            planPath,
+		AppliedManifest: manifestPath,
+		Retention:       retention,
+		LockKey:         lockKey,
+		LockTTL:         lockTTL,
+		Out:             os.Stdout,
+	}
+	var outFile *os.File
+	if outPath != "" {
+		outFile, err = os.Create(outPath)
+		if err != nil {
+			fail("Error creating output file: %v", err)
+		}
+		args.Out = outFile
+	}
+	var notifier *app.Notifier
+	runCommand := func(application *app.Application) error {
+		prepareApplication(application, notifier, args.Out)
+		return cmd.Run(application, args)
+	}
+
+	if runAs != "" {
+		if err := storage.ValidateRole(runAs); err != nil {
+			fail("Invalid -run-as value: %v", err)
+		}
+	}
+
+	for _, statement := range preLock {
+		if err := storage.ValidateSessionStatement(statement); err != nil {
+			fail("Invalid -pre-lock-statement value: %v", err)
+		}
+	}
+
+	skipVersions, err := processes.ParseVersionList(skip)
+	if err != nil {
+		fail("Invalid -skip value: %v", err)
+	}
+
+	if noopExitCode < 0 || noopExitCode > 125 {
+		fail("Invalid -exit-code-on-noop value: %d (expected 0-125)", noopExitCode)
+	}
+
+	scope, err := processes.ParseLockScope(lockScope)
+	if err != nil {
+		fail("Invalid -lock-scope value: %v", err)
+	}
+
+	dirtyPolicy, err := processes.ParseOnDirty(onDirty)
+	if err != nil {
+		fail("Invalid -on-dirty value: %v", err)
+	}
+
+	stmtDelimiter, err := processes.ParseDelimiter(delimiter)
+	if err != nil {
+		fail("Invalid -delimiter value: %v", err)
+	}
+
+	icons, err := processes.ParseStatusIcons(statusIcons)
+	if err != nil {
+		fail("Invalid -status-icons value: %v", err)
+	}
+
+	simulateFailureVersion := 0
+	if simulateFail != "" {
+		simulateFailureVersion, err = processes.ParseSimulateFailure(simulateFail)
+		if err != nil {
+			fail("Invalid -simulate-failure value: %v", err)
+		}
 	}
 
 	l := logger.New()
-	db := storage.NewPostgresStorage(database, l)
-	application := app.New(l, db)
-
-	switch command {
-	case "create":
-		application.Create(migrationName, path, "sql")
-	case "up":
-		application.Up(path)
-	case "down":
-		application.Down(path)
-	case "redo":
-		application.Redo(path)
-	case "status":
-		application.Status()
-	case "dbversion":
-		application.DBVersion()
+	if shardSpec != "" {
+		l.Info("Shard %s: migrating %d of %d databases", shardSpec, len(dsns), totalDSNs)
+	}
+	if notifyURL != "" {
+		notifier, err = app.NewNotifier(notifyURL, notifyOn, notifyTimeout, l)
+		if err != nil {
+			fail("Invalid -notify-on value: %v", err)
+		}
+	}
+
+	opts := processes.Options{
+		RunAs:                 runAs,
+		CheckPerms:            checkPerms,
+		StatementTimeout:      stmtTimeout,
+		PreLockStatements:     preLock,
+		DeadlockRetries:       deadlockRetry,
+		Delimiter:             stmtDelimiter,
+		SkipVersions:          skipVersions,
+		ApplySkipped:          applySkipped,
+		OnlySQL:               onlySQL,
+		PostUpAnalyze:         postUpAnalyze || postUpVacuum,
+		PostUpVacuum:          postUpVacuum,
+		Gates:                 gates,
+		StatusLabel:           statusLabel,
+		StatusVerbose:         verbose,
+		StatusWide:            wide,
+		StatusFull:            statusFull,
+		StatusVerifyChecksums: verifySums,
+		StatusIcons:           icons,
+		PlainVersion:          plainVersion,
+		LockScope:             scope,
+		LockWait:              lockWait,
+		AbortLockWait:         abortLockWait,
+		CommitEvery:           commitEvery,
+		LockRetryInterval:     lockRetry,
+		HeartbeatInterval:     heartbeat,
+		MaxParallelStatements: maxParallel,
+		LockJitter:            lockJitter,
+		OnDirty:               dirtyPolicy,
+
+		ContinueOnMissingDown:  continueRedo,
+		SimulateFailureVersion: simulateFailureVersion,
+	}
+
+	newLoggedStorage := func(dsn string, l logger.Logger) storage.SQLStorage {
+		driver, err := storage.LookupDriver(storage.DriverName(dsn))
+		if err != nil {
+			fail("Invalid connection string: %v", err)
+		}
+		st := driver.Open(dsn, l)
+		// Настройки ниже есть только у хранилища PostgreSQL.
+		db, ok := st.(*storage.PostgresStorage)
+		if !ok {
+			return st
+		}
+		if diagnoseLock {
+			db.EnableLockDiagnostics(lockDiagnosticsInterval)
+		}
+		db.SetForceRecreateTable(forceRecreate)
+		db.SetAutoCreateTable(!noAutoCreate)
+		db.SetApplicationName(appName)
+		if trackingTable != "" {
+			// Имя таблицы уже проверено при разборе флагов.
+			_ = db.SetTrackingTable(trackingTable)
+		}
+		return db
+	}
+	newStorage := func(dsn string) storage.SQLStorage {
+		return newLoggedStorage(dsn, l)
+	}
+
+	if scratchDSN != "" {
+		if err := config.ValidateDSN(scratchDSN); err != nil {
+			fail("Invalid -scratch-dsn value: %v", err)
+		}
+		args.Scratch = newStorage(scratchDSN)
+	}
+
+	if cmd.Name == "test-migrate" {
+		args.TestDatabase, err = app.NewPostgresTestDatabase(dsns[0], l, func(dsn string) storage.SQLStorage {
+			return newStorage(dsn)
+		})
+		if err != nil {
+			fail("Invalid connection string: %v", err)
+		}
+	}
+
+	shards := make([]app.Shard, 0, len(dsns))
+	for i, dsn := range dsns {
+		shards = append(shards, app.Shard{
+			ID: fmt.Sprintf("shard-%d", positions[i]+1),
+			Open: func(l logger.Logger) storage.SQLStorage {
+				return newLoggedStorage(dsn, l)
+			},
+		})
+	}
+	if cmd.AllShards && len(shards) > 1 {
+		args.Shards = shards
+	}
+
+	if len(dsns) == 1 || cmd.FilesOnly || cmd.AllShards {
+		application := app.New(l, newStorage(dsns[0]))
+		application.Options = opts
+		// При нескольких базах запросы из параллельных шардов перемешались бы,
+		// поэтому подтверждение там возможно только через -assume-yes.
+		application.EnablePrompts(os.Stdin, os.Stdout)
+		if interactive && !application.EnableInteractive(os.Stdin, os.Stdout) {
+			l.Warn("Standard input is not a terminal; -interactive is disabled")
+		}
+		err = runCommand(application)
+	} else {
+		_, err = app.RunShards(l, shards, opts, parallel, runCommand)
+	}
+
+	if outFile != nil {
+		if closeErr := outFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	exitCode := 0
+	switch {
+	case err == nil:
+	case errors.Is(err, app.ErrNoOp):
+		exitCode = noopExitCode
+	case errors.Is(err, app.ErrNoMigrations):
+		exitCode = exitNoMigrations
 	default:
-		fmt.Println("Invalid operation. Use one of the following: create, up, down, redo, status, dbversion.")
+		exitCode = 1
+	}
+	reporter.Finish(command, exitCode, err)
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// prepareApplication переносит в application параметры запуска из флагов.
+// Вызывается и при одной базе, и для каждого шарда RunShards, поэтому всё,
+// что влияет на выполнение команды, задаётся здесь.
+func prepareApplication(application *app.Application, notifier *app.Notifier, out io.Writer) {
+	if notifier != nil {
+		application.AfterRun = append(application.AfterRun, notifier.Notify)
+	}
+	application.StorePlan = storePlan
+	application.FromGit = fromGit
+	application.AllowMissing = allowMissing
+	application.AssumeYes = assumeYes
+	application.ReadOnly = readOnly
+	if preview {
+		application.Preview = out
+	}
+	application.ReportNoOp = noopExitCode != 0
+	application.Reporter = reporter
+}
+
+// fail сообщает об ошибке подготовки запуска через reporter и завершает
+// процесс с кодом 1.
+func fail(format string, a ...interface{}) {
+	reporter.Fail(command, 1, fmt.Errorf(format, a...))
+	os.Exit(1)
+}
+
+// dsnComponents собирает части строки подключения из флагов -host, -port,
+// -user, -password, -dbname и -sslmode.
+func dsnComponents() config.DSN {
+	components := config.DSN{User: dbUser, Password: dbPassword, Database: dbName}
+	if dbHost != "" {
+		components.Hosts = strings.Split(dbHost, ",")
 	}
+	if dbPort != "" {
+		components.Ports = strings.Split(dbPort, ",")
+	}
+	if sslMode != "" {
+		components.Params = map[string]string{"sslmode": sslMode}
+	}
+	return components
+}
+
+// stringList — значение повторяемого строкового флага.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, "; ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
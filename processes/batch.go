@@ -0,0 +1,56 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidBatch = errors.New("некорректный размер пакета")
+
+// batchSize возвращает размер пакета из директивы "-- migrator:batch 10000".
+// Ноль означает, что директивы нет и SQL выполняется целиком.
+func batchSize(sql string) (int, error) {
+	args := directiveArgs(sql, "batch")
+	if len(args) == 0 {
+		return 0, nil
+	}
+
+	size, err := strconv.Atoi(strings.TrimSpace(args[len(args)-1]))
+	if err != nil || size < 1 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidBatch, args[len(args)-1])
+	}
+	return size, nil
+}
+
+// migrateSQL выполняет SQL миграции. Команды миграции с директивой
+// "-- migrate:parallel" выполняются одновременно, см. migrateParallel.
+// Миграция с директивой "-- migrator:batch N" — один параметризованный запрос, изменяющий не более
+// $1 строк; он повторяется пакетами по N строк, каждый пакет фиксируется
+// отдельно, пока очередной пакет не изменит ни одной строки. Возвращает
+// число изменённых строк. Непустой savepoint — точка сохранения открытой
+// транзакции, к которой откатывается неудачная попытка, см. migrateWithRetry.
+func (m *Migrator) migrateSQL(ctx context.Context, sql, savepoint string) (int64, error) {
+	if isParallel(sql) {
+		return m.migrateParallel(ctx, sql)
+	}
+
+	size, err := batchSize(sql)
+	if err != nil {
+		return 0, err
+	}
+	if size == 0 {
+		return m.migrateWithRetry(ctx, sql, savepoint)
+	}
+
+	total, err := m.storage.MigrateBatches(ctx, sql, size, func(batch int, rows, total int64) {
+		m.logger.Info("Пакет %d: изменено строк %d, всего %d", batch, rows, total)
+	})
+	if err != nil {
+		return total, err
+	}
+	m.logger.Info("Пакетная миграция завершена, изменено строк: %d", total)
+	return total, nil
+}
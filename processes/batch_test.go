@@ -0,0 +1,61 @@
+package processes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const backfillSQL = `-- migrator:batch 10000
+UPDATE users SET email_lower = lower(email)
+WHERE id IN (SELECT id FROM users WHERE email_lower IS NULL LIMIT $1);`
+
+func TestBatchSize(t *testing.T) {
+	size, err := batchSize(backfillSQL)
+	require.NoError(t, err)
+	assert.Equal(t, 10000, size)
+
+	size, err = batchSize("CREATE TABLE users (id serial);")
+	require.NoError(t, err)
+	assert.Equal(t, 0, size)
+
+	for _, sql := range []string{"-- migrator:batch", "-- migrator:batch 0", "-- migrator:batch many"} {
+		_, err := batchSize(sql)
+		assert.ErrorIs(t, err, ErrInvalidBatch, sql)
+	}
+}
+
+func TestUpRunsBatchesUntilNoRowsAffected(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	st.SetBatchRows(10000, 10000, 3500)
+
+	migrator := New(st, logger.New())
+	migrator.Create("backfill_email_lower", backfillSQL, "", nil, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, []string{backfillSQL, backfillSQL, backfillSQL, backfillSQL}, st.ExecutedSQL())
+
+	status, err := migrator.VersionStatus(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.StatusSuccess, status)
+}
+
+func TestUpRejectsInvalidBatchSize(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+
+	migrator := New(st, logger.New())
+	migrator.Create("backfill_email_lower", "-- migrator:batch 0\nUPDATE users SET flag = true;", "", nil, nil)
+
+	assert.ErrorIs(t, migrator.Up(ctx), ErrMigrationUp)
+	assert.Empty(t, st.ExecutedSQL())
+
+	status, err := migrator.VersionStatus(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.StatusError, status)
+}
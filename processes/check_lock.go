@@ -0,0 +1,38 @@
+package processes
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+// CheckLock берёт сессионную блокировку мигратора и сразу снимает её, не
+// трогая миграции. Занятая блокировка — ошибка ErrLockBusy, а при заданном
+// Options.LockWait — ErrLockWaitTimeout после ожидания. Область блокировки
+// не учитывается: проверяется именно блокировка, которую возьмёт up.
+func (m *Migrator) CheckLock(ctx context.Context) error {
+	if err := m.checkWritable(); err != nil {
+		return err
+	}
+
+	if m.options.LockWait > 0 {
+		if _, err := m.retryLock(ctx); err != nil {
+			return err
+		}
+	} else if err := m.storage.TryLock(ctx); err != nil {
+		if errors.Is(err, storage.ErrLockBusy) {
+			m.logger.Error("Блокировка занята другим процессом")
+		} else {
+			m.logger.Error("Ошибка при блокировке: %v", err)
+		}
+		return err
+	}
+
+	if err := m.storage.Unlock(ctx); err != nil {
+		m.logger.Error("Ошибка при разблокировке: %v", err)
+		return err
+	}
+	m.logger.Info("Блокировка успешно взята и снята")
+	return nil
+}
@@ -0,0 +1,57 @@
+package processes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLockAcquiresAndReleases(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{LockScope: LockScopeMigration})
+
+	assert.NoError(t, migrator.CheckLock(context.Background()))
+	assert.Equal(t, 1, st.LockCalls(), "The session lock is checked regardless of the lock scope")
+	assert.Equal(t, 1, st.UnlockCalls())
+}
+
+func TestCheckLockBusy(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	st.SetLockBusy(1)
+	migrator := New(st, logger.New())
+
+	assert.ErrorIs(t, migrator.CheckLock(context.Background()), storage.ErrLockBusy)
+	assert.Equal(t, 0, st.UnlockCalls(), "A lock that was not acquired is not released")
+}
+
+func TestCheckLockWaitsForBusyLock(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	st.SetLockBusy(2)
+	migrator := New(st, logger.New()).WithOptions(Options{LockWait: time.Second, LockRetryInterval: time.Millisecond})
+
+	assert.NoError(t, migrator.CheckLock(context.Background()))
+	assert.Equal(t, 1, st.LockCalls())
+	assert.Equal(t, 1, st.UnlockCalls())
+}
+
+func TestCheckLockWaitTimeout(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	st.SetLockBusy(1000)
+	migrator := New(st, logger.New()).WithOptions(Options{LockWait: 5 * time.Millisecond, LockRetryInterval: time.Millisecond})
+
+	assert.ErrorIs(t, migrator.CheckLock(context.Background()), ErrLockWaitTimeout)
+	assert.Equal(t, 0, st.UnlockCalls())
+}
+
+func TestCheckLockRefusesReplica(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	st.SetInRecovery(true)
+	migrator := New(st, logger.New())
+
+	assert.ErrorIs(t, migrator.CheckLock(context.Background()), ErrReadOnlyDatabase)
+	assert.Equal(t, 0, st.LockCalls())
+}
@@ -0,0 +1,82 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("применённые миграции изменены после применения")
+	ErrNothingToVerify  = errors.New("не загружены файлы миграций для сверки контрольных сумм")
+)
+
+// ModifiedMigrations возвращает успешно применённые миграции, исходные файлы
+// которых (SQL или Go) изменились после применения. Миграции, записанные без
+// контрольной суммы (например, старой версией мигратора), не проверяются.
+func (m *Migrator) ModifiedMigrations(ctx context.Context) ([]storage.Migration, error) {
+	recorded, err := m.storage.SelectMigrations(ctx)
+	if err != nil && !errors.Is(err, storage.ErrMigrationNotFound) {
+		m.logger.Error("Ошибка при получении статуса: %v", err)
+		return nil, ErrGetStatus
+	}
+
+	checksums := make(map[int]string, len(recorded))
+	for _, migr := range recorded {
+		if migr.GetStatus() == storage.StatusSuccess {
+			checksums[migr.GetVersion()] = migr.GetChecksum()
+		}
+	}
+
+	var modified []storage.Migration
+	for _, migration := range m.migrations {
+		stored, ok := checksums[migration.Version]
+		if !ok || stored == "" || migration.Checksum == "" || stored == migration.Checksum {
+			continue
+		}
+		modified = append(modified, migration)
+	}
+	return modified, nil
+}
+
+// Verify сверяет контрольные суммы применённых миграций с файлами на диске
+// и возвращает ErrChecksumMismatch, если хотя бы одна миграция изменена.
+func (m *Migrator) Verify(ctx context.Context) error {
+	all, err := m.ModifiedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	var modified []storage.Migration
+	for _, migration := range all {
+		if m.isSelected(migration.Version) {
+			modified = append(modified, migration)
+		}
+	}
+	if len(modified) == 0 {
+		m.logger.Info("Контрольные суммы применённых миграций совпадают с файлами")
+		return nil
+	}
+
+	for _, migration := range modified {
+		m.warnModified(migration)
+	}
+	return checksumMismatch(modified)
+}
+
+// checksumMismatch возвращает ErrChecksumMismatch с версиями изменённых миграций.
+func checksumMismatch(modified []storage.Migration) error {
+	versions := make([]string, 0, len(modified))
+	for _, migration := range modified {
+		versions = append(versions, strconv.Itoa(migration.Version))
+	}
+	return fmt.Errorf("%w: %s", ErrChecksumMismatch, strings.Join(versions, ", "))
+}
+
+func (m *Migrator) warnModified(migration storage.Migration) {
+	m.logger.Warn("Миграция %d (%s) изменена после применения: %s",
+		migration.Version, migration.Name, migration.SourceFile)
+}
@@ -0,0 +1,104 @@
+package processes
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyDetectsEditedMigration(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+
+	applied := New(st, logger.New())
+	applied.Add(storage.Migration{Name: "create_users", Up: "CREATE TABLE users (id serial);", Checksum: "aaa"})
+	applied.Add(storage.Migration{Name: "seed_users", UpGo: func(ctx context.Context) error { return nil }, Checksum: "bbb"})
+	require.NoError(t, applied.Up(ctx))
+
+	unchanged := New(st, logger.New())
+	unchanged.Add(storage.Migration{Name: "create_users", Checksum: "aaa"})
+	unchanged.Add(storage.Migration{Name: "seed_users", Checksum: "bbb"})
+	assert.NoError(t, unchanged.Verify(ctx))
+
+	edited := New(st, logger.New())
+	edited.Add(storage.Migration{Name: "create_users", Checksum: "aaa"})
+	edited.Add(storage.Migration{Name: "seed_users", Checksum: "ccc"})
+	err := edited.Verify(ctx)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.Contains(t, err.Error(), ": 2")
+
+	modified, err := edited.ModifiedMigrations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, versionsOf(modified))
+}
+
+func TestVerifyIgnoresMigrationsWithoutChecksum(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+
+	// Запись без контрольной суммы сделана мигратором до её появления.
+	require.NoError(t, st.InsertMigration(ctx, storage.CreateMigration("create_users", storage.StatusSuccess, 1, time.Now())))
+
+	migrator := New(st, logger.New())
+	migrator.Add(storage.Migration{Name: "create_users", Checksum: "aaa"})
+	assert.NoError(t, migrator.Verify(ctx))
+}
+
+// driftLines возвращает строки статуса с меткой DRIFT.
+func driftLines(lines []string) []string {
+	var drift []string
+	for _, line := range lines {
+		if strings.HasSuffix(line, " DRIFT") {
+			drift = append(drift, line)
+		}
+	}
+	return drift
+}
+
+func TestStatusVerifyChecksumsFlagsDrift(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+
+	applied := New(st, logger.New())
+	applied.Add(storage.Migration{Name: "create_users", Up: "CREATE TABLE users (id serial);", Checksum: "aaa"})
+	applied.Add(storage.Migration{Name: "create_orders", Up: "CREATE TABLE orders (id serial);", Checksum: "bbb"})
+	require.NoError(t, applied.Up(ctx))
+
+	out := &lineRecorder{ZeroLogger: logger.New()}
+	edited := New(st, out).WithOptions(Options{StatusVerifyChecksums: true})
+	edited.Add(storage.Migration{Name: "create_users", Checksum: "aaa"})
+	edited.Add(storage.Migration{Name: "create_orders", Checksum: "ccc"})
+
+	err := edited.Status(ctx)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	drift := driftLines(out.lines)
+	require.Len(t, drift, 1)
+	assert.Contains(t, drift[0], "create_orders")
+}
+
+func TestStatusVerifyChecksumsCleanHistory(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+
+	applied := New(st, logger.New())
+	applied.Add(storage.Migration{Name: "create_users", Up: "CREATE TABLE users (id serial);", Checksum: "aaa"})
+	require.NoError(t, applied.Up(ctx))
+
+	out := &lineRecorder{ZeroLogger: logger.New()}
+	unchanged := New(st, out).WithOptions(Options{StatusVerifyChecksums: true})
+	unchanged.Add(storage.Migration{Name: "create_users", Checksum: "aaa"})
+
+	assert.NoError(t, unchanged.Status(ctx))
+	assert.Empty(t, driftLines(out.lines))
+}
+
+func TestStatusVerifyChecksumsNeedsMigrationFiles(t *testing.T) {
+	migrator := New(storage.NewMockSQLStorage(), logger.New()).WithOptions(Options{StatusVerifyChecksums: true})
+	assert.ErrorIs(t, migrator.Status(context.Background()), ErrNothingToVerify)
+}
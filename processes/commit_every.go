@@ -0,0 +1,106 @@
+package processes
+
+import (
+	"context"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+// downBatch объединяет откаты нескольких версий в одну транзакцию,
+// фиксируя её после каждых Options.CommitEvery миграций. Сбой отката
+// отменяет всю незафиксированную часть пакета: уже зафиксированные
+// пакеты остаются откаченными.
+type downBatch struct {
+	m     *Migrator
+	every int
+	open  bool
+	size  int
+	// mark — число откаченных миграций в Result до начала пакета.
+	mark int
+}
+
+// newDownBatch создаёт пакет отката. При области LockScopeMigration каждая
+// миграция откатывается в собственной транзакции под pg_advisory_xact_lock,
+// поэтому миграции в пакеты не объединяются.
+func (m *Migrator) newDownBatch() *downBatch {
+	if m.options.LockScope == LockScopeMigration {
+		return &downBatch{m: m, every: 1}
+	}
+	return &downBatch{m: m, every: m.options.CommitEvery}
+}
+
+// batchable сообщает, может ли откат миграции выполняться в общей
+// транзакции пакета. Go-шаги и директивы parallel, report, expect-rows
+// и batch сами управляют транзакциями, поэтому такие миграции
+// откатываются отдельно.
+// Директива timeout задаёт statement_timeout через пул, единственное
+// соединение которого занято транзакцией пакета, и тоже исключается.
+func batchable(migration storage.Migration) bool {
+	if migration.DownGo != nil || isParallel(migration.Down) || hasReports(migration.Down) ||
+		len(directiveArgs(migration.Down, "expect-rows")) > 0 {
+		return false
+	}
+	if len(directiveArgs(migration.Down, "timeout")) > 0 {
+		return false
+	}
+	size, err := batchSize(migration.Down)
+	return err == nil && size == 0
+}
+
+// down откатывает миграцию в текущем пакете или, если она не может
+// в него войти, отдельно после фиксации пакета.
+func (b *downBatch) down(ctx context.Context, migration *storage.Migration) error {
+	if b.every <= 1 || !batchable(*migration) {
+		if err := b.commit(ctx); err != nil {
+			return err
+		}
+		return b.m.downMigration(ctx, migration, migration.Down, migration.DownGo)
+	}
+
+	if !b.open {
+		if err := b.m.storage.Begin(ctx); err != nil {
+			b.m.logger.Error("Ошибка при открытии транзакции: %v", err)
+			return err
+		}
+		b.open, b.size, b.mark = true, 0, len(b.m.result.RolledBack)
+	}
+
+	if err := b.m.downMigration(ctx, migration, migration.Down, migration.DownGo); err != nil {
+		b.abort(ctx)
+		// Запись об ошибке внутри прерванной транзакции отменена вместе с ней.
+		b.m.recordFailure(ctx, migration, storage.StatusError, err)
+		return err
+	}
+
+	b.size++
+	if b.size >= b.every {
+		return b.commit(ctx)
+	}
+	return nil
+}
+
+// commit фиксирует открытый пакет.
+func (b *downBatch) commit(ctx context.Context) error {
+	if !b.open {
+		return nil
+	}
+	b.open = false
+	if err := b.m.storage.Commit(ctx); err != nil {
+		b.m.logger.Error("Ошибка при фиксации транзакции: %v", err)
+		b.m.result.RolledBack = b.m.result.RolledBack[:b.mark]
+		return err
+	}
+	b.m.logger.Info("Зафиксирован откат %d миграций", b.size)
+	return nil
+}
+
+// abort откатывает открытый пакет и убирает его миграции из Result.
+func (b *downBatch) abort(ctx context.Context) {
+	if !b.open {
+		return
+	}
+	b.open = false
+	b.m.rollback(ctx)
+	b.m.result.RolledBack = b.m.result.RolledBack[:b.mark]
+	b.m.logger.Warn("Откат %d миграций незафиксированного пакета отменён", b.size)
+}
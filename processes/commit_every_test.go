@@ -0,0 +1,124 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingDownStorage возвращает ошибку при выполнении SQL failSQL.
+type failingDownStorage struct {
+	*storage.MockSQLStorage
+	failSQL string
+}
+
+func (s failingDownStorage) Migrate(ctx context.Context, sql string) (int64, error) {
+	if sql == s.failSQL {
+		return 0, errors.New("relation is in use")
+	}
+	return s.MockSQLStorage.Migrate(ctx, sql)
+}
+
+func newCommitEveryMigrator(t *testing.T, st storage.SQLStorage, every int) *Migrator {
+	t.Helper()
+	migrator := New(st, logger.New())
+	for i := 1; i <= 5; i++ {
+		migrator.Create(fmt.Sprintf("create_t%d", i),
+			fmt.Sprintf("CREATE TABLE t%d (id serial);", i), fmt.Sprintf("DROP TABLE t%d;", i), nil, nil)
+	}
+	require.NoError(t, migrator.Up(context.Background()))
+	return migrator.WithOptions(Options{CommitEvery: every})
+}
+
+func TestDownToCommitsEachMigrationByDefault(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	migrator := newCommitEveryMigrator(t, st, 0)
+
+	require.NoError(t, migrator.DownTo(context.Background(), 0))
+	assert.Empty(t, st.TxLog(), "Each rollback commits on its own without an explicit transaction")
+	assert.Len(t, migrator.Result().RolledBack, 5)
+}
+
+func TestDownToCommitsEveryN(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	migrator := newCommitEveryMigrator(t, st, 2)
+
+	require.NoError(t, migrator.DownTo(context.Background(), 0))
+	assert.Equal(t, []string{"BEGIN", "COMMIT", "BEGIN", "COMMIT", "BEGIN", "COMMIT"}, st.TxLog())
+	assert.Len(t, migrator.Result().RolledBack, 5)
+
+	statuses, err := st.SelectAppliedVersions(context.Background())
+	require.NoError(t, err)
+	for version := 1; version <= 5; version++ {
+		assert.Equal(t, storage.StatusCancel, statuses[version])
+	}
+}
+
+func TestDownToCommitsBatchBeforeGoMigration(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	migrator.Create("create_t1", "CREATE TABLE t1 (id serial);", "DROP TABLE t1;", nil, nil)
+	migrator.Create("seed_t1", "", "", nil, func(ctx context.Context) error { return nil })
+	migrator.Create("create_t3", "CREATE TABLE t3 (id serial);", "DROP TABLE t3;", nil, nil)
+	migrator.Create("create_t4", "CREATE TABLE t4 (id serial);", "DROP TABLE t4;", nil, nil)
+	require.NoError(t, migrator.Up(ctx))
+	migrator = migrator.WithOptions(Options{CommitEvery: 3})
+
+	txStart := len(st.TxLog())
+	require.NoError(t, migrator.DownTo(ctx, 0))
+	assert.Equal(t, []string{
+		"BEGIN", "COMMIT", // версии 4 и 3
+		"BEGIN", "COMMIT", // Go-миграция 2 в собственной транзакции
+		"BEGIN", "COMMIT", // версия 1
+	}, st.TxLog()[txStart:])
+}
+
+func TestDownToCommitsBatchBeforeMigrationWithTimeout(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	migrator.Create("create_t1", "CREATE TABLE t1 (id serial);", "DROP TABLE t1;", nil, nil)
+	migrator.Create("create_t2", "CREATE TABLE t2 (id serial);", "-- migrator:timeout 1m\nDROP TABLE t2;", nil, nil)
+	migrator.Create("create_t3", "CREATE TABLE t3 (id serial);", "DROP TABLE t3;", nil, nil)
+	require.NoError(t, migrator.Up(ctx))
+	migrator = migrator.WithOptions(Options{CommitEvery: 3})
+
+	assert.False(t, batchable(migrator.migrations[1]))
+	require.NoError(t, migrator.DownTo(ctx, 0))
+	assert.Equal(t, []string{
+		"BEGIN", "COMMIT", // версия 3
+		"BEGIN", "COMMIT", // версия 1; версия 2 с тайм-аутом откатывается вне пакета
+	}, st.TxLog())
+	assert.Len(t, migrator.Result().RolledBack, 3)
+}
+
+func TestDownToFailureRollsBackOpenBatch(t *testing.T) {
+	ctx := context.Background()
+	st := failingDownStorage{MockSQLStorage: storage.NewMockSQLStorage(), failSQL: "DROP TABLE t2;"}
+	migrator := newCommitEveryMigrator(t, st, 2)
+
+	assert.ErrorIs(t, migrator.DownTo(ctx, 0), ErrMigrationDown)
+	assert.Equal(t, []string{"BEGIN", "COMMIT", "BEGIN", "ROLLBACK"}, st.TxLog())
+	assert.Equal(t, []string{
+		"CREATE TABLE t1 (id serial);", "CREATE TABLE t2 (id serial);", "CREATE TABLE t3 (id serial);",
+		"CREATE TABLE t4 (id serial);", "CREATE TABLE t5 (id serial);",
+		"DROP TABLE t5;", "DROP TABLE t4;",
+	}, st.ExecutedSQL(), "The rollback of version 3 is undone with its batch")
+
+	var rolledBack []int
+	for _, migration := range migrator.Result().RolledBack {
+		rolledBack = append(rolledBack, migration.Version)
+	}
+	assert.Equal(t, []int{5, 4}, rolledBack)
+
+	status, err := migrator.VersionStatus(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, storage.StatusError, status)
+}
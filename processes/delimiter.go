@@ -0,0 +1,177 @@
+package processes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var ErrInvalidDelimiter = errors.New("некорректный разделитель команд")
+
+// DefaultDelimiter — разделитель команд SQL по умолчанию.
+const DefaultDelimiter = ";"
+
+// ParseDelimiter проверяет значение флага -delimiter: разделитель не может
+// быть пустым или содержать пробельные символы.
+func ParseDelimiter(s string) (string, error) {
+	if s == "" {
+		return DefaultDelimiter, nil
+	}
+	if strings.IndexFunc(s, unicode.IsSpace) >= 0 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidDelimiter, s)
+	}
+	return s, nil
+}
+
+// DelimiterDirective возвращает разделитель из директивы
+// "-- migrator:delimiter /" или пустую строку, если директивы нет. Директива
+// без значения или со значением, содержащим пробелы, — ошибка
+// ErrInvalidDelimiter; файлы с ней отклоняются при загрузке.
+func DelimiterDirective(sql string) (string, error) {
+	args := directiveArgs(sql, "delimiter")
+	if len(args) == 0 {
+		return "", nil
+	}
+	value := strings.TrimSpace(args[len(args)-1])
+	if value == "" {
+		return "", fmt.Errorf("%w: директива delimiter без значения", ErrInvalidDelimiter)
+	}
+	return ParseDelimiter(value)
+}
+
+// delimiter возвращает разделитель команд миграции: директива
+// "-- migrator:delimiter /" в файле имеет приоритет над Options.Delimiter.
+func (m *Migrator) delimiter(sql string) string {
+	if delimiter, err := DelimiterDirective(sql); err == nil && delimiter != "" {
+		return delimiter
+	}
+	if m.options.Delimiter != "" {
+		return m.options.Delimiter
+	}
+	return DefaultDelimiter
+}
+
+// normalizeDelimiter переписывает миграцию с нестандартным разделителем
+// (например, "/" после блоков PL/SQL) в команды, разделённые ";", которые
+// понимает Postgres. Миграция с разделителем по умолчанию не меняется.
+func (m *Migrator) normalizeDelimiter(sql string) string {
+	delimiter := m.delimiter(sql)
+	if delimiter == DefaultDelimiter {
+		return sql
+	}
+
+	// ";" ставится на отдельной строке, чтобы не оказаться внутри
+	// однострочного комментария в конце команды.
+	var normalized strings.Builder
+	for _, statement := range splitStatements(sql, delimiter) {
+		normalized.WriteString(statement)
+		normalized.WriteString("\n;\n")
+	}
+	return normalized.String()
+}
+
+// splitStatements делит SQL на команды по разделителю delimiter. Разделитель
+// завершает команду, только если за ним до конца строки нет ничего, кроме
+// пробелов, и он стоит вне строковых литералов, идентификаторов в кавычках,
+// тел в долларовых кавычках и комментариев. Поэтому "/" в выражении "a / b"
+// команду не завершает, а "/" на отдельной строке — завершает.
+func splitStatements(sql, delimiter string) []string {
+	var (
+		statements []string
+		start      int
+	)
+	add := func(statement string) {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+
+	for i := 0; i < len(sql); {
+		rest := sql[i:]
+		switch {
+		case strings.HasPrefix(rest, "--"):
+			i += lineEnd(rest)
+		case strings.HasPrefix(rest, "/*"):
+			i += blockCommentEnd(rest)
+		case rest[0] == '\'' || rest[0] == '"':
+			i += quotedEnd(rest, rest[0])
+		case rest[0] == '$' && dollarTag(rest) != "":
+			tag := dollarTag(rest)
+			end := strings.Index(rest[len(tag):], tag)
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += len(tag) + end + len(tag)
+			}
+		case strings.HasPrefix(rest, delimiter) && strings.TrimSpace(rest[len(delimiter):lineEnd(rest)]) == "":
+			add(sql[start:i])
+			i += len(delimiter)
+			start = i
+		default:
+			i++
+		}
+	}
+	add(sql[start:])
+	return statements
+}
+
+// lineEnd возвращает длину s до конца первой строки (без перевода строки).
+func lineEnd(s string) int {
+	if end := strings.IndexByte(s, '\n'); end >= 0 {
+		return end
+	}
+	return len(s)
+}
+
+// blockCommentEnd возвращает длину комментария /* */ в начале s
+// с учётом вложенных комментариев.
+func blockCommentEnd(s string) int {
+	depth := 0
+	for i := 0; i < len(s)-1; i++ {
+		switch {
+		case s[i] == '/' && s[i+1] == '*':
+			depth++
+			i++
+		case s[i] == '*' && s[i+1] == '/':
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(s)
+}
+
+// quotedEnd возвращает длину литерала в кавычках quote в начале s;
+// удвоенная кавычка внутри литерала его не завершает.
+func quotedEnd(s string, quote byte) int {
+	for i := 1; i < len(s); i++ {
+		if s[i] != quote {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(s)
+}
+
+// dollarTag возвращает открывающую долларовую кавычку ("$$" или "$body$")
+// в начале s или пустую строку.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || unicode.IsLetter(rune(c)) || (i > 1 && unicode.IsDigit(rune(c))):
+		default:
+			return ""
+		}
+	}
+	return ""
+}
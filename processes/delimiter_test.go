@@ -0,0 +1,101 @@
+package processes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const plsqlMigration = `CREATE OR REPLACE PROCEDURE touch_users AS
+BEGIN
+  UPDATE users SET updated_at = SYSDATE WHERE id = 10 / 2;
+END;
+/
+-- split here? no: /
+INSERT INTO audit VALUES ('a / b
+/
+c');
+/
+`
+
+func TestSplitStatementsWithCustomDelimiter(t *testing.T) {
+	statements := splitStatements(plsqlMigration, "/")
+	require.Len(t, statements, 2)
+	assert.Equal(t, `CREATE OR REPLACE PROCEDURE touch_users AS
+BEGIN
+  UPDATE users SET updated_at = SYSDATE WHERE id = 10 / 2;
+END;`, statements[0])
+	assert.Equal(t, `-- split here? no: /
+INSERT INTO audit VALUES ('a / b
+/
+c');`, statements[1])
+}
+
+func TestSplitStatementsWithDefaultDelimiter(t *testing.T) {
+	sql := `CREATE FUNCTION one() RETURNS int AS $body$
+BEGIN
+  RETURN 1;
+END;
+$body$ LANGUAGE plpgsql;
+/* block; comment */ INSERT INTO "odd;name" VALUES ('it''s;');
+SELECT 1`
+
+	assert.Equal(t, []string{
+		"CREATE FUNCTION one() RETURNS int AS $body$\nBEGIN\n  RETURN 1;\nEND;\n$body$ LANGUAGE plpgsql",
+		`/* block; comment */ INSERT INTO "odd;name" VALUES ('it''s;')`,
+		"SELECT 1",
+	}, splitStatements(sql, ";"))
+}
+
+func TestParseDelimiter(t *testing.T) {
+	delimiter, err := ParseDelimiter("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultDelimiter, delimiter)
+
+	delimiter, err = ParseDelimiter("/")
+	require.NoError(t, err)
+	assert.Equal(t, "/", delimiter)
+
+	_, err = ParseDelimiter("/ /")
+	assert.ErrorIs(t, err, ErrInvalidDelimiter)
+}
+
+func TestDelimiterDirective(t *testing.T) {
+	delimiter, err := DelimiterDirective("-- migrator:delimiter /\nSELECT 1\n/\n")
+	require.NoError(t, err)
+	assert.Equal(t, "/", delimiter)
+
+	delimiter, err = DelimiterDirective("SELECT 1;")
+	require.NoError(t, err)
+	assert.Equal(t, "", delimiter)
+
+	for _, sql := range []string{"-- migrator:delimiter\nSELECT 1;", "-- migrator:delimiter / /\nSELECT 1;"} {
+		_, err := DelimiterDirective(sql)
+		assert.ErrorIs(t, err, ErrInvalidDelimiter, sql)
+	}
+}
+
+func TestUpNormalizesCustomDelimiter(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{Delimiter: "/"})
+	migrator.Create("create_users", "CREATE TABLE users (id serial)\n/\nCREATE INDEX users_id ON users (id)\n/\n", "", nil, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, []string{"CREATE TABLE users (id serial)\n;\nCREATE INDEX users_id ON users (id)\n;\n"}, st.ExecutedSQL())
+}
+
+func TestDelimiterDirectiveOverridesOption(t *testing.T) {
+	migrator := New(storage.NewMockSQLStorage(), logger.New()).WithOptions(Options{Delimiter: "/"})
+
+	assert.Equal(t, "GO", migrator.delimiter("-- migrator:delimiter GO\nSELECT 1\nGO"))
+	assert.Equal(t, "/", migrator.delimiter("SELECT 1\n/"))
+	assert.Equal(t, ";", New(storage.NewMockSQLStorage(), logger.New()).delimiter("SELECT 1;"))
+
+	sql := "SELECT 1;"
+	assert.Equal(t, sql, New(storage.NewMockSQLStorage(), logger.New()).normalizeDelimiter(sql))
+}
@@ -0,0 +1,102 @@
+package processes
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+var (
+	ErrDependencyCycle      = errors.New("циклическая зависимость между миграциями")
+	ErrDependencyNotApplied = errors.New("зависимость миграции не применена")
+)
+
+// dependencies возвращает явные зависимости загруженных миграций,
+// объявленные директивами "-- migrate:depends-on 3,5", по версиям.
+func (m *Migrator) dependencies() (map[int][]int, error) {
+	deps := make(map[int][]int)
+	for _, migration := range m.migrations {
+		for _, arg := range directiveArgs(migration.Up, "depends-on") {
+			versions, err := ParseVersionList(arg)
+			if err != nil {
+				return nil, fmt.Errorf("миграция %d: %w", migration.Version, err)
+			}
+			deps[migration.Version] = append(deps[migration.Version], versions...)
+		}
+	}
+	return deps, nil
+}
+
+// checkDependencies проверяет перед применением миграции, что все версии из
+// её директив depends-on уже успешно применены: по статусам statuses,
+// прочитанным в начале Up, или в этом же запуске (appliedNow). При выборочном
+// применении, пропуске версий или шлюзах порядок номеров этого не гарантирует.
+func checkDependencies(migration *storage.Migration, statuses map[int]string, appliedNow map[int]bool) error {
+	var missing []int
+	for _, arg := range directiveArgs(migration.Up, "depends-on") {
+		versions, err := ParseVersionList(arg)
+		if err != nil {
+			return fmt.Errorf("миграция %d: %w", migration.Version, err)
+		}
+		for _, dep := range versions {
+			if dep != migration.Version && statuses[dep] != storage.StatusSuccess && !appliedNow[dep] {
+				missing = append(missing, dep)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: миграция %d (%s) требует версии %v", ErrDependencyNotApplied,
+			migration.Version, migration.Name, missing)
+	}
+	return nil
+}
+
+// rollbackOrder упорядочивает версии для отката: миграция откатывается раньше
+// всех миграций, от которых она зависит. Среди готовых к откату версий первой
+// выбирается старшая, поэтому без директив порядок совпадает с убыванием версий.
+// Зависимости на версии вне набора не учитываются.
+func rollbackOrder(versions []int, deps map[int][]int) ([]int, error) {
+	inSet := make(map[int]bool, len(versions))
+	for _, v := range versions {
+		inSet[v] = true
+	}
+
+	// dependents[v] — сколько ещё не откаченных миграций набора зависят от v явно.
+	dependents := make(map[int]int, len(versions))
+	for _, v := range versions {
+		for _, dep := range deps[v] {
+			if inSet[dep] && dep != v {
+				dependents[dep]++
+			}
+		}
+	}
+
+	remaining := append([]int(nil), versions...)
+	sort.Sort(sort.Reverse(sort.IntSlice(remaining)))
+
+	order := make([]int, 0, len(versions))
+	for len(remaining) > 0 {
+		next := -1
+		for i, v := range remaining {
+			if dependents[v] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return nil, fmt.Errorf("%w: %v", ErrDependencyCycle, remaining)
+		}
+
+		v := remaining[next]
+		remaining = append(remaining[:next], remaining[next+1:]...)
+		order = append(order, v)
+		for _, dep := range deps[v] {
+			if inSet[dep] && dep != v {
+				dependents[dep]--
+			}
+		}
+	}
+	return order, nil
+}
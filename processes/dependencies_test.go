@@ -0,0 +1,102 @@
+package processes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollbackOrderWithoutDependencies(t *testing.T) {
+	order, err := rollbackOrder([]int{1, 3, 2}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 2, 1}, order)
+}
+
+func TestRollbackOrderFollowsDependencies(t *testing.T) {
+	// 2 зависит от 4, 4 зависит от 5: 2 откатывается раньше 4, а 4 — раньше 5.
+	deps := map[int][]int{2: {4}, 4: {5}, 3: {1}}
+
+	order, err := rollbackOrder([]int{1, 2, 3, 4, 5}, deps)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 2, 4, 5, 1}, order)
+}
+
+func TestRollbackOrderIgnoresVersionsOutsideSet(t *testing.T) {
+	order, err := rollbackOrder([]int{2, 3}, map[int][]int{2: {7}, 3: {1}})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 2}, order)
+}
+
+func TestRollbackOrderDetectsCycle(t *testing.T) {
+	_, err := rollbackOrder([]int{1, 2}, map[int][]int{1: {2}, 2: {1}})
+	assert.ErrorIs(t, err, ErrDependencyCycle)
+}
+
+// newOutOfOrderMigrator описывает миграцию 2, которая ссылается на таблицу
+// из миграции 3 и поэтому применяется после неё (сначала пропускается).
+func newOutOfOrderMigrator(st *schemaStorage, opts Options) *Migrator {
+	migrator := New(st, logger.New()).WithOptions(opts)
+	migrator.Create("create_users", "CREATE TABLE users", "DROP TABLE users", nil, nil)
+	migrator.Create("create_invoices", "CREATE TABLE invoices REFERENCES orders\n-- migrate:depends-on 3",
+		"DROP TABLE invoices", nil, nil)
+	migrator.Create("create_orders", "CREATE TABLE orders REFERENCES users", "DROP TABLE orders", nil, nil)
+	return migrator
+}
+
+func TestDownToRollsBackInReverseDependencyOrder(t *testing.T) {
+	ctx := context.Background()
+	st := newSchemaStorage()
+
+	require.NoError(t, newOutOfOrderMigrator(st, Options{SkipVersions: []int{2}}).Up(ctx))
+	require.NoError(t, newOutOfOrderMigrator(st, Options{ApplySkipped: true}).Up(ctx))
+	require.Len(t, st.tables, 3)
+
+	// По убыванию версий первой была бы удалена orders, на которую ссылается invoices.
+	require.NoError(t, newOutOfOrderMigrator(st, Options{}).DownTo(ctx, 0))
+
+	assert.Empty(t, st.tables)
+	assert.Equal(t, []string{"DROP TABLE invoices", "DROP TABLE orders", "DROP TABLE users"}, st.statements[3:])
+}
+
+func TestDownRollsBackDependentMigrationFirst(t *testing.T) {
+	ctx := context.Background()
+	st := newSchemaStorage()
+
+	require.NoError(t, newOutOfOrderMigrator(st, Options{SkipVersions: []int{2}}).Up(ctx))
+	require.NoError(t, newOutOfOrderMigrator(st, Options{ApplySkipped: true}).Up(ctx))
+
+	require.NoError(t, newOutOfOrderMigrator(st, Options{}).Down(ctx))
+	assert.Equal(t, []string{"DROP TABLE invoices"}, st.statements[3:])
+	assert.Equal(t, map[string]string{"users": "", "orders": "users"}, st.tables)
+}
+
+func TestUpRejectsUnappliedDependency(t *testing.T) {
+	ctx := context.Background()
+	st := newSchemaStorage()
+
+	err := newOutOfOrderMigrator(st, Options{}).Up(ctx)
+	assert.ErrorIs(t, err, ErrMigrationUp)
+	assert.ErrorIs(t, err, ErrDependencyNotApplied)
+	assert.Contains(t, err.Error(), "миграция 2 (create_invoices) требует версии [3]")
+	assert.Equal(t, []string{"CREATE TABLE users"}, st.statements, "dependent migration must not run")
+}
+
+func TestUpAppliesMigrationWithSatisfiedDependencies(t *testing.T) {
+	ctx := context.Background()
+	st := newSchemaStorage()
+
+	// Версия 3 применена раньше версии 2, которая от неё зависит.
+	require.NoError(t, newOutOfOrderMigrator(st, Options{SkipVersions: []int{2}}).Up(ctx))
+	require.NoError(t, newOutOfOrderMigrator(st, Options{ApplySkipped: true}).Up(ctx))
+	assert.Len(t, st.tables, 3)
+
+	// Зависимость от версии, применённой в этом же запуске.
+	migrator := New(storage.NewMockSQLStorage(), logger.New())
+	migrator.Create("create_users", "CREATE TABLE users", "DROP TABLE users", nil, nil)
+	migrator.Create("create_orders", "-- migrate:depends-on 1\nCREATE TABLE orders", "DROP TABLE orders", nil, nil)
+	assert.NoError(t, migrator.Up(ctx))
+}
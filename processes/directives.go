@@ -0,0 +1,123 @@
+package processes
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// directivePrefixes — префиксы комментариев, которыми миграция передаёт указания
+// мигратору, например "-- migrator:analyze users" или "-- migrate:gate NEW_BILLING".
+var directivePrefixes = []string{"-- migrator:", "-- migrate:"}
+
+// directiveArgs возвращает аргументы всех директив с указанным именем,
+// найденных в тексте SQL, в порядке их появления.
+func directiveArgs(sql, name string) []string {
+	var args []string
+	for _, line := range strings.Split(sql, "\n") {
+		line = strings.TrimSpace(line)
+		for _, prefix := range directivePrefixes {
+			if !strings.HasPrefix(line, prefix) {
+				continue
+			}
+
+			fields := strings.Fields(strings.TrimPrefix(line, prefix))
+			if len(fields) > 0 && fields[0] == name {
+				args = append(args, strings.Join(fields[1:], " "))
+			}
+			break
+		}
+	}
+	return args
+}
+
+// directiveValues возвращает значения всех директив с указанным именем
+// во всех переданных текстах SQL, без повторов. Директива может перечислять
+// несколько значений через запятую или пробел.
+func directiveValues(name string, sqls ...string) []string {
+	var values []string
+	seen := make(map[string]bool)
+	for _, sql := range sqls {
+		for _, arg := range directiveArgs(sql, name) {
+			for _, value := range strings.FieldsFunc(arg, func(r rune) bool { return r == ',' || r == ' ' }) {
+				if !seen[value] {
+					seen[value] = true
+					values = append(values, value)
+				}
+			}
+		}
+	}
+	return values
+}
+
+// analyzeTables возвращает таблицы, объявленные директивами "-- migrator:analyze".
+func analyzeTables(sqls ...string) []string {
+	return directiveValues("analyze", sqls...)
+}
+
+// migrationLabels возвращает метки, объявленные директивами "-- migrate:labels billing,q3".
+func migrationLabels(sql string) []string {
+	return directiveValues("labels", sql)
+}
+
+// migrationAuthor возвращает автора из директивы "-- migrate:author имя <email>".
+// Если директив несколько, действует последняя.
+func migrationAuthor(sql string) string {
+	args := directiveArgs(sql, "author")
+	if len(args) == 0 {
+		return ""
+	}
+	return args[len(args)-1]
+}
+
+// gateEnvPrefix — префикс переменной окружения, включающей шлюз миграции.
+const gateEnvPrefix = "MIGRATOR_GATE_"
+
+// gateNames возвращает имена шлюзов, объявленных директивами "-- migrate:gate".
+func gateNames(sql string) []string {
+	var names []string
+	for _, arg := range directiveArgs(sql, "gate") {
+		names = append(names, strings.Fields(arg)...)
+	}
+	return names
+}
+
+// ParseGate разбирает значение флага -gate вида "NAME=true".
+// Значение без "=" включает шлюз.
+func ParseGate(s string) (string, bool, error) {
+	name, value, found := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", false, fmt.Errorf("%w: %q", ErrInvalidGate, s)
+	}
+	if !found {
+		return name, true, nil
+	}
+
+	enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %q", ErrInvalidGate, s)
+	}
+	return name, enabled, nil
+}
+
+// closedGate возвращает первый выключенный шлюз из перечисленных в SQL миграции.
+// Значение из Options.Gates имеет приоритет над переменной окружения
+// MIGRATOR_GATE_<NAME>; шлюз, не заданный ни там, ни там, считается выключенным.
+func (m *Migrator) closedGate(sql string) (string, bool) {
+	for _, name := range gateNames(sql) {
+		if enabled, ok := m.options.Gates[name]; ok {
+			if !enabled {
+				return name, true
+			}
+			continue
+		}
+
+		enabled, err := strconv.ParseBool(os.Getenv(gateEnvPrefix + name))
+		if err != nil || !enabled {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,58 @@
+package processes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeTables(t *testing.T) {
+	sql := `-- migrator:analyze users
+INSERT INTO users SELECT * FROM staging_users;
+  -- migrator:analyze orders, billing.invoices users
+-- migrator:other ignored
+-- not a directive: migrator:analyze skipped`
+
+	assert.Equal(t, []string{"users", "orders", "billing.invoices"}, analyzeTables(sql))
+	assert.Empty(t, analyzeTables("CREATE TABLE users (id serial);"))
+}
+
+func TestParseGate(t *testing.T) {
+	name, enabled, err := ParseGate("NEW_BILLING=true")
+	assert.NoError(t, err)
+	assert.Equal(t, "NEW_BILLING", name)
+	assert.True(t, enabled)
+
+	name, enabled, err = ParseGate("NEW_BILLING=false")
+	assert.NoError(t, err)
+	assert.Equal(t, "NEW_BILLING", name)
+	assert.False(t, enabled)
+
+	_, enabled, err = ParseGate("NEW_BILLING")
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+
+	_, _, err = ParseGate("=true")
+	assert.ErrorIs(t, err, ErrInvalidGate)
+
+	_, _, err = ParseGate("NEW_BILLING=maybe")
+	assert.ErrorIs(t, err, ErrInvalidGate)
+}
+
+func TestDirectivesAcceptBothPrefixes(t *testing.T) {
+	sql := "-- migrate:gate NEW_BILLING\n-- migrator:gate FAST_PATH\nSELECT 1;"
+	assert.Equal(t, []string{"NEW_BILLING", "FAST_PATH"}, gateNames(sql))
+}
+
+func TestMigrationLabels(t *testing.T) {
+	sql := "-- migrate:labels billing,q3\n-- migrate:labels q3 payments\nCREATE TABLE invoices (id serial);"
+	assert.Equal(t, []string{"billing", "q3", "payments"}, migrationLabels(sql))
+	assert.Empty(t, migrationLabels("CREATE TABLE users (id serial);"))
+}
+
+func TestMigrationAuthor(t *testing.T) {
+	sql := "-- migrate:author Jane Doe <jane@example.com>\nCREATE TABLE invoices (id serial);"
+	assert.Equal(t, "Jane Doe <jane@example.com>", migrationAuthor(sql))
+	assert.Equal(t, "Bob", migrationAuthor("-- migrator:author Alice\n-- migrate:author Bob\nSELECT 1;"))
+	assert.Empty(t, migrationAuthor("CREATE TABLE users (id serial);"))
+}
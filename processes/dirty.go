@@ -0,0 +1,76 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+var (
+	ErrDirty          = errors.New("в служебной таблице остались незавершённые миграции")
+	ErrInvalidOnDirty = errors.New("некорректная политика для незавершённых миграций")
+)
+
+const (
+	// OnDirtyHalt — Up отказывается работать, пока незавершённые записи
+	// не разобраны вручную.
+	OnDirtyHalt = "halt"
+	// OnDirtyClean — Up удаляет незавершённые записи и выполняет эти версии заново.
+	OnDirtyClean = "clean"
+)
+
+// ParseOnDirty разбирает значение флага -on-dirty.
+func ParseOnDirty(s string) (string, error) {
+	switch s {
+	case "", OnDirtyHalt:
+		return OnDirtyHalt, nil
+	case OnDirtyClean:
+		return OnDirtyClean, nil
+	default:
+		return "", fmt.Errorf("%w: %q, expected %s or %s", ErrInvalidOnDirty, s, OnDirtyHalt, OnDirtyClean)
+	}
+}
+
+// dirtyVersions возвращает версии, оставшиеся в статусе process, error или
+// cancelled после сбоя, по возрастанию.
+func dirtyVersions(statuses map[int]string) []int {
+	var versions []int
+	for version, status := range statuses {
+		if status == storage.StatusProcess || status == storage.StatusError || status == storage.StatusCancelled {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// handleDirty применяет Options.OnDirty к незавершённым записям: при
+// OnDirtyHalt возвращает ErrDirty, при OnDirtyClean удаляет записи, чтобы
+// Up выполнил эти версии заново. Возвращает статусы после очистки.
+func (m *Migrator) handleDirty(ctx context.Context, statuses map[int]string) (map[int]string, error) {
+	dirty := dirtyVersions(statuses)
+	if len(dirty) == 0 {
+		return statuses, nil
+	}
+
+	if m.options.OnDirty != OnDirtyClean {
+		err := fmt.Errorf("%w: versions %v; resolve them manually or rerun with -on-dirty %s",
+			ErrDirty, dirty, OnDirtyClean)
+		m.logger.Error("Ошибка: %v", err)
+		return nil, err
+	}
+
+	for _, version := range dirty {
+		m.logger.Warn("Удаление незавершённой записи версии %d (%s) перед повтором",
+			version, statuses[version])
+		if err := m.storage.DeleteMigration(ctx, version); err != nil {
+			m.logger.Error("Ошибка при удалении незавершённой записи: %v", err)
+			return nil, err
+		}
+		delete(statuses, version)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,58 @@
+package processes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDirtyStorage возвращает хранилище, в котором версия 1 применена,
+// а версия 2 осталась в статусе status после сбоя.
+func newDirtyStorage(t *testing.T, status string) *storage.MockSQLStorage {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	require.NoError(t, st.InsertMigration(ctx, storage.CreateMigration("create_users", storage.StatusSuccess, 1, time.Now())))
+	require.NoError(t, st.InsertMigration(ctx, storage.CreateMigration("create_orders", status, 2, time.Now())))
+	return st
+}
+
+func TestUpHaltsOnDirtyRowByDefault(t *testing.T) {
+	for _, status := range []string{storage.StatusProcess, storage.StatusError} {
+		st := newDirtyStorage(t, status)
+
+		err := newThreeTableMigrator(st, Options{}).Up(context.Background())
+		assert.ErrorIs(t, err, ErrDirty, status)
+		assert.Contains(t, err.Error(), "-on-dirty clean")
+		assert.Empty(t, st.ExecutedSQL(), status)
+		assert.Equal(t, status, statusByVersion(t, st)[2])
+	}
+}
+
+func TestUpCleansDirtyRowAndRetriesVersion(t *testing.T) {
+	st := newDirtyStorage(t, storage.StatusProcess)
+
+	require.NoError(t, newThreeTableMigrator(st, Options{OnDirty: OnDirtyClean}).Up(context.Background()))
+	assert.Equal(t, []string{"CREATE TABLE orders", "CREATE TABLE items"}, st.ExecutedSQL())
+	assert.Equal(t, map[int]string{
+		1: storage.StatusSuccess,
+		2: storage.StatusSuccess,
+		3: storage.StatusSuccess,
+	}, statusByVersion(t, st))
+}
+
+func TestParseOnDirty(t *testing.T) {
+	policy, err := ParseOnDirty("")
+	require.NoError(t, err)
+	assert.Equal(t, OnDirtyHalt, policy)
+
+	policy, err = ParseOnDirty("clean")
+	require.NoError(t, err)
+	assert.Equal(t, OnDirtyClean, policy)
+
+	_, err = ParseOnDirty("ignore")
+	assert.ErrorIs(t, err, ErrInvalidOnDirty)
+}
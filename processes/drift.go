@@ -0,0 +1,40 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+var ErrSchemaDrift = errors.New("схема базы данных отличается от ожидаемой")
+
+// SchemaSnapshot возвращает снимок текущей схемы базы данных.
+func (m *Migrator) SchemaSnapshot(ctx context.Context) (storage.SchemaSnapshot, error) {
+	snapshot, err := m.storage.SchemaSnapshot(ctx)
+	if err != nil {
+		m.logger.Error("Ошибка при чтении схемы: %v", err)
+		return storage.SchemaSnapshot{}, err
+	}
+	return snapshot, nil
+}
+
+// ReportDrift сравнивает текущую схему с ожидаемым снимком и возвращает
+// расхождения, например изменения, внесённые вручную в обход миграций.
+// Если расхождения есть, вместе с ними возвращается ErrSchemaDrift.
+func (m *Migrator) ReportDrift(ctx context.Context, expected storage.SchemaSnapshot) ([]storage.SchemaChange, error) {
+	actual, err := m.SchemaSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := storage.DiffSchema(expected, actual)
+	if len(changes) == 0 {
+		m.logger.Info("Схема базы данных совпадает с ожидаемой")
+		return nil, nil
+	}
+
+	m.logger.Warn("Обнаружено расхождений схемы: %d", len(changes))
+	return changes, fmt.Errorf("%w: %d differences", ErrSchemaDrift, len(changes))
+}
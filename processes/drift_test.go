@@ -0,0 +1,30 @@
+package processes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportDrift(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	expected := storage.SchemaSnapshot{Tables: map[string]map[string]string{"users": {"id": "integer NOT NULL"}}}
+	st.SetSchemaSnapshot(expected)
+	migrator := New(st, logger.New())
+
+	changes, err := migrator.ReportDrift(ctx, expected)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	st.SetSchemaSnapshot(storage.SchemaSnapshot{Tables: map[string]map[string]string{
+		"users": {"id": "integer NOT NULL", "note": "text"},
+	}})
+	changes, err = migrator.ReportDrift(ctx, expected)
+	assert.ErrorIs(t, err, ErrSchemaDrift)
+	assert.Equal(t, []storage.SchemaChange{{Kind: storage.ChangeAdded, Object: "column users.note", Detail: "text"}}, changes)
+}
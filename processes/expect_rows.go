@@ -0,0 +1,75 @@
+package processes
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidExpectRows = errors.New("некорректная директива expect-rows")
+	ErrUnexpectedRows    = errors.New("число изменённых строк не соответствует ожидаемому")
+)
+
+// rowsExpectation — ожидание директивы "-- migrate:expect-rows >0":
+// оператор ">", "=" или ">=" и число строк.
+type rowsExpectation struct {
+	op   string
+	rows int64
+}
+
+func (e rowsExpectation) String() string {
+	return e.op + strconv.FormatInt(e.rows, 10)
+}
+
+// met сообщает, удовлетворяет ли rows ожиданию.
+func (e rowsExpectation) met(rows int64) bool {
+	switch e.op {
+	case ">":
+		return rows > e.rows
+	case ">=":
+		return rows >= e.rows
+	default:
+		return rows == e.rows
+	}
+}
+
+// migrationRowsExpectation возвращает ожидание из директивы expect-rows.
+// Если директив несколько, действует последняя; без директивы — nil.
+// Поддерживаются ">0", "=N" и ">=N".
+func migrationRowsExpectation(sql string) (*rowsExpectation, error) {
+	args := directiveArgs(sql, "expect-rows")
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	value := strings.ReplaceAll(args[len(args)-1], " ", "")
+	var expectation rowsExpectation
+	switch {
+	case strings.HasPrefix(value, ">="):
+		expectation.op = ">="
+	case strings.HasPrefix(value, "="):
+		expectation.op = "="
+	case value == ">0":
+		expectation.op = ">"
+	default:
+		return nil, fmt.Errorf("%w: %q, ожидается >0, =N или >=N", ErrInvalidExpectRows, value)
+	}
+
+	rows, err := strconv.ParseInt(strings.TrimPrefix(value, expectation.op), 10, 64)
+	if err != nil || rows < 0 {
+		return nil, fmt.Errorf("%w: %q, ожидается >0, =N или >=N", ErrInvalidExpectRows, value)
+	}
+	expectation.rows = rows
+	return &expectation, nil
+}
+
+// checkRowsExpectation сверяет число изменённых строк с ожиданием директивы
+// expect-rows. Без директивы проверка всегда проходит.
+func checkRowsExpectation(expectation *rowsExpectation, rows int64) error {
+	if expectation == nil || expectation.met(rows) {
+		return nil
+	}
+	return fmt.Errorf("%w: ожидалось %s, изменено %d", ErrUnexpectedRows, expectation, rows)
+}
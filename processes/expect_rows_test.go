@@ -0,0 +1,105 @@
+package processes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationRowsExpectation(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected *rowsExpectation
+	}{
+		{"UPDATE users SET active = true;", nil},
+		{"-- migrate:expect-rows >0\nUPDATE users SET active = true;", &rowsExpectation{op: ">", rows: 0}},
+		{"-- migrate:expect-rows =3\nDELETE FROM users;", &rowsExpectation{op: "=", rows: 3}},
+		{"-- migrate:expect-rows >= 10\nDELETE FROM users;", &rowsExpectation{op: ">=", rows: 10}},
+		{"-- migrate:expect-rows =1\n-- migrate:expect-rows >0\nSELECT 1;", &rowsExpectation{op: ">", rows: 0}},
+	}
+	for _, test := range tests {
+		expectation, err := migrationRowsExpectation(test.sql)
+		require.NoError(t, err, test.sql)
+		assert.Equal(t, test.expected, expectation, test.sql)
+	}
+
+	for _, value := range []string{"", ">5", "<3", "=-1", "=many", "3"} {
+		_, err := migrationRowsExpectation("-- migrate:expect-rows " + value + "\nSELECT 1;")
+		assert.ErrorIs(t, err, ErrInvalidExpectRows, value)
+	}
+}
+
+func TestRowsExpectationMet(t *testing.T) {
+	assert.True(t, rowsExpectation{op: ">", rows: 0}.met(1))
+	assert.False(t, rowsExpectation{op: ">", rows: 0}.met(0))
+	assert.True(t, rowsExpectation{op: "=", rows: 3}.met(3))
+	assert.False(t, rowsExpectation{op: "=", rows: 3}.met(4))
+	assert.True(t, rowsExpectation{op: ">=", rows: 2}.met(2))
+	assert.False(t, rowsExpectation{op: ">=", rows: 2}.met(1))
+}
+
+func TestUpWithMetRowsExpectation(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	sql := "-- migrate:expect-rows >0\nUPDATE users SET active = true;"
+	st.SetAffectedRows(sql, 5)
+
+	migrator := New(st, logger.New())
+	migrator.Create("activate_users", sql, "", nil, nil)
+	require.NoError(t, migrator.Up(ctx))
+
+	statuses, err := st.SelectAppliedVersions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess}, statuses)
+}
+
+func TestUpFailsOnUnmetRowsExpectation(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+
+	out := &lineRecorder{ZeroLogger: logger.New()}
+	migrator := New(st, out)
+	migrator.Create("backfill_emails", "-- migrate:expect-rows >=2\nUPDATE users SET email = lower(email);", "", nil, nil)
+	migrator.Create("create_orders", "CREATE TABLE orders (id serial);", "", nil, nil)
+	require.ErrorIs(t, migrator.Up(ctx), ErrMigrationUp)
+
+	statuses, err := st.SelectAppliedVersions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{1: storage.StatusError}, statuses, "Up stops at the migration with the unmet expectation")
+	assert.Contains(t, out.lines, "Миграция 1: изменено строк 0")
+	assert.Equal(t, []string{"BEGIN", "SAVEPOINT migration_sql", "ROLLBACK TO SAVEPOINT migration_sql", "COMMIT"}, st.TxLog(),
+		"The SQL is rolled back in the transaction that records the error")
+	assert.Empty(t, st.ExecutedSQL())
+}
+
+func TestUnmetRowsExpectationRollsBackSQLStepWithGoStep(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	goRan := false
+
+	migrator := New(st, logger.New())
+	migrator.Create("purge_sessions", "-- migrate:expect-rows =3\nDELETE FROM sessions;", "", func(ctx context.Context) error {
+		goRan = true
+		return nil
+	}, nil)
+	require.Error(t, migrator.Up(ctx))
+	assert.False(t, goRan, "The Go step does not run after the SQL step fails its expectation")
+
+	statuses, err := st.SelectAppliedVersions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{1: storage.StatusError}, statuses)
+}
+
+func TestUpRejectsInvalidRowsExpectation(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+
+	migrator := New(st, logger.New())
+	migrator.Create("activate_users", "-- migrate:expect-rows <1\nUPDATE users SET active = true;", "", nil, nil)
+	require.Error(t, migrator.Up(ctx))
+	assert.Empty(t, st.ExecutedSQL(), "SQL is not executed when the directive is invalid")
+}
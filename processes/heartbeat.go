@@ -0,0 +1,56 @@
+package processes
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// startHeartbeat раз в Options.HeartbeatInterval сообщает, что миграция
+// version всё ещё выполняется. Возвращённая функция останавливает сообщения
+// и дожидается завершения фоновой горутины, поэтому после неё сообщений
+// о миграции уже не будет. При нулевом интервале ничего не запускается.
+func (m *Migrator) startHeartbeat(ctx context.Context, version int) func() {
+	interval := m.options.HeartbeatInterval
+	if interval <= 0 {
+		return func() {}
+	}
+
+	start := time.Now()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// select выбирает готовый случай случайно: не сообщаем
+				// о миграции, которая уже завершилась или отменена.
+				select {
+				case <-done:
+					return
+				case <-ctx.Done():
+					return
+				default:
+				}
+				m.logger.Info("Миграция %d всё ещё выполняется (прошло %s)",
+					version, time.Since(start).Round(time.Second))
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
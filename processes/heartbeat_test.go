@@ -0,0 +1,87 @@
+package processes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncRecorder запоминает строки Info из нескольких горутин.
+type syncRecorder struct {
+	*logger.ZeroLogger
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *syncRecorder) Info(msg string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(msg, v...))
+}
+
+func (l *syncRecorder) heartbeats() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	count := 0
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, "Миграция 1 всё ещё выполняется") {
+			count++
+		}
+	}
+	return count
+}
+
+func slowMigrator(out logger.Logger, interval time.Duration) *Migrator {
+	migrator := New(storage.NewMockSQLStorage(), out).WithOptions(Options{HeartbeatInterval: interval})
+	migrator.Create("backfill", "", "", func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		}
+	}, nil)
+	return migrator
+}
+
+func TestHeartbeatFiresDuringSlowMigration(t *testing.T) {
+	out := &syncRecorder{ZeroLogger: logger.New()}
+	require.NoError(t, slowMigrator(out, 5*time.Millisecond).Up(context.Background()))
+
+	beats := out.heartbeats()
+	assert.GreaterOrEqual(t, beats, 1)
+
+	// После завершения миграции сообщения прекращаются.
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, beats, out.heartbeats())
+}
+
+func TestHeartbeatDisabledByDefault(t *testing.T) {
+	out := &syncRecorder{ZeroLogger: logger.New()}
+	require.NoError(t, slowMigrator(out, 0).Up(context.Background()))
+	assert.Equal(t, 0, out.heartbeats())
+}
+
+func TestHeartbeatStopsOnCancel(t *testing.T) {
+	out := &syncRecorder{ZeroLogger: logger.New()}
+	migrator := New(storage.NewMockSQLStorage(), out).WithOptions(Options{HeartbeatInterval: time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := migrator.startHeartbeat(ctx, 1)
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	time.Sleep(5 * time.Millisecond)
+	beats := out.heartbeats()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, beats, out.heartbeats())
+	stop()
+	stop()
+}
@@ -0,0 +1,62 @@
+package processes
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+// errAlreadyDone — пока процесс ждал блокировку, версию перевёл в итоговый
+// статус другой процесс.
+var errAlreadyDone = errors.New("миграция уже выполнена другим процессом")
+
+// singleTx сообщает, выполняется ли миграция с SQL целиком в одной
+// транзакции вместе с записью статуса, см. executeSteps. Так выполняются
+// миграции с SQL- и Go-шагом, чтобы сбой Go-шага отменял SQL; все SQL-миграции
+// при области LockScopeMigration — под pg_advisory_xact_lock; миграции
+// с директивой report, части которых выполняются отдельными запросами
+// и должны фиксироваться вместе; и миграции с директивой expect-rows, чтобы
+// при невыполненном ожидании их SQL откатывался. Директивы parallel и batch
+// сами управляют транзакциями, поэтому такие миграции выполняются без неё.
+// Go-шаг, выполняемый отдельным процессом (storage.Migration.GoProcess),
+// в транзакцию не входит и запускается после её фиксации, см. executeSteps.
+func (m *Migrator) singleTx(sql string, goFunc func(ctx context.Context) error) bool {
+	if sql == "" || isParallel(sql) {
+		return false
+	}
+	if goFunc == nil && m.options.LockScope != LockScopeMigration && !hasReports(sql) &&
+		len(directiveArgs(sql, "expect-rows")) == 0 {
+		return false
+	}
+	size, err := batchSize(sql)
+	return err == nil && size == 0
+}
+
+// lockVersion берёт pg_advisory_xact_lock в открытой транзакции и заново
+// читает статус версии: статусы, прочитанные до блокировки, могли устареть.
+// Если версия уже в статусе doneStatus, возвращается errAlreadyDone. При
+// AbortLockWait блокировка берётся без ожидания, и занятая блокировка
+// завершает миграцию ошибкой storage.ErrLockBusy.
+func (m *Migrator) lockVersion(ctx context.Context, migration storage.IMigration, doneStatus string) error {
+	if m.options.AbortLockWait {
+		if err := m.logLockErr(m.storage.TryXactLock(ctx)); err != nil {
+			return err
+		}
+	} else if err := m.storage.XactLock(ctx); err != nil {
+		m.logger.Error("Ошибка при блокировке: %v", err)
+		return err
+	}
+
+	statuses, err := m.storage.SelectAppliedVersions(ctx)
+	if err != nil {
+		m.logger.Error("Ошибка при получении списка миграций: %v", err)
+		return err
+	}
+	if statuses[migration.GetVersion()] == doneStatus {
+		m.logger.Info("Миграция %d (%s) уже выполнена другим процессом, пропускаем",
+			migration.GetVersion(), migration.GetName())
+		return errAlreadyDone
+	}
+	return nil
+}
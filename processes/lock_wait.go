@@ -0,0 +1,80 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+var ErrLockWaitTimeout = errors.New("блокировка не освободилась за время ожидания")
+
+// lockRetryInterval — пауза между попытками взять занятую блокировку,
+// если Options.LockRetryInterval не задан.
+var lockRetryInterval = time.Second
+
+// lockRetryDelay возвращает паузу перед следующей попыткой: interval плюс
+// случайная добавка из [0, jitter). Добавка разводит во времени повторы
+// процессов, которые одновременно упёрлись в одну блокировку, например
+// подов масштабированного развёртывания, стартующих разом.
+func lockRetryDelay(interval, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+	return interval + time.Duration(rand.Int63n(int64(jitter)))
+}
+
+// waitLock берёт блокировку для Up. При заданном Options.LockWait занятая
+// другим процессом блокировка не считается ошибкой: попытки повторяются до
+// истечения LockWait. Версии Up читает уже после получения блокировки,
+// поэтому применяются только миграции, которые другой процесс не успел
+// применить. Ошибки, отличные от занятой блокировки, возвращаются сразу.
+func (m *Migrator) waitLock(ctx context.Context) (func(), error) {
+	if m.options.LockWait <= 0 || m.options.LockScope == LockScopeMigration || m.options.AbortLockWait {
+		return m.lockRun(ctx)
+	}
+	if err := m.checkWritable(); err != nil {
+		return nil, err
+	}
+	return m.retryLock(ctx)
+}
+
+// retryLock повторяет попытки взять сессионную блокировку до истечения
+// Options.LockWait.
+func (m *Migrator) retryLock(ctx context.Context) (func(), error) {
+	deadline := time.Now().Add(m.options.LockWait)
+	for attempt := 1; ; attempt++ {
+		err := m.storage.TryLock(ctx)
+		if err == nil {
+			return m.unlocker(ctx), nil
+		}
+		if !errors.Is(err, storage.ErrLockBusy) {
+			m.logger.Error("Ошибка при блокировке: %v", err)
+			return nil, err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			m.logger.Error("Блокировка занята другим процессом дольше %s", m.options.LockWait)
+			return nil, fmt.Errorf("%w: %s", ErrLockWaitTimeout, m.options.LockWait)
+		}
+
+		interval := m.options.LockRetryInterval
+		if interval <= 0 {
+			interval = lockRetryInterval
+		}
+		delay := lockRetryDelay(interval, m.options.LockJitter)
+		if delay > remaining {
+			delay = remaining
+		}
+		m.logger.Info("Блокировка занята другим процессом, попытка %d, повтор через %s", attempt, delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// busyLockStorage имитирует другой процесс, который держит блокировку
+// busy попыток TryLock и за это время применяет первую миграцию.
+type busyLockStorage struct {
+	*storage.MockSQLStorage
+	busy     int
+	err      error
+	attempts int
+}
+
+func (s *busyLockStorage) TryLock(ctx context.Context) error {
+	s.attempts++
+	if s.err != nil {
+		return s.err
+	}
+	if s.attempts <= s.busy {
+		if s.attempts == 1 {
+			other := &storage.Migration{Version: 1, Name: "create_users", Status: storage.StatusSuccess}
+			if err := s.InsertMigration(ctx, other); err != nil {
+				return err
+			}
+		}
+		return storage.ErrLockBusy
+	}
+	return s.MockSQLStorage.TryLock(ctx)
+}
+
+func noLockRetryInterval(t *testing.T) {
+	interval := lockRetryInterval
+	lockRetryInterval = time.Millisecond
+	t.Cleanup(func() { lockRetryInterval = interval })
+}
+
+func TestUpWaitsForBusyLockAndAppliesRemaining(t *testing.T) {
+	noLockRetryInterval(t)
+	ctx := context.Background()
+	st := &busyLockStorage{MockSQLStorage: storage.NewMockSQLStorage(), busy: 2}
+	migrator := newThreeTableMigrator(st, Options{LockWait: time.Minute})
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, 3, st.attempts)
+	assert.Equal(t, []string{"CREATE TABLE orders", "CREATE TABLE items"}, st.ExecutedSQL())
+	assert.Equal(t, 1, st.LockCalls())
+	assert.Equal(t, 1, st.UnlockCalls())
+	assert.Equal(t, map[int]string{
+		1: storage.StatusSuccess,
+		2: storage.StatusSuccess,
+		3: storage.StatusSuccess,
+	}, statusByVersion(t, st))
+}
+
+func TestUpGivesUpWaitingForLockAfterDeadline(t *testing.T) {
+	noLockRetryInterval(t)
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	st.SetLockBusy(1 << 20)
+	migrator := newThreeTableMigrator(st, Options{LockWait: 20 * time.Millisecond})
+
+	assert.ErrorIs(t, migrator.Up(ctx), ErrLockWaitTimeout)
+	assert.Empty(t, st.ExecutedSQL())
+	assert.Equal(t, 0, st.UnlockCalls())
+}
+
+func TestUpDoesNotRetryLockErrors(t *testing.T) {
+	noLockRetryInterval(t)
+	ctx := context.Background()
+	connErr := errors.New("connection reset")
+	st := &busyLockStorage{MockSQLStorage: storage.NewMockSQLStorage(), err: connErr}
+	migrator := newThreeTableMigrator(st, Options{LockWait: time.Minute})
+
+	assert.ErrorIs(t, migrator.Up(ctx), connErr)
+	assert.Equal(t, 1, st.attempts)
+	assert.Empty(t, st.ExecutedSQL())
+}
+
+// blockingLockStorage имитирует блокировку, которую держит другой процесс:
+// Lock сообщает в waiting, что ждёт, и возвращается только после release.
+type blockingLockStorage struct {
+	*storage.MockSQLStorage
+	waiting  chan struct{}
+	release  chan struct{}
+	tryLocks int
+}
+
+func (s *blockingLockStorage) Lock(ctx context.Context) error {
+	close(s.waiting)
+	<-s.release
+	return s.MockSQLStorage.Lock(ctx)
+}
+
+func (s *blockingLockStorage) TryLock(ctx context.Context) error {
+	s.tryLocks++
+	return s.MockSQLStorage.TryLock(ctx)
+}
+
+func TestUpWithoutLockWaitBlocksInLock(t *testing.T) {
+	ctx := context.Background()
+	st := &blockingLockStorage{
+		MockSQLStorage: storage.NewMockSQLStorage(),
+		waiting:        make(chan struct{}),
+		release:        make(chan struct{}),
+	}
+	migrator := newThreeTableMigrator(st, Options{})
+
+	done := make(chan error, 1)
+	go func() { done <- migrator.Up(ctx) }()
+
+	<-st.waiting
+	select {
+	case err := <-done:
+		t.Fatalf("Up returned while the lock was held: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	assert.Empty(t, st.ExecutedSQL(), "No migration runs before the lock is acquired")
+
+	close(st.release)
+	require.NoError(t, <-done)
+	assert.Equal(t, 0, st.tryLocks, "Without -lock-wait Up waits in Lock instead of polling TryLock")
+	assert.Equal(t, 1, st.LockCalls())
+	assert.Len(t, st.ExecutedSQL(), 3)
+}
+
+func TestLockRetryDelayStaysWithinJitterRange(t *testing.T) {
+	interval, jitter := 100*time.Millisecond, 50*time.Millisecond
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 1000; i++ {
+		delay := lockRetryDelay(interval, jitter)
+		require.GreaterOrEqual(t, delay, interval)
+		require.Less(t, delay, interval+jitter)
+		seen[delay] = true
+	}
+	assert.Greater(t, len(seen), 1, "jitter should vary the delay")
+	assert.Equal(t, interval, lockRetryDelay(interval, 0))
+}
+
+func TestUpUsesConfiguredLockRetryInterval(t *testing.T) {
+	ctx := context.Background()
+	st := &busyLockStorage{MockSQLStorage: storage.NewMockSQLStorage(), busy: 2}
+	migrator := newThreeTableMigrator(st, Options{
+		LockWait:          time.Minute,
+		LockRetryInterval: time.Millisecond,
+		LockJitter:        time.Millisecond,
+	})
+
+	start := time.Now()
+	require.NoError(t, migrator.Up(ctx))
+	assert.Less(t, time.Since(start), lockRetryInterval)
+	assert.Equal(t, 3, st.attempts)
+}
+
+func TestAbortLockWaitFailsImmediatelyOnBusyLock(t *testing.T) {
+	ctx := context.Background()
+	st := &busyLockStorage{MockSQLStorage: storage.NewMockSQLStorage(), busy: 100}
+	migrator := newThreeTableMigrator(st, Options{AbortLockWait: true, LockWait: time.Minute})
+
+	start := time.Now()
+	err := migrator.Up(ctx)
+	assert.ErrorIs(t, err, storage.ErrLockBusy)
+	assert.Less(t, time.Since(start), lockRetryInterval, "no retry when -abort-lock-wait is set")
+	assert.Equal(t, 1, st.attempts)
+	assert.Empty(t, st.ExecutedSQL())
+	assert.Equal(t, 0, st.LockCalls(), "blocking pg_advisory_lock is never called")
+
+	st.attempts = 0
+	assert.ErrorIs(t, migrator.Down(ctx), storage.ErrLockBusy)
+	assert.Equal(t, 1, st.attempts)
+}
+
+func TestAbortLockWaitTakesFreeLock(t *testing.T) {
+	ctx := context.Background()
+	st := &busyLockStorage{MockSQLStorage: storage.NewMockSQLStorage()}
+	migrator := newThreeTableMigrator(st, Options{AbortLockWait: true})
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, 1, st.attempts)
+	assert.Len(t, st.ExecutedSQL(), 3)
+	assert.Equal(t, 1, st.UnlockCalls())
+}
+
+func TestAbortLockWaitWithMigrationScopeFailsOnBusyLock(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	st.SetLockBusy(1)
+	migrator := newThreeTableMigrator(st, Options{AbortLockWait: true, LockScope: LockScopeMigration})
+
+	assert.ErrorIs(t, migrator.Up(ctx), storage.ErrLockBusy)
+	assert.Equal(t, []string{"BEGIN", "ROLLBACK"}, st.TxLog())
+	assert.Empty(t, st.ExecutedSQL())
+	statuses, err := st.SelectAppliedVersions(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, statuses, "A busy lock is not recorded as a failed migration")
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Len(t, st.XactLockedSQL(), 3)
+}
@@ -4,6 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Edestus789/sql-migrator/logger"
@@ -17,9 +21,175 @@ type IMigration interface {
 	Create(name, up, down string, upGo, downGo func(ctx context.Context) error)
 	Up(context.Context) error
 	Down(context.Context) error
-	Redo(context.Context) error
+	DownTo(ctx context.Context, version int) error
+	Redo(context.Context) (RedoResult, error)
 	Status(context.Context) error
 	DBVersion(context.Context) error
+	Reset(context.Context) error
+	CurrentVersion(context.Context) (int, error)
+}
+
+// Options задаёт параметры выполнения миграций.
+type Options struct {
+	// RunAs — роль, от имени которой выполняются миграции: SET ROLE сразу
+	// после подключения, до создания служебной таблицы.
+	RunAs string
+	// StatementTimeout — statement_timeout сессии (SET statement_timeout после
+	// подключения): база сама прерывает слишком долгие запросы. На ожидание
+	// блокировки мигратора он не действует. 0 — не задавать.
+	StatementTimeout time.Duration
+	// PreLockStatements — команды SET, которые выполняются в сессии сразу
+	// после подключения, до взятия блокировки, например "SET lock_timeout = '5s'".
+	PreLockStatements []string
+	// CheckPerms включает проверку прав роли при подключении: без CREATE на
+	// схему или записи в schema_migrations Connect завершается ошибкой
+	// ErrInsufficientPrivileges до выполнения миграций.
+	CheckPerms bool
+	// DeadlockRetries — сколько раз повторить SQL миграции после ошибки
+	// взаимоблокировки (40P01) или сериализации (40001).
+	DeadlockRetries int
+	// SkipVersions — версии, которые Up не применяет, а помечает как пропущенные.
+	SkipVersions []int
+	// OnlyVersions ограничивает Up и Verify этими версиями; остальные
+	// ожидающие версии Up оставляет как есть, ничего не записывая. nil — без
+	// ограничения, пустой словарь — ни одной версии.
+	OnlyVersions map[int]bool
+	// ApplySkipped разрешает Up применить ранее пропущенные версии.
+	ApplySkipped bool
+	// PostUpAnalyze включает ANALYZE после успешного Up: для таблиц из директив
+	// "-- migrator:analyze" применённых миграций или для всей базы, если их нет.
+	PostUpAnalyze bool
+	// PostUpVacuum заменяет ANALYZE на VACUUM ANALYZE.
+	PostUpVacuum bool
+	// PlainVersion оставляет в выводе dbversion только номер применённой версии.
+	PlainVersion bool
+	// StatusVerifyChecksums сверяет в Status контрольные суммы применённых
+	// миграций с файлами, отмечает изменённые меткой DRIFT и возвращает
+	// ErrChecksumMismatch.
+	StatusVerifyChecksums bool
+	// StatusFull заменяет таблицу статуса разделами применённых и ожидающих
+	// миграций с итоговой строкой.
+	StatusFull bool
+	// StatusVerbose добавляет в вывод статуса путь к исходному файлу миграции.
+	StatusVerbose bool
+	// StatusWide добавляет в вывод статуса автора миграции из директивы
+	// "-- migrate:author".
+	StatusWide bool
+	// OnlySQL оставляет при Up миграции с Go-шагом ожидающими, ничего
+	// о них не записывая: SQL применяется и без установленного Go, а эти
+	// версии применит следующий Up без OnlySQL.
+	OnlySQL bool
+	// StatusIcons — набор значков перед статусом в таблице статуса:
+	// StatusIconsNone, StatusIconsUnicode или StatusIconsASCII.
+	StatusIcons string
+	// StatusLabel ограничивает вывод статуса миграциями с указанной меткой.
+	StatusLabel string
+	// SimulateFailureVersion — версия, на которой Up искусственно падает после
+	// записи статуса process. Только для проверки обработки сбоев; работает
+	// лишь при MIGRATOR_ALLOW_SIMULATE=1.
+	SimulateFailureVersion int
+	// Gates — состояние шлюзов из директив "-- migrate:gate", заданное флагами.
+	// Имеет приоритет над переменными окружения MIGRATOR_GATE_<NAME>.
+	Gates map[string]bool
+	// BeforeMigration, если задан, вызывается перед каждой миграцией Up, Down
+	// и DownTo и решает, выполнить её, пропустить или остановиться.
+	BeforeMigration func(migration storage.Migration, direction string) (StepDecision, error)
+	// LockScope — область advisory lock: LockScopeRun (по умолчанию) или
+	// LockScopeMigration.
+	LockScope string
+	// Delimiter — разделитель команд в файлах миграций, например "/" для
+	// блоков PL/SQL. Директива "-- migrator:delimiter" в файле имеет приоритет.
+	Delimiter string
+	// ContinueOnMissingDown разрешает Redo миграции без отката: откат
+	// пропускается, и миграция вверх выполняется повторно.
+	ContinueOnMissingDown bool
+	// ResetSkipDowns — Reset только очищает служебную таблицу, не откатывая миграции.
+	ResetSkipDowns bool
+	// CommitEvery — после скольких откаченных миграций фиксируется транзакция
+	// при откате нескольких версий (DownTo, Reset, Nuke). 0 и 1 — каждая
+	// миграция фиксируется отдельно.
+	CommitEvery int
+	// OnDirty — что делает Up с записями, оставшимися в статусе process или
+	// error после сбоя: OnDirtyHalt (по умолчанию) или OnDirtyClean.
+	OnDirty string
+	// LockWait — сколько Up ждёт блокировку, занятую другим процессом,
+	// повторяя попытки. 0 — ждать в pg_advisory_lock без ограничения.
+	LockWait time.Duration
+	// MaxParallelStatements — сколько команд миграции с директивой parallel
+	// выполняется одновременно; 0 — DefaultParallelStatements.
+	MaxParallelStatements int
+	// HeartbeatInterval — как часто во время выполнения миграции сообщать,
+	// что она ещё идёт. 0 отключает сообщения.
+	HeartbeatInterval time.Duration
+	// LockRetryInterval — пауза между попытками при LockWait; 0 — одна секунда.
+	LockRetryInterval time.Duration
+	// LockJitter — верхняя граница случайной добавки к LockRetryInterval.
+	LockJitter time.Duration
+	// AbortLockWait — одна попытка pg_try_advisory_lock вместо ожидания:
+	// занятая другим процессом блокировка сразу завершает команду
+	// с storage.ErrLockBusy. Имеет приоритет над LockWait. При области
+	// LockScopeMigration так же, через pg_try_advisory_xact_lock, берётся
+	// блокировка каждой миграции.
+	AbortLockWait bool
+}
+
+const (
+	// LockScopeRun — сессионная блокировка на всё время выполнения команды.
+	LockScopeRun = "run"
+	// LockScopeMigration — транзакционная блокировка pg_advisory_xact_lock
+	// внутри транзакции каждой миграции.
+	LockScopeMigration = "migration"
+)
+
+// ParseLockScope разбирает значение флага -lock-scope.
+func ParseLockScope(s string) (string, error) {
+	switch s {
+	case "", LockScopeRun:
+		return LockScopeRun, nil
+	case LockScopeMigration:
+		return LockScopeMigration, nil
+	default:
+		return "", fmt.Errorf("%w: %q, expected %s or %s", ErrInvalidLockScope, s, LockScopeRun, LockScopeMigration)
+	}
+}
+
+// allowSimulateEnv — переменная окружения, без которой имитация сбоев не работает.
+const allowSimulateEnv = "MIGRATOR_ALLOW_SIMULATE"
+
+// ParseSimulateFailure разбирает значение флага -simulate-failure вида "version=N".
+// Флаг принимается, только если установлена переменная MIGRATOR_ALLOW_SIMULATE=1.
+func ParseSimulateFailure(s string) (int, error) {
+	if os.Getenv(allowSimulateEnv) != "1" {
+		return 0, ErrSimulateNotAllowed
+	}
+
+	value, found := strings.CutPrefix(strings.TrimSpace(s), "version=")
+	if !found {
+		return 0, fmt.Errorf("%w: %q, expected version=N", ErrInvalidVersion, s)
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidVersion, value)
+	}
+	return version, nil
+}
+
+// ParseVersionList разбирает список версий через запятую, например "5,7".
+func ParseVersionList(s string) ([]int, error) {
+	var versions []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		version, err := strconv.Atoi(part)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidVersion, part)
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
 }
 
 // Структура Migrator реализует интерфейс IMigration.
@@ -27,6 +197,10 @@ type Migrator struct {
 	logger     logger.Logger
 	storage    storage.SQLStorage
 	migrations []storage.Migration
+	options    Options
+	result     Result
+	// closed — Close уже вызван после последнего Connect.
+	closed bool
 }
 
 // Определение ошибок для обработки различных ситуаций.
@@ -34,9 +208,21 @@ var (
 	ErrMigrationUp                = errors.New("ошибка выполнения миграции вверх")
 	ErrMigrationDown              = errors.New("ошибка выполнения миграции вниз")
 	ErrMigrationRedo              = errors.New("ошибка выполнения повторной миграции")
+	ErrNothingToRedo              = errors.New("нет применённых миграций для повторного выполнения")
+	ErrMigrationCancelled         = errors.New("миграция прервана, изменения могли примениться частично")
 	ErrGetStatus                  = errors.New("ошибка получения статуса БД")
 	ErrGetVersion                 = errors.New("ошибка получения версии БД")
 	ErrUnexpectedMigrationVersion = errors.New("неожиданная версия миграции")
+	ErrInvalidVersion             = errors.New("некорректная версия миграции")
+	ErrPostUpAnalyze              = errors.New("ошибка обновления статистики после миграции")
+	ErrInvalidGate                = errors.New("некорректное значение шлюза")
+	ErrSimulatedFailure           = errors.New("искусственный сбой миграции")
+	ErrSimulateNotAllowed         = errors.New("имитация сбоя запрещена без MIGRATOR_ALLOW_SIMULATE=1")
+	ErrInvalidLockScope           = errors.New("некорректная область блокировки")
+	ErrInsufficientPrivileges     = errors.New("недостаточно прав для выполнения миграций")
+	ErrMissingDown                = errors.New("у миграции нет отката")
+	ErrNukeIncomplete             = errors.New("не все миграции откачены, служебная таблица сохранена")
+	ErrReadOnlyDatabase           = errors.New("база данных — реплика в режиме восстановления и доступна только для чтения")
 )
 
 // Конструктор для создания нового объекта Migrator.
@@ -48,23 +234,104 @@ func New(connString storage.SQLStorage, logger logger.Logger) *Migrator {
 	}
 }
 
+// Метод для установки параметров выполнения миграций.
+func (m *Migrator) WithOptions(opts Options) *Migrator {
+	m.options = opts
+	return m
+}
+
 // Метод для подключения к базе данных.
 func (m *Migrator) Connect(ctx context.Context) error {
 	m.logger.Info("Подключение к базе данных")
 
+	// Роль задаётся до подключения: хранилище переключается на неё раньше,
+	// чем создаёт служебную таблицу.
+	if m.options.RunAs != "" {
+		if err := m.storage.SetRole(ctx, m.options.RunAs); err != nil {
+			m.logger.Error("Ошибка при смене роли: %v", err)
+			return err
+		}
+	}
+
 	if err := m.storage.Connect(ctx); err != nil {
 		m.logger.Error("Ошибка при подключении: %v", err)
 		return err
 	}
+	m.closed = false
+
+	if m.options.StatementTimeout > 0 {
+		if err := m.storage.SetStatementTimeout(ctx, m.options.StatementTimeout); err != nil {
+			m.logger.Error("Ошибка при установке statement_timeout: %v", err)
+			if closeErr := m.storage.Close(); closeErr != nil {
+				m.logger.Error("Ошибка при закрытии: %v", closeErr)
+			}
+			return err
+		}
+	}
+
+	for _, statement := range m.options.PreLockStatements {
+		if err := m.storage.ExecSessionStatement(ctx, statement); err != nil {
+			m.logger.Error("Ошибка при настройке сессии: %v", err)
+			if closeErr := m.storage.Close(); closeErr != nil {
+				m.logger.Error("Ошибка при закрытии: %v", closeErr)
+			}
+			return err
+		}
+	}
+
+	if m.options.CheckPerms {
+		if err := m.checkPermissions(ctx); err != nil {
+			if closeErr := m.Close(ctx); closeErr != nil {
+				m.logger.Error("Ошибка при закрытии: %v", closeErr)
+			}
+			return err
+		}
+	}
 
 	m.logger.Info("Подключение к базе данных успешно")
 	return nil
 }
 
-// Метод для закрытия подключения к базе данных.
+// checkPermissions проверяет, что у роли, от имени которой выполняются
+// миграции, есть нужные привилегии, чтобы отказ в доступе обнаружился
+// до выполнения миграций, а не посреди них.
+func (m *Migrator) checkPermissions(ctx context.Context) error {
+	missing, err := m.storage.MissingPrivileges(ctx)
+	if err != nil {
+		m.logger.Error("Ошибка при проверке прав: %v", err)
+		return err
+	}
+	if len(missing) > 0 {
+		err := fmt.Errorf("%w: missing %s", ErrInsufficientPrivileges, strings.Join(missing, ", "))
+		m.logger.Error("Ошибка: %v", err)
+		return err
+	}
+	m.logger.Info("Права роли достаточны для выполнения миграций")
+	return nil
+}
+
+// Метод для закрытия подключения к базе данных. Повторный вызов до
+// следующего Connect ничего не делает и возвращает nil, поэтому Close можно
+// вызвать и явно, и в defer.
 func (m *Migrator) Close(ctx context.Context) error {
+	if m.closed {
+		return nil
+	}
+	m.closed = true
 	m.logger.Info("Закрытие подключения к базе данных")
 
+	if m.options.StatementTimeout > 0 {
+		if err := m.storage.ResetStatementTimeout(ctx); err != nil {
+			m.logger.Error("Ошибка при сбросе statement_timeout: %v", err)
+		}
+	}
+
+	if m.options.RunAs != "" {
+		if err := m.storage.ResetRole(ctx); err != nil {
+			m.logger.Error("Ошибка при сбросе роли: %v", err)
+		}
+	}
+
 	if err := m.storage.Close(); err != nil {
 		m.logger.Error("Ошибка при закрытии: %v", err)
 		return err
@@ -76,92 +343,309 @@ func (m *Migrator) Close(ctx context.Context) error {
 
 // Метод для создания миграции.
 func (m *Migrator) Create(name, up, down string, upGo, downGo func(ctx context.Context) error) {
-	m.logger.Info("Создание миграции: %s", name)
-	m.migrations = append(m.migrations, storage.Migration{
-		Status:  "success",
-		Version: len(m.migrations) + 1,
-		Name:    name,
-		Up:      up,
-		Down:    down,
-		UpGo:    upGo,
-		DownGo:  downGo,
+	m.Add(storage.Migration{
+		Name:   name,
+		Up:     up,
+		Down:   down,
+		UpGo:   upGo,
+		DownGo: downGo,
 	})
-	m.logger.Info("Миграция %s создана", name)
+}
+
+// Add добавляет загруженную миграцию следующей версией. В отличие от Create
+// сохраняет дополнительные поля миграции, например путь к исходному файлу.
+func (m *Migrator) Add(migration storage.Migration) {
+	m.logger.Info("Создание миграции: %s", migration.Name)
+	migration.Status = "success"
+	migration.Version = len(m.migrations) + 1
+	migration.Labels = migrationLabels(migration.Up)
+	migration.Author = migrationAuthor(migration.Up)
+	m.migrations = append(m.migrations, migration)
+	m.logger.Info("Миграция %s создана", migration.Name)
 }
 
 // Метод для выполнения миграций вверх.
 func (m *Migrator) Up(ctx context.Context) error {
 	m.logger.Info("Начало выполнения миграций")
 
-	if err := m.storage.Lock(ctx); err != nil {
-		m.logger.Error("Ошибка при блокировке: %v", err)
+	unlock, err := m.waitLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	statuses, err := m.storage.SelectAppliedVersions(ctx)
+	if err != nil {
+		m.logger.Error("Ошибка при получении списка миграций: %v", err)
 		return err
 	}
-	defer func(storage storage.SQLStorage, ctx context.Context) {
-		err := storage.Unlock(ctx)
-		if err != nil {
-			m.logger.Error("Ошибка при разблокировке: %v", err)
-		}
-	}(m.storage, ctx)
 
-	lastVersion := 0
-	lastMigration, err := m.storage.SelectLastMigrationByStatus(ctx, storage.StatusSuccess)
-	if err == nil {
-		lastVersion = lastMigration.GetVersion()
-	} else if !errors.Is(err, storage.ErrMigrationNotFound) {
-		m.logger.Error("1Ошибка при получении последней успешной миграции: %v", err)
+	statuses, err = m.handleDirty(ctx, statuses)
+	if err != nil {
 		return err
 	}
 
-	if lastMigration != nil && lastMigration.GetVersion()-1 > len(m.migrations) {
+	lastVersion := lastAppliedVersion(statuses)
+	if lastVersion-1 > len(m.migrations) {
 		m.logger.Error("Ошибка: %v", ErrUnexpectedMigrationVersion)
 		return ErrUnexpectedMigrationVersion
 	}
 
-	for i := lastVersion; i < len(m.migrations); i++ {
-		err = m.upMigration(ctx, &m.migrations[i], m.migrations[i].Up, m.migrations[i].UpGo)
+	var applied []*storage.Migration
+	appliedNow := make(map[int]bool)
+	for i := 0; i < len(m.migrations); i++ {
+		migration := &m.migrations[i]
+		version := i + 1
+		skipped := statuses[version] == storage.StatusSkipped
+
+		switch {
+		// Ожидающей считается любая версия без статуса success, в том числе
+		// ниже последней применённой: её могла обойти выборочная команда.
+		case statuses[version] == storage.StatusSuccess:
+			continue
+		case !m.isSelected(version):
+			m.logger.Info("Миграция %d (%s) не входит в выбранные версии", version, migration.GetName())
+			continue
+		case m.heldByOnlySQL(version):
+			m.logger.Info("Миграция %d (%s) содержит Go-шаг и остаётся ожидающей: включён -only-sql",
+				version, migration.GetName())
+			continue
+		case m.isSkipRequested(version):
+			if err := m.skipMigration(ctx, migration); err != nil {
+				return ErrMigrationUp
+			}
+			continue
+		case skipped && !m.options.ApplySkipped:
+			m.logger.Info("Миграция %d (%s) ранее пропущена, используйте -apply-skipped для её применения",
+				version, migration.GetName())
+			continue
+		}
+
+		if err := checkDependencies(migration, statuses, appliedNow); err != nil {
+			m.logger.Error("Ошибка при выполнении миграции вверх: %v", err)
+			return fmt.Errorf("%w: %w", ErrMigrationUp, err)
+		}
+
+		if gate, closed := m.closedGate(migration.Up); closed {
+			if err := m.gateMigration(ctx, migration, gate); err != nil {
+				return ErrMigrationUp
+			}
+			continue
+		}
+
+		exists, err := m.existingObjects(ctx, migration.Up)
+		if err != nil {
+			m.logger.Error("Ошибка при выполнении миграции вверх: %v", err)
+			return fmt.Errorf("%w: %w", ErrMigrationUp, err)
+		}
+		if exists {
+			if err := m.adoptMigration(ctx, migration); err != nil {
+				return ErrMigrationUp
+			}
+			appliedNow[version] = true
+			continue
+		}
+
+		decision, err := m.decide(*migration, DirectionUp)
+		if err != nil {
+			return err
+		}
+		switch decision {
+		case StepSkip:
+			if err := m.skipMigration(ctx, migration); err != nil {
+				return ErrMigrationUp
+			}
+			continue
+		case StepQuit:
+			return nil
+		}
+
+		err = m.upMigration(ctx, migration, migration.Up, migration.UpGo)
 		if err != nil {
 			m.logger.Error("Ошибка при выполнении миграции вверх: %v", err)
-			return ErrMigrationUp
+			return fmt.Errorf("%w: %w", ErrMigrationUp, err)
 		}
+		applied = append(applied, migration)
+		appliedNow[version] = true
 	}
 
 	m.logger.Info("Миграции успешно выполнены")
+	return m.analyzeApplied(ctx, applied)
+}
+
+// analyzeApplied обновляет статистику после Up, если задан PostUpAnalyze:
+// анализируются таблицы, объявленные директивой "-- migrator:analyze",
+// а без таких директив — вся база данных.
+func (m *Migrator) analyzeApplied(ctx context.Context, applied []*storage.Migration) error {
+	if !m.options.PostUpAnalyze || len(applied) == 0 {
+		return nil
+	}
+
+	sqls := make([]string, 0, len(applied))
+	for _, migration := range applied {
+		sqls = append(sqls, migration.Up)
+	}
+
+	tables := analyzeTables(sqls...)
+	m.logger.Info("Обновление статистики планировщика")
+	if err := m.storage.Analyze(ctx, tables, m.options.PostUpVacuum); err != nil {
+		m.logger.Error("Ошибка при обновлении статистики: %v", err)
+		return fmt.Errorf("%w: %w", ErrPostUpAnalyze, err)
+	}
+	return nil
+}
+
+// Pending возвращает загруженные миграции, которые ещё не применены успешно,
+// в порядке версий. Метод ничего не выводит и не берёт блокировку.
+func (m *Migrator) Pending(ctx context.Context) ([]storage.Migration, error) {
+	pending, _, err := m.partition(ctx)
+	return pending, err
+}
+
+// Applied возвращает загруженные миграции, успешно применённые в базе данных,
+// в порядке версий. Метод ничего не выводит и не берёт блокировку.
+func (m *Migrator) Applied(ctx context.Context) ([]storage.Migration, error) {
+	_, applied, err := m.partition(ctx)
+	return applied, err
+}
+
+// partition делит загруженные миграции на ожидающие и применённые
+// по статусам, записанным в хранилище.
+func (m *Migrator) partition(ctx context.Context) (pending, applied []storage.Migration, err error) {
+	statuses, err := m.storage.SelectAppliedVersions(ctx)
+	if err != nil {
+		m.logger.Error("Ошибка при получении списка миграций: %v", err)
+		return nil, nil, err
+	}
+
+	pending = make([]storage.Migration, 0, len(m.migrations))
+	applied = make([]storage.Migration, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		migration.Status = statuses[migration.Version]
+		if migration.Status == storage.StatusSuccess {
+			applied = append(applied, migration)
+		} else {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, applied, nil
+}
+
+// VersionStatus возвращает записанный в хранилище статус версии
+// или пустую строку, если версия ещё не выполнялась.
+func (m *Migrator) VersionStatus(ctx context.Context, version int) (string, error) {
+	statuses, err := m.storage.SelectAppliedVersions(ctx)
+	if err != nil {
+		m.logger.Error("Ошибка при получении списка миграций: %v", err)
+		return "", err
+	}
+	return statuses[version], nil
+}
+
+// lastAppliedVersion возвращает наибольшую успешно применённую версию или 0.
+func lastAppliedVersion(statuses map[int]string) int {
+	last := 0
+	for version, status := range statuses {
+		if status == storage.StatusSuccess && version > last {
+			last = version
+		}
+	}
+	return last
+}
+
+// isSelected сообщает, входит ли версия в Options.OnlyVersions.
+func (m *Migrator) isSelected(version int) bool {
+	return m.options.OnlyVersions == nil || m.options.OnlyVersions[version]
+}
+
+// heldByOnlySQL сообщает, что версия содержит Go-шаг и при включённом
+// OnlySQL остаётся ожидающей.
+func (m *Migrator) heldByOnlySQL(version int) bool {
+	migration := m.migrations[version-1]
+	return m.options.OnlySQL && (migration.UpGo != nil || migration.DownGo != nil)
+}
+
+// isSkipRequested сообщает, должна ли версия быть пропущена: она указана
+// в SkipVersions.
+func (m *Migrator) isSkipRequested(version int) bool {
+	for _, skip := range m.options.SkipVersions {
+		if skip == version {
+			return true
+		}
+	}
+	return false
+}
+
+// skipMigration помечает миграцию как пропущенную, не выполняя её.
+func (m *Migrator) skipMigration(ctx context.Context, migration storage.IMigration) error {
+	m.logger.Warn("ВНИМАНИЕ: миграция %d (%s) пропущена по запросу. "+
+		"Последующие миграции, зависящие от неё, могут завершиться ошибкой",
+		migration.GetVersion(), migration.GetName())
+
+	migration.SetStatus(storage.StatusSkipped)
+	migration.SetStatusChangeTime(time.Now())
+	if err := m.storage.InsertMigration(ctx, migration); err != nil {
+		m.logger.Error("Ошибка при вставке миграции: %v", err)
+		return err
+	}
+	return nil
+}
+
+// gateMigration помечает миграцию как отложенную шлюзом gate, не выполняя её.
+// Миграция будет применена следующим Up, как только шлюз будет включён.
+func (m *Migrator) gateMigration(ctx context.Context, migration storage.IMigration, gate string) error {
+	m.logger.Info("Миграция %d (%s) отложена: шлюз %s выключен (%s%s=true или -gate %s=true)",
+		migration.GetVersion(), migration.GetName(), gate, gateEnvPrefix, gate, gate)
+
+	migration.SetStatus(storage.StatusGated)
+	migration.SetStatusChangeTime(time.Now())
+	if err := m.storage.InsertMigration(ctx, migration); err != nil {
+		m.logger.Error("Ошибка при вставке миграции: %v", err)
+		return err
+	}
 	return nil
 }
 
 func (m *Migrator) Down(ctx context.Context) error {
 	m.logger.Info("Начало выполнения отката миграций")
 
-	if err := m.storage.Lock(ctx); err != nil {
-		m.logger.Error("Ошибка при блокировке: %v", err)
+	unlock, err := m.lockRun(ctx)
+	if err != nil {
 		return err
 	}
-	defer func(storage storage.SQLStorage, ctx context.Context) {
-		err := storage.Unlock(ctx)
-		if err != nil {
-			m.logger.Error("Ошибка при разблокировке: %v", err)
-		}
-	}(m.storage, ctx)
+	defer unlock()
 
-	lastMigration, err := m.storage.SelectLastMigrationByStatus(ctx, storage.StatusSuccess)
+	statuses, err := m.storage.SelectAppliedVersions(ctx)
 	if err != nil {
-		if errors.Is(err, storage.ErrMigrationNotFound) {
-			m.logger.Warn("Нет успешных миграций для отката")
-			return nil
+		m.logger.Error("Ошибка при получении списка миграций: %v", err)
+		return err
+	}
+
+	applied := make([]int, 0, len(statuses))
+	for version, status := range statuses {
+		if status == storage.StatusSuccess {
+			applied = append(applied, version)
 		}
-		m.logger.Error("Ошибка при получении "+
-			"последней успешной миграции: %v", err)
+	}
+	if len(applied) == 0 {
+		m.logger.Warn("Нет успешных миграций для отката")
+		return nil
+	}
+
+	// Откатывается миграция, от которой не зависит ни одна другая применённая:
+	// без директив depends-on это старшая версия.
+	versions, err := m.rollbackOrder(applied)
+	if err != nil {
 		return err
 	}
 
-	if lastMigration.GetVersion() > len(m.migrations) {
-		m.logger.Error("Ошибка: %v", ErrUnexpectedMigrationVersion)
-		return ErrUnexpectedMigrationVersion
+	migration := &m.migrations[versions[0]-1]
+	decision, err := m.decide(*migration, DirectionDown)
+	if err != nil || decision != StepApply {
+		return err
 	}
 
-	downMigrationIndex := lastMigration.GetVersion() - 1
-	err = m.downMigration(ctx, &m.migrations[downMigrationIndex], m.migrations[downMigrationIndex].Down, m.migrations[downMigrationIndex].DownGo)
+	err = m.downMigration(ctx, migration, migration.Down, migration.DownGo)
 	if err != nil {
 		m.logger.Error("Ошибка при выполнении отката миграции: %v", err)
 		return ErrMigrationDown
@@ -171,8 +655,267 @@ func (m *Migrator) Down(ctx context.Context) error {
 	return nil
 }
 
+// Метод для отката всех успешных миграций с версией выше указанной.
+// Откат всегда выполняется строго от старшей версии к младшей, так как
+// более поздние миграции могут зависеть от объектов, созданных ранними.
+func (m *Migrator) DownTo(ctx context.Context, version int) error {
+	m.logger.Info("Начало отката миграций до версии %d", version)
+
+	unlock, err := m.lockRun(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return m.downTo(ctx, version)
+}
+
+// Nuke возвращает базу данных в исходное состояние: откатывает все
+// применённые миграции от старшей версии к младшей и удаляет служебную
+// таблицу. В отличие от reset, после Nuke не остаётся и записей о миграциях.
+// Если какая-либо миграция осталась применённой (например, пропущена
+// в интерактивном режиме), таблица не удаляется.
+func (m *Migrator) Nuke(ctx context.Context) error {
+	m.logger.Warn("Откат всех миграций и удаление служебной таблицы")
+
+	unlock, err := m.lockRun(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := m.downTo(ctx, 0); err != nil {
+		return err
+	}
+
+	migrations, err := m.storage.SelectMigrations(ctx)
+	if err != nil && !errors.Is(err, storage.ErrMigrationNotFound) {
+		m.logger.Error("Ошибка при получении списка миграций: %v", err)
+		return err
+	}
+	if remaining := appliedVersionsAbove(migrations, 0); len(remaining) > 0 {
+		m.logger.Error("Ошибка: %v: %v", ErrNukeIncomplete, remaining)
+		return fmt.Errorf("%w: %v", ErrNukeIncomplete, remaining)
+	}
+
+	if err := m.storage.DropTrackingTable(ctx); err != nil {
+		m.logger.Error("Ошибка при удалении служебной таблицы: %v", err)
+		return err
+	}
+
+	m.logger.Info("База данных возвращена в исходное состояние")
+	return nil
+}
+
+// RollbackPreview возвращает миграции, которые откатил бы DownTo(version),
+// в порядке отката; Down откатил бы первую из них. Блокировка не берётся:
+// метод нужен, чтобы показать откат оператору до его выполнения.
+func (m *Migrator) RollbackPreview(ctx context.Context, version int) ([]storage.Migration, error) {
+	migrations, err := m.storage.SelectMigrations(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrMigrationNotFound) {
+			return nil, nil
+		}
+		m.logger.Error("Ошибка при получении списка миграций: %v", err)
+		return nil, err
+	}
+
+	versions, err := m.rollbackOrder(appliedVersionsAbove(migrations, version))
+	if err != nil {
+		return nil, err
+	}
+
+	preview := make([]storage.Migration, 0, len(versions))
+	for _, v := range versions {
+		preview = append(preview, m.migrations[v-1])
+	}
+	return preview, nil
+}
+
+// downTo откатывает применённые миграции выше version под уже взятой блокировкой.
+func (m *Migrator) downTo(ctx context.Context, version int) error {
+	migrations, err := m.storage.SelectMigrations(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrMigrationNotFound) {
+			m.logger.Warn("Нет успешных миграций для отката")
+			return nil
+		}
+		m.logger.Error("Ошибка при получении списка миграций: %v", err)
+		return err
+	}
+
+	versions, err := m.rollbackOrder(appliedVersionsAbove(migrations, version))
+	if err != nil {
+		return err
+	}
+
+	batch := m.newDownBatch()
+	for _, v := range versions {
+		migration := &m.migrations[v-1]
+		decision, err := m.decide(*migration, DirectionDown)
+		if err != nil {
+			batch.abort(ctx)
+			return err
+		}
+		if decision == StepSkip {
+			continue
+		}
+		if decision == StepQuit {
+			return batch.commit(ctx)
+		}
+
+		if err := batch.down(ctx, migration); err != nil {
+			m.logger.Error("Ошибка при выполнении отката миграции: %v", err)
+			return ErrMigrationDown
+		}
+	}
+	if err := batch.commit(ctx); err != nil {
+		return err
+	}
+
+	m.logger.Info("Откат миграций до версии %d успешно выполнен", version)
+	return nil
+}
+
+// rollbackOrder проверяет, что версии загружены, и упорядочивает их для отката
+// с учётом директив depends-on.
+func (m *Migrator) rollbackOrder(versions []int) ([]int, error) {
+	for _, v := range versions {
+		if v > len(m.migrations) {
+			m.logger.Error("Ошибка: %v", ErrUnexpectedMigrationVersion)
+			return nil, ErrUnexpectedMigrationVersion
+		}
+	}
+
+	deps, err := m.dependencies()
+	if err != nil {
+		m.logger.Error("Ошибка в директиве depends-on: %v", err)
+		return nil, err
+	}
+
+	order, err := rollbackOrder(versions, deps)
+	if err != nil {
+		m.logger.Error("Ошибка при определении порядка отката: %v", err)
+		return nil, err
+	}
+	return order, nil
+}
+
+// appliedVersionsAbove возвращает версии успешных миграций выше указанной,
+// отсортированные по убыванию. Хранилище не обязано возвращать строки
+// в каком-либо порядке, поэтому сортировка выполняется явно.
+func appliedVersionsAbove(migrations []storage.IMigration, version int) []int {
+	versions := make([]int, 0, len(migrations))
+	for _, migr := range migrations {
+		if migr.GetStatus() == storage.StatusSuccess && migr.GetVersion() > version {
+			versions = append(versions, migr.GetVersion())
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	return versions
+}
+
+// lockRun берёт сессионную блокировку на всё время команды. При области
+// LockScopeMigration блокировка берётся внутри каждой миграции, и lockRun
+// ничего не делает. Возвращаемая функция снимает блокировку.
+func (m *Migrator) lockRun(ctx context.Context) (func(), error) {
+	if err := m.checkWritable(); err != nil {
+		return nil, err
+	}
+	if m.options.LockScope == LockScopeMigration {
+		return func() {}, nil
+	}
+
+	if err := m.sessionLock(ctx); err != nil {
+		return nil, err
+	}
+	return m.unlocker(ctx), nil
+}
+
+// sessionLock берёт сессионную блокировку мигратора: при AbortLockWait
+// одной попыткой TryLock, иначе ожидая её освобождения в Lock.
+func (m *Migrator) sessionLock(ctx context.Context) error {
+	if !m.options.AbortLockWait {
+		if err := m.storage.Lock(ctx); err != nil {
+			m.logger.Error("Ошибка при блокировке: %v", err)
+			return err
+		}
+		return nil
+	}
+	return m.logLockErr(m.storage.TryLock(ctx))
+}
+
+// logLockErr выводит ошибку попытки взять блокировку без ожидания
+// и возвращает её без изменений.
+func (m *Migrator) logLockErr(err error) error {
+	switch {
+	case err == nil:
+	case errors.Is(err, storage.ErrLockBusy):
+		m.logger.Error("Блокировка занята другим процессом, запуск прерван без ожидания")
+	default:
+		m.logger.Error("Ошибка при блокировке: %v", err)
+	}
+	return err
+}
+
+// checkWritable не даёт изменяющей команде начать работу на реплике:
+// там и блокировка, и запись в служебную таблицу завершились бы
+// малопонятной ошибкой. Команды чтения блокировку не берут и не проверяются.
+func (m *Migrator) checkWritable() error {
+	if !m.storage.InRecovery() {
+		return nil
+	}
+	m.logger.Error("База данных — реплика в режиме восстановления (pg_is_in_recovery), " +
+		"изменения на ней невозможны; подключитесь к основному серверу")
+	return ErrReadOnlyDatabase
+}
+
+// unlocker возвращает функцию, снимающую сессионную блокировку.
+func (m *Migrator) unlocker(ctx context.Context) func() {
+	return func() {
+		if err := m.storage.Unlock(ctx); err != nil {
+			m.logger.Error("Ошибка при разблокировке: %v", err)
+		}
+	}
+}
+
+// migrate выполняет SQL миграции и возвращает число изменённых строк.
+// Команды с нестандартным разделителем предварительно переписываются
+// через ";". Миграция с директивой report выполняется по частям,
+// см. migrateWithReports.
+func (m *Migrator) migrate(ctx context.Context, sql string) (int64, error) {
+	if hasReports(sql) {
+		return m.migrateWithReports(ctx, sql)
+	}
+	return m.storage.Migrate(ctx, m.normalizeDelimiter(sql))
+}
+
+// runGo выполняет Go-шаг миграции. Транзакция Go-шага не берёт блокировку
+// мигратора, поэтому при области LockScopeMigration на время шага берётся
+// сессионная блокировка.
+func (m *Migrator) runGo(ctx context.Context, goFunc func(ctx context.Context) error) error {
+	if m.options.LockScope != LockScopeMigration {
+		return goFunc(ctx)
+	}
+
+	if err := m.sessionLock(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if err := m.storage.Unlock(ctx); err != nil {
+			m.logger.Error("Ошибка при разблокировке: %v", err)
+		}
+	}()
+	return goFunc(ctx)
+}
+
 // Вспомогательный метод для выполнения миграции.
 func (m *Migrator) executeMigration(ctx context.Context, migration storage.IMigration, sql string, goFunc func(ctx context.Context) error, processStatus, successStatus, errorStatus string) error {
+	if m.singleTx(sql, goFunc) {
+		return m.executeSteps(ctx, migration, sql, goFunc, processStatus, successStatus, errorStatus)
+	}
+
 	migration.SetStatus(processStatus)
 	migration.SetStatusChangeTime(time.Now())
 
@@ -181,118 +924,530 @@ func (m *Migrator) executeMigration(ctx context.Context, migration storage.IMigr
 		return err
 	}
 
+	if processStatus == storage.StatusProcess && m.simulateFailure(migration.GetVersion()) {
+		m.logger.Warn("Имитация сбоя миграции %d: запись остаётся в статусе %s", migration.GetVersion(), processStatus)
+		return ErrSimulatedFailure
+	}
+
+	stopHeartbeat := m.startHeartbeat(ctx, migration.GetVersion())
+	defer stopHeartbeat()
+
+	// Статус миграции записывается с исходным ctx: после истечения тайм-аута
+	// директивы timeout записать ошибку всё равно нужно.
+	stepCtx, restoreTimeout, err := m.withMigrationTimeout(ctx, sql)
+	if err != nil {
+		m.logger.Error("Ошибка при установке тайм-аута миграции: %v", err)
+		return m.recordFailure(ctx, migration, errorStatus, err)
+	}
+	defer restoreTimeout()
+
+	expectation, err := migrationRowsExpectation(sql)
+	if err != nil {
+		m.logger.Error("Ошибка в директиве expect-rows: %v", err)
+		return m.recordFailure(ctx, migration, errorStatus, err)
+	}
+
+	if sql != "" {
+		rows, err := m.migrateSQL(stepCtx, sql, "")
+		if err != nil {
+			m.logger.Error("Ошибка при выполнении SQL-миграции: %v", err)
+			return m.recordStepFailure(ctx, stepCtx, migration, errorStatus, err)
+		}
+		m.recordRowsAffected(migration, rows)
+		if err := checkRowsExpectation(expectation, rows); err != nil {
+			// Сюда доходят только миграции с директивами batch и parallel:
+			// остальные с expect-rows выполняются в транзакции, см. singleTx.
+			m.logger.Error("Миграция %d (%s): %v. Изменения SQL уже применены, проверьте базу данных",
+				migration.GetVersion(), migration.GetName(), err)
+			return m.recordFailure(ctx, migration, errorStatus, err)
+		}
+	}
+
+	// SQL миграции с директивами batch и parallel фиксируется сам, поэтому
+	// Go-шаг такой миграции выполняется уже после него, см. singleTx.
 	if goFunc != nil {
-		if err := goFunc(ctx); err != nil {
-			migration.SetStatus(errorStatus)
-			migration.SetStatusChangeTime(time.Now())
-			err := m.storage.InsertMigration(ctx, migration)
-			m.logger.Error("Ошибка при выполнении Go-миграции: %v", err)
-			return err
+		return m.runGoStep(ctx, stepCtx, migration, goFunc, successStatus, errorStatus)
+	}
+
+	migration.SetStatus(successStatus)
+	migration.SetStatusChangeTime(time.Now())
+	if err := m.storage.InsertMigration(ctx, migration); err != nil {
+		m.logger.Error("Ошибка при вставке миграции: %v", err)
+		return err
+	}
+
+	m.logger.Info("Миграция %s до версии %d успешно применена", migration.GetName(), migration.GetVersion())
+	return nil
+}
+
+// runGoStep выполняет Go-шаг после уже зафиксированного SQL миграции
+// и записывает итоговый статус, см. applyGo.
+func (m *Migrator) runGoStep(ctx, stepCtx context.Context, migration storage.IMigration, goFunc func(ctx context.Context) error, successStatus, errorStatus string) error {
+	if err := m.runGo(stepCtx, func(ctx context.Context) error {
+		return m.storage.WithTx(ctx, func(txCtx context.Context) error {
+			return m.applyGo(txCtx, migration, goFunc, successStatus)
+		})
+	}); err != nil {
+		m.logger.Error("Ошибка при выполнении Go-миграции: %v", err)
+		return m.recordStepFailure(ctx, stepCtx, migration, errorStatus, err)
+	}
+	m.logger.Info("Миграция %s до версии %d успешно применена", migration.GetName(), migration.GetVersion())
+	return nil
+}
+
+// applyGo выполняет Go-шаг и записывает итоговый статус в транзакции,
+// открытой WithTx: изменения Go-миграции через storage.ExecutorFromContext
+// фиксируются или откатываются вместе с записью статуса.
+func (m *Migrator) applyGo(ctx context.Context, migration storage.IMigration, goFunc func(ctx context.Context) error, successStatus string) error {
+	if err := goFunc(ctx); err != nil {
+		return err
+	}
+
+	migration.SetStatus(successStatus)
+	migration.SetStatusChangeTime(time.Now())
+	if err := m.storage.InsertMigration(ctx, migration); err != nil {
+		m.logger.Error("Ошибка при вставке миграции: %v", err)
+		return err
+	}
+	return nil
+}
+
+// recordStepFailure записывает сбой шага миграции. Если шаг прерван отменой
+// контекста, записывается статус cancelled: отменённый контекст не позволил
+// бы записать и его, поэтому запись выполняется без отмены.
+func (m *Migrator) recordStepFailure(ctx, stepCtx context.Context, migration storage.IMigration, errorStatus string, stepErr error) error {
+	cause := stepCtx.Err()
+	if cause == nil {
+		return m.recordFailure(ctx, migration, errorStatus, stepErr)
+	}
+
+	m.logger.Error("Миграция %d (%s) прервана: %v. Изменения могли примениться частично, "+
+		"проверьте базу данных перед повторным запуском", migration.GetVersion(), migration.GetName(), cause)
+	return m.recordFailure(context.WithoutCancel(ctx), migration, storage.StatusCancelled,
+		fmt.Errorf("%w: %w", ErrMigrationCancelled, stepErr))
+}
+
+// recordFailure записывает статус ошибки миграции и возвращает исходную
+// ошибку шага; ошибка записи статуса только попадает в журнал.
+func (m *Migrator) recordFailure(ctx context.Context, migration storage.IMigration, errorStatus string, stepErr error) error {
+	migration.SetStatus(errorStatus)
+	migration.SetStatusChangeTime(time.Now())
+	if err := m.storage.InsertMigration(ctx, migration); err != nil {
+		m.logger.Error("Ошибка при вставке миграции: %v", err)
+	}
+	return stepErr
+}
+
+// executeSteps выполняет миграцию в одной транзакции: записывает статус
+// выполнения, выполняет SQL после точки сохранения migration_sql, затем
+// Go-шаг и записывает итоговый статус. При сбое любого шага транзакция
+// откатывается к точке сохранения, поэтому SQL отменяется и при сбое
+// Go-шага, а статус ошибки записывается в той же транзакции. Изменения
+// самого Go-шага в эту транзакцию не входят: он выполняется в собственной
+// сессии или процессе и должен сам отменять их при ошибке. Невыполненное
+// ожидание expect-rows считается сбоем SQL-шага. При области
+// LockScopeMigration транзакция начинается с pg_advisory_xact_lock,
+// см. lockVersion.
+func (m *Migrator) executeSteps(ctx context.Context, migration storage.IMigration, sql string, goFunc func(ctx context.Context) error, processStatus, successStatus, errorStatus string) error {
+	// Статус миграции записывается с исходным ctx: после истечения тайм-аута
+	// директивы timeout записать ошибку всё равно нужно.
+	stepCtx, restoreTimeout, err := m.withMigrationTimeout(ctx, sql)
+	if err != nil {
+		m.logger.Error("Ошибка при установке тайм-аута миграции: %v", err)
+		return m.recordFailure(ctx, migration, errorStatus, err)
+	}
+	defer restoreTimeout()
+
+	expectation, err := migrationRowsExpectation(sql)
+	if err != nil {
+		m.logger.Error("Ошибка в директиве expect-rows: %v", err)
+		return m.recordFailure(ctx, migration, errorStatus, err)
+	}
+
+	if err := m.storage.Begin(ctx); err != nil {
+		m.logger.Error("Ошибка при открытии транзакции: %v", err)
+		return err
+	}
+	if m.options.LockScope == LockScopeMigration {
+		if err := m.lockVersion(stepCtx, migration, successStatus); err != nil {
+			m.rollback(ctx)
+			// Занятая блокировка — не сбой миграции: её выполняет другой процесс.
+			if errors.Is(err, errAlreadyDone) || errors.Is(err, storage.ErrLockBusy) {
+				return err
+			}
+			return m.recordStepFailure(ctx, stepCtx, migration, errorStatus, err)
 		}
-	} else if sql != "" {
-		if err := m.storage.Migrate(ctx, sql); err != nil {
-			migration.SetStatus(errorStatus)
-			migration.SetStatusChangeTime(time.Now())
-			err := m.storage.InsertMigration(ctx, migration)
-			m.logger.Error("Ошибка при выполнении SQL-миграции: %v", err)
+	}
+
+	migration.SetStatus(processStatus)
+	migration.SetStatusChangeTime(time.Now())
+	if err := m.storage.InsertMigration(ctx, migration); err != nil {
+		m.logger.Error("Ошибка при вставке миграции: %v", err)
+		m.rollback(ctx)
+		return err
+	}
+
+	if processStatus == storage.StatusProcess && m.simulateFailure(migration.GetVersion()) {
+		m.logger.Warn("Имитация сбоя миграции %d: запись остаётся в статусе %s", migration.GetVersion(), processStatus)
+		if err := m.storage.Commit(ctx); err != nil {
+			m.logger.Error("Ошибка при фиксации транзакции: %v", err)
+		}
+		return ErrSimulatedFailure
+	}
+
+	stopHeartbeat := m.startHeartbeat(ctx, migration.GetVersion())
+	defer stopHeartbeat()
+
+	const savepoint = "migration_sql"
+	if err := m.storage.Savepoint(ctx, savepoint); err != nil {
+		m.rollback(ctx)
+		return err
+	}
+
+	// Go-шаг отдельным процессом не видит эту транзакцию и ждал бы снятия
+	// её блокировок, поэтому выполняется только после фиксации SQL.
+	goAfterCommit := goFunc != nil && goProcess(migration)
+
+	rows, stepErr := m.migrateSQL(stepCtx, sql, savepoint)
+	if stepErr == nil {
+		m.recordRowsAffected(migration, rows)
+		stepErr = checkRowsExpectation(expectation, rows)
+	}
+	if stepErr == nil && goFunc != nil && !goAfterCommit {
+		stepErr = m.runGo(m.storage.ContextWithTx(stepCtx), goFunc)
+	}
+
+	status := successStatus
+	if goAfterCommit {
+		status = processStatus
+	}
+	if stepErr != nil {
+		m.logger.Error("Ошибка миграции %d, изменения SQL отменяются: %v", migration.GetVersion(), stepErr)
+		// После отмены контекста соединение с запросом закрыто, и транзакцию
+		// можно только откатить; статус записывается уже вне её.
+		if stepCtx.Err() != nil {
+			m.rollback(ctx)
+			return m.recordStepFailure(ctx, stepCtx, migration, errorStatus, stepErr)
+		}
+		if err := m.storage.RollbackToSavepoint(ctx, savepoint); err != nil {
+			m.rollback(ctx)
 			return err
 		}
+		status = errorStatus
 	}
 
-	migration.SetStatus(successStatus)
+	migration.SetStatus(status)
 	migration.SetStatusChangeTime(time.Now())
 	if err := m.storage.InsertMigration(ctx, migration); err != nil {
 		m.logger.Error("Ошибка при вставке миграции: %v", err)
+		m.rollback(ctx)
 		return err
 	}
 
+	if err := m.storage.Commit(ctx); err != nil {
+		m.logger.Error("Ошибка при фиксации транзакции: %v", err)
+		return err
+	}
+	if stepErr != nil {
+		return stepErr
+	}
+	if goAfterCommit {
+		return m.runGoStep(ctx, stepCtx, migration, goFunc, successStatus, errorStatus)
+	}
 	m.logger.Info("Миграция %s до версии %d успешно применена", migration.GetName(), migration.GetVersion())
 	return nil
 }
 
+// goProcess сообщает, выполняется ли Go-шаг миграции отдельным процессом,
+// см. storage.Migration.GoProcess.
+func goProcess(migration storage.IMigration) bool {
+	m, ok := migration.(*storage.Migration)
+	return ok && m.GoProcess
+}
+
+// recordRowsAffected записывает в журнал и в запись миграции число строк,
+// изменённых её SQL. Оно сохраняется в служебной таблице вместе с итоговым статусом.
+func (m *Migrator) recordRowsAffected(migration storage.IMigration, rows int64) {
+	migration.SetRowsAffected(rows)
+	m.logger.Info("Миграция %d: изменено строк %d", migration.GetVersion(), rows)
+}
+
+// rollback откатывает открытую транзакцию после сбоя, только записывая
+// в журнал ошибку самого отката.
+func (m *Migrator) rollback(ctx context.Context) {
+	if err := m.storage.Rollback(ctx); err != nil {
+		m.logger.Error("Ошибка при откате транзакции: %v", err)
+	}
+}
+
+// simulateFailure сообщает, нужно ли имитировать сбой на версии version.
+// Переменная окружения проверяется здесь же, чтобы хук нельзя было включить
+// одними параметрами, минуя CLI.
+func (m *Migrator) simulateFailure(version int) bool {
+	return m.options.SimulateFailureVersion == version && version > 0 &&
+		os.Getenv(allowSimulateEnv) == "1"
+}
+
 // Метод для выполнения миграции вверх.
 func (m *Migrator) upMigration(ctx context.Context, migration storage.IMigration, sql string, upGo func(ctx context.Context) error) error {
-	return m.executeMigration(ctx, migration, sql, upGo, storage.StatusProcess, storage.StatusSuccess, storage.StatusError)
+	start := time.Now()
+	err := m.executeMigration(ctx, migration, sql, upGo, storage.StatusProcess, storage.StatusSuccess, storage.StatusError)
+	if errors.Is(err, errAlreadyDone) {
+		return nil
+	}
+	if err != nil {
+		m.recordFailedAttempt(migration, time.Since(start), err)
+		return err
+	}
+	m.recordApplied(migration, time.Since(start))
+	return nil
 }
 
 // Метод для выполнения миграции вниз.
 func (m *Migrator) downMigration(ctx context.Context, migration storage.IMigration, sql string, downGo func(ctx context.Context) error) error {
-	return m.executeMigration(ctx, migration, sql, downGo, storage.StatusCancellation, storage.StatusCancel, storage.StatusError)
+	start := time.Now()
+	err := m.executeMigration(ctx, migration, sql, downGo, storage.StatusCancellation, storage.StatusCancel, storage.StatusError)
+	if errors.Is(err, errAlreadyDone) {
+		return nil
+	}
+	if err != nil {
+		m.recordFailedAttempt(migration, time.Since(start), err)
+		return err
+	}
+	m.recordRolledBack(migration, time.Since(start))
+	return nil
 }
 
-// Метод для выполнения повторной миграции.
-func (m *Migrator) Redo(ctx context.Context) error {
+// Redo откатывает последнюю применённую миграцию (с учётом depends-on) и
+// заново применяет именно её, возвращая её версию и имя.
+func (m *Migrator) Redo(ctx context.Context) (RedoResult, error) {
 	m.logger.Info("Начало выполнения повторной миграции")
 
+	if err := m.checkRedoDown(ctx); err != nil {
+		return RedoResult{}, err
+	}
+
+	rolledBack := len(m.result.RolledBack)
 	err := m.Down(ctx)
 	if err != nil {
 		m.logger.Error("Ошибка при откате миграции: %v", err)
-		return err
+		return RedoResult{}, err
 	}
-
-	lastVersion := 0
-	lastMigration, err := m.storage.SelectLastMigrationByStatus(ctx, storage.StatusSuccess)
-	if err == nil {
-		lastVersion = lastMigration.GetVersion()
-	} else if !errors.Is(err, storage.ErrMigrationNotFound) {
-		m.logger.Error("Ошибка при получении последней успешной миграции: %v", err)
-		return err
+	if len(m.result.RolledBack) == rolledBack {
+		m.logger.Warn("Нет откаченной миграции для повторного выполнения")
+		return RedoResult{}, ErrNothingToRedo
 	}
 
-	if lastMigration != nil && lastMigration.GetVersion()-1 > len(m.migrations) {
-		m.logger.Error("Ошибка: %v", ErrUnexpectedMigrationVersion)
-		return ErrUnexpectedMigrationVersion
-	}
-
-	err = m.upMigration(ctx, &m.migrations[lastVersion], m.migrations[lastVersion].Up, m.migrations[lastVersion].UpGo)
+	// Down откатывает миграцию, от которой не зависят другие, а не обязательно
+	// следующую за последней успешной, поэтому повторно применяется ровно она.
+	version := m.result.RolledBack[len(m.result.RolledBack)-1].Version
+	migration := &m.migrations[version-1]
+	err = m.upMigration(ctx, migration, migration.Up, migration.UpGo)
 	if err != nil {
 		m.logger.Error("Ошибка при повторной миграции: %v", err)
-		return ErrMigrationRedo
+		return RedoResult{}, ErrMigrationRedo
 	}
 
 	m.logger.Info("Повторная миграция успешно выполнена")
-	return nil
+	return RedoResult{Version: version, Name: migration.Name}, nil
 }
 
+// noTrackingTableMessage выводится статусом до первого up, когда служебной
+// таблицы ещё нет.
+const noTrackingTableMessage = "Миграции ещё не применялись (служебная таблица не создана)"
+
 // Метод для получения статуса миграций.
 func (m *Migrator) Status(ctx context.Context) error {
+	if m.options.StatusFull {
+		return m.statusFull(ctx)
+	}
+
+	if m.options.StatusVerifyChecksums && len(m.migrations) == 0 {
+		return ErrNothingToVerify
+	}
+
 	migrations, err := m.storage.SelectMigrations(ctx)
+	if storage.IsTrackingTableAbsent(err) {
+		m.logger.Info(noTrackingTableMessage)
+		return nil
+	}
 	if err != nil {
 		m.logger.Error("Ошибка при получении статуса: %v", err)
 		return ErrGetStatus
 	}
 
+	// Если миграции загружены из директории, отмечаем изменённые после применения.
+	var modified []storage.Migration
+	if len(m.migrations) > 0 {
+		modified, err = m.ModifiedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	drifted := make(map[int]bool, len(modified))
+	for _, migration := range modified {
+		drifted[migration.Version] = true
+	}
+
 	border := "._____________________._____________________._____________________."
 	header := fmt.Sprintf("| %-19s | %-19s | %-19s |",
 		"Название", "Статус", "Время")
+	if m.options.StatusVerbose {
+		border += "_____________________________________."
+		header += fmt.Sprintf(" %-35s |", "Файл")
+	}
+	if m.options.StatusWide {
+		border += "_____________________________________."
+		header += fmt.Sprintf(" %-35s |", "Автор")
+	}
 	m.logger.Info(border)
 	m.logger.Info(header)
 
 	for _, migr := range migrations {
+		if !m.matchesStatusLabel(migr) {
+			continue
+		}
 		formatMigration := fmt.Sprintf("| %-19s | %-19s | %s |",
 			migr.GetName(),
-			migr.GetStatus(),
+			statusWithIcon(migr.GetStatus(), m.options.StatusIcons),
 			migr.GetStatusChangeTime().Format("2006-01-02 15:04:05"))
+		if m.options.StatusVerbose {
+			formatMigration += fmt.Sprintf(" %-35s |", migr.GetSourceFile())
+		}
+		if m.options.StatusWide {
+			formatMigration += fmt.Sprintf(" %-35s |", migr.GetAuthor())
+		}
+		if m.options.StatusVerifyChecksums && migr.GetStatus() == storage.StatusSuccess && drifted[migr.GetVersion()] {
+			formatMigration += " DRIFT"
+		}
 
 		m.logger.Info(formatMigration)
 	}
 
 	m.logger.Info(border)
+
+	if err := m.logLatestTag(ctx); err != nil {
+		return err
+	}
+
+	for _, migration := range modified {
+		m.warnModified(migration)
+	}
+	if m.options.StatusVerifyChecksums && len(modified) > 0 {
+		return checksumMismatch(modified)
+	}
 	return nil
 }
 
-// Метод для получения текущей версии базы данных.
-func (m *Migrator) DBVersion(ctx context.Context) error {
-	lastVersion := 0
+// AcquirePersistentLock захватывает постоянную блокировку key, ожидая её
+// освобождения другим владельцем и проверяя её каждые poll.
+func (m *Migrator) AcquirePersistentLock(ctx context.Context, key, owner string, ttl, poll time.Duration) error {
+	for {
+		err := m.storage.AcquirePersistentLock(ctx, key, owner, ttl)
+		if err == nil {
+			m.logger.Info("Блокировка %s захвачена владельцем %s", key, owner)
+			return nil
+		}
+		if !errors.Is(err, storage.ErrLockHeld) {
+			m.logger.Error("Ошибка при захвате блокировки %s: %v", key, err)
+			return err
+		}
 
-	lastMigration, err := m.storage.SelectLastMigrationByStatus(ctx, storage.StatusSuccess)
-	if err == nil {
-		lastVersion = lastMigration.GetVersion()
-	} else if !errors.Is(err, storage.ErrMigrationNotFound) {
+		m.logger.Warn("Ожидание блокировки: %v", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
+
+// ReleasePersistentLock снимает постоянную блокировку key.
+func (m *Migrator) ReleasePersistentLock(ctx context.Context, key string) error {
+	if err := m.storage.ReleasePersistentLock(ctx, key); err != nil {
+		m.logger.Error("Ошибка при снятии блокировки %s: %v", key, err)
+		return err
+	}
+	m.logger.Info("Блокировка %s снята", key)
+	return nil
+}
+
+// StatusRecords возвращает записанные в хранилище миграции, отсортированные
+// по версии. В отличие от Status метод ничего не выводит.
+func (m *Migrator) StatusRecords(ctx context.Context) ([]storage.Migration, error) {
+	migrations, err := m.storage.SelectMigrations(ctx)
+	if err != nil && !errors.Is(err, storage.ErrMigrationNotFound) {
+		m.logger.Error("Ошибка при получении статуса: %v", err)
+		return nil, ErrGetStatus
+	}
+
+	records := make([]storage.Migration, 0, len(migrations))
+	for _, migr := range migrations {
+		if !m.matchesStatusLabel(migr) {
+			continue
+		}
+		records = append(records, storage.Migration{
+			Name:             migr.GetName(),
+			Version:          migr.GetVersion(),
+			Status:           migr.GetStatus(),
+			StatusChangeTime: migr.GetStatusChangeTime(),
+			Labels:           migr.GetLabels(),
+			SourceFile:       migr.GetSourceFile(),
+			Checksum:         migr.GetChecksum(),
+			Up:               migr.GetUpSQL(),
+			RowsAffected:     migr.GetRowsAffected(),
+			Author:           migr.GetAuthor(),
+		})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Version < records[j].Version })
+	return records, nil
+}
+
+// matchesStatusLabel сообщает, проходит ли запись фильтр Options.StatusLabel.
+func (m *Migrator) matchesStatusLabel(migr storage.IMigration) bool {
+	if m.options.StatusLabel == "" {
+		return true
+	}
+	for _, label := range migr.GetLabels() {
+		if label == m.options.StatusLabel {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentVersion возвращает последнюю успешно применённую версию или 0 для
+// пустой базы данных. Это лёгкий запрос для проверок готовности.
+func (m *Migrator) CurrentVersion(ctx context.Context) (int, error) {
+	version, err := m.storage.CurrentVersion(ctx)
+	if err != nil {
 		m.logger.Error("Ошибка при получении версии БД: %v", err)
-		return ErrGetVersion
+		return 0, ErrGetVersion
+	}
+	return version, nil
+}
+
+// Метод для получения текущей версии базы данных. Если миграции загружены
+// из директории и не запрошен Options.PlainVersion, выводится также последняя
+// доступная на диске версия и число загруженных миграций, не применённых
+// успешно: пропущенные и упавшие версии ниже текущей тоже считаются.
+func (m *Migrator) DBVersion(ctx context.Context) error {
+	lastVersion, err := m.CurrentVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if m.options.PlainVersion || len(m.migrations) == 0 {
+		m.logger.Info("Версия: %d", lastVersion)
+		return nil
 	}
 
-	m.logger.Info("Версия: %d", lastVersion)
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	latest := m.migrations[len(m.migrations)-1].Version
+	m.logger.Info("Применённая версия: %d, последняя на диске: %d, ожидают применения: %d",
+		lastVersion, latest, len(pending))
 	return nil
 }
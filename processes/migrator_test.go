@@ -0,0 +1,983 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaStorage эмулирует зависимости между таблицами: таблицу нельзя удалить,
+// пока существует таблица, которая на неё ссылается.
+type schemaStorage struct {
+	*storage.MockSQLStorage
+	tables     map[string]string
+	statements []string
+}
+
+func newSchemaStorage() *schemaStorage {
+	return &schemaStorage{
+		MockSQLStorage: storage.NewMockSQLStorage(),
+		tables:         make(map[string]string),
+	}
+}
+
+func (s *schemaStorage) Migrate(ctx context.Context, sql string) (int64, error) {
+	s.statements = append(s.statements, sql)
+
+	fields := strings.Fields(sql)
+	switch {
+	case len(fields) >= 3 && fields[0] == "CREATE":
+		parent := ""
+		if len(fields) >= 5 && fields[3] == "REFERENCES" {
+			parent = fields[4]
+		}
+		s.tables[fields[2]] = parent
+	case len(fields) >= 3 && fields[0] == "DROP":
+		for name, parent := range s.tables {
+			if parent == fields[2] {
+				return 0, errors.New("cannot drop " + fields[2] + ": " + name + " depends on it")
+			}
+		}
+		delete(s.tables, fields[2])
+	}
+
+	return s.MockSQLStorage.Migrate(ctx, sql)
+}
+
+func TestDownToRollsBackHighestVersionFirst(t *testing.T) {
+	ctx := context.Background()
+	st := newSchemaStorage()
+	migrator := New(st, logger.New())
+
+	migrator.Create("create_users", "CREATE TABLE users", "DROP TABLE users", nil, nil)
+	migrator.Create("create_orders", "CREATE TABLE orders REFERENCES users", "DROP TABLE orders", nil, nil)
+	migrator.Create("create_items", "CREATE TABLE items REFERENCES orders", "DROP TABLE items", nil, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	require.Len(t, st.tables, 3)
+
+	// Мок возвращает строки в порядке вставки, то есть по возрастанию версий:
+	// без явной сортировки первой была бы удалена таблица users.
+	require.NoError(t, migrator.DownTo(ctx, 0))
+
+	assert.Empty(t, st.tables)
+	assert.Equal(t, []string{"DROP TABLE items", "DROP TABLE orders", "DROP TABLE users"}, st.statements[3:])
+
+	migrations, err := st.SelectMigrations(ctx)
+	require.NoError(t, err)
+	for _, migr := range migrations {
+		assert.Equal(t, storage.StatusCancel, migr.GetStatus())
+	}
+}
+
+func TestDownToKeepsTargetVersion(t *testing.T) {
+	ctx := context.Background()
+	st := newSchemaStorage()
+	migrator := New(st, logger.New())
+
+	migrator.Create("create_users", "CREATE TABLE users", "DROP TABLE users", nil, nil)
+	migrator.Create("create_orders", "CREATE TABLE orders REFERENCES users", "DROP TABLE orders", nil, nil)
+	migrator.Create("create_items", "CREATE TABLE items REFERENCES orders", "DROP TABLE items", nil, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	require.NoError(t, migrator.DownTo(ctx, 1))
+
+	assert.Equal(t, map[string]string{"users": ""}, st.tables)
+}
+
+func TestAppliedVersionsAboveSortsDescending(t *testing.T) {
+	now := time.Now()
+	migrations := []storage.IMigration{
+		storage.CreateMigration("b", storage.StatusSuccess, 2, now),
+		storage.CreateMigration("d", storage.StatusSuccess, 4, now),
+		storage.CreateMigration("a", storage.StatusSuccess, 1, now),
+		storage.CreateMigration("c", storage.StatusCancel, 3, now),
+		storage.CreateMigration("e", storage.StatusSuccess, 5, now),
+	}
+
+	assert.Equal(t, []int{5, 4, 2}, appliedVersionsAbove(migrations, 1))
+}
+
+func TestConnectSwitchesRole(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{RunAs: "ddl_admin"})
+
+	require.NoError(t, migrator.Connect(ctx))
+	assert.Equal(t, []string{"SET ROLE ddl_admin"}, st.RoleStatements())
+
+	require.NoError(t, migrator.Close(ctx))
+	assert.Equal(t, []string{"SET ROLE ddl_admin", "RESET ROLE"}, st.RoleStatements())
+}
+
+// roleOrderStorage записывает подключение и смену роли в общий журнал,
+// чтобы проверить их порядок.
+type roleOrderStorage struct {
+	*storage.MockSQLStorage
+	calls []string
+}
+
+func (s *roleOrderStorage) Connect(ctx context.Context) error {
+	s.calls = append(s.calls, "CONNECT")
+	return s.MockSQLStorage.Connect(ctx)
+}
+
+func (s *roleOrderStorage) SetRole(ctx context.Context, role string) error {
+	s.calls = append(s.calls, "SET ROLE "+role)
+	return s.MockSQLStorage.SetRole(ctx, role)
+}
+
+func TestConnectSetsRoleBeforeTrackingTableIsCreated(t *testing.T) {
+	st := &roleOrderStorage{MockSQLStorage: storage.NewMockSQLStorage()}
+	migrator := New(st, logger.New()).WithOptions(Options{RunAs: "Deployer"})
+
+	require.NoError(t, migrator.Connect(context.Background()))
+	assert.Equal(t, []string{"SET ROLE Deployer", "CONNECT"}, st.calls,
+		"The role is known to the storage before Connect creates the tracking table")
+	assert.Equal(t, []string{"SET ROLE deployer"}, st.RoleStatements(), "Role names fold to lower case")
+}
+
+func TestCloseTwiceIsNoOp(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{RunAs: "ddl_admin"})
+
+	require.NoError(t, migrator.Connect(ctx))
+	require.NoError(t, migrator.Close(ctx))
+	require.NoError(t, migrator.Close(ctx))
+	assert.Equal(t, 1, st.CloseCalls())
+	assert.Equal(t, []string{"SET ROLE ddl_admin", "RESET ROLE"}, st.RoleStatements())
+
+	// После нового подключения Close снова закрывает хранилище.
+	require.NoError(t, migrator.Connect(ctx))
+	require.NoError(t, migrator.Close(ctx))
+	assert.Equal(t, 2, st.CloseCalls())
+}
+
+func TestConnectWithoutRoleDoesNotSwitch(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+
+	require.NoError(t, migrator.Connect(ctx))
+	require.NoError(t, migrator.Close(ctx))
+	assert.Empty(t, st.RoleStatements())
+}
+
+func TestConnectSetsStatementTimeout(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{StatementTimeout: 30 * time.Second})
+
+	require.NoError(t, migrator.Connect(ctx))
+	assert.Equal(t, []string{"SET statement_timeout = 30000;"}, st.SessionStatements())
+
+	require.NoError(t, migrator.Close(ctx))
+	assert.Equal(t, []string{"SET statement_timeout = 30000;", "RESET statement_timeout;"}, st.SessionStatements())
+}
+
+func TestConnectWithoutStatementTimeoutKeepsDefault(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+
+	require.NoError(t, migrator.Connect(ctx))
+	require.NoError(t, migrator.Close(ctx))
+	assert.Empty(t, st.SessionStatements())
+}
+
+// sessionOrderStorage записывает настройку сессии и взятие блокировки
+// в общий журнал, чтобы проверить их порядок.
+type sessionOrderStorage struct {
+	*storage.MockSQLStorage
+	calls []string
+}
+
+func (s *sessionOrderStorage) ExecSessionStatement(ctx context.Context, statement string) error {
+	s.calls = append(s.calls, statement)
+	return s.MockSQLStorage.ExecSessionStatement(ctx, statement)
+}
+
+func (s *sessionOrderStorage) Lock(ctx context.Context) error {
+	s.calls = append(s.calls, "LOCK")
+	return s.MockSQLStorage.Lock(ctx)
+}
+
+func TestPreLockStatementsRunInOrderBeforeLock(t *testing.T) {
+	ctx := context.Background()
+	st := &sessionOrderStorage{MockSQLStorage: storage.NewMockSQLStorage()}
+	migrator := New(st, logger.New()).WithOptions(Options{PreLockStatements: []string{
+		"SET lock_timeout = '5s'",
+		"SET idle_in_transaction_session_timeout = 60000",
+	}})
+	migrator.Create("create_users", "CREATE TABLE users (id serial);", "", nil, nil)
+
+	require.NoError(t, migrator.Connect(ctx))
+	require.NoError(t, migrator.Up(ctx))
+	require.NoError(t, migrator.Close(ctx))
+
+	assert.Equal(t, []string{
+		"SET lock_timeout = '5s'",
+		"SET idle_in_transaction_session_timeout = 60000",
+		"LOCK",
+	}, st.calls)
+}
+
+func TestConnectRejectsInvalidPreLockStatement(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{PreLockStatements: []string{"DROP TABLE users"}})
+
+	err := migrator.Connect(context.Background())
+	assert.ErrorIs(t, err, storage.ErrInvalidSessionStatement)
+	assert.Empty(t, st.SessionStatements())
+}
+
+func TestConnectRejectsInvalidRole(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{RunAs: `admin"; DROP TABLE users; --`})
+
+	err := migrator.Connect(context.Background())
+	assert.ErrorIs(t, err, storage.ErrInvalidRole)
+	assert.Empty(t, st.RoleStatements())
+}
+
+func TestConnectChecksPermissions(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	st.SetMissingPrivileges("CREATE on schema public", "INSERT on table schema_migrations")
+	migrator := New(st, logger.New()).WithOptions(Options{
+		RunAs:      "deployer",
+		CheckPerms: true,
+	})
+
+	err := migrator.Connect(ctx)
+	assert.ErrorIs(t, err, ErrInsufficientPrivileges)
+	assert.Contains(t, err.Error(), "CREATE on schema public, INSERT on table schema_migrations")
+	assert.Equal(t, []string{"SET ROLE deployer", "RESET ROLE"}, st.RoleStatements())
+}
+
+func TestConnectPassesPermissionCheck(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{CheckPerms: true})
+
+	require.NoError(t, migrator.Connect(ctx))
+	require.NoError(t, migrator.Close(ctx))
+}
+
+func TestConnectSkipsPermissionCheckByDefault(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	st.SetMissingPrivileges("CREATE on schema public")
+	migrator := New(st, logger.New())
+
+	require.NoError(t, migrator.Connect(context.Background()))
+}
+
+func newThreeTableMigrator(st storage.SQLStorage, opts Options) *Migrator {
+	migrator := New(st, logger.New()).WithOptions(opts)
+	migrator.Create("create_users", "CREATE TABLE users", "DROP TABLE users", nil, nil)
+	migrator.Create("create_orders", "CREATE TABLE orders", "DROP TABLE orders", nil, nil)
+	migrator.Create("create_items", "CREATE TABLE items", "DROP TABLE items", nil, nil)
+	return migrator
+}
+
+func statusByVersion(t *testing.T, st storage.SQLStorage) map[int]string {
+	t.Helper()
+
+	migrations, err := st.SelectMigrations(context.Background())
+	require.NoError(t, err)
+
+	statuses := make(map[int]string)
+	for _, migr := range migrations {
+		statuses[migr.GetVersion()] = migr.GetStatus()
+	}
+	return statuses
+}
+
+func TestUpSkipsRequestedVersions(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newThreeTableMigrator(st, Options{SkipVersions: []int{2}})
+
+	require.NoError(t, migrator.Up(ctx))
+
+	assert.Equal(t, []string{"CREATE TABLE users", "CREATE TABLE items"}, st.ExecutedSQL())
+	assert.Equal(t, map[int]string{
+		1: storage.StatusSuccess,
+		2: storage.StatusSkipped,
+		3: storage.StatusSuccess,
+	}, statusByVersion(t, st))
+}
+
+func TestUpDoesNotRetrySkippedVersions(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+
+	require.NoError(t, newThreeTableMigrator(st, Options{SkipVersions: []int{3}}).Up(ctx))
+	require.NoError(t, newThreeTableMigrator(st, Options{}).Up(ctx))
+
+	assert.Equal(t, []string{"CREATE TABLE users", "CREATE TABLE orders"}, st.ExecutedSQL())
+	assert.Equal(t, storage.StatusSkipped, statusByVersion(t, st)[3])
+}
+
+func TestUpAppliesSkippedVersionsOnRequest(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+
+	require.NoError(t, newThreeTableMigrator(st, Options{SkipVersions: []int{2}}).Up(ctx))
+	require.NoError(t, newThreeTableMigrator(st, Options{ApplySkipped: true}).Up(ctx))
+
+	assert.Equal(t, []string{"CREATE TABLE users", "CREATE TABLE items", "CREATE TABLE orders"}, st.ExecutedSQL())
+	assert.Equal(t, storage.StatusSuccess, statusByVersion(t, st)[2])
+}
+
+func TestParseVersionList(t *testing.T) {
+	versions, err := ParseVersionList("5, 7,,9")
+	require.NoError(t, err)
+	assert.Equal(t, []int{5, 7, 9}, versions)
+
+	versions, err = ParseVersionList("")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+
+	_, err = ParseVersionList("5,x")
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+
+	_, err = ParseVersionList("-1")
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+}
+
+func versionsOf(migrations []storage.Migration) []int {
+	versions := make([]int, 0, len(migrations))
+	for _, migration := range migrations {
+		versions = append(versions, migration.Version)
+	}
+	return versions
+}
+
+func TestPendingAndAppliedPartition(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+
+	require.NoError(t, newThreeTableMigrator(st, Options{SkipVersions: []int{3}}).Up(ctx))
+	require.NoError(t, newThreeTableMigrator(st, Options{}).DownTo(ctx, 1))
+
+	migrator := newThreeTableMigrator(st, Options{})
+	migrator.Create("create_payments", "CREATE TABLE payments", "DROP TABLE payments", nil, nil)
+
+	pending, err := migrator.Pending(ctx)
+	require.NoError(t, err)
+	applied, err := migrator.Applied(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{2, 3, 4}, versionsOf(pending))
+	assert.Equal(t, []int{1}, versionsOf(applied))
+
+	assert.Equal(t, storage.StatusCancel, pending[0].Status)
+	assert.Equal(t, storage.StatusSkipped, pending[1].Status)
+	assert.Equal(t, "", pending[2].Status)
+	assert.Equal(t, storage.StatusSuccess, applied[0].Status)
+
+	// Методы только читают состояние и не берут блокировку.
+	assert.Equal(t, 2, st.LockCalls())
+}
+
+func TestPendingOnEmptyDatabase(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	migrator := newThreeTableMigrator(st, Options{})
+
+	pending, err := migrator.Pending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, versionsOf(pending))
+
+	applied, err := migrator.Applied(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+}
+
+func TestUpRunsAnalyzeForDeclaredTables(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{PostUpAnalyze: true})
+	migrator.Create("load_users", "-- migrator:analyze users\nINSERT INTO users SELECT 1", "", nil, nil)
+	migrator.Create("load_orders", "-- migrator:analyze orders users\nINSERT INTO orders SELECT 1", "", nil, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, []string{`ANALYZE "users", "orders";`}, st.AnalyzeStatements())
+}
+
+func TestUpPostAnalyzeWholeDatabase(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+
+	require.NoError(t, newThreeTableMigrator(st, Options{PostUpAnalyze: true, PostUpVacuum: true}).Up(ctx))
+	assert.Equal(t, []string{"VACUUM ANALYZE;"}, st.AnalyzeStatements())
+
+	// Повторный Up ничего не применяет, поэтому и статистику не обновляет.
+	require.NoError(t, newThreeTableMigrator(st, Options{PostUpAnalyze: true}).Up(ctx))
+	assert.Len(t, st.AnalyzeStatements(), 1)
+}
+
+func TestUpWithoutAnalyzeOption(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+
+	require.NoError(t, newThreeTableMigrator(st, Options{}).Up(context.Background()))
+	assert.Empty(t, st.AnalyzeStatements())
+
+	// Директива analyze без -post-up-analyze статистику тоже не обновляет.
+	st = storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	migrator.Create("load_users", "-- migrator:analyze users\nINSERT INTO users SELECT 1", "", nil, nil)
+	require.NoError(t, migrator.Up(context.Background()))
+	assert.Empty(t, st.AnalyzeStatements())
+}
+
+func newGatedMigrator(st storage.SQLStorage, opts Options) *Migrator {
+	migrator := New(st, logger.New()).WithOptions(opts)
+	migrator.Create("create_users", "CREATE TABLE users", "DROP TABLE users", nil, nil)
+	migrator.Create("create_invoices", "-- migrate:gate NEW_BILLING\nCREATE TABLE invoices", "DROP TABLE invoices", nil, nil)
+	migrator.Create("create_items", "CREATE TABLE items", "DROP TABLE items", nil, nil)
+	return migrator
+}
+
+func TestUpSkipsGatedMigration(t *testing.T) {
+	t.Setenv("MIGRATOR_GATE_NEW_BILLING", "")
+	st := storage.NewMockSQLStorage()
+
+	require.NoError(t, newGatedMigrator(st, Options{}).Up(context.Background()))
+
+	assert.Equal(t, []string{"CREATE TABLE users", "CREATE TABLE items"}, st.ExecutedSQL())
+	assert.Equal(t, map[int]string{
+		1: storage.StatusSuccess,
+		2: storage.StatusGated,
+		3: storage.StatusSuccess,
+	}, statusByVersion(t, st))
+}
+
+func TestUpAppliesGatedMigrationOnceEnabled(t *testing.T) {
+	t.Setenv("MIGRATOR_GATE_NEW_BILLING", "false")
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+
+	require.NoError(t, newGatedMigrator(st, Options{}).Up(ctx))
+
+	t.Setenv("MIGRATOR_GATE_NEW_BILLING", "true")
+	require.NoError(t, newGatedMigrator(st, Options{}).Up(ctx))
+
+	assert.Equal(t, []string{"CREATE TABLE users", "CREATE TABLE items",
+		"-- migrate:gate NEW_BILLING\nCREATE TABLE invoices"}, st.ExecutedSQL())
+	assert.Equal(t, storage.StatusSuccess, statusByVersion(t, st)[2])
+}
+
+func TestGateFlagOverridesEnvironment(t *testing.T) {
+	t.Setenv("MIGRATOR_GATE_NEW_BILLING", "true")
+	st := storage.NewMockSQLStorage()
+
+	opts := Options{Gates: map[string]bool{"NEW_BILLING": false}}
+	require.NoError(t, newGatedMigrator(st, opts).Up(context.Background()))
+	assert.Equal(t, storage.StatusGated, statusByVersion(t, st)[2])
+
+	t.Setenv("MIGRATOR_GATE_NEW_BILLING", "")
+	opts = Options{Gates: map[string]bool{"NEW_BILLING": true}}
+	require.NoError(t, newGatedMigrator(st, opts).Up(context.Background()))
+	assert.Equal(t, storage.StatusSuccess, statusByVersion(t, st)[2])
+}
+
+func TestLastAppliedVersion(t *testing.T) {
+	assert.Equal(t, 0, lastAppliedVersion(map[int]string{}))
+	assert.Equal(t, 3, lastAppliedVersion(map[int]string{
+		1: storage.StatusSuccess,
+		3: storage.StatusSuccess,
+		4: storage.StatusCancel,
+		5: storage.StatusSkipped,
+	}))
+}
+
+func TestStatusWideShowsAuthor(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	out := &lineRecorder{ZeroLogger: logger.New()}
+	migrator := New(st, out).WithOptions(Options{StatusWide: true})
+	migrator.Create("create_users", "-- migrate:author Jane Doe <jane@example.com>\nCREATE TABLE users (id serial);", "", nil, nil)
+	migrator.Create("create_orders", "CREATE TABLE orders (id serial);", "", nil, nil)
+	require.NoError(t, migrator.Up(ctx))
+
+	records, err := migrator.StatusRecords(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Doe <jane@example.com>", records[0].Author)
+	assert.Empty(t, records[1].Author)
+
+	out.lines = nil
+	require.NoError(t, migrator.Status(ctx))
+	assert.Contains(t, out.lines[1], "Автор")
+	assert.Contains(t, out.lines[2], "| create_users ")
+	assert.Contains(t, out.lines[2], "| Jane Doe <jane@example.com> ")
+}
+
+func TestStatusRecordsFilterByLabel(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+
+	migrator := New(st, logger.New())
+	migrator.Create("create_users", "CREATE TABLE users", "DROP TABLE users", nil, nil)
+	migrator.Create("create_invoices", "-- migrate:labels billing,q3\nCREATE TABLE invoices", "DROP TABLE invoices", nil, nil)
+	migrator.Create("create_payments", "-- migrate:labels billing\nCREATE TABLE payments", "DROP TABLE payments", nil, nil)
+	require.NoError(t, migrator.Up(ctx))
+
+	records, err := New(st, logger.New()).WithOptions(Options{StatusLabel: "billing"}).StatusRecords(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 3}, versionsOf(records))
+	assert.Equal(t, []string{"billing", "q3"}, records[0].Labels)
+
+	records, err = New(st, logger.New()).WithOptions(Options{StatusLabel: "q3"}).StatusRecords(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, versionsOf(records))
+
+	records, err = New(st, logger.New()).StatusRecords(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 3)
+}
+
+func TestParseSimulateFailureRequiresGuard(t *testing.T) {
+	t.Setenv("MIGRATOR_ALLOW_SIMULATE", "")
+	_, err := ParseSimulateFailure("version=2")
+	assert.ErrorIs(t, err, ErrSimulateNotAllowed)
+
+	t.Setenv("MIGRATOR_ALLOW_SIMULATE", "1")
+	version, err := ParseSimulateFailure("version=2")
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+
+	_, err = ParseSimulateFailure("2")
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+}
+
+func TestSimulatedFailureLeavesDirtyState(t *testing.T) {
+	t.Setenv("MIGRATOR_ALLOW_SIMULATE", "1")
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+
+	err := newThreeTableMigrator(st, Options{SimulateFailureVersion: 2}).Up(ctx)
+	require.ErrorIs(t, err, ErrMigrationUp)
+
+	assert.Equal(t, []string{"CREATE TABLE users"}, st.ExecutedSQL())
+	assert.Equal(t, map[int]string{
+		1: storage.StatusSuccess,
+		2: storage.StatusProcess,
+	}, statusByVersion(t, st))
+
+	pending, err := newThreeTableMigrator(st, Options{}).Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, storage.StatusProcess, pending[0].Status)
+
+	// По умолчанию следующий запуск отказывается работать с «грязной» версией,
+	// а с -on-dirty clean удаляет её запись и продолжает с неё.
+	assert.ErrorIs(t, newThreeTableMigrator(st, Options{}).Up(ctx), ErrDirty)
+	require.NoError(t, newThreeTableMigrator(st, Options{OnDirty: OnDirtyClean}).Up(ctx))
+	assert.Equal(t, []string{"CREATE TABLE users", "CREATE TABLE orders", "CREATE TABLE items"}, st.ExecutedSQL())
+}
+
+func TestSimulatedFailureIgnoredWithoutGuard(t *testing.T) {
+	t.Setenv("MIGRATOR_ALLOW_SIMULATE", "")
+	st := storage.NewMockSQLStorage()
+
+	require.NoError(t, newThreeTableMigrator(st, Options{SimulateFailureVersion: 2}).Up(context.Background()))
+	assert.Len(t, st.ExecutedSQL(), 3)
+}
+
+func TestLockScopeMigrationUsesTransactionLock(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{LockScope: LockScopeMigration})
+	migrator.Create("create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;", nil, nil)
+	migrator.Create("create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;", nil, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, 0, st.LockCalls(), "No session lock is taken around the run")
+	assert.Equal(t, []string{"CREATE TABLE users (id serial);", "CREATE TABLE orders (id serial);"}, st.XactLockedSQL())
+
+	require.NoError(t, migrator.Down(ctx))
+	assert.Equal(t, 0, st.LockCalls())
+	assert.Equal(t, "DROP TABLE orders;", st.XactLockedSQL()[2])
+}
+
+func TestLockScopeMigrationWritesStatusUnderLock(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{LockScope: LockScopeMigration})
+	migrator.Create("create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;", nil, nil)
+
+	require.NoError(t, migrator.Up(context.Background()))
+	assert.Equal(t, []string{"BEGIN", "XACT LOCK", "SAVEPOINT migration_sql", "COMMIT"}, st.TxLog())
+	assert.Equal(t, []string{"1 process", "1 success"}, st.XactLockedStatuses(),
+		"Both tracking rows are written in the locked transaction")
+}
+
+func TestLockScopeMigrationRechecksStatusAfterLock(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	migrator := newThreeTableMigrator(st, Options{
+		LockScope: LockScopeMigration,
+		// Пока мигратор ждёт блокировку, версию 2 применяет другой процесс.
+		BeforeMigration: func(migration storage.Migration, _ string) (StepDecision, error) {
+			if migration.Version == 2 {
+				require.NoError(t, st.InsertMigration(context.Background(),
+					storage.CreateMigration(migration.Name, storage.StatusSuccess, 2, time.Now())))
+			}
+			return StepApply, nil
+		},
+	})
+
+	require.NoError(t, migrator.Up(context.Background()))
+	assert.Equal(t, []string{"CREATE TABLE users", "CREATE TABLE items"}, st.ExecutedSQL())
+	assert.Len(t, migrator.Result().Applied, 2)
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess, 2: storage.StatusSuccess, 3: storage.StatusSuccess},
+		statusByVersion(t, st))
+}
+
+func TestLockScopeRunUsesSessionLock(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	migrator.Create("create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;", nil, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, 1, st.LockCalls())
+	assert.Equal(t, 1, st.UnlockCalls())
+	assert.Empty(t, st.XactLockedSQL())
+}
+
+func TestLockScopeMigrationLocksGoStep(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{LockScope: LockScopeMigration})
+
+	var lockedDuringStep int
+	migrator.Create("seed_users", "", "", func(ctx context.Context) error {
+		lockedDuringStep = st.LockCalls() - st.UnlockCalls()
+		return nil
+	}, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, 1, lockedDuringStep, "Session lock is held around the Go step transaction")
+	assert.Equal(t, 1, st.UnlockCalls())
+}
+
+func TestParseLockScope(t *testing.T) {
+	scope, err := ParseLockScope("")
+	require.NoError(t, err)
+	assert.Equal(t, LockScopeRun, scope)
+
+	scope, err = ParseLockScope("migration")
+	require.NoError(t, err)
+	assert.Equal(t, LockScopeMigration, scope)
+
+	_, err = ParseLockScope("table")
+	assert.ErrorIs(t, err, ErrInvalidLockScope)
+}
+
+func TestCombinedStepsRollBackSQLWhenGoFails(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	errGoStep := errors.New("go step failed")
+	migrator.Create("seed_users", "CREATE TABLE users (id serial);", "", func(ctx context.Context) error {
+		return errGoStep
+	}, nil)
+
+	assert.ErrorIs(t, migrator.Up(ctx), ErrMigrationUp)
+	assert.Empty(t, st.ExecutedSQL(), "SQL step must be undone when the Go step fails")
+	assert.Equal(t, []string{
+		"BEGIN",
+		"SAVEPOINT migration_sql",
+		"ROLLBACK TO SAVEPOINT migration_sql",
+		"COMMIT",
+	}, st.TxLog())
+
+	status, err := migrator.VersionStatus(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.StatusError, status, "Error status is committed in the same transaction")
+}
+
+func TestCombinedStepsCommitBothSteps(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	goRan := false
+	migrator.Create("seed_users", "CREATE TABLE users (id serial);", "", func(ctx context.Context) error {
+		goRan = true
+		return nil
+	}, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.True(t, goRan)
+	assert.Equal(t, []string{"CREATE TABLE users (id serial);"}, st.ExecutedSQL())
+	assert.Equal(t, []string{"BEGIN", "SAVEPOINT migration_sql", "COMMIT"}, st.TxLog())
+
+	status, err := migrator.VersionStatus(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.StatusSuccess, status)
+}
+
+func TestCombinedStepsGoStepUsesMigrationTx(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	errGoStep := errors.New("go step failed")
+	migrator.Create("seed_users", "CREATE TABLE users (id serial);", "", func(ctx context.Context) error {
+		executor, ok := storage.ExecutorFromContext(ctx)
+		require.True(t, ok, "Go step gets the executor of the migration transaction")
+		_, err := executor.Exec(ctx, "INSERT INTO users VALUES (1);")
+		require.NoError(t, err)
+		return errGoStep
+	}, nil)
+
+	assert.ErrorIs(t, migrator.Up(ctx), ErrMigrationUp)
+	assert.Empty(t, st.ExecutedSQL(), "Go step changes are undone together with the SQL step")
+}
+
+func TestCombinedStepsRunGoProcessAfterCommit(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	var txDuringStep []string
+	migrator.Add(storage.Migration{
+		Name: "seed_users",
+		Up:   "CREATE TABLE users (id serial);",
+		UpGo: func(ctx context.Context) error {
+			txDuringStep = append(txDuringStep, st.TxLog()...)
+			return nil
+		},
+		GoProcess: true,
+	})
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, []string{"BEGIN", "SAVEPOINT migration_sql", "COMMIT", "BEGIN"}, txDuringStep,
+		"Go process starts after the SQL step is committed")
+	assert.Equal(t, []string{"CREATE TABLE users (id serial);"}, st.ExecutedSQL())
+
+	status, err := migrator.VersionStatus(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.StatusSuccess, status)
+}
+
+func TestCombinedStepsGoProcessFailureKeepsCommittedSQL(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	errGoStep := errors.New("go step failed")
+	migrator.Add(storage.Migration{
+		Name:      "seed_users",
+		Up:        "CREATE TABLE users (id serial);",
+		UpGo:      func(ctx context.Context) error { return errGoStep },
+		GoProcess: true,
+	})
+
+	assert.ErrorIs(t, migrator.Up(ctx), ErrMigrationUp)
+	assert.Equal(t, []string{"CREATE TABLE users (id serial);"}, st.ExecutedSQL(),
+		"SQL step is already committed when the Go process fails")
+
+	status, err := migrator.VersionStatus(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.StatusError, status)
+}
+
+func TestGoMigrationRollsBackWithStatusOnError(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	errGoStep := errors.New("go step failed")
+	migrator.Create("seed_users", "", "", func(ctx context.Context) error {
+		_, err := st.Migrate(ctx, "INSERT INTO users VALUES (1);")
+		require.NoError(t, err)
+		return errGoStep
+	}, nil)
+
+	err := migrator.Up(ctx)
+	assert.ErrorIs(t, err, errGoStep)
+	assert.Empty(t, st.ExecutedSQL(), "Go step changes must be rolled back")
+	assert.Equal(t, []string{"BEGIN", "ROLLBACK"}, st.TxLog())
+
+	status, err := migrator.VersionStatus(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.StatusError, status)
+}
+
+func TestGoMigrationCommitsWithStatus(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	var txDuringStep []string
+	migrator.Create("seed_users", "", "", func(ctx context.Context) error {
+		txDuringStep = append(txDuringStep, st.TxLog()...)
+		_, err := st.Migrate(ctx, "INSERT INTO users VALUES (1);")
+		return err
+	}, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, []string{"BEGIN"}, txDuringStep, "Go step runs inside the transaction")
+	assert.Equal(t, []string{"INSERT INTO users VALUES (1);"}, st.ExecutedSQL())
+	assert.Equal(t, []string{"BEGIN", "COMMIT"}, st.TxLog())
+
+	status, err := migrator.VersionStatus(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.StatusSuccess, status)
+}
+
+func TestDownToBeforeMigrationSkipKeepsApplied(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	migrator.Create("create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;", nil, nil)
+	migrator.Create("create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;", nil, nil)
+	require.NoError(t, migrator.Up(ctx))
+
+	var asked []string
+	migrator.WithOptions(Options{BeforeMigration: func(migration storage.Migration, direction string) (StepDecision, error) {
+		asked = append(asked, direction+" "+migration.Name)
+		if migration.Version == 2 {
+			return StepSkip, nil
+		}
+		return StepApply, nil
+	}})
+	require.NoError(t, migrator.DownTo(ctx, 0))
+
+	assert.Equal(t, []string{"down create_orders", "down create_users"}, asked)
+	assert.Equal(t, map[int]string{1: storage.StatusCancel, 2: storage.StatusSuccess}, statusByVersion(t, st))
+}
+
+func TestNukeRollsBackAllAndDropsTable(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newThreeTableMigrator(st, Options{})
+	require.NoError(t, migrator.Up(ctx))
+
+	require.NoError(t, migrator.Nuke(ctx))
+	assert.Equal(t, []string{
+		"CREATE TABLE users", "CREATE TABLE orders", "CREATE TABLE items",
+		"DROP TABLE items", "DROP TABLE orders", "DROP TABLE users",
+	}, st.ExecutedSQL())
+	assert.True(t, st.TrackingTableDropped())
+	assert.Equal(t, 2, st.LockCalls())
+
+	_, err := st.SelectMigrations(ctx)
+	assert.ErrorIs(t, err, storage.ErrMigrationNotFound)
+}
+
+func TestNukeKeepsTableWhenMigrationStaysApplied(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newThreeTableMigrator(st, Options{
+		BeforeMigration: func(migration storage.Migration, direction string) (StepDecision, error) {
+			if direction == DirectionDown && migration.Version == 2 {
+				return StepSkip, nil
+			}
+			return StepApply, nil
+		},
+	})
+	require.NoError(t, migrator.Up(ctx))
+
+	assert.ErrorIs(t, migrator.Nuke(ctx), ErrNukeIncomplete)
+	assert.False(t, st.TrackingTableDropped())
+	assert.Equal(t, storage.StatusSuccess, statusByVersion(t, st)[2])
+}
+
+func TestUpAppliesOnlySelectedVersions(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	require.NoError(t, newThreeTableMigrator(st, Options{OnlyVersions: map[int]bool{2: true}}).Up(ctx))
+	assert.Equal(t, []string{"CREATE TABLE orders"}, st.ExecutedSQL())
+	assert.Equal(t, map[int]string{2: storage.StatusSuccess}, statusByVersion(t, st))
+
+	st = storage.NewMockSQLStorage()
+	require.NoError(t, newThreeTableMigrator(st, Options{OnlyVersions: map[int]bool{}}).Up(ctx))
+	assert.Empty(t, st.ExecutedSQL())
+}
+
+func TestUpAfterSelectiveRunAppliesLowerVersions(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	require.NoError(t, newThreeTableMigrator(st, Options{OnlyVersions: map[int]bool{3: true}}).Up(ctx))
+
+	plan, err := newThreeTableMigrator(st, Options{}).Plan(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []PlanStep{
+		{Version: 1, Name: "create_users"},
+		{Version: 2, Name: "create_orders"},
+	}, plan.Migrations)
+
+	require.NoError(t, newThreeTableMigrator(st, Options{}).Up(ctx))
+	assert.Equal(t, []string{"CREATE TABLE items", "CREATE TABLE users", "CREATE TABLE orders"}, st.ExecutedSQL())
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess, 2: storage.StatusSuccess, 3: storage.StatusSuccess},
+		statusByVersion(t, st))
+}
+
+// cancellingStorage отменяет контекст во время выполнения SQL миграции,
+// как это сделал бы сигнал или тайм-аут, и, как pgx, не выполняет запросы
+// с отменённым контекстом.
+type cancellingStorage struct {
+	*storage.MockSQLStorage
+	cancel context.CancelFunc
+}
+
+func (s *cancellingStorage) Migrate(ctx context.Context, sql string) (int64, error) {
+	s.cancel()
+	return 0, ctx.Err()
+}
+
+func (s *cancellingStorage) InsertMigration(ctx context.Context, migration storage.IMigration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.MockSQLStorage.InsertMigration(ctx, migration)
+}
+
+// errorRecorder запоминает сообщения об ошибках.
+type errorRecorder struct {
+	*logger.ZeroLogger
+	errors []string
+}
+
+func (l *errorRecorder) Error(msg string, v ...interface{}) {
+	l.errors = append(l.errors, fmt.Sprintf(msg, v...))
+}
+
+func TestCancelledMigrationRecordedAsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	st := &cancellingStorage{MockSQLStorage: storage.NewMockSQLStorage(), cancel: cancel}
+	log := &errorRecorder{ZeroLogger: logger.New()}
+	migrator := New(st, log)
+	migrator.Create("create_index", "CREATE INDEX CONCURRENTLY users_email ON users (email)", "", nil, nil)
+
+	err := migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrMigrationCancelled)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, storage.StatusCancelled, statusByVersion(t, st.MockSQLStorage)[1])
+	assert.Contains(t, log.errors, "Миграция 1 (create_index) прервана: context canceled. "+
+		"Изменения могли примениться частично, проверьте базу данных перед повторным запуском")
+}
+
+func TestCancelledMigrationIsDirty(t *testing.T) {
+	assert.Equal(t, []int{2}, dirtyVersions(map[int]string{1: storage.StatusSuccess, 2: storage.StatusCancelled}))
+}
+
+func TestRollbackPreviewListsRollbackOrder(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newThreeTableMigrator(st, Options{})
+	require.NoError(t, migrator.Up(ctx))
+
+	preview, err := migrator.RollbackPreview(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, preview, 2)
+	assert.Equal(t, 3, preview[0].Version)
+	assert.Equal(t, 2, preview[1].Version)
+	assert.Len(t, st.ExecutedSQL(), 3, "preview does not roll anything back")
+}
@@ -0,0 +1,30 @@
+package processes
+
+import (
+	"context"
+)
+
+// DefaultParallelStatements — сколько команд миграции с директивой parallel
+// выполняется одновременно, если Options.MaxParallelStatements не задан.
+const DefaultParallelStatements = 4
+
+// isParallel сообщает, помечена ли миграция директивой "-- migrate:parallel".
+func isParallel(sql string) bool {
+	return len(directiveArgs(sql, "parallel")) > 0
+}
+
+// migrateParallel выполняет независимые команды миграции, например создание
+// индексов, одновременно на отдельных соединениях вне транзакции. Команды
+// делятся по разделителю миграции; после первой ошибки новые команды не
+// запускаются, а уже выполненные не откатываются. Число изменённых строк
+// не подсчитывается: такие команды — DDL.
+func (m *Migrator) migrateParallel(ctx context.Context, sql string) (int64, error) {
+	workers := m.options.MaxParallelStatements
+	if workers <= 0 {
+		workers = DefaultParallelStatements
+	}
+
+	statements := splitStatements(sql, m.delimiter(sql))
+	m.logger.Info("Параллельное выполнение команд: %d, одновременно не более %d", len(statements), workers)
+	return 0, m.storage.MigrateParallel(ctx, statements, workers)
+}
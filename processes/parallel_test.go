@@ -0,0 +1,62 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const parallelIndexesSQL = `-- migrate:parallel
+CREATE INDEX CONCURRENTLY users_email_idx ON users (email);
+CREATE INDEX CONCURRENTLY users_created_idx ON users (created_at);
+CREATE INDEX CONCURRENTLY orders_user_idx ON orders (user_id);
+CREATE INDEX CONCURRENTLY orders_created_idx ON orders (created_at);`
+
+var parallelIndexes = []string{
+	"-- migrate:parallel\nCREATE INDEX CONCURRENTLY users_email_idx ON users (email)",
+	"CREATE INDEX CONCURRENTLY users_created_idx ON users (created_at)",
+	"CREATE INDEX CONCURRENTLY orders_user_idx ON orders (user_id)",
+	"CREATE INDEX CONCURRENTLY orders_created_idx ON orders (created_at)",
+}
+
+func TestUpRunsParallelStatements(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{MaxParallelStatements: 2})
+	migrator.Create("add_indexes", parallelIndexesSQL, "", nil, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.ElementsMatch(t, parallelIndexes, st.ExecutedSQL())
+	assert.Equal(t, 2, st.MaxParallel())
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess}, statusByVersion(t, st))
+}
+
+func TestUpParallelStatementErrorFailsMigration(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	indexErr := errors.New("could not create unique index")
+	st.SetStatementError(parallelIndexes[1], indexErr)
+
+	migrator := New(st, logger.New()).WithOptions(Options{MaxParallelStatements: 1})
+	migrator.Create("add_indexes", parallelIndexesSQL, "", nil, nil)
+
+	assert.ErrorIs(t, migrator.Up(ctx), ErrMigrationUp)
+	// С одним обработчиком после ошибки второй команды новые не запускаются.
+	assert.Equal(t, parallelIndexes[:1], st.ExecutedSQL())
+	assert.Equal(t, map[int]string{1: storage.StatusError}, statusByVersion(t, st))
+}
+
+func TestMigrateParallelReportsFailedStatement(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	indexErr := errors.New("could not create unique index")
+	st.SetStatementError("CREATE INDEX b", indexErr)
+
+	err := st.MigrateParallel(context.Background(), []string{"CREATE INDEX a", "CREATE INDEX b"}, 4)
+	assert.ErrorIs(t, err, indexErr)
+	assert.Contains(t, err.Error(), "statement 2")
+}
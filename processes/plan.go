@@ -0,0 +1,170 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+var ErrPlanMismatch = errors.New("план не соответствует миграциям")
+
+// Plan — упорядоченный список миграций, которые применит Up. План
+// утверждается заранее, а затем выполняется ApplyPlan без изменений.
+type Plan struct {
+	Migrations []PlanStep `json:"migrations"`
+}
+
+// PlanStep — миграция в плане.
+type PlanStep struct {
+	Version  int    `json:"version"`
+	Name     string `json:"name"`
+	Checksum string `json:"checksum"`
+}
+
+// Plan возвращает миграции, которые применил бы Up с текущими параметрами,
+// в порядке применения. Решения повторяют Up без побочных эффектов:
+// пропускаемые и закрытые шлюзом версии в план не входят.
+func (m *Migrator) Plan(ctx context.Context) (Plan, error) {
+	statuses, err := m.storage.SelectAppliedVersions(ctx)
+	if err != nil {
+		m.logger.Error("Ошибка при получении списка миграций: %v", err)
+		return Plan{}, err
+	}
+
+	lastVersion := lastAppliedVersion(statuses)
+	if lastVersion-1 > len(m.migrations) {
+		m.logger.Error("Ошибка: %v", ErrUnexpectedMigrationVersion)
+		return Plan{}, ErrUnexpectedMigrationVersion
+	}
+
+	plan := Plan{Migrations: []PlanStep{}}
+	for _, migration := range m.migrations {
+		version := migration.Version
+		skipped := statuses[version] == storage.StatusSkipped
+
+		switch {
+		// Ожидающей считается любая версия без статуса success, в том числе
+		// ниже последней применённой: её могла обойти выборочная команда.
+		case statuses[version] == storage.StatusSuccess:
+			continue
+		case m.heldByOnlySQL(version), m.isSkipRequested(version), skipped && !m.options.ApplySkipped:
+			continue
+		}
+		if _, closed := m.closedGate(migration.Up); closed {
+			continue
+		}
+
+		plan.Migrations = append(plan.Migrations, PlanStep{
+			Version:  version,
+			Name:     migration.Name,
+			Checksum: migration.Checksum,
+		})
+	}
+	return plan, nil
+}
+
+// Exclude возвращает план без миграций, уже перечисленных в manifest.
+// Миграция считается перечисленной, только если совпадают и версия,
+// и контрольная сумма: изменённая с тех пор миграция остаётся в плане.
+func (p Plan) Exclude(manifest Plan) Plan {
+	listed := make(map[int]string, len(manifest.Migrations))
+	for _, step := range manifest.Migrations {
+		listed[step.Version] = step.Checksum
+	}
+
+	rest := Plan{Migrations: []PlanStep{}}
+	for _, step := range p.Migrations {
+		if checksum, ok := listed[step.Version]; ok && checksum == step.Checksum {
+			continue
+		}
+		rest.Migrations = append(rest.Migrations, step)
+	}
+	return rest
+}
+
+// UpExcept выполняет Up, пропуская ожидающие миграции, которые перечислены
+// в manifest с той же контрольной суммой. Пропущенные версии записываются
+// со статусом skipped, как версии из Options.SkipVersions: следующий Up
+// применит их только с ApplySkipped, а не потеряет молча.
+func (m *Migrator) UpExcept(ctx context.Context, manifest Plan) error {
+	pending, err := m.Plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	rest := make(map[int]bool)
+	for _, step := range pending.Exclude(manifest).Migrations {
+		rest[step.Version] = true
+	}
+	skip := append([]int(nil), m.options.SkipVersions...)
+	for _, step := range pending.Migrations {
+		if !rest[step.Version] {
+			skip = append(skip, step.Version)
+		}
+	}
+	m.logger.Info("Миграций из манифеста, которые будут пропущены: %d", len(skip)-len(m.options.SkipVersions))
+
+	m.options.SkipVersions = skip
+	return m.Up(ctx)
+}
+
+// ApplyPlan применяет ровно миграции плана в его порядке. Если миграция
+// плана изменилась на диске, исчезла или уже применена, ничего не
+// применяется и возвращается ErrPlanMismatch.
+func (m *Migrator) ApplyPlan(ctx context.Context, plan Plan) error {
+	m.logger.Info("Начало выполнения плана из %d миграций", len(plan.Migrations))
+
+	unlock, err := m.lockRun(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	statuses, err := m.storage.SelectAppliedVersions(ctx)
+	if err != nil {
+		m.logger.Error("Ошибка при получении списка миграций: %v", err)
+		return err
+	}
+
+	for _, step := range plan.Migrations {
+		if err := m.checkPlanStep(step, statuses); err != nil {
+			m.logger.Error("План отклонён: %v", err)
+			return err
+		}
+	}
+
+	for _, step := range plan.Migrations {
+		migration := &m.migrations[step.Version-1]
+		if err := m.upMigration(ctx, migration, migration.Up, migration.UpGo); err != nil {
+			m.logger.Error("Ошибка при выполнении миграции вверх: %v", err)
+			return ErrMigrationUp
+		}
+	}
+
+	m.logger.Info("План успешно выполнен")
+	return nil
+}
+
+// checkPlanStep проверяет, что миграция плана есть на диске в том же виде
+// и ещё не применена.
+func (m *Migrator) checkPlanStep(step PlanStep, statuses map[int]string) error {
+	if step.Version < 1 || step.Version > len(m.migrations) {
+		return fmt.Errorf("%w: version %d is not on disk", ErrPlanMismatch, step.Version)
+	}
+
+	migration := m.migrations[step.Version-1]
+	switch {
+	case migration.Name != step.Name:
+		return fmt.Errorf("%w: version %d is %s on disk, %s in plan",
+			ErrPlanMismatch, step.Version, migration.Name, step.Name)
+	case migration.Checksum != step.Checksum:
+		return fmt.Errorf("%w: version %d (%s) changed since the plan was made",
+			ErrPlanMismatch, step.Version, step.Name)
+	case statuses[step.Version] == storage.StatusSuccess:
+		return fmt.Errorf("%w: version %d (%s) is already applied",
+			ErrPlanMismatch, step.Version, step.Name)
+	}
+	return nil
+}
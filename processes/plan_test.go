@@ -0,0 +1,96 @@
+package processes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPlanMigrator(st storage.SQLStorage, opts Options, checksums ...string) *Migrator {
+	migrator := New(st, logger.New()).WithOptions(opts)
+	for i, checksum := range checksums {
+		migrator.Add(storage.Migration{
+			Name:     "migration_" + string(rune('a'+i)),
+			Up:       "SELECT 1;",
+			Checksum: checksum,
+		})
+	}
+	return migrator
+}
+
+func TestPlanListsWhatUpWouldApply(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	require.NoError(t, newPlanMigrator(st, Options{}, "c1").Up(ctx))
+
+	migrator := newPlanMigrator(st, Options{SkipVersions: []int{3}}, "c1", "c2", "c3", "c4")
+	plan, err := migrator.Plan(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []PlanStep{
+		{Version: 2, Name: "migration_b", Checksum: "c2"},
+		{Version: 4, Name: "migration_d", Checksum: "c4"},
+	}, plan.Migrations)
+}
+
+func TestApplyPlanRunsExactlyThePlan(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newPlanMigrator(st, Options{}, "c1", "c2", "c3")
+
+	plan := Plan{Migrations: []PlanStep{{Version: 1, Name: "migration_a", Checksum: "c1"}}}
+	require.NoError(t, migrator.ApplyPlan(ctx, plan))
+
+	statuses, err := st.SelectAppliedVersions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess}, statuses)
+}
+
+func TestApplyPlanRejectsMismatch(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newPlanMigrator(st, Options{}, "c1", "c2-edited")
+
+	plan := Plan{Migrations: []PlanStep{
+		{Version: 1, Name: "migration_a", Checksum: "c1"},
+		{Version: 2, Name: "migration_b", Checksum: "c2"},
+	}}
+	err := migrator.ApplyPlan(ctx, plan)
+	assert.ErrorIs(t, err, ErrPlanMismatch)
+	assert.Contains(t, err.Error(), "version 2 (migration_b) changed")
+	assert.Empty(t, st.ExecutedSQL(), "Nothing is applied when any step mismatches")
+
+	err = migrator.ApplyPlan(ctx, Plan{Migrations: []PlanStep{{Version: 5, Name: "migration_e"}}})
+	assert.ErrorIs(t, err, ErrPlanMismatch)
+}
+
+func TestApplyPlanRejectsAppliedVersion(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newPlanMigrator(st, Options{}, "c1")
+	plan := Plan{Migrations: []PlanStep{{Version: 1, Name: "migration_a", Checksum: "c1"}}}
+
+	require.NoError(t, migrator.ApplyPlan(ctx, plan))
+	assert.ErrorIs(t, migrator.ApplyPlan(ctx, plan), ErrPlanMismatch)
+}
+
+func TestPlanExcludeMatchesByChecksum(t *testing.T) {
+	plan := Plan{Migrations: []PlanStep{
+		{Version: 1, Name: "migration_a", Checksum: "c1"},
+		{Version: 2, Name: "migration_b", Checksum: "c2-edited"},
+		{Version: 3, Name: "migration_c", Checksum: "c3"},
+	}}
+	manifest := Plan{Migrations: []PlanStep{
+		{Version: 1, Name: "migration_a", Checksum: "c1"},
+		{Version: 2, Name: "migration_b", Checksum: "c2"},
+	}}
+
+	assert.Equal(t, []PlanStep{
+		{Version: 2, Name: "migration_b", Checksum: "c2-edited"},
+		{Version: 3, Name: "migration_c", Checksum: "c3"},
+	}, plan.Exclude(manifest).Migrations)
+	assert.Equal(t, plan, plan.Exclude(Plan{}))
+}
@@ -0,0 +1,57 @@
+package processes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newReplicaMigrator создаёт мигратор над хранилищем, которое сообщает
+// о реплике в режиме восстановления.
+func newReplicaMigrator(t *testing.T, opts Options) (*Migrator, *storage.MockSQLStorage) {
+	t.Helper()
+	st := storage.NewMockSQLStorage()
+	st.SetInRecovery(true)
+	migrator := New(st, logger.New()).WithOptions(opts)
+	migrator.Create("create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;", nil, nil)
+	require.NoError(t, migrator.Connect(context.Background()))
+	return migrator, st
+}
+
+func TestMutatingCommandsFailOnReplica(t *testing.T) {
+	ctx := context.Background()
+	for name, run := range map[string]func(*Migrator) error{
+		"up":     func(m *Migrator) error { return m.Up(ctx) },
+		"down":   func(m *Migrator) error { return m.Down(ctx) },
+		"downto": func(m *Migrator) error { return m.DownTo(ctx, 0) },
+	} {
+		migrator, st := newReplicaMigrator(t, Options{})
+		err := run(migrator)
+		assert.ErrorIs(t, err, ErrReadOnlyDatabase, name)
+		assert.Equal(t, 0, st.LockCalls(), name)
+		assert.Empty(t, st.ExecutedSQL(), name)
+	}
+}
+
+func TestUpFailsOnReplicaWithLockWait(t *testing.T) {
+	migrator, st := newReplicaMigrator(t, Options{LockWait: time.Second})
+
+	assert.ErrorIs(t, migrator.Up(context.Background()), ErrReadOnlyDatabase)
+	assert.Empty(t, st.ExecutedSQL())
+}
+
+func TestReadCommandsWorkOnReplica(t *testing.T) {
+	ctx := context.Background()
+	migrator, _ := newReplicaMigrator(t, Options{})
+
+	pending, err := migrator.Pending(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+	_, err = migrator.CurrentVersion(ctx)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,138 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+// RedoResult — миграция, откаченная и заново применённая Redo или RedoNamed.
+type RedoResult struct {
+	Version int
+	Name    string
+}
+
+// checkRedoDown до обращения к миграциям проверяет, что у последней
+// применённой миграции есть откат. Иначе Redo отметил бы её откаченной,
+// ничего не выполнив, и упал бы уже на повторном применении.
+func (m *Migrator) checkRedoDown(ctx context.Context) error {
+	last, err := m.storage.SelectLastMigrationByStatus(ctx, storage.StatusSuccess)
+	if errors.Is(err, storage.ErrMigrationNotFound) {
+		return nil
+	}
+	if err != nil {
+		m.logger.Error("Ошибка при получении последней успешной миграции: %v", err)
+		return err
+	}
+
+	version := last.GetVersion()
+	if version < 1 || version > len(m.migrations) {
+		return nil
+	}
+	return m.requireDown(version)
+}
+
+// requireDown возвращает ErrMissingDown, если у загруженной миграции version
+// нет отката и не задан Options.ContinueOnMissingDown.
+func (m *Migrator) requireDown(version int) error {
+	migration := m.migrations[version-1]
+	if hasDown(migration) {
+		return nil
+	}
+
+	if m.options.ContinueOnMissingDown {
+		m.logger.Warn("У миграции %d (%s) нет отката, повторно выполняется только миграция вверх",
+			version, migration.Name)
+		return nil
+	}
+
+	err := fmt.Errorf("cannot redo: migration %d (%s) has no down: %w", version, migration.Name, ErrMissingDown)
+	m.logger.Error("Ошибка: %v", err)
+	return err
+}
+
+var (
+	ErrMigrationNameNotFound  = errors.New("миграция с таким именем не найдена")
+	ErrAmbiguousMigrationName = errors.New("несколько миграций с таким именем")
+	ErrMigrationNotApplied    = errors.New("миграция не применена")
+)
+
+// RedoNamed откатывает и заново применяет миграцию с именем name, а не
+// последнюю применённую. Имя должно принадлежать ровно одной загруженной
+// миграции, и она должна быть успешно применена. Более поздние миграции
+// не затрагиваются.
+func (m *Migrator) RedoNamed(ctx context.Context, name string) (RedoResult, error) {
+	m.logger.Info("Начало повторного выполнения миграции %s", name)
+
+	var versions []int
+	for i, migration := range m.migrations {
+		if migration.Name == name {
+			versions = append(versions, i+1)
+		}
+	}
+	switch len(versions) {
+	case 0:
+		return RedoResult{}, fmt.Errorf("%w: %s", ErrMigrationNameNotFound, name)
+	case 1:
+	default:
+		list := make([]string, len(versions))
+		for i, version := range versions {
+			list[i] = strconv.Itoa(version)
+		}
+		return RedoResult{}, fmt.Errorf("%w: %s (версии %s)", ErrAmbiguousMigrationName, name, strings.Join(list, ", "))
+	}
+	version := versions[0]
+
+	unlock, err := m.lockRun(ctx)
+	if err != nil {
+		return RedoResult{}, err
+	}
+	defer unlock()
+
+	statuses, err := m.storage.SelectAppliedVersions(ctx)
+	if err != nil {
+		m.logger.Error("Ошибка при получении списка миграций: %v", err)
+		return RedoResult{}, err
+	}
+	if statuses[version] != storage.StatusSuccess {
+		return RedoResult{}, fmt.Errorf("%w: %d (%s)", ErrMigrationNotApplied, version, name)
+	}
+	if err := m.requireDown(version); err != nil {
+		return RedoResult{}, err
+	}
+	if later := lastAppliedVersion(statuses); later > version {
+		m.logger.Warn("Миграции новее %d остаются применёнными: откат %s не должен от них зависеть", version, name)
+	}
+
+	migration := &m.migrations[version-1]
+	if err := m.downMigration(ctx, migration, migration.Down, migration.DownGo); err != nil {
+		m.logger.Error("Ошибка при откате миграции: %v", err)
+		return RedoResult{}, ErrMigrationDown
+	}
+	if err := m.upMigration(ctx, migration, migration.Up, migration.UpGo); err != nil {
+		m.logger.Error("Ошибка при повторной миграции: %v", err)
+		return RedoResult{}, ErrMigrationRedo
+	}
+
+	m.logger.Info("Миграция %d (%s) выполнена повторно", version, name)
+	return RedoResult{Version: version, Name: name}, nil
+}
+
+// hasDown сообщает, есть ли у миграции откат: Go-функция или SQL,
+// содержащий что-то кроме пустых строк и комментариев.
+func hasDown(migration storage.Migration) bool {
+	if migration.DownGo != nil {
+		return true
+	}
+	for _, line := range strings.Split(migration.Down, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package processes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMissingDownMigrator(st storage.SQLStorage, opts Options) *Migrator {
+	migrator := New(st, logger.New()).WithOptions(opts)
+	migrator.Create("create_users", "CREATE TABLE users", "DROP TABLE users", nil, nil)
+	migrator.Create("seed_users", "INSERT INTO users DEFAULT VALUES", "-- irreversible\n", nil, nil)
+	return migrator
+}
+
+func TestRedoFailsEarlyWithoutDown(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newMissingDownMigrator(st, Options{})
+	require.NoError(t, migrator.Up(ctx))
+	lockCalls := st.LockCalls()
+
+	_, err := migrator.Redo(ctx)
+	assert.ErrorIs(t, err, ErrMissingDown)
+	assert.Contains(t, err.Error(), "cannot redo: migration 2 (seed_users) has no down")
+
+	assert.Equal(t, lockCalls, st.LockCalls())
+	assert.Equal(t, []string{"CREATE TABLE users", "INSERT INTO users DEFAULT VALUES"}, st.ExecutedSQL())
+	assert.Equal(t, storage.StatusSuccess, statusByVersion(t, st)[2])
+}
+
+func TestRedoContinuesOnMissingDown(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newMissingDownMigrator(st, Options{ContinueOnMissingDown: true})
+	require.NoError(t, migrator.Up(ctx))
+
+	result, err := migrator.Redo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RedoResult{Version: 2, Name: "seed_users"}, result)
+	assert.Equal(t, []string{
+		"CREATE TABLE users", "INSERT INTO users DEFAULT VALUES",
+		"-- irreversible\n", "INSERT INTO users DEFAULT VALUES",
+	}, st.ExecutedSQL())
+	assert.Equal(t, storage.StatusSuccess, statusByVersion(t, st)[2])
+}
+
+func TestHasDown(t *testing.T) {
+	assert.True(t, hasDown(storage.Migration{Down: "-- drop\nDROP TABLE users;"}))
+	assert.True(t, hasDown(storage.Migration{DownGo: func(context.Context) error { return nil }}))
+	assert.False(t, hasDown(storage.Migration{Down: "\n  -- nothing to undo\n"}))
+	assert.False(t, hasDown(storage.Migration{}))
+}
+
+func TestRedoNamedReappliesOnlyThatMigration(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newThreeTableMigrator(st, Options{})
+	require.NoError(t, migrator.Up(ctx))
+
+	result, err := migrator.RedoNamed(ctx, "create_orders")
+	require.NoError(t, err)
+	assert.Equal(t, RedoResult{Version: 2, Name: "create_orders"}, result)
+	assert.Equal(t, []string{
+		"CREATE TABLE users", "CREATE TABLE orders", "CREATE TABLE items",
+		"DROP TABLE orders", "CREATE TABLE orders",
+	}, st.ExecutedSQL())
+	assert.Equal(t, map[int]string{
+		1: storage.StatusSuccess,
+		2: storage.StatusSuccess,
+		3: storage.StatusSuccess,
+	}, statusByVersion(t, st))
+}
+
+func TestRedoNamedRejectsAmbiguousName(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	migrator.Create("backfill", "UPDATE users SET a = 1", "UPDATE users SET a = NULL", nil, nil)
+	migrator.Create("backfill", "UPDATE users SET b = 1", "UPDATE users SET b = NULL", nil, nil)
+	require.NoError(t, migrator.Up(ctx))
+
+	_, err := migrator.RedoNamed(ctx, "backfill")
+	assert.ErrorIs(t, err, ErrAmbiguousMigrationName)
+	assert.Contains(t, err.Error(), "1, 2")
+	assert.Len(t, st.ExecutedSQL(), 2)
+}
+
+func TestRedoNamedRequiresAppliedMigration(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newThreeTableMigrator(st, Options{SkipVersions: []int{3}})
+	require.NoError(t, migrator.Up(ctx))
+
+	_, err := migrator.RedoNamed(ctx, "create_items")
+	assert.ErrorIs(t, err, ErrMigrationNotApplied)
+	_, err = migrator.RedoNamed(ctx, "create_payments")
+	assert.ErrorIs(t, err, ErrMigrationNameNotFound)
+	assert.Len(t, st.ExecutedSQL(), 2)
+}
+
+func TestRedoNamedRequiresDown(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	migrator.Create("create_users", "CREATE TABLE users", "", nil, nil)
+	require.NoError(t, migrator.Up(ctx))
+
+	_, err := migrator.RedoNamed(ctx, "create_users")
+	assert.ErrorIs(t, err, ErrMissingDown)
+	assert.Equal(t, []string{"CREATE TABLE users"}, st.ExecutedSQL())
+}
+
+func TestRedoReappliesTheRolledBackVersion(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newThreeTableMigrator(st, Options{SkipVersions: []int{2}})
+	require.NoError(t, migrator.Up(ctx))
+
+	result, err := migrator.Redo(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RedoResult{Version: 3, Name: "create_items"}, result)
+	assert.Equal(t, []string{
+		"CREATE TABLE users", "CREATE TABLE items",
+		"DROP TABLE items", "CREATE TABLE items",
+	}, st.ExecutedSQL())
+	assert.Equal(t, storage.StatusSkipped, statusByVersion(t, st)[2])
+}
+
+func TestRedoWithNothingApplied(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newThreeTableMigrator(st, Options{})
+
+	_, err := migrator.Redo(ctx)
+	assert.ErrorIs(t, err, ErrNothingToRedo)
+	assert.Empty(t, st.ExecutedSQL())
+}
@@ -0,0 +1,102 @@
+package processes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+// maxReportRows — сколько строк результата отчёта выводится в журнал.
+var maxReportRows = 50
+
+// hasReports сообщает, помечена ли в миграции хотя бы одна команда
+// директивой "-- migrator:report".
+func hasReports(sql string) bool {
+	return len(directiveArgs(sql, "report")) > 0
+}
+
+// migrateWithReports выполняет миграцию по командам: команда, перед которой
+// стоит директива "-- migrator:report", выполняется как запрос, и его
+// результат выводится в журнал таблицей; идущие подряд остальные команды
+// выполняются вместе, как обычный SQL миграции. Все части выполняются
+// в транзакции миграции, см. singleTx.
+func (m *Migrator) migrateWithReports(ctx context.Context, sql string) (int64, error) {
+	var (
+		pending []string
+		total   int64
+	)
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		rows, err := m.storage.Migrate(ctx, strings.Join(pending, "\n;\n")+"\n;\n")
+		total += rows
+		pending = nil
+		return err
+	}
+
+	for _, statement := range splitStatements(sql, m.delimiter(sql)) {
+		if !hasReports(statement) {
+			pending = append(pending, statement)
+			continue
+		}
+		if err := flush(); err != nil {
+			return total, err
+		}
+		report, err := m.storage.QueryReport(ctx, statement)
+		if err != nil {
+			return total, err
+		}
+		m.logReport(report)
+	}
+	err := flush()
+	return total, err
+}
+
+// logReport выводит результат запроса таблицей с выравниванием по столбцам.
+func (m *Migrator) logReport(report storage.Report) {
+	widths := make([]int, len(report.Columns))
+	for i, column := range report.Columns {
+		widths[i] = utf8.RuneCountInString(column)
+	}
+	shown := report.Rows
+	if len(shown) > maxReportRows {
+		shown = shown[:maxReportRows]
+	}
+	for _, row := range shown {
+		for i, value := range row {
+			if i < len(widths) && utf8.RuneCountInString(value) > widths[i] {
+				widths[i] = utf8.RuneCountInString(value)
+			}
+		}
+	}
+
+	formatRow := func(values []string) string {
+		cells := make([]string, len(widths))
+		for i, width := range widths {
+			value := ""
+			if i < len(values) {
+				value = values[i]
+			}
+			cells[i] = fmt.Sprintf("%-*s", width, value)
+		}
+		return "| " + strings.Join(cells, " | ") + " |"
+	}
+	separator := make([]string, len(widths))
+	for i, width := range widths {
+		separator[i] = strings.Repeat("-", width)
+	}
+
+	m.logger.Info("%s", formatRow(report.Columns))
+	m.logger.Info("%s", "|-"+strings.Join(separator, "-|-")+"-|")
+	for _, row := range shown {
+		m.logger.Info("%s", formatRow(row))
+	}
+	if hidden := len(report.Rows) - len(shown); hidden > 0 {
+		m.logger.Info("… ещё строк: %d", hidden)
+	}
+	m.logger.Info("Строк в отчёте: %d", len(report.Rows))
+}
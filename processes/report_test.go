@@ -0,0 +1,99 @@
+package processes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reportSQL = `UPDATE users SET email = lower(email);
+ALTER TABLE users ADD CONSTRAINT users_email_lower CHECK (email = lower(email));
+
+-- migrator:report
+SELECT status, count(*) AS total FROM users GROUP BY status;
+
+ANALYZE users;`
+
+func TestUpLogsReportRows(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	st.SetReports(storage.Report{
+		Columns: []string{"status", "total"},
+		Rows:    [][]string{{"active", "1200"}, {"blocked", "7"}},
+	})
+
+	out := &lineRecorder{ZeroLogger: logger.New()}
+	migrator := New(st, out)
+	migrator.Create("normalize_emails", reportSQL, "", nil, nil)
+	require.NoError(t, migrator.Up(ctx))
+
+	assert.Equal(t, []string{
+		"UPDATE users SET email = lower(email)\n;\nALTER TABLE users ADD CONSTRAINT users_email_lower CHECK (email = lower(email))\n;\n",
+		"-- migrator:report\nSELECT status, count(*) AS total FROM users GROUP BY status",
+		"ANALYZE users\n;\n",
+	}, st.ExecutedSQL())
+
+	assert.Subset(t, out.lines, []string{
+		"| status  | total |",
+		"|---------|-------|",
+		"| active  | 1200  |",
+		"| blocked | 7     |",
+		"Строк в отчёте: 2",
+	})
+}
+
+func TestReportMigrationRunsInOneTransaction(t *testing.T) {
+	for _, c := range []struct {
+		scope string
+		txLog []string
+	}{
+		{LockScopeRun, []string{"BEGIN", "SAVEPOINT migration_sql", "COMMIT"}},
+		{LockScopeMigration, []string{"BEGIN", "XACT LOCK", "SAVEPOINT migration_sql", "COMMIT"}},
+	} {
+		st := storage.NewMockSQLStorage()
+		migrator := New(st, logger.New()).WithOptions(Options{LockScope: c.scope})
+		migrator.Create("normalize_emails", reportSQL, "", nil, nil)
+
+		require.NoError(t, migrator.Up(context.Background()), c.scope)
+		assert.Equal(t, c.txLog, st.TxLog(), c.scope)
+		assert.Len(t, st.ExecutedSQL(), 3, c.scope)
+	}
+}
+
+func TestReportKeepsPercentSigns(t *testing.T) {
+	out := &lineRecorder{ZeroLogger: logger.New()}
+	New(storage.NewMockSQLStorage(), out).logReport(storage.Report{
+		Columns: []string{"share"},
+		Rows:    [][]string{{"100%d"}},
+	})
+
+	assert.Contains(t, out.lines, "| share |")
+	assert.Contains(t, out.lines, "| 100%d |")
+}
+
+func TestReportTruncatesLongResults(t *testing.T) {
+	rows := maxReportRows
+	maxReportRows = 2
+	t.Cleanup(func() { maxReportRows = rows })
+
+	out := &lineRecorder{ZeroLogger: logger.New()}
+	New(storage.NewMockSQLStorage(), out).logReport(storage.Report{
+		Columns: []string{"id"},
+		Rows:    [][]string{{"1"}, {"2"}, {"3"}, {"4"}},
+	})
+
+	assert.Equal(t, []string{"| id |", "|----|", "| 1  |", "| 2  |", "… ещё строк: 2", "Строк в отчёте: 4"}, out.lines)
+}
+
+func TestMigrationWithoutReportRunsAsOneCommand(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	migrator.Create("create_users", "CREATE TABLE users (id serial);\nCREATE INDEX ON users (id);", "", nil, nil)
+
+	require.NoError(t, migrator.Up(context.Background()))
+	assert.Equal(t, []string{"CREATE TABLE users (id serial);\nCREATE INDEX ON users (id);"}, st.ExecutedSQL())
+}
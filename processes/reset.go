@@ -0,0 +1,35 @@
+package processes
+
+import (
+	"context"
+)
+
+// Reset возвращает мигратор к чистому состоянию для тестов, встраивающих
+// мигратор: под блокировкой откатывает все применённые миграции и удаляет
+// все записи служебной таблицы, оставляя саму таблицу. С
+// Options.ResetSkipDowns откаты не выполняются и только очищается таблица.
+// Это программный API: команды CLI reset и nuke его не используют
+// и спрашивают подтверждение отдельно.
+func (m *Migrator) Reset(ctx context.Context) error {
+	m.logger.Info("Сброс состояния мигратора")
+
+	unlock, err := m.lockRun(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if !m.options.ResetSkipDowns {
+		if err := m.downTo(ctx, 0); err != nil {
+			return err
+		}
+	}
+
+	if err := m.storage.DeleteMigrations(ctx); err != nil {
+		m.logger.Error("Ошибка при очистке служебной таблицы: %v", err)
+		return err
+	}
+
+	m.logger.Info("Состояние мигратора сброшено")
+	return nil
+}
@@ -0,0 +1,57 @@
+package processes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResetRollsBackAndClearsHistory(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newThreeTableMigrator(st, Options{})
+	require.NoError(t, migrator.Up(ctx))
+	locks, unlocks := st.LockCalls(), st.UnlockCalls()
+
+	require.NoError(t, migrator.Reset(ctx))
+	assert.Equal(t, locks+1, st.LockCalls())
+	assert.Equal(t, unlocks+1, st.UnlockCalls())
+	assert.Equal(t, []string{
+		"CREATE TABLE users", "CREATE TABLE orders", "CREATE TABLE items",
+		"DROP TABLE items", "DROP TABLE orders", "DROP TABLE users",
+	}, st.ExecutedSQL())
+
+	statuses, err := st.SelectAppliedVersions(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, statuses)
+
+	// После сброса миграции применяются заново с первой версии.
+	require.NoError(t, migrator.Up(ctx))
+	assert.Len(t, st.ExecutedSQL(), 9)
+}
+
+func TestResetSkipDownsOnlyClearsHistory(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	require.NoError(t, newThreeTableMigrator(st, Options{}).Up(ctx))
+
+	migrator := newThreeTableMigrator(st, Options{ResetSkipDowns: true})
+	require.NoError(t, migrator.Reset(ctx))
+	assert.Len(t, st.ExecutedSQL(), 3)
+
+	statuses, err := st.SelectAppliedVersions(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, statuses)
+}
+
+func TestResetOnEmptyDatabase(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	require.NoError(t, newThreeTableMigrator(st, Options{}).Reset(ctx))
+	assert.Empty(t, st.ExecutedSQL())
+	assert.Equal(t, 1, st.LockCalls())
+	assert.Equal(t, 1, st.UnlockCalls())
+}
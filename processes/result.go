@@ -0,0 +1,61 @@
+package processes
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+// AppliedMigration — миграция, выполненная мигратором.
+type AppliedMigration struct {
+	Version  int
+	Name     string
+	Checksum string
+	Duration time.Duration
+}
+
+// Result — итог работы мигратора: миграции, применённые вверх,
+// откаченные миграции и миграции, выполнение которых завершилось ошибкой,
+// в порядке выполнения.
+type Result struct {
+	Applied    []AppliedMigration
+	RolledBack []AppliedMigration
+	Failed     []AppliedMigration
+}
+
+// NoOp сообщает, что мигратор не применил и не откатил ни одной миграции.
+func (r Result) NoOp() bool {
+	return len(r.Applied) == 0 && len(r.RolledBack) == 0
+}
+
+// Result возвращает миграции, применённые этим мигратором.
+func (m *Migrator) Result() Result {
+	return m.result
+}
+
+func (m *Migrator) recordApplied(migration storage.IMigration, duration time.Duration) {
+	m.result.Applied = append(m.result.Applied, appliedMigration(migration, duration))
+}
+
+func (m *Migrator) recordRolledBack(migration storage.IMigration, duration time.Duration) {
+	m.result.RolledBack = append(m.result.RolledBack, appliedMigration(migration, duration))
+}
+
+// recordFailedAttempt записывает миграцию, завершившуюся ошибкой err.
+// Миграция, не начатая из-за занятой блокировки, не записывается.
+func (m *Migrator) recordFailedAttempt(migration storage.IMigration, duration time.Duration, err error) {
+	if errors.Is(err, storage.ErrLockBusy) {
+		return
+	}
+	m.result.Failed = append(m.result.Failed, appliedMigration(migration, duration))
+}
+
+func appliedMigration(migration storage.IMigration, duration time.Duration) AppliedMigration {
+	return AppliedMigration{
+		Version:  migration.GetVersion(),
+		Name:     migration.GetName(),
+		Checksum: migration.GetChecksum(),
+		Duration: duration,
+	}
+}
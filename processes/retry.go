@@ -0,0 +1,53 @@
+package processes
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+// deadlockBackoff — базовая пауза перед повтором после взаимоблокировки.
+var deadlockBackoff = 100 * time.Millisecond
+
+// retryDelay возвращает паузу перед повтором attempt (с 1): пауза растёт
+// с номером попытки и случайно смещается, чтобы конкурирующие сессии
+// не столкнулись снова в тот же момент.
+func retryDelay(attempt int) time.Duration {
+	if deadlockBackoff <= 0 {
+		return 0
+	}
+	return time.Duration(attempt)*deadlockBackoff + time.Duration(rand.Int63n(int64(deadlockBackoff)))
+}
+
+// migrateWithRetry выполняет SQL миграции, повторяя его не более
+// Options.DeadlockRetries раз после взаимоблокировки или сбоя сериализации.
+// Вне транзакции каждая попытка выполняется в собственной неявной
+// транзакции и при ошибке откатывается целиком. В открытой транзакции
+// ошибка прерывает её, поэтому перед повтором выполняется откат к точке
+// сохранения savepoint, заданной перед SQL миграции. Остальные ошибки
+// возвращаются сразу.
+func (m *Migrator) migrateWithRetry(ctx context.Context, sql, savepoint string) (int64, error) {
+	for attempt := 1; ; attempt++ {
+		rows, err := m.migrate(ctx, sql)
+		if err == nil || attempt > m.options.DeadlockRetries || !storage.IsRetryable(err) {
+			return rows, err
+		}
+		if savepoint != "" {
+			if rollbackErr := m.storage.RollbackToSavepoint(ctx, savepoint); rollbackErr != nil {
+				m.logger.Error("Ошибка при откате к точке сохранения %s: %v", savepoint, rollbackErr)
+				return rows, err
+			}
+		}
+
+		delay := retryDelay(attempt)
+		m.logger.Warn("Взаимоблокировка при выполнении миграции, повтор %d из %d через %s: %v",
+			attempt, m.options.DeadlockRetries, delay, err)
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
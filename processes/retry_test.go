@@ -0,0 +1,118 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyStorage возвращает err на первых failures вызовах Migrate.
+type flakyStorage struct {
+	*storage.MockSQLStorage
+	err      error
+	failures int
+	attempts int
+}
+
+func (s *flakyStorage) Migrate(ctx context.Context, sql string) (int64, error) {
+	s.attempts++
+	if s.attempts <= s.failures {
+		return 0, s.err
+	}
+	return s.MockSQLStorage.Migrate(ctx, sql)
+}
+
+func newFlakyMigrator(t *testing.T, st *flakyStorage, retries int) *Migrator {
+	backoff := deadlockBackoff
+	deadlockBackoff = 0
+	t.Cleanup(func() { deadlockBackoff = backoff })
+
+	migrator := New(st, logger.New()).WithOptions(Options{DeadlockRetries: retries})
+	migrator.Create("create_users", "CREATE TABLE users (id serial);", "DROP TABLE users;", nil, nil)
+	return migrator
+}
+
+func TestUpRetriesDeadlock(t *testing.T) {
+	ctx := context.Background()
+	st := &flakyStorage{
+		MockSQLStorage: storage.NewMockSQLStorage(),
+		err:            &pgconn.PgError{Code: "40P01", Message: "deadlock detected"},
+		failures:       1,
+	}
+	migrator := newFlakyMigrator(t, st, 3)
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, 2, st.attempts)
+	assert.Equal(t, []string{"CREATE TABLE users (id serial);"}, st.ExecutedSQL())
+
+	status, err := migrator.VersionStatus(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.StatusSuccess, status)
+}
+
+func TestUpStopsRetryingAfterLimit(t *testing.T) {
+	ctx := context.Background()
+	st := &flakyStorage{
+		MockSQLStorage: storage.NewMockSQLStorage(),
+		err:            &pgconn.PgError{Code: "40001", Message: "could not serialize access"},
+		failures:       10,
+	}
+	migrator := newFlakyMigrator(t, st, 2)
+
+	_, err := migrator.migrateWithRetry(ctx, "CREATE TABLE users (id serial);", "")
+	assert.Error(t, err)
+	assert.Equal(t, 3, st.attempts, "One attempt plus two retries")
+}
+
+func TestUpDoesNotRetryOtherErrors(t *testing.T) {
+	ctx := context.Background()
+	st := &flakyStorage{
+		MockSQLStorage: storage.NewMockSQLStorage(),
+		err:            errors.New("syntax error"),
+		failures:       1,
+	}
+	migrator := newFlakyMigrator(t, st, 3)
+
+	_, err := migrator.migrateWithRetry(ctx, "CREATE TABLE users (id serial);", "")
+	assert.Error(t, err)
+	assert.Equal(t, 1, st.attempts)
+}
+
+func TestCombinedMigrationRetriesDeadlock(t *testing.T) {
+	ctx := context.Background()
+	st := &flakyStorage{
+		MockSQLStorage: storage.NewMockSQLStorage(),
+		err:            &pgconn.PgError{Code: "40P01", Message: "deadlock detected"},
+		failures:       1,
+	}
+	backoff := deadlockBackoff
+	deadlockBackoff = 0
+	t.Cleanup(func() { deadlockBackoff = backoff })
+
+	migrator := New(st, logger.New()).WithOptions(Options{DeadlockRetries: 3})
+	goRan := false
+	migrator.Create("seed_users", "CREATE TABLE users (id serial);", "", func(ctx context.Context) error {
+		goRan = true
+		return nil
+	}, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, 2, st.attempts)
+	assert.True(t, goRan)
+	assert.Equal(t, []string{
+		"BEGIN",
+		"SAVEPOINT migration_sql",
+		"ROLLBACK TO SAVEPOINT migration_sql",
+		"COMMIT",
+	}, st.TxLog(), "Retry after rolling back the failed attempt")
+
+	status, err := migrator.VersionStatus(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, storage.StatusSuccess, status)
+}
@@ -0,0 +1,48 @@
+package processes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpRecordsAffectedRows(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	st.SetAffectedRows("UPDATE users SET active = true;", 42)
+
+	out := &lineRecorder{ZeroLogger: logger.New()}
+	migrator := New(st, out)
+	migrator.Create("create_users", "CREATE TABLE users (id serial);", "", nil, nil)
+	migrator.Create("activate_users", "UPDATE users SET active = true;", "", nil, nil)
+	require.NoError(t, migrator.Up(ctx))
+
+	assert.Contains(t, out.lines, "Миграция 1: изменено строк 0")
+	assert.Contains(t, out.lines, "Миграция 2: изменено строк 42")
+
+	records, err := migrator.StatusRecords(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, int64(0), records[0].RowsAffected)
+	assert.Equal(t, int64(42), records[1].RowsAffected)
+}
+
+func TestUpRecordsAffectedRowsOfSQLStepWithGoStep(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	st.SetAffectedRows("DELETE FROM sessions;", 7)
+
+	migrator := New(st, logger.New())
+	migrator.Create("purge_sessions", "DELETE FROM sessions;", "", func(ctx context.Context) error { return nil }, nil)
+	require.NoError(t, migrator.Up(ctx))
+
+	records, err := migrator.StatusRecords(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, storage.StatusSuccess, records[0].Status)
+	assert.Equal(t, int64(7), records[0].RowsAffected)
+}
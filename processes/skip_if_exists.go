@@ -0,0 +1,98 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+var ErrInvalidSkipIfExists = errors.New("некорректная директива skip-if-exists")
+
+var regObjectName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// skipIfExistsObjects разбирает директивы
+// "-- migrate:skip-if-exists table=users column=users.email". Таблица
+// указывается как [схема.]таблица, столбец — как [схема.]таблица.столбец.
+func skipIfExistsObjects(sql string) ([]storage.SchemaObject, error) {
+	var objects []storage.SchemaObject
+	for _, arg := range directiveArgs(sql, "skip-if-exists") {
+		fields := strings.Fields(arg)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%w: не указан объект", ErrInvalidSkipIfExists)
+		}
+		for _, field := range fields {
+			object, err := parseSchemaObject(field)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, object)
+		}
+	}
+	return objects, nil
+}
+
+func parseSchemaObject(field string) (storage.SchemaObject, error) {
+	kind, name, ok := strings.Cut(field, "=")
+	parts := strings.Split(name, ".")
+	for _, part := range parts {
+		if !regObjectName.MatchString(part) {
+			ok = false
+		}
+	}
+	if ok {
+		switch {
+		case kind == "table" && len(parts) == 1:
+			return storage.SchemaObject{Table: parts[0]}, nil
+		case kind == "table" && len(parts) == 2:
+			return storage.SchemaObject{Schema: parts[0], Table: parts[1]}, nil
+		case kind == "column" && len(parts) == 2:
+			return storage.SchemaObject{Table: parts[0], Column: parts[1]}, nil
+		case kind == "column" && len(parts) == 3:
+			return storage.SchemaObject{Schema: parts[0], Table: parts[1], Column: parts[2]}, nil
+		}
+	}
+	return storage.SchemaObject{}, fmt.Errorf("%w: %q, ожидается table=[схема.]таблица или column=[схема.]таблица.столбец",
+		ErrInvalidSkipIfExists, field)
+}
+
+// existingObjects проверяет объекты директивы skip-if-exists. Миграция
+// пропускается, только если существуют все перечисленные объекты; тогда
+// возвращается true.
+func (m *Migrator) existingObjects(ctx context.Context, sql string) (bool, error) {
+	objects, err := skipIfExistsObjects(sql)
+	if err != nil || len(objects) == 0 {
+		return false, err
+	}
+	for _, object := range objects {
+		exists, err := m.storage.ObjectExists(ctx, object)
+		if err != nil {
+			m.logger.Error("Ошибка при проверке объекта %s: %v", object, err)
+			return false, err
+		}
+		if !exists {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// adoptMigration записывает миграцию как успешную, не выполняя её: объекты,
+// которые она создаёт, уже есть в базе, например при переходе на мигратор
+// с существующей схемой.
+func (m *Migrator) adoptMigration(ctx context.Context, migration storage.IMigration) error {
+	m.logger.Info("Миграция %d (%s) не выполняется: объекты skip-if-exists уже существуют, она записана как успешная",
+		migration.GetVersion(), migration.GetName())
+
+	migration.SetStatus(storage.StatusSuccess)
+	migration.SetStatusChangeTime(time.Now())
+	if err := m.storage.InsertMigration(ctx, migration); err != nil {
+		m.logger.Error("Ошибка при вставке миграции: %v", err)
+		return err
+	}
+	return nil
+}
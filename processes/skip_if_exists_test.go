@@ -0,0 +1,74 @@
+package processes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const adoptUsersSQL = `-- migrate:skip-if-exists table=users column=users.email
+CREATE TABLE users (id serial, email text);`
+
+func TestSkipIfExistsObjects(t *testing.T) {
+	objects, err := skipIfExistsObjects("-- migrate:skip-if-exists table=users column=billing.invoices.total\n-- migrator:skip-if-exists table=audit.log")
+	require.NoError(t, err)
+	assert.Equal(t, []storage.SchemaObject{
+		{Table: "users"},
+		{Schema: "billing", Table: "invoices", Column: "total"},
+		{Schema: "audit", Table: "log"},
+	}, objects)
+
+	for _, sql := range []string{
+		"-- migrate:skip-if-exists",
+		"-- migrate:skip-if-exists users",
+		"-- migrate:skip-if-exists index=users_pkey",
+		"-- migrate:skip-if-exists column=email",
+		"-- migrate:skip-if-exists table=users;drop",
+	} {
+		_, err := skipIfExistsObjects(sql)
+		assert.ErrorIs(t, err, ErrInvalidSkipIfExists, sql)
+	}
+}
+
+func TestUpSkipsMigrationWhenObjectsExist(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	st.SetExistingObjects("users", "users.email")
+
+	migrator := New(st, logger.New())
+	migrator.Create("create_users", adoptUsersSQL, "DROP TABLE users;", nil, nil)
+	migrator.Create("create_orders", "CREATE TABLE orders (id serial);", "DROP TABLE orders;", nil, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, []string{"CREATE TABLE orders (id serial);"}, st.ExecutedSQL())
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess, 2: storage.StatusSuccess}, statusByVersion(t, st))
+}
+
+func TestUpRunsMigrationWhenObjectIsMissing(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	// Таблица есть, а столбца нет: миграцию нужно выполнить.
+	st.SetExistingObjects("users")
+
+	migrator := New(st, logger.New())
+	migrator.Create("create_users", adoptUsersSQL, "DROP TABLE users;", nil, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, []string{adoptUsersSQL}, st.ExecutedSQL())
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess}, statusByVersion(t, st))
+}
+
+func TestUpRejectsInvalidSkipIfExists(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	migrator.Create("create_users", "-- migrate:skip-if-exists users\nCREATE TABLE users (id serial);", "", nil, nil)
+
+	err := migrator.Up(context.Background())
+	assert.ErrorIs(t, err, ErrMigrationUp)
+	assert.ErrorIs(t, err, ErrInvalidSkipIfExists)
+	assert.Empty(t, st.ExecutedSQL())
+}
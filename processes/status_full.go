@@ -0,0 +1,67 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+// statusFull выводит два раздела: «Применённые» — успешно применённые
+// миграции по записям в базе данных, и «Ожидающие» — миграции из директории,
+// ещё не применённые, а затем итоговую строку.
+func (m *Migrator) statusFull(ctx context.Context) error {
+	recorded, err := m.storage.SelectMigrations(ctx)
+	if storage.IsTrackingTableAbsent(err) {
+		m.logger.Info(noTrackingTableMessage)
+		return nil
+	}
+	if err != nil && !errors.Is(err, storage.ErrMigrationNotFound) {
+		m.logger.Error("Ошибка при получении статуса: %v", err)
+		return ErrGetStatus
+	}
+
+	pending, _, err := m.partition(ctx)
+	if err != nil {
+		return ErrGetStatus
+	}
+
+	applied := make([]storage.IMigration, 0, len(recorded))
+	for _, migr := range recorded {
+		if migr.GetStatus() == storage.StatusSuccess && m.matchesStatusLabel(migr) {
+			applied = append(applied, migr)
+		}
+	}
+	sort.Slice(applied, func(i, j int) bool { return applied[i].GetVersion() < applied[j].GetVersion() })
+
+	m.logger.Info("Применённые:")
+	for _, migr := range applied {
+		m.logger.Info("  %05d %-30s %s", migr.GetVersion(), migr.GetName(),
+			migr.GetStatusChangeTime().Format("2006-01-02 15:04:05"))
+	}
+	if len(applied) == 0 {
+		m.logger.Info("  нет")
+	}
+
+	m.logger.Info("Ожидающие:")
+	shown := 0
+	for _, migration := range pending {
+		if !m.matchesStatusLabel(&migration) {
+			continue
+		}
+		shown++
+		line := fmt.Sprintf("  %05d %-30s", migration.Version, migration.Name)
+		if migration.Status != "" {
+			line += " " + statusWithIcon(migration.Status, m.options.StatusIcons)
+		}
+		m.logger.Info("%s", line)
+	}
+	if shown == 0 {
+		m.logger.Info("  нет")
+	}
+
+	m.logger.Info("Итого: применено %d, ожидает %d", len(applied), shown)
+	return nil
+}
@@ -0,0 +1,86 @@
+package processes
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// section возвращает строки между заголовком title и следующей строкой без отступа.
+func section(lines []string, title string) []string {
+	var body []string
+	inside := false
+	for _, line := range lines {
+		switch {
+		case line == title:
+			inside = true
+		case inside && strings.HasPrefix(line, "  "):
+			body = append(body, strings.TrimSpace(line))
+		case inside:
+			return body
+		}
+	}
+	return body
+}
+
+func TestStatusFullSplitsAppliedAndPending(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	require.NoError(t, newThreeTableMigrator(st, Options{SkipVersions: []int{2, 3}}).Up(ctx))
+
+	out := &lineRecorder{ZeroLogger: logger.New()}
+	migrator := New(st, out).WithOptions(Options{StatusFull: true})
+	migrator.Create("create_users", "CREATE TABLE users", "DROP TABLE users", nil, nil)
+	migrator.Create("create_orders", "CREATE TABLE orders", "DROP TABLE orders", nil, nil)
+	migrator.Create("create_items", "CREATE TABLE items", "DROP TABLE items", nil, nil)
+	require.NoError(t, migrator.Status(ctx))
+
+	applied := section(out.lines, "Применённые:")
+	require.Len(t, applied, 1)
+	assert.True(t, strings.HasPrefix(applied[0], "00001 create_users"), applied[0])
+
+	pending := section(out.lines, "Ожидающие:")
+	require.Len(t, pending, 2)
+	assert.True(t, strings.HasPrefix(pending[0], "00002 create_orders"), pending[0])
+	assert.Contains(t, pending[0], storage.StatusSkipped)
+	assert.True(t, strings.HasPrefix(pending[1], "00003 create_items"), pending[1])
+
+	assert.Equal(t, "Итого: применено 1, ожидает 2", out.lines[len(out.lines)-1])
+}
+
+func TestStatusFullOnEmptyDatabase(t *testing.T) {
+	out := &lineRecorder{ZeroLogger: logger.New()}
+	migrator := New(storage.NewMockSQLStorage(), out).WithOptions(Options{StatusFull: true})
+	migrator.Create("create_users", "CREATE TABLE users", "DROP TABLE users", nil, nil)
+
+	require.NoError(t, migrator.Status(context.Background()))
+	assert.Equal(t, []string{"нет"}, section(out.lines, "Применённые:"))
+	assert.Len(t, section(out.lines, "Ожидающие:"), 1)
+	assert.Equal(t, "Итого: применено 0, ожидает 1", out.lines[len(out.lines)-1])
+}
+
+// absentTableStorage ведёт себя как база, в которой ещё нет служебной таблицы.
+type absentTableStorage struct {
+	*storage.MockSQLStorage
+}
+
+func (absentTableStorage) SelectMigrations(context.Context) ([]storage.IMigration, error) {
+	return nil, &pgconn.PgError{Code: "42P01", Message: `relation "schema_migrations" does not exist`}
+}
+
+func TestStatusBeforeFirstUp(t *testing.T) {
+	for _, full := range []bool{false, true} {
+		out := &lineRecorder{ZeroLogger: logger.New()}
+		st := absentTableStorage{MockSQLStorage: storage.NewMockSQLStorage()}
+		migrator := New(st, out).WithOptions(Options{StatusFull: full})
+
+		require.NoError(t, migrator.Status(context.Background()))
+		assert.Equal(t, []string{noTrackingTableMessage}, out.lines)
+	}
+}
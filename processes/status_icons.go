@@ -0,0 +1,67 @@
+package processes
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+var ErrInvalidStatusIcons = errors.New("некорректный набор значков статуса")
+
+const (
+	// StatusIconsNone — статус выводится только словом.
+	StatusIconsNone = "none"
+	// StatusIconsUnicode — перед статусом выводится значок Unicode.
+	StatusIconsUnicode = "unicode"
+	// StatusIconsASCII — значки из символов ASCII для терминалов без Unicode.
+	StatusIconsASCII = "ascii"
+)
+
+// statusIcons задаёт значки статусов для каждого набора. Значки различаются
+// формой, а не цветом, поэтому таблица статуса читается и без цвета.
+var statusIcons = map[string]map[string]string{
+	StatusIconsUnicode: {
+		storage.StatusSuccess:      "✓",
+		storage.StatusError:        "✗",
+		storage.StatusProcess:      "…",
+		storage.StatusCancellation: "…",
+		storage.StatusCancel:       "↩",
+		storage.StatusSkipped:      "↷",
+		storage.StatusGated:        "⏸",
+		storage.StatusCancelled:    "⊘",
+	},
+	StatusIconsASCII: {
+		storage.StatusSuccess:      "[+]",
+		storage.StatusError:        "[x]",
+		storage.StatusProcess:      "[~]",
+		storage.StatusCancellation: "[~]",
+		storage.StatusCancel:       "[<]",
+		storage.StatusSkipped:      "[>]",
+		storage.StatusGated:        "[|]",
+		storage.StatusCancelled:    "[!]",
+	},
+}
+
+// ParseStatusIcons разбирает значение флага -status-icons.
+func ParseStatusIcons(s string) (string, error) {
+	switch s {
+	case "", StatusIconsNone:
+		return StatusIconsNone, nil
+	case StatusIconsUnicode, StatusIconsASCII:
+		return s, nil
+	default:
+		return "", fmt.Errorf("%w: %q, expected %s, %s or %s",
+			ErrInvalidStatusIcons, s, StatusIconsNone, StatusIconsUnicode, StatusIconsASCII)
+	}
+}
+
+// statusWithIcon добавляет к статусу значок из набора icons.
+// Неизвестный статус выводится без значка.
+func statusWithIcon(status, icons string) string {
+	icon, ok := statusIcons[icons][status]
+	if !ok {
+		return status
+	}
+	return icon + " " + status
+}
@@ -0,0 +1,79 @@
+package processes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lineRecorder запоминает строки, выведенные через Info.
+type lineRecorder struct {
+	*logger.ZeroLogger
+	lines []string
+}
+
+func (l *lineRecorder) Info(msg string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(msg, v...))
+}
+
+var allStatuses = []string{
+	storage.StatusSuccess, storage.StatusError, storage.StatusProcess, storage.StatusCancellation,
+	storage.StatusCancel, storage.StatusSkipped, storage.StatusGated, storage.StatusCancelled,
+}
+
+func TestStatusWithIcon(t *testing.T) {
+	assert.Equal(t, "✓ success", statusWithIcon(storage.StatusSuccess, StatusIconsUnicode))
+	assert.Equal(t, "✗ error", statusWithIcon(storage.StatusError, StatusIconsUnicode))
+	assert.Equal(t, "… process", statusWithIcon(storage.StatusProcess, StatusIconsUnicode))
+	assert.Equal(t, "↩ cancel", statusWithIcon(storage.StatusCancel, StatusIconsUnicode))
+	assert.Equal(t, "[+] success", statusWithIcon(storage.StatusSuccess, StatusIconsASCII))
+	assert.Equal(t, "success", statusWithIcon(storage.StatusSuccess, StatusIconsNone))
+
+	for _, status := range allStatuses {
+		assert.Contains(t, statusIcons[StatusIconsUnicode], status)
+		icon := statusIcons[StatusIconsASCII][status]
+		for _, r := range icon {
+			assert.Less(t, r, rune(128), "ASCII icon for "+status+" must be plain ASCII")
+		}
+	}
+}
+
+func TestStatusShowsIconForEachStatus(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	for i, status := range allStatuses {
+		migration := storage.CreateMigration("migration_"+status, status, i+1, time.Now())
+		require.NoError(t, st.InsertMigration(ctx, migration))
+	}
+
+	for _, icons := range []string{StatusIconsUnicode, StatusIconsASCII} {
+		out := &lineRecorder{ZeroLogger: logger.New()}
+		migrator := New(st, out).WithOptions(Options{StatusIcons: icons})
+		require.NoError(t, migrator.Status(ctx))
+
+		table := strings.Join(out.lines, "\n")
+		for _, status := range allStatuses {
+			assert.Contains(t, table, "| "+statusIcons[icons][status]+" "+status+" ")
+		}
+	}
+}
+
+func TestParseStatusIcons(t *testing.T) {
+	icons, err := ParseStatusIcons("")
+	require.NoError(t, err)
+	assert.Equal(t, StatusIconsNone, icons)
+
+	icons, err = ParseStatusIcons("ascii")
+	require.NoError(t, err)
+	assert.Equal(t, StatusIconsASCII, icons)
+
+	_, err = ParseStatusIcons("emoji")
+	assert.ErrorIs(t, err, ErrInvalidStatusIcons)
+}
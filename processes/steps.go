@@ -0,0 +1,40 @@
+package processes
+
+import "github.com/Edestus789/sql-migrator/storage"
+
+// StepDecision — решение оператора о миграции в пошаговом режиме.
+type StepDecision int
+
+const (
+	// StepApply — выполнить миграцию.
+	StepApply StepDecision = iota
+	// StepSkip — не выполнять миграцию. При Up она записывается как
+	// пропущенная, при откате остаётся применённой.
+	StepSkip
+	// StepQuit — остановить выполнение без ошибки; блокировка снимается.
+	StepQuit
+)
+
+// Направления выполнения миграции, передаваемые в Options.BeforeMigration.
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+// decide спрашивает Options.BeforeMigration, что делать с миграцией.
+// Без обработчика миграция выполняется.
+func (m *Migrator) decide(migration storage.Migration, direction string) (StepDecision, error) {
+	if m.options.BeforeMigration == nil {
+		return StepApply, nil
+	}
+	decision, err := m.options.BeforeMigration(migration, direction)
+	if err != nil {
+		m.logger.Error("Ошибка пошагового режима: %v", err)
+		return StepQuit, err
+	}
+	if decision == StepQuit {
+		m.logger.Warn("Выполнение остановлено оператором перед миграцией %d (%s)",
+			migration.Version, migration.Name)
+	}
+	return decision, nil
+}
@@ -0,0 +1,61 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Edestus789/sql-migrator/storage"
+)
+
+var ErrEmptyTag = errors.New("не указано имя тега релиза")
+
+// Tag записывает тег релиза label: текущую версию схемы и контрольные суммы
+// успешно применённых миграций. Тег не является миграцией и не влияет на
+// Up и Down; он помогает сопоставить релиз приложения с состоянием схемы.
+func (m *Migrator) Tag(ctx context.Context, label string) (storage.ReleaseTag, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return storage.ReleaseTag{}, ErrEmptyTag
+	}
+
+	version, err := m.CurrentVersion(ctx)
+	if err != nil {
+		return storage.ReleaseTag{}, err
+	}
+
+	migrations, err := m.storage.SelectMigrations(ctx)
+	if err != nil && !errors.Is(err, storage.ErrMigrationNotFound) {
+		m.logger.Error("Ошибка при получении миграций: %v", err)
+		return storage.ReleaseTag{}, err
+	}
+	checksums := make(map[int]string)
+	for _, migration := range migrations {
+		if migration.GetStatus() == storage.StatusSuccess {
+			checksums[migration.GetVersion()] = migration.GetChecksum()
+		}
+	}
+
+	tag := storage.ReleaseTag{Label: label, Version: version, Checksums: checksums}
+	if err := m.storage.InsertTag(ctx, tag); err != nil {
+		m.logger.Error("Ошибка при записи тега %s: %v", label, err)
+		return storage.ReleaseTag{}, err
+	}
+	m.logger.Info("Тег %s записан: версия схемы %d, миграций %d", label, version, len(checksums))
+	return tag, nil
+}
+
+// logLatestTag выводит последний тег релиза, если он есть.
+func (m *Migrator) logLatestTag(ctx context.Context) error {
+	tag, err := m.storage.LatestTag(ctx)
+	if errors.Is(err, storage.ErrTagNotFound) {
+		return nil
+	}
+	if err != nil {
+		m.logger.Error("Ошибка при получении тега релиза: %v", err)
+		return err
+	}
+	m.logger.Info("Последний тег: %s, версия схемы %d, %s",
+		tag.Label, tag.Version, tag.CreatedAt.Format("2006-01-02 15:04:05"))
+	return nil
+}
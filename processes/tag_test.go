@@ -0,0 +1,75 @@
+package processes
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagCapturesCurrentVersion(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := newThreeTableMigrator(st, Options{SkipVersions: []int{3}})
+	require.NoError(t, migrator.Up(ctx))
+
+	tag, err := migrator.Tag(ctx, " v2.3.0 ")
+	require.NoError(t, err)
+	assert.Equal(t, "v2.3.0", tag.Label)
+	assert.Equal(t, 2, tag.Version)
+	assert.Len(t, tag.Checksums, 2)
+	assert.Contains(t, tag.Checksums, 1)
+	assert.Contains(t, tag.Checksums, 2)
+
+	latest, err := st.LatestTag(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "v2.3.0", latest.Label)
+	assert.Equal(t, 2, latest.Version)
+}
+
+func TestTagOnEmptyDatabase(t *testing.T) {
+	ctx := context.Background()
+	migrator := New(storage.NewMockSQLStorage(), logger.New())
+
+	tag, err := migrator.Tag(ctx, "v0.1.0")
+	require.NoError(t, err)
+	assert.Equal(t, 0, tag.Version)
+	assert.Empty(t, tag.Checksums)
+}
+
+func TestTagRequiresLabel(t *testing.T) {
+	st := storage.NewMockSQLStorage()
+	_, err := New(st, logger.New()).Tag(context.Background(), " ")
+	assert.ErrorIs(t, err, ErrEmptyTag)
+
+	_, err = st.LatestTag(context.Background())
+	assert.ErrorIs(t, err, storage.ErrTagNotFound)
+}
+
+func TestStatusShowsLatestTag(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	require.NoError(t, newThreeTableMigrator(st, Options{}).Up(ctx))
+
+	out := &lineRecorder{ZeroLogger: logger.New()}
+	migrator := New(st, out)
+	_, err := migrator.Tag(ctx, "v2.2.0")
+	require.NoError(t, err)
+	_, err = migrator.Tag(ctx, "v2.3.0")
+	require.NoError(t, err)
+
+	out.lines = nil
+	require.NoError(t, migrator.Status(ctx))
+	var tagLines []string
+	for _, line := range out.lines {
+		if strings.HasPrefix(line, "Последний тег:") {
+			tagLines = append(tagLines, line)
+		}
+	}
+	require.Len(t, tagLines, 1)
+	assert.True(t, strings.HasPrefix(tagLines[0], "Последний тег: v2.3.0, версия схемы 3"), tagLines[0])
+}
@@ -0,0 +1,57 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidTimeout = errors.New("некорректный тайм-аут миграции")
+
+// migrationTimeout возвращает тайм-аут из директивы "-- migrator:timeout 30m".
+// Ноль означает, что директивы нет.
+func migrationTimeout(sql string) (time.Duration, error) {
+	args := directiveArgs(sql, "timeout")
+	if len(args) == 0 {
+		return 0, nil
+	}
+
+	value := strings.TrimSpace(args[len(args)-1])
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidTimeout, value)
+	}
+	return timeout, nil
+}
+
+// withMigrationTimeout применяет тайм-аут директивы timeout к одной миграции:
+// возвращает контекст с этим сроком и задаёт такой же statement_timeout.
+// Функция restore возвращает statement_timeout к значению до директивы,
+// заданному -statement-timeout или -pre-lock-statement, и освобождает
+// контекст. Без директивы контекст не меняется.
+func (m *Migrator) withMigrationTimeout(ctx context.Context, sql string) (context.Context, func(), error) {
+	timeout, err := migrationTimeout(sql)
+	if err != nil || timeout == 0 {
+		return ctx, func() {}, err
+	}
+
+	previous, err := m.storage.StatementTimeout(ctx)
+	if err != nil {
+		return ctx, func() {}, err
+	}
+	m.logger.Info("Тайм-аут миграции: %s", timeout)
+	if err := m.storage.SetStatementTimeout(ctx, timeout); err != nil {
+		return ctx, func() {}, err
+	}
+
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	restore := func() {
+		cancel()
+		if err := m.storage.RestoreStatementTimeout(ctx, previous); err != nil {
+			m.logger.Error("Ошибка при восстановлении statement_timeout: %v", err)
+		}
+	}
+	return stepCtx, restore, nil
+}
@@ -0,0 +1,92 @@
+package processes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/Edestus789/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const slowBackfillSQL = `-- migrator:timeout 30m
+UPDATE orders SET total = subtotal + tax;`
+
+func TestMigrationTimeout(t *testing.T) {
+	timeout, err := migrationTimeout(slowBackfillSQL)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Minute, timeout)
+
+	timeout, err = migrationTimeout("UPDATE orders SET total = 0;")
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), timeout)
+
+	for _, sql := range []string{"-- migrator:timeout", "-- migrator:timeout soon", "-- migrator:timeout -5m"} {
+		_, err := migrationTimeout(sql)
+		assert.ErrorIs(t, err, ErrInvalidTimeout, sql)
+	}
+}
+
+func TestTimeoutDirectiveAppliesAndRestoresStatementTimeout(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{StatementTimeout: 5 * time.Second})
+
+	var deadline time.Time
+	migrator.Create("backfill_totals", slowBackfillSQL, "", func(ctx context.Context) error {
+		deadline, _ = ctx.Deadline()
+		return nil
+	}, nil)
+	migrator.Create("create_items", "CREATE TABLE items (id serial);", "", nil, nil)
+
+	require.NoError(t, migrator.Connect(ctx))
+	start := time.Now()
+	require.NoError(t, migrator.Up(ctx))
+
+	assert.Equal(t, []string{
+		"SET statement_timeout = 5000;",
+		"SET statement_timeout = 1800000;",
+		"SET statement_timeout = '5000ms';",
+	}, st.SessionStatements())
+	assert.False(t, deadline.Before(start.Add(30*time.Minute)))
+	assert.True(t, deadline.Before(start.Add(31*time.Minute)))
+}
+
+func TestTimeoutDirectiveRestoresDefaultWithoutGlobalTimeout(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	migrator.Create("backfill_totals", slowBackfillSQL, "", nil, nil)
+
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, []string{"SET statement_timeout = 1800000;", "SET statement_timeout = '0';"}, st.SessionStatements())
+}
+
+func TestTimeoutDirectiveRestoresPreLockStatementTimeout(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New()).WithOptions(Options{PreLockStatements: []string{"SET statement_timeout = '2min'"}})
+	migrator.Create("backfill_totals", slowBackfillSQL, "", nil, nil)
+
+	require.NoError(t, migrator.Connect(ctx))
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, []string{
+		"SET statement_timeout = '2min'",
+		"SET statement_timeout = 1800000;",
+		"SET statement_timeout = '2min';",
+	}, st.SessionStatements())
+}
+
+func TestInvalidTimeoutDirectiveFailsMigration(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMockSQLStorage()
+	migrator := New(st, logger.New())
+	migrator.Create("backfill_totals", "-- migrator:timeout soon\nUPDATE orders SET total = 0;", "", nil, nil)
+
+	assert.ErrorIs(t, migrator.Up(ctx), ErrMigrationUp)
+	assert.Empty(t, st.ExecutedSQL())
+	assert.Empty(t, st.SessionStatements())
+	assert.Equal(t, map[int]string{1: storage.StatusError}, statusByVersion(t, st))
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// AdminConn выполняет команды над базами данных сервера: CREATE DATABASE
+// и DROP DATABASE нельзя выполнить ни в транзакции, ни из самой удаляемой
+// базы, поэтому для них используется отдельное соединение с сервисной
+// базой, которое открывается при первой команде.
+type AdminConn struct {
+	connString string
+	pool       *pgxpool.Pool
+	logger     logger.Logger
+}
+
+func NewAdminConn(connString string, logger logger.Logger) *AdminConn {
+	return &AdminConn{connString: connString, logger: logger}
+}
+
+// CreateDatabase создаёт базу name копией базы template.
+func (a *AdminConn) CreateDatabase(ctx context.Context, name, template string) error {
+	a.logger.Info("Creating database %s from template %s", name, template)
+	return a.exec(ctx, "CREATE DATABASE "+pgx.Identifier{name}.Sanitize()+
+		" TEMPLATE "+pgx.Identifier{template}.Sanitize()+";")
+}
+
+// DropDatabase удаляет базу name, если она существует.
+func (a *AdminConn) DropDatabase(ctx context.Context, name string) error {
+	a.logger.Info("Dropping database %s", name)
+	return a.exec(ctx, "DROP DATABASE IF EXISTS "+pgx.Identifier{name}.Sanitize()+";")
+}
+
+func (a *AdminConn) Close() error {
+	if a.pool != nil {
+		a.pool.Close()
+		a.pool = nil
+	}
+	return nil
+}
+
+func (a *AdminConn) exec(ctx context.Context, sql string) error {
+	if a.pool == nil {
+		config, err := pgxpool.ParseConfig(a.connString)
+		if err != nil {
+			a.logger.Error("Failed to parse admin connection string: %v", err)
+			return err
+		}
+		config.MaxConns = 1
+		pool, err := pgxpool.ConnectConfig(ctx, config)
+		if err != nil {
+			a.logger.Error("Failed to connect to the admin database: %v", err)
+			return err
+		}
+		a.pool = pool
+	}
+	if _, err := a.pool.Exec(ctx, sql); err != nil {
+		a.logger.Error("Failed to execute admin command: %v", err)
+		return err
+	}
+	return nil
+}
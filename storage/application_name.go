@@ -0,0 +1,35 @@
+package storage
+
+import "github.com/jackc/pgx/v4"
+
+// Version — версия мигратора. Задаётся при сборке:
+// go build -ldflags "-X github.com/Edestus789/sql-migrator/storage.Version=v1.2.0".
+var Version = "dev"
+
+// DefaultApplicationName возвращает application_name, под которым соединения
+// мигратора видны в pg_stat_activity.
+func DefaultApplicationName() string {
+	return "sql-migrator/" + Version
+}
+
+// SetApplicationName задаёт application_name соединений вместо значения
+// по умолчанию. Явно заданное имя имеет приоритет и над параметром в DSN.
+func (storage *PostgresStorage) SetApplicationName(name string) {
+	storage.applicationName = name
+}
+
+// applyApplicationName записывает application_name в параметры соединения.
+// Имя по умолчанию не заменяет application_name, уже указанный в DSN.
+func (storage *PostgresStorage) applyApplicationName(config *pgx.ConnConfig) {
+	if config.RuntimeParams == nil {
+		config.RuntimeParams = make(map[string]string)
+	}
+	params := config.RuntimeParams
+	if storage.applicationName != "" {
+		params["application_name"] = storage.applicationName
+		return
+	}
+	if _, ok := params["application_name"]; !ok {
+		params["application_name"] = DefaultApplicationName()
+	}
+}
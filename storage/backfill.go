@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// BackfillBatchSize — размер пакета, которым SafeAddColumn заполняет
+// новую колонку.
+const BackfillBatchSize = 1000
+
+var (
+	ErrInvalidColumn = errors.New("table, column and column type must not be empty")
+	// ErrBackfillWithoutLimit возвращается, если SQL заполнения не
+	// ограничивает пакет параметром $1.
+	ErrBackfillWithoutLimit = errors.New("backfill SQL must limit each batch with $1")
+)
+
+// SafeAddColumn добавляет в таблицу NOT NULL колонку без долгой блокировки
+// таблицы: колонка создаётся допускающей NULL, заполняется пакетами
+// backfillSQL (как в MigrateBatches, $1 получает размер пакета, а запрос
+// должен обновлять только ещё не заполненные строки), после чего
+// ограничение NOT NULL проверяется через CHECK ... NOT VALID и VALIDATE
+// CONSTRAINT и только затем устанавливается. Каждая операция выполняется
+// отдельным запросом, поэтому прерванный вызов можно безопасно повторить.
+func SafeAddColumn(ctx context.Context, s SQLStorage, table, column, columnType, backfillSQL string) error {
+	if table == "" || column == "" || columnType == "" {
+		return ErrInvalidColumn
+	}
+	if !strings.Contains(backfillSQL, "$1") {
+		return ErrBackfillWithoutLimit
+	}
+
+	quotedTable := quoteIdentifier(driverPostgres, table)
+	quotedColumn := pgx.Identifier{column}.Sanitize()
+	constraint := pgx.Identifier{column + "_not_null"}.Sanitize()
+
+	if _, err := s.Migrate(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s;", quotedTable, quotedColumn, columnType)); err != nil {
+		return fmt.Errorf("add column %s: %w", column, err)
+	}
+	if _, err := s.MigrateBatches(ctx, backfillSQL, BackfillBatchSize, nil); err != nil {
+		return fmt.Errorf("backfill column %s: %w", column, err)
+	}
+
+	steps := []string{
+		fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s;", quotedTable, constraint),
+		fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s CHECK (%s IS NOT NULL) NOT VALID;", quotedTable, constraint, quotedColumn),
+		fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s;", quotedTable, constraint),
+		fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;", quotedTable, quotedColumn),
+		fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;", quotedTable, constraint),
+	}
+	for _, step := range steps {
+		if _, err := s.Migrate(ctx, step); err != nil {
+			return fmt.Errorf("set column %s not null: %w", column, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeAddColumnOperationSequence(t *testing.T) {
+	st := NewMockSQLStorage()
+	st.SetBatchRows(1000, 250)
+	backfill := "UPDATE users SET status = 'active' WHERE id IN (SELECT id FROM users WHERE status IS NULL LIMIT $1);"
+
+	require.NoError(t, SafeAddColumn(context.Background(), st, "public.users", "status", "text", backfill))
+	assert.Equal(t, []string{
+		`ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "status" text;`,
+		backfill,
+		backfill,
+		backfill,
+		`ALTER TABLE "public"."users" DROP CONSTRAINT IF EXISTS "status_not_null";`,
+		`ALTER TABLE "public"."users" ADD CONSTRAINT "status_not_null" CHECK ("status" IS NOT NULL) NOT VALID;`,
+		`ALTER TABLE "public"."users" VALIDATE CONSTRAINT "status_not_null";`,
+		`ALTER TABLE "public"."users" ALTER COLUMN "status" SET NOT NULL;`,
+		`ALTER TABLE "public"."users" DROP CONSTRAINT "status_not_null";`,
+	}, st.ExecutedSQL())
+}
+
+func TestSafeAddColumnValidatesArguments(t *testing.T) {
+	st := NewMockSQLStorage()
+
+	err := SafeAddColumn(context.Background(), st, "users", "", "text", "UPDATE users SET x = 1 LIMIT $1;")
+	assert.ErrorIs(t, err, ErrInvalidColumn)
+
+	err = SafeAddColumn(context.Background(), st, "users", "status", "text", "UPDATE users SET status = 'active';")
+	assert.ErrorIs(t, err, ErrBackfillWithoutLimit)
+	assert.Empty(t, st.ExecutedSQL())
+}
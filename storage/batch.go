@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrInvalidBatchSize возвращается для размера пакета меньше единицы.
+var ErrInvalidBatchSize = errors.New("batch size must be positive")
+
+// BatchProgress получает номер завершённого пакета (с 1), число строк,
+// изменённых этим пакетом, и общее число изменённых строк.
+type BatchProgress func(batch int, rows, total int64)
+
+// runBatches вызывает exec, пока очередной пакет изменяет хотя бы одну строку,
+// и возвращает общее число изменённых строк.
+func runBatches(ctx context.Context, size int, exec func(ctx context.Context) (int64, error), progress BatchProgress) (int64, error) {
+	if size < 1 {
+		return 0, ErrInvalidBatchSize
+	}
+
+	var total int64
+	for batch := 1; ; batch++ {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		rows, err := exec(ctx)
+		if err != nil {
+			return total, err
+		}
+		if rows == 0 {
+			return total, nil
+		}
+
+		total += rows
+		if progress != nil {
+			progress(batch, rows, total)
+		}
+	}
+}
+
+// MigrateBatches выполняет параметризованный SQL миграции пакетами: $1
+// получает размер пакета, и каждый пакет фиксируется отдельно, поэтому
+// блокировки строк не копятся на всю таблицу. Выполнение повторяется,
+// пока очередной пакет не изменит ни одной строки.
+func (storage *PostgresStorage) MigrateBatches(ctx context.Context, sql string, size int, progress BatchProgress) (int64, error) {
+	storage.logger.Info("Executing migration SQL in batches of %d rows", size)
+
+	total, err := runBatches(ctx, size, func(ctx context.Context) (int64, error) {
+		tag, err := storage.db().Exec(ctx, sql, size)
+		if err != nil {
+			return 0, err
+		}
+		return tag.RowsAffected(), nil
+	}, progress)
+	if err != nil {
+		storage.logger.Error("Failed to execute migration SQL batch: %v", err)
+	}
+	return total, err
+}
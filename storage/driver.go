@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Edestus789/sql-migrator/logger"
+)
+
+// DriverCapabilities — возможности драйвера, от которых зависят команды
+// мигратора: транзакции нужны для атомарных миграций и savepoint'ов,
+// advisory lock — для защиты от одновременного запуска.
+type DriverCapabilities struct {
+	Transactions bool
+	AdvisoryLock bool
+}
+
+// String возвращает возможности одной строкой, например
+// "supports-transactions=yes supports-advisory-lock=no".
+func (c DriverCapabilities) String() string {
+	return "supports-transactions=" + yesNo(c.Transactions) +
+		" supports-advisory-lock=" + yesNo(c.AdvisoryLock)
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "yes"
+	}
+	return "no"
+}
+
+// Driver описывает драйвер базы данных, доступный мигратору.
+type Driver struct {
+	Name         string
+	Description  string
+	Capabilities DriverCapabilities
+	// Open создаёт хранилище драйвера для строки подключения dsn.
+	Open func(dsn string, l logger.Logger) SQLStorage
+}
+
+// ErrUnknownDriver возвращается LookupDriver для незарегистрированного имени.
+var ErrUnknownDriver = errors.New("unknown database driver")
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]Driver{
+		string(driverPostgres): {
+			Name:         string(driverPostgres),
+			Description:  "PostgreSQL via pgx",
+			Capabilities: DriverCapabilities{Transactions: true, AdvisoryLock: true},
+			Open: func(dsn string, l logger.Logger) SQLStorage {
+				return NewPostgresStorage(dsn, l)
+			},
+		},
+	}
+)
+
+// RegisterDriver добавляет описание драйвера в реестр. Драйверы подключаются
+// сборкой со своим пакетом, который вызывает эту функцию в init; повторная
+// регистрация имени заменяет описание.
+func RegisterDriver(driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	drivers[strings.ToLower(driver.Name)] = driver
+}
+
+// Drivers возвращает зарегистрированные драйверы в алфавитном порядке имён.
+func Drivers() []Driver {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	list := make([]Driver, 0, len(drivers))
+	for _, driver := range drivers {
+		list = append(list, driver)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// LookupDriver возвращает зарегистрированный драйвер по имени без учёта
+// регистра или ErrUnknownDriver.
+func LookupDriver(name string) (Driver, error) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	driver, ok := drivers[strings.ToLower(name)]
+	if !ok || driver.Open == nil {
+		return Driver{}, fmt.Errorf("%w: %q", ErrUnknownDriver, name)
+	}
+	return driver, nil
+}
+
+// DriverName возвращает имя драйвера для строки подключения: схему URL,
+// где postgresql — синоним postgres, или postgres для строки в формате
+// ключ=значение.
+func DriverName(dsn string) string {
+	scheme, _, found := strings.Cut(dsn, "://")
+	if !found {
+		return string(driverPostgres)
+	}
+	scheme = strings.ToLower(scheme)
+	if scheme == "postgresql" {
+		return string(driverPostgres)
+	}
+	return scheme
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltInDrivers(t *testing.T) {
+	list := Drivers()
+	require.NotEmpty(t, list)
+	assert.Equal(t, "postgres", list[0].Name)
+	assert.Equal(t, DriverCapabilities{Transactions: true, AdvisoryLock: true}, list[0].Capabilities)
+	assert.Equal(t, "supports-transactions=yes supports-advisory-lock=yes", list[0].Capabilities.String())
+}
+
+func TestRegisterDriver(t *testing.T) {
+	t.Cleanup(func() {
+		driversMu.Lock()
+		delete(drivers, "sqlite")
+		driversMu.Unlock()
+	})
+
+	RegisterDriver(Driver{Name: "sqlite", Capabilities: DriverCapabilities{Transactions: true}})
+	var names []string
+	for _, driver := range Drivers() {
+		names = append(names, driver.Name)
+	}
+	assert.Equal(t, []string{"postgres", "sqlite"}, names)
+	assert.Equal(t, "supports-transactions=yes supports-advisory-lock=no",
+		DriverCapabilities{Transactions: true}.String())
+}
+
+func TestLookupDriverByDSN(t *testing.T) {
+	for _, dsn := range []string{
+		"postgres://user@localhost/app",
+		"postgresql://user@localhost/app",
+		"host=localhost dbname=app",
+	} {
+		driver, err := LookupDriver(DriverName(dsn))
+		require.NoError(t, err, dsn)
+		assert.Equal(t, "postgres", driver.Name, dsn)
+		_, ok := driver.Open(dsn, logger.New()).(*PostgresStorage)
+		assert.True(t, ok, dsn)
+	}
+
+	_, err := LookupDriver(DriverName("mysql://user@localhost/app"))
+	assert.ErrorIs(t, err, ErrUnknownDriver)
+}
@@ -2,6 +2,8 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -10,11 +12,23 @@ type IMigration interface {
 	GetStatus() string
 	GetVersion() int
 	GetStatusChangeTime() time.Time
+	GetLabels() []string
+	GetSourceFile() string
+	GetChecksum() string
+	GetUpSQL() string
+	GetRowsAffected() int64
+	GetAuthor() string
 
 	SetName(name string)
 	SetStatus(status string)
 	SetVersion(version int)
 	SetStatusChangeTime(statusChangeTime time.Time)
+	SetLabels(labels []string)
+	SetSourceFile(sourceFile string)
+	SetChecksum(checksum string)
+	SetUpSQL(sql string)
+	SetRowsAffected(rows int64)
+	SetAuthor(author string)
 }
 
 type Migration struct {
@@ -22,10 +36,21 @@ type Migration struct {
 	Version          int
 	Status           string
 	StatusChangeTime time.Time
+	Labels           []string
+	SourceFile       string
+	Checksum         string
 	Up               string
 	Down             string
-	UpGo             func(ctx context.Context) error
-	DownGo           func(ctx context.Context) error
+	// RowsAffected — сколько строк изменил SQL последнего выполнения миграции.
+	RowsAffected int64
+	// Author — автор миграции из директивы "-- migrate:author".
+	Author string
+	UpGo   func(ctx context.Context) error
+	DownGo func(ctx context.Context) error
+	// GoProcess — UpGo и DownGo выполняются отдельным процессом (Go-миграции
+	// из файлов): они не видят незафиксированную транзакцию мигратора, и SQL
+	// такой миграции фиксируется до запуска Go-шага.
+	GoProcess bool
 }
 
 func CreateMigration(name, status string, version int, statusChangeTime time.Time) IMigration {
@@ -53,6 +78,33 @@ func (m *Migration) GetStatusChangeTime() time.Time {
 	return m.StatusChangeTime
 }
 
+func (m *Migration) GetLabels() []string {
+	return m.Labels
+}
+
+func (m *Migration) GetSourceFile() string {
+	return m.SourceFile
+}
+
+func (m *Migration) GetChecksum() string {
+	return m.Checksum
+}
+
+// GetUpSQL возвращает SQL миграции вверх, который записывается в служебную
+// таблицу вместе со статусом.
+func (m *Migration) GetUpSQL() string {
+	return m.Up
+}
+
+// GetRowsAffected возвращает число строк, изменённых SQL миграции.
+func (m *Migration) GetRowsAffected() int64 {
+	return m.RowsAffected
+}
+
+func (m *Migration) GetAuthor() string {
+	return m.Author
+}
+
 func (m *Migration) SetName(name string) {
 	m.Name = name
 }
@@ -68,3 +120,69 @@ func (m *Migration) SetVersion(version int) {
 func (m *Migration) SetStatusChangeTime(statusChangeTime time.Time) {
 	m.StatusChangeTime = statusChangeTime
 }
+
+func (m *Migration) SetLabels(labels []string) {
+	m.Labels = labels
+}
+
+func (m *Migration) SetSourceFile(sourceFile string) {
+	m.SourceFile = sourceFile
+}
+
+func (m *Migration) SetChecksum(checksum string) {
+	m.Checksum = checksum
+}
+
+func (m *Migration) SetUpSQL(sql string) {
+	m.Up = sql
+}
+
+func (m *Migration) SetRowsAffected(rows int64) {
+	m.RowsAffected = rows
+}
+
+func (m *Migration) SetAuthor(author string) {
+	m.Author = author
+}
+
+// String возвращает краткое описание миграции: версию, имя, статус, метки
+// и доступные реализации каждого направления, без текста SQL.
+func (m Migration) String() string {
+	status := m.Status
+	if status == "" {
+		status = "-"
+	}
+	s := fmt.Sprintf("%05d_%s [%s] up=%s down=%s",
+		m.Version, m.Name, status,
+		directionKinds(m.Up, m.UpGo != nil),
+		directionKinds(m.Down, m.DownGo != nil))
+	if len(m.Labels) > 0 {
+		s += " labels=" + strings.Join(m.Labels, ",")
+	}
+	return s
+}
+
+// Dump возвращает описание миграции вместе с полным текстом SQL обоих направлений.
+func (m Migration) Dump() string {
+	var b strings.Builder
+	b.WriteString(m.String())
+	b.WriteString("\n-- up\n")
+	b.WriteString(m.Up)
+	b.WriteString("\n-- down\n")
+	b.WriteString(m.Down)
+	return b.String()
+}
+
+func directionKinds(sql string, hasGo bool) string {
+	kinds := make([]string, 0, 2)
+	if strings.TrimSpace(sql) != "" {
+		kinds = append(kinds, "sql")
+	}
+	if hasGo {
+		kinds = append(kinds, "go")
+	}
+	if len(kinds) == 0 {
+		return "none"
+	}
+	return strings.Join(kinds, "+")
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationString(t *testing.T) {
+	goFunc := func(context.Context) error { return nil }
+
+	migration := Migration{
+		Name:    "create_users",
+		Version: 3,
+		Status:  StatusSuccess,
+		Up:      "CREATE TABLE users (id serial);",
+		Down:    "DROP TABLE users;",
+		DownGo:  goFunc,
+	}
+	assert.Equal(t, "00003_create_users [success] up=sql down=sql+go", migration.String())
+	assert.Equal(t, migration.String(), fmt.Sprint(&migration))
+
+	pending := Migration{Name: "seed", Version: 12, UpGo: goFunc}
+	assert.Equal(t, "00012_seed [-] up=go down=none", pending.String())
+}
+
+func TestMigrationDump(t *testing.T) {
+	migration := Migration{
+		Name:    "create_users",
+		Version: 1,
+		Up:      "CREATE TABLE users (id serial);",
+		Down:    "DROP TABLE users;",
+	}
+
+	assert.NotContains(t, migration.String(), "CREATE TABLE")
+	assert.Equal(t,
+		"00001_create_users [-] up=sql down=sql\n-- up\nCREATE TABLE users (id serial);\n-- down\nDROP TABLE users;",
+		migration.Dump())
+}
+
+func TestMigrationStringWithLabels(t *testing.T) {
+	migration := Migration{Name: "create_invoices", Version: 2, Status: StatusSuccess, Up: "SELECT 1", Labels: []string{"billing", "q3"}}
+	assert.Equal(t, "00002_create_invoices [success] up=sql down=none labels=billing,q3", migration.String())
+}
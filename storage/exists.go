@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+)
+
+// SchemaObject — таблица или столбец, существование которых проверяет
+// ObjectExists. Пустая Schema означает текущую схему, пустой Column — саму
+// таблицу.
+type SchemaObject struct {
+	Schema string
+	Table  string
+	Column string
+}
+
+func (o SchemaObject) String() string {
+	name := o.Table
+	if o.Schema != "" {
+		name = o.Schema + "." + name
+	}
+	if o.Column != "" {
+		name += "." + o.Column
+	}
+	return name
+}
+
+// ObjectExists проверяет по information_schema, существует ли таблица или
+// столбец. Проверка специфична для Postgres: имена сравниваются как есть,
+// поэтому идентификаторы без кавычек указываются в нижнем регистре.
+func (storage *PostgresStorage) ObjectExists(ctx context.Context, object SchemaObject) (bool, error) {
+	var (
+		exists bool
+		err    error
+	)
+	if object.Column == "" {
+		err = storage.db().QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM information_schema.tables
+				WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema())
+					AND table_name = $2
+			);`, object.Schema, object.Table).Scan(&exists)
+	} else {
+		err = storage.db().QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema())
+					AND table_name = $2
+					AND column_name = $3
+			);`, object.Schema, object.Table, object.Column).Scan(&exists)
+	}
+	if err != nil {
+		storage.logger.Error("Failed to check whether %s exists: %v", object, err)
+		return false, err
+	}
+	return exists, nil
+}
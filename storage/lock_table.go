@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrLockHeld возвращается, когда постоянная блокировка занята другим владельцем.
+var ErrLockHeld = errors.New("lock is held by another owner")
+
+// PersistentLock описывает запись постоянной блокировки.
+type PersistentLock struct {
+	Key        string
+	Owner      string
+	AcquiredAt time.Time
+	// ExpiresAt — момент, после которого блокировку может забрать другой владелец.
+	// Нулевое значение означает, что блокировка не истекает.
+	ExpiresAt time.Time
+}
+
+// Постоянная блокировка хранится в таблице, а не в advisory lock: advisory lock
+// принадлежит сессии и освобождается при завершении процесса, поэтому не может
+// удерживаться между отдельными запусками CLI. Если владелец упал, не сняв
+// блокировку, её освобождает истечение TTL.
+const createLockTableSQL = `CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+	key TEXT PRIMARY KEY,
+	owner TEXT NOT NULL,
+	acquired_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ
+);`
+
+// AcquirePersistentLock захватывает блокировку key для owner. Повторный захват
+// тем же владельцем продлевает её. Если блокировка занята другим владельцем
+// и не истекла, возвращается ErrLockHeld с описанием текущего владельца.
+// ttl == 0 означает блокировку без срока действия.
+func (storage *PostgresStorage) AcquirePersistentLock(ctx context.Context, key, owner string, ttl time.Duration) error {
+	storage.logger.Info("Acquiring persistent lock %q", key)
+
+	if _, err := storage.pool.Exec(ctx, createLockTableSQL); err != nil {
+		storage.logger.Error("Failed to create schema_migrations_lock table: %v", err)
+		return err
+	}
+
+	var expiresAt *time.Time
+	if ttl > 0 {
+		t := time.Now().Add(ttl)
+		expiresAt = &t
+	}
+
+	tag, err := storage.pool.Exec(ctx, `
+		INSERT INTO schema_migrations_lock (key, owner, acquired_at, expires_at)
+		VALUES ($1, $2, now(), $3)
+		ON CONFLICT (key) DO UPDATE
+		SET owner = EXCLUDED.owner, acquired_at = EXCLUDED.acquired_at, expires_at = EXCLUDED.expires_at
+		WHERE schema_migrations_lock.owner = EXCLUDED.owner
+			OR schema_migrations_lock.expires_at < now();`,
+		key, owner, expiresAt)
+	if err != nil {
+		storage.logger.Error("Failed to acquire persistent lock: %v", err)
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var (
+		holder     string
+		acquiredAt time.Time
+		expires    *time.Time
+	)
+	err = storage.pool.QueryRow(ctx,
+		`SELECT owner, acquired_at, expires_at FROM schema_migrations_lock WHERE key = $1;`, key).
+		Scan(&holder, &acquiredAt, &expires)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Блокировку освободили между запросами — сообщаем о занятости,
+			// вызывающий код повторит попытку.
+			return fmt.Errorf("%w: %s", ErrLockHeld, key)
+		}
+		storage.logger.Error("Failed to read persistent lock: %v", err)
+		return err
+	}
+
+	lock := PersistentLock{Key: key, Owner: holder, AcquiredAt: acquiredAt}
+	if expires != nil {
+		lock.ExpiresAt = *expires
+	}
+	return lockHeldError(lock)
+}
+
+// ReleasePersistentLock снимает блокировку key. Снять блокировку может любой
+// процесс: команда unlock обычно запускается отдельно от команды lock.
+func (storage *PostgresStorage) ReleasePersistentLock(ctx context.Context, key string) error {
+	storage.logger.Info("Releasing persistent lock %q", key)
+
+	if _, err := storage.pool.Exec(ctx, createLockTableSQL); err != nil {
+		storage.logger.Error("Failed to create schema_migrations_lock table: %v", err)
+		return err
+	}
+
+	if _, err := storage.pool.Exec(ctx, `DELETE FROM schema_migrations_lock WHERE key = $1;`, key); err != nil {
+		storage.logger.Error("Failed to release persistent lock: %v", err)
+		return err
+	}
+	return nil
+}
+
+func lockHeldError(lock PersistentLock) error {
+	expires := "never expires"
+	if !lock.ExpiresAt.IsZero() {
+		expires = "expires at " + lock.ExpiresAt.Format(time.RFC3339)
+	}
+	return fmt.Errorf("%w: %s held by %s since %s, %s",
+		ErrLockHeld, lock.Key, lock.Owner, lock.AcquiredAt.Format(time.RFC3339), expires)
+}
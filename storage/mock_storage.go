@@ -3,15 +3,61 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 )
 
 type MockSQLStorage struct {
 	migrations []IMigration
+	executed   []string
+	xactLocked []string
+	// xactStatuses — записи служебной таблицы, сделанные под XactLock.
+	xactStatuses []string
+	xactHeld     bool
+	batchRows    []int64
+	affected     map[string]int64
+	reports      []Report
+	existing     map[string]bool
+	failing      map[string]error
+	txLog        []string
+	// savepoints — длина executed на момент создания точки сохранения.
+	savepoints map[string]int
+	inTx       bool
+	txStart    int
+	schema     SchemaSnapshot
+	roles      []string
+	session    []string
+	missing    []string
+	analyzed   []string
+	locks      map[string]PersistentLock
+	tags       []ReleaseTag
+
+	// timeout — statement_timeout сессии для StatementTimeout.
+	timeout string
+
+	// parallel защищает executed и parallelMax в MigrateParallel.
+	parallel    sync.Mutex
+	running     int
+	parallelMax int
+
+	lockBusy    int
+	tableDrops  int
+	lockCalls   int
+	unlockCalls int
+	closeCalls  int
+	inRecovery  bool
 }
 
 func NewMockSQLStorage() *MockSQLStorage {
 	return &MockSQLStorage{
 		migrations: []IMigration{},
+		locks:      make(map[string]PersistentLock),
+		savepoints: make(map[string]int),
 	}
 }
 
@@ -19,25 +65,182 @@ func (m *MockSQLStorage) Connect(_ context.Context) error {
 	return nil
 }
 
+// SetInRecovery задаёт, сообщает ли хранилище о реплике в режиме восстановления.
+func (m *MockSQLStorage) SetInRecovery(inRecovery bool) {
+	m.inRecovery = inRecovery
+}
+
+func (m *MockSQLStorage) InRecovery() bool {
+	return m.inRecovery
+}
+
 func (m *MockSQLStorage) Close() error {
+	m.closeCalls++
 	return nil
 }
 
+// CloseCalls возвращает количество вызовов Close.
+func (m *MockSQLStorage) CloseCalls() int {
+	return m.closeCalls
+}
+
 func (m *MockSQLStorage) Lock(_ context.Context) error {
+	m.lockCalls++
 	return nil
 }
 
+// TryLock возвращает ErrLockBusy, пока не исчерпаны попытки, заданные
+// SetLockBusy, а затем берёт блокировку как Lock.
+func (m *MockSQLStorage) TryLock(ctx context.Context) error {
+	if m.lockBusy > 0 {
+		m.lockBusy--
+		return ErrLockBusy
+	}
+	return m.Lock(ctx)
+}
+
+// SetLockBusy задаёт, сколько следующих вызовов TryLock и TryXactLock
+// застанут блокировку занятой другой сессией.
+func (m *MockSQLStorage) SetLockBusy(attempts int) {
+	m.lockBusy = attempts
+}
+
 func (m *MockSQLStorage) Unlock(_ context.Context) error {
+	m.unlockCalls++
 	return nil
 }
 
+func (m *MockSQLStorage) AcquirePersistentLock(_ context.Context, key, owner string, ttl time.Duration) error {
+	now := time.Now()
+	if lock, ok := m.locks[key]; ok && lock.Owner != owner &&
+		(lock.ExpiresAt.IsZero() || lock.ExpiresAt.After(now)) {
+		return lockHeldError(lock)
+	}
+
+	lock := PersistentLock{Key: key, Owner: owner, AcquiredAt: now}
+	if ttl > 0 {
+		lock.ExpiresAt = now.Add(ttl)
+	}
+	m.locks[key] = lock
+	return nil
+}
+
+func (m *MockSQLStorage) ReleasePersistentLock(_ context.Context, key string) error {
+	delete(m.locks, key)
+	return nil
+}
+
+func (m *MockSQLStorage) InsertTag(_ context.Context, tag ReleaseTag) error {
+	tag.CreatedAt = time.Now()
+	m.tags = append(m.tags, tag)
+	return nil
+}
+
+func (m *MockSQLStorage) LatestTag(_ context.Context) (ReleaseTag, error) {
+	if len(m.tags) == 0 {
+		return ReleaseTag{}, ErrTagNotFound
+	}
+	return m.tags[len(m.tags)-1], nil
+}
+
+// LockCalls возвращает количество вызовов Lock.
+func (m *MockSQLStorage) LockCalls() int {
+	return m.lockCalls
+}
+
+// UnlockCalls возвращает количество вызовов Unlock.
+func (m *MockSQLStorage) UnlockCalls() int {
+	return m.unlockCalls
+}
+
+func (m *MockSQLStorage) SetRole(_ context.Context, role string) error {
+	if err := ValidateRole(role); err != nil {
+		return err
+	}
+	m.roles = append(m.roles, "SET ROLE "+strings.ToLower(role))
+	return nil
+}
+
+func (m *MockSQLStorage) ResetRole(_ context.Context) error {
+	m.roles = append(m.roles, "RESET ROLE")
+	return nil
+}
+
+func (m *MockSQLStorage) SetStatementTimeout(_ context.Context, timeout time.Duration) error {
+	m.session = append(m.session, statementTimeoutSQL(timeout))
+	m.timeout = fmt.Sprintf("%dms", timeout.Milliseconds())
+	return nil
+}
+
+func (m *MockSQLStorage) ResetStatementTimeout(_ context.Context) error {
+	m.session = append(m.session, "RESET statement_timeout;")
+	m.timeout = ""
+	return nil
+}
+
+// StatementTimeout возвращает значение, заданное последней командой
+// statement_timeout; без неё — "0", как у сервера по умолчанию.
+func (m *MockSQLStorage) StatementTimeout(_ context.Context) (string, error) {
+	if m.timeout == "" {
+		return "0", nil
+	}
+	return m.timeout, nil
+}
+
+func (m *MockSQLStorage) RestoreStatementTimeout(_ context.Context, value string) error {
+	m.session = append(m.session, restoreStatementTimeoutSQL(value))
+	m.timeout = value
+	return nil
+}
+
+func (m *MockSQLStorage) ExecSessionStatement(_ context.Context, statement string) error {
+	if err := ValidateSessionStatement(statement); err != nil {
+		return err
+	}
+	m.session = append(m.session, statement)
+	match := regSessionStatement.FindStringSubmatch(statement)
+	if sessionSettingName(match) == "statement_timeout" {
+		m.timeout = strings.Trim(match[2], "'")
+	}
+	return nil
+}
+
+// SessionStatements возвращает выполненные команды настройки сессии:
+// SET/RESET statement_timeout и ExecSessionStatement.
+func (m *MockSQLStorage) SessionStatements() []string {
+	return m.session
+}
+
+// SetMissingPrivileges задаёт привилегии, которых якобы не хватает роли.
+func (m *MockSQLStorage) SetMissingPrivileges(privileges ...string) {
+	m.missing = privileges
+}
+
+func (m *MockSQLStorage) MissingPrivileges(_ context.Context) ([]string, error) {
+	return m.missing, nil
+}
+
+// RoleStatements возвращает выполненные команды SET ROLE/RESET ROLE.
+func (m *MockSQLStorage) RoleStatements() []string {
+	return m.roles
+}
+
 func (m *MockSQLStorage) InsertMigration(ctx context.Context, migration IMigration) error {
+	if m.xactHeld {
+		m.xactStatuses = append(m.xactStatuses, fmt.Sprintf("%d %s", migration.GetVersion(), migration.GetStatus()))
+	}
 	for _, m := range m.migrations {
 		if m.GetVersion() == migration.GetVersion() && m.GetName() == migration.GetName() {
 			m.SetStatus(migration.GetStatus())
 			m.SetStatusChangeTime(migration.GetStatusChangeTime())
 			m.SetVersion(migration.GetVersion())
 			m.SetName(migration.GetName())
+			m.SetLabels(migration.GetLabels())
+			m.SetSourceFile(migration.GetSourceFile())
+			m.SetChecksum(migration.GetChecksum())
+			m.SetUpSQL(migration.GetUpSQL())
+			m.SetRowsAffected(migration.GetRowsAffected())
+			m.SetAuthor(migration.GetAuthor())
 			return nil
 		}
 	}
@@ -56,27 +259,332 @@ func (m *MockSQLStorage) UpdateMigration(ctx context.Context, migration IMigrati
 	return errors.New("migration not found")
 }
 
-func (m *MockSQLStorage) Migrate(ctx context.Context, sql string) error {
+// Migrate записывает SQL в ExecutedSQL и возвращает число строк,
+// заданное для него SetAffectedRows.
+func (m *MockSQLStorage) Migrate(ctx context.Context, sql string) (int64, error) {
+	if m.xactHeld {
+		m.xactLocked = append(m.xactLocked, sql)
+	}
+	m.executed = append(m.executed, sql)
+	return m.affected[sql], nil
+}
+
+// SetAffectedRows задаёт число строк, которое Migrate вернёт для sql.
+func (m *MockSQLStorage) SetAffectedRows(sql string, rows int64) {
+	if m.affected == nil {
+		m.affected = make(map[string]int64)
+	}
+	m.affected[sql] = rows
+}
+
+// XactLock отмечает в журнале транзакции взятие блокировки; до конца
+// транзакции Migrate и InsertMigration запоминают, что выполнялись под ней.
+func (m *MockSQLStorage) XactLock(_ context.Context) error {
+	if !m.inTx {
+		return ErrNoTransaction
+	}
+	m.txLog = append(m.txLog, "XACT LOCK")
+	m.xactHeld = true
 	return nil
 }
 
+// TryXactLock возвращает ErrLockBusy, пока не исчерпаны попытки, заданные
+// SetLockBusy, а затем берёт блокировку как XactLock.
+func (m *MockSQLStorage) TryXactLock(ctx context.Context) error {
+	if !m.inTx {
+		return ErrNoTransaction
+	}
+	if m.lockBusy > 0 {
+		m.lockBusy--
+		return ErrLockBusy
+	}
+	return m.XactLock(ctx)
+}
+
+// XactLockedSQL возвращает SQL, выполненный через Migrate под XactLock.
+func (m *MockSQLStorage) XactLockedSQL() []string {
+	return m.xactLocked
+}
+
+// XactLockedStatuses возвращает записи InsertMigration, сделанные
+// под XactLock, в виде "версия статус".
+func (m *MockSQLStorage) XactLockedStatuses() []string {
+	return m.xactStatuses
+}
+
+// SetExistingObjects задаёт таблицы и столбцы, которые ObjectExists считает
+// существующими, в виде "users", "public.users" или "users.email".
+func (m *MockSQLStorage) SetExistingObjects(names ...string) {
+	m.existing = make(map[string]bool, len(names))
+	for _, name := range names {
+		m.existing[name] = true
+	}
+}
+
+func (m *MockSQLStorage) ObjectExists(_ context.Context, object SchemaObject) (bool, error) {
+	return m.existing[object.String()], nil
+}
+
+// SetStatementError задаёт ошибку, с которой MigrateParallel завершит команду sql.
+func (m *MockSQLStorage) SetStatementError(sql string, err error) {
+	if m.failing == nil {
+		m.failing = make(map[string]error)
+	}
+	m.failing[sql] = err
+}
+
+// MigrateParallel выполняет команды в пуле горутин так же, как хранилище
+// Postgres, и записывает их в ExecutedSQL в порядке завершения.
+func (m *MockSQLStorage) MigrateParallel(ctx context.Context, statements []string, workers int) error {
+	return runParallel(ctx, statements, workers, func(ctx context.Context, sql string) error {
+		m.parallel.Lock()
+		m.running++
+		if m.running > m.parallelMax {
+			m.parallelMax = m.running
+		}
+		m.parallel.Unlock()
+
+		// Даём другим командам начаться, чтобы они действительно шли одновременно.
+		time.Sleep(time.Millisecond)
+
+		m.parallel.Lock()
+		defer m.parallel.Unlock()
+		m.running--
+		if err := m.failing[sql]; err != nil {
+			return err
+		}
+		m.executed = append(m.executed, sql)
+		return nil
+	})
+}
+
+// MaxParallel возвращает наибольшее число команд MigrateParallel,
+// выполнявшихся одновременно.
+func (m *MockSQLStorage) MaxParallel() int {
+	return m.parallelMax
+}
+
+// SetReports задаёт результаты очередных вызовов QueryReport. После
+// исчерпания списка запросы возвращают пустой результат.
+func (m *MockSQLStorage) SetReports(reports ...Report) {
+	m.reports = reports
+}
+
+// QueryReport записывает SQL в ExecutedSQL и возвращает заданный результат.
+func (m *MockSQLStorage) QueryReport(ctx context.Context, sql string) (Report, error) {
+	m.executed = append(m.executed, sql)
+	if len(m.reports) == 0 {
+		return Report{}, nil
+	}
+	report := m.reports[0]
+	m.reports = m.reports[1:]
+	return report, nil
+}
+
+// SetBatchRows задаёт число строк, изменяемых очередными пакетами
+// MigrateBatches. После исчерпания списка пакеты не изменяют строк.
+func (m *MockSQLStorage) SetBatchRows(rows ...int64) {
+	m.batchRows = rows
+}
+
+// MigrateBatches записывает SQL каждого пакета в ExecutedSQL.
+func (m *MockSQLStorage) MigrateBatches(ctx context.Context, sql string, size int, progress BatchProgress) (int64, error) {
+	return runBatches(ctx, size, func(ctx context.Context) (int64, error) {
+		m.executed = append(m.executed, sql)
+		if len(m.batchRows) == 0 {
+			return 0, nil
+		}
+		rows := m.batchRows[0]
+		m.batchRows = m.batchRows[1:]
+		return rows, nil
+	}, progress)
+}
+
+// ExecutedSQL возвращает SQL, переданный в Migrate, в порядке выполнения.
+func (m *MockSQLStorage) ExecutedSQL() []string {
+	return m.executed
+}
+
+// Begin, Commit, Rollback, Savepoint и RollbackToSavepoint ведут журнал
+// команд транзакции; откат удаляет из ExecutedSQL выполненный после
+// соответствующей точки SQL, имитируя отмену изменений.
+func (m *MockSQLStorage) Begin(_ context.Context) error {
+	m.txLog = append(m.txLog, "BEGIN")
+	m.inTx = true
+	m.txStart = len(m.executed)
+	return nil
+}
+
+func (m *MockSQLStorage) Commit(_ context.Context) error {
+	if !m.inTx {
+		return ErrNoTransaction
+	}
+	m.txLog = append(m.txLog, "COMMIT")
+	m.inTx, m.xactHeld = false, false
+	m.savepoints = make(map[string]int)
+	return nil
+}
+
+func (m *MockSQLStorage) Rollback(_ context.Context) error {
+	if !m.inTx {
+		return ErrNoTransaction
+	}
+	m.txLog = append(m.txLog, "ROLLBACK")
+	m.executed = m.executed[:m.txStart]
+	m.inTx, m.xactHeld = false, false
+	m.savepoints = make(map[string]int)
+	return nil
+}
+
+func (m *MockSQLStorage) Savepoint(_ context.Context, name string) error {
+	if !m.inTx {
+		return ErrNoTransaction
+	}
+	m.txLog = append(m.txLog, "SAVEPOINT "+name)
+	m.savepoints[name] = len(m.executed)
+	return nil
+}
+
+func (m *MockSQLStorage) RollbackToSavepoint(_ context.Context, name string) error {
+	mark, ok := m.savepoints[name]
+	if !m.inTx || !ok {
+		return ErrNoTransaction
+	}
+	m.txLog = append(m.txLog, "ROLLBACK TO SAVEPOINT "+name)
+	m.executed = m.executed[:mark]
+	return nil
+}
+
+// WithTx выполняет fn между Begin и Commit, а при ошибке fn откатывает
+// транзакцию. Контекст fn несёт Executor, как у ContextWithTx.
+func (m *MockSQLStorage) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := m.Begin(ctx); err != nil {
+		return err
+	}
+	if err := fn(m.ContextWithTx(ctx)); err != nil {
+		_ = m.Rollback(ctx)
+		return err
+	}
+	return m.Commit(ctx)
+}
+
+// ContextWithTx внутри транзакции возвращает ctx с Executor, который
+// выполняет Exec через Migrate; вне транзакции ctx не меняется.
+func (m *MockSQLStorage) ContextWithTx(ctx context.Context) context.Context {
+	if !m.inTx {
+		return ctx
+	}
+	return ContextWithExecutor(ctx, mockExecutor{m})
+}
+
+// mockExecutor — Executor транзакции MockSQLStorage. Запросы с результатом
+// в тестах не нужны и не поддерживаются.
+type mockExecutor struct {
+	storage *MockSQLStorage
+}
+
+func (e mockExecutor) Exec(ctx context.Context, sql string, _ ...interface{}) (pgconn.CommandTag, error) {
+	_, err := e.storage.Migrate(ctx, sql)
+	return nil, err
+}
+
+func (e mockExecutor) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	return nil, errMockQuery
+}
+
+func (e mockExecutor) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	return mockErrRow{}
+}
+
+var errMockQuery = errors.New("mock executor does not support queries")
+
+type mockErrRow struct{}
+
+func (mockErrRow) Scan(...interface{}) error {
+	return errMockQuery
+}
+
+// SetSchemaSnapshot задаёт схему, которую возвращает SchemaSnapshot.
+func (m *MockSQLStorage) SetSchemaSnapshot(snapshot SchemaSnapshot) {
+	m.schema = snapshot
+}
+
+func (m *MockSQLStorage) SchemaSnapshot(_ context.Context) (SchemaSnapshot, error) {
+	return m.schema, nil
+}
+
+// TxLog возвращает выполненные команды управления транзакциями.
+func (m *MockSQLStorage) TxLog() []string {
+	return m.txLog
+}
+
+func (m *MockSQLStorage) Analyze(_ context.Context, tables []string, vacuum bool) error {
+	m.analyzed = append(m.analyzed, analyzeStatement(tables, vacuum))
+	return nil
+}
+
+// AnalyzeStatements возвращает команды ANALYZE, которые выполнил бы Postgres.
+func (m *MockSQLStorage) AnalyzeStatements() []string {
+	return m.analyzed
+}
+
 func (m *MockSQLStorage) SelectMigrations(ctx context.Context) ([]IMigration, error) {
 	if len(m.migrations) == 0 {
-		return nil, errors.New("no migrations found")
+		return nil, ErrMigrationNotFound
 	}
 	return m.migrations, nil
 }
 
+func (m *MockSQLStorage) SelectAppliedVersions(ctx context.Context) (map[int]string, error) {
+	statuses := make(map[int]string, len(m.migrations))
+	for _, migration := range m.migrations {
+		statuses[migration.GetVersion()] = migration.GetStatus()
+	}
+	return statuses, nil
+}
+
+func (m *MockSQLStorage) CurrentVersion(ctx context.Context) (int, error) {
+	version := 0
+	for _, migration := range m.migrations {
+		if migration.GetStatus() == StatusSuccess && migration.GetVersion() > version {
+			version = migration.GetVersion()
+		}
+	}
+	return version, nil
+}
+
 func (m *MockSQLStorage) SelectLastMigrationByStatus(ctx context.Context, status string) (IMigration, error) {
 	for i := len(m.migrations) - 1; i >= 0; i-- {
 		if m.migrations[i].GetStatus() == status {
 			return m.migrations[i], nil
 		}
 	}
-	return nil, errors.New("no migrations found with status " + status)
+	return nil, ErrMigrationNotFound
 }
 
 func (m *MockSQLStorage) DeleteMigrations(ctx context.Context) error {
 	m.migrations = []IMigration{}
 	return nil
 }
+
+func (m *MockSQLStorage) DeleteMigration(ctx context.Context, version int) error {
+	kept := m.migrations[:0]
+	for _, migration := range m.migrations {
+		if migration.GetVersion() != version {
+			kept = append(kept, migration)
+		}
+	}
+	m.migrations = kept
+	return nil
+}
+
+func (m *MockSQLStorage) DropTrackingTable(ctx context.Context) error {
+	m.migrations = []IMigration{}
+	m.tableDrops++
+	return nil
+}
+
+// TrackingTableDropped сообщает, удалялась ли служебная таблица.
+func (m *MockSQLStorage) TrackingTableDropped() bool {
+	return m.tableDrops > 0
+}
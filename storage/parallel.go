@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// runParallel выполняет statements не более чем workers одновременно. После
+// первой ошибки новые команды не запускаются, а уже запущенным отменяется
+// контекст. Возвращаются ошибки всех упавших команд, кроме вызванных этой
+// отменой, с номером команды (с 1).
+func runParallel(ctx context.Context, statements []string, workers int, exec func(ctx context.Context, sql string) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type job struct {
+		index int
+		sql   string
+	}
+	jobs := make(chan job)
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				err := exec(runCtx, job.sql)
+				if err == nil || (runCtx.Err() != nil && ctx.Err() == nil && errors.Is(err, context.Canceled)) {
+					continue
+				}
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("statement %d: %w", job.index+1, err))
+				mu.Unlock()
+				cancel()
+			}
+		}()
+	}
+
+send:
+	for i, sql := range statements {
+		select {
+		case <-runCtx.Done():
+			break send
+		case jobs <- job{index: i, sql: sql}:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return ctx.Err()
+}
+
+// MigrateParallel выполняет независимые команды миграции, например
+// CREATE INDEX CONCURRENTLY, одновременно на workers отдельных соединениях
+// вне транзакции. Соединения открываются только на время вызова, и каждое
+// сразу настраивается как основная сессия: роль -run-as, statement_timeout
+// и параметры -pre-lock-statement (см. sessionStatements).
+func (storage *PostgresStorage) MigrateParallel(ctx context.Context, statements []string, workers int) error {
+	storage.logger.Info("Executing %d migration statements on up to %d connections", len(statements), workers)
+
+	config, err := pgxpool.ParseConfig(storage.connString)
+	if err != nil {
+		storage.logger.Error("Failed to parse connection string: %v", err)
+		return err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	config.MaxConns = int32(workers)
+	storage.applyApplicationName(config.ConnConfig)
+	session := storage.sessionStatements()
+	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		return configureSession(ctx, conn, session)
+	}
+
+	pool, err := pgxpool.ConnectConfig(ctx, config)
+	if err != nil {
+		storage.logger.Error("Failed to connect to the database: %v", err)
+		return err
+	}
+	defer pool.Close()
+
+	err = runParallel(ctx, statements, workers, func(ctx context.Context, sql string) error {
+		_, err := pool.Exec(ctx, sql)
+		return err
+	})
+	if err != nil {
+		storage.logger.Error("Failed to execute migration statements: %v", err)
+	}
+	return err
+}
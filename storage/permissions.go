@@ -0,0 +1,42 @@
+package storage
+
+import "context"
+
+// MissingPrivileges возвращает привилегии, которых не хватает текущей роли
+// для выполнения миграций: CREATE на схему служебной таблицы и INSERT и UPDATE
+// на саму таблицу. Если таблицы ещё нет, её права не проверяются: таблицу
+// создаст сама роль при наличии CREATE на схему.
+func (storage *PostgresStorage) MissingPrivileges(ctx context.Context) ([]string, error) {
+	storage.logger.Info("Checking privileges of the current role")
+
+	table := storage.trackingTable()
+	tableSchema, _ := splitTrackingTable(table)
+
+	var (
+		schema                      string
+		canCreate, canInsert, canUp bool
+	)
+	err := storage.db().QueryRow(ctx, `
+		SELECT COALESCE(NULLIF($1, ''), current_schema()),
+			has_schema_privilege(COALESCE(NULLIF($1, ''), current_schema()), 'CREATE'),
+			to_regclass($2::text) IS NULL OR has_table_privilege($2::text, 'INSERT'),
+			to_regclass($2::text) IS NULL OR has_table_privilege($2::text, 'UPDATE');`,
+		tableSchema, storage.quotedTrackingTable(),
+	).Scan(&schema, &canCreate, &canInsert, &canUp)
+	if err != nil {
+		storage.logger.Error("Failed to check privileges: %v", err)
+		return nil, err
+	}
+
+	var missing []string
+	if !canCreate {
+		missing = append(missing, "CREATE on schema "+schema)
+	}
+	if !canInsert {
+		missing = append(missing, "INSERT on table "+table)
+	}
+	if !canUp {
+		missing = append(missing, "UPDATE on table "+table)
+	}
+	return missing, nil
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// sqlDriver — диалект SQL, от которого зависит квотирование идентификаторов.
+type sqlDriver string
+
+const (
+	driverPostgres sqlDriver = "postgres"
+	driverMySQL    sqlDriver = "mysql"
+)
+
+// quoteIdentifier заключает в кавычки имя таблицы, возможно с именем схемы
+// ("audit.migrations"), по правилам драйвера: в Postgres двойные кавычки,
+// в MySQL обратные. Каждая часть имени квотируется отдельно, а кавычки внутри
+// неё удваиваются, поэтому имена в смешанном регистре и зарезервированные
+// слова подставляются в SQL как есть.
+func quoteIdentifier(driver sqlDriver, name string) string {
+	parts := strings.Split(name, ".")
+	if driver == driverMySQL {
+		for i, part := range parts {
+			parts[i] = "`" + strings.ReplaceAll(part, "`", "``") + "`"
+		}
+		return strings.Join(parts, ".")
+	}
+	return pgx.Identifier(parts).Sanitize()
+}
+
+// quotedTrackingTable возвращает имя служебной таблицы, готовое для
+// подстановки в SQL.
+func (storage *PostgresStorage) quotedTrackingTable() string {
+	return quoteIdentifier(driverPostgres, storage.trackingTable())
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		postgres string
+		mysql    string
+	}{
+		{"schema_migrations", `"schema_migrations"`, "`schema_migrations`"},
+		{"audit.Migrations", `"audit"."Migrations"`, "`audit`.`Migrations`"},
+		{"order", `"order"`, "`order`"},
+		{`odd"name`, `"odd""name"`, "`odd\"name`"},
+		{"odd`name", "\"odd`name\"", "`odd``name`"},
+	} {
+		assert.Equal(t, c.postgres, quoteIdentifier(driverPostgres, c.name), c.name)
+		assert.Equal(t, c.mysql, quoteIdentifier(driverMySQL, c.name), c.name)
+	}
+}
+
+func TestTrackingTableQuotedInSQL(t *testing.T) {
+	storage := NewPostgresStorage("", nil)
+	require.NoError(t, storage.SetTrackingTable("Audit.order"))
+
+	assert.Equal(t, "Audit.order", storage.trackingTable())
+	assert.Contains(t, createTrackingTableSQL(storage.quotedTrackingTable()), `CREATE TABLE IF NOT EXISTS "Audit"."order" (`)
+	assert.Equal(t, []string{`ALTER TABLE "Audit"."order" ADD COLUMN IF NOT EXISTS author TEXT;`},
+		missingColumnStatements(storage.quotedTrackingTable(), []string{"version", "name", "status", "statuschangetime", "labels", "source_file", "checksum", "up_sql", "rows_affected"}))
+}
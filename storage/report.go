@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// Report — результат запроса, помеченного директивой report: имена столбцов
+// и значения строк, уже приведённые к тексту.
+type Report struct {
+	Columns []string
+	Rows    [][]string
+}
+
+// QueryReport выполняет запрос миграции и возвращает его результат, чтобы
+// мигратор вывел его в журнал. NULL выводится как "NULL".
+func (storage *PostgresStorage) QueryReport(ctx context.Context, sql string) (Report, error) {
+	storage.logger.Info("Executing migration report query")
+
+	rows, err := storage.db().Query(ctx, sql)
+	if err != nil {
+		storage.logger.Error("Failed to execute migration report query: %v", err)
+		return Report{}, err
+	}
+	defer rows.Close()
+
+	var report Report
+	for _, field := range rows.FieldDescriptions() {
+		report.Columns = append(report.Columns, string(field.Name))
+	}
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			storage.logger.Error("Failed to read migration report row: %v", err)
+			return Report{}, err
+		}
+		row := make([]string, len(values))
+		for i, value := range values {
+			if value == nil {
+				row[i] = "NULL"
+				continue
+			}
+			row[i] = fmt.Sprint(value)
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		storage.logger.Error("Failed to execute migration report query: %v", err)
+		return Report{}, err
+	}
+	return report, nil
+}
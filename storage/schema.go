@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+// DefaultTrackingTable — служебная таблица мигратора по умолчанию.
+const DefaultTrackingTable = "schema_migrations"
+
+var (
+	ErrInvalidTable = errors.New("invalid tracking table name")
+
+	regTableName = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_$]{0,62}\.)?[A-Za-z_][A-Za-z0-9_$]{0,62}$`)
+)
+
+// ValidateTrackingTable проверяет имя служебной таблицы: идентификатор Postgres,
+// возможно с именем схемы ("audit.migrations"). Имя подставляется в SQL
+// в кавычках (см. quoteIdentifier) и сохраняет регистр, но кавычки, пробелы
+// и другие символы не допускаются.
+func ValidateTrackingTable(table string) error {
+	if !regTableName.MatchString(table) {
+		return fmt.Errorf("%w: %q", ErrInvalidTable, table)
+	}
+	return nil
+}
+
+// SetTrackingTable задаёт служебную таблицу, в которой хранятся записи
+// о миграциях, вместо schema_migrations.
+func (storage *PostgresStorage) SetTrackingTable(table string) error {
+	if err := ValidateTrackingTable(table); err != nil {
+		return err
+	}
+	storage.table = table
+	return nil
+}
+
+// trackingTable возвращает имя служебной таблицы без кавычек: для сообщений
+// и параметров запросов к information_schema. В SQL подставляется
+// quotedTrackingTable.
+func (storage *PostgresStorage) trackingTable() string {
+	if storage.table == "" {
+		return DefaultTrackingTable
+	}
+	return storage.table
+}
+
+// splitTrackingTable делит имя служебной таблицы на схему и имя таблицы.
+// Пустая схема означает текущую схему.
+func splitTrackingTable(table string) (schema, name string) {
+	if schema, name, found := strings.Cut(table, "."); found {
+		return schema, name
+	}
+	return "", table
+}
+
+// trackingColumn описывает колонку служебной таблицы.
+type trackingColumn struct {
+	name       string
+	definition string
+}
+
+// trackingColumns — актуальная схема служебной таблицы. Новые колонки
+// добавляются в конец списка: при подключении недостающие колонки
+// создаются в существующей таблице через ALTER TABLE ... ADD COLUMN.
+var trackingColumns = []trackingColumn{
+	{name: "version", definition: "INTEGER PRIMARY KEY"},
+	{name: "name", definition: "CHARACTER VARYING(100)"},
+	{name: "status", definition: "CHARACTER VARYING(20)"},
+	{name: "statuschangetime", definition: "TIMESTAMP"},
+	{name: "labels", definition: "TEXT[]"},
+	{name: "source_file", definition: "TEXT"},
+	{name: "checksum", definition: "TEXT"},
+	{name: "up_sql", definition: "TEXT"},
+	{name: "rows_affected", definition: "BIGINT"},
+	{name: "author", definition: "TEXT"},
+}
+
+// ErrTrackingTableMissing возвращается, когда автосоздание служебной таблицы
+// отключено, а таблицы нет или она устарела.
+var ErrTrackingTableMissing = errors.New("tracking table is missing or outdated")
+
+// ErrTrackingTableAbsent возвращается, когда служебной таблицы нет вовсе:
+// в базе ещё не запускалась ни одна миграция.
+var ErrTrackingTableAbsent = errors.New("tracking table not initialized")
+
+// sqlStateUndefinedTable — код SQLSTATE обращения к несуществующей таблице.
+const sqlStateUndefinedTable = "42P01"
+
+// IsTrackingTableAbsent сообщает, вызвана ли ошибка отсутствием служебной
+// таблицы: при подключении без автосоздания или при запросе к ней.
+func IsTrackingTableAbsent(err error) bool {
+	if errors.Is(err, ErrTrackingTableAbsent) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == sqlStateUndefinedTable
+}
+
+// SetAutoCreateTable управляет созданием и обновлением служебной таблицы
+// при подключении. Если оно отключено, таблицу заранее создаёт администратор.
+func (storage *PostgresStorage) SetAutoCreateTable(enabled bool) {
+	storage.noAutoCreateTable = !enabled
+}
+
+// trackingTableError формирует ошибку с точным DDL, который нужно выполнить
+// администратору базы данных.
+func trackingTableError(table string, statements []string) error {
+	return fmt.Errorf("%w: %s must be provisioned before running migrations; run as a privileged role:\n%s",
+		ErrTrackingTableMissing, table, strings.Join(statements, "\n"))
+}
+
+// SetForceRecreateTable включает пересоздание служебной таблицы при подключении.
+func (storage *PostgresStorage) SetForceRecreateTable(force bool) {
+	storage.forceRecreateTable = force
+}
+
+func createTrackingTableSQL(table string) string {
+	columns := make([]string, 0, len(trackingColumns))
+	for _, column := range trackingColumns {
+		columns = append(columns, column.name+" "+column.definition)
+	}
+	return "CREATE TABLE IF NOT EXISTS " + table + " (\n\t" + strings.Join(columns, ",\n\t") + "\n);"
+}
+
+// missingColumnStatements возвращает ALTER TABLE для колонок актуальной схемы,
+// отсутствующих среди existing.
+func missingColumnStatements(table string, existing []string) []string {
+	present := make(map[string]bool, len(existing))
+	for _, column := range existing {
+		present[strings.ToLower(column)] = true
+	}
+
+	var statements []string
+	for _, column := range trackingColumns {
+		if present[column.name] {
+			continue
+		}
+		definition := strings.TrimSuffix(column.definition, " PRIMARY KEY")
+		statements = append(statements,
+			"ALTER TABLE "+table+" ADD COLUMN IF NOT EXISTS "+column.name+" "+definition+";")
+	}
+	return statements
+}
+
+// ensureSchema создаёт служебную таблицу, если её нет, и дополняет
+// таблицу, созданную предыдущей версией мигратора, недостающими колонками.
+func (storage *PostgresStorage) ensureSchema(ctx context.Context) error {
+	table := storage.trackingTable()
+	// На реплике DDL невозможен даже для CREATE TABLE IF NOT EXISTS.
+	if storage.noAutoCreateTable || storage.inRecovery {
+		return storage.checkSchema(ctx)
+	}
+
+	if _, err := storage.pool.Exec(ctx, createTrackingTableSQL(storage.quotedTrackingTable())); err != nil {
+		storage.logger.Error("Failed to create %s table: %v", table, err)
+		return err
+	}
+
+	existing, err := storage.trackingTableColumns(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range missingColumnStatements(storage.quotedTrackingTable(), existing) {
+		storage.logger.Info("Upgrading %s table: %s", table, statement)
+		if _, err := storage.pool.Exec(ctx, statement); err != nil {
+			storage.logger.Error("Failed to upgrade %s table: %v", table, err)
+			return err
+		}
+	}
+
+	if storage.forceRecreateTable {
+		return storage.recreateTable(ctx)
+	}
+	return nil
+}
+
+// checkSchema проверяет служебную таблицу, не выполняя DDL: роль мигратора
+// может не иметь прав на создание таблиц.
+func (storage *PostgresStorage) checkSchema(ctx context.Context) error {
+	table := storage.trackingTable()
+	existing, err := storage.trackingTableColumns(ctx)
+	if err != nil {
+		return err
+	}
+
+	var statements []string
+	if len(existing) == 0 {
+		statements = []string{createTrackingTableSQL(storage.quotedTrackingTable())}
+	} else {
+		statements = missingColumnStatements(storage.quotedTrackingTable(), existing)
+	}
+	if len(statements) == 0 {
+		return nil
+	}
+
+	err = trackingTableError(table, statements)
+	if len(existing) == 0 {
+		err = fmt.Errorf("%w: %w", ErrTrackingTableAbsent, err)
+	}
+	storage.logger.Error("%v", err)
+	return err
+}
+
+// DropTrackingTable удаляет служебную таблицу вместе со всеми записями
+// о миграциях. Следующее подключение создаст её заново.
+func (storage *PostgresStorage) DropTrackingTable(ctx context.Context) error {
+	table := storage.trackingTable()
+	storage.logger.Warn("Dropping %s table", table)
+
+	if _, err := storage.db().Exec(ctx, "DROP TABLE IF EXISTS "+storage.quotedTrackingTable()+";"); err != nil {
+		storage.logger.Error("Failed to drop %s table: %v", table, err)
+		return err
+	}
+	return nil
+}
+
+// trackingTableColumns возвращает колонки служебной таблицы
+// или пустой список, если таблицы нет.
+func (storage *PostgresStorage) trackingTableColumns(ctx context.Context) ([]string, error) {
+	table := storage.trackingTable()
+	schema, name := splitTrackingTable(table)
+
+	rows, err := storage.pool.Query(ctx,
+		`SELECT column_name FROM information_schema.columns
+		WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2;`,
+		schema, name)
+	if err != nil {
+		storage.logger.Error("Failed to inspect %s table: %v", table, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var existing []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			storage.logger.Error("Failed to inspect %s table: %v", table, err)
+			return nil, err
+		}
+		existing = append(existing, column)
+	}
+	if err := rows.Err(); err != nil {
+		storage.logger.Error("Failed to inspect %s table: %v", table, err)
+		return nil, err
+	}
+	return existing, nil
+}
+
+// recreateTable пересоздаёт служебную таблицу по актуальной схеме,
+// перенося в неё записи, прочитанные через SelectMigrations. Используется,
+// когда добавления колонок недостаточно (например, изменился тип колонки).
+func (storage *PostgresStorage) recreateTable(ctx context.Context) error {
+	table := storage.trackingTable()
+	storage.logger.Warn("Recreating %s table", table)
+
+	migrations, err := storage.SelectMigrations(ctx)
+	if err != nil && !errors.Is(err, ErrMigrationNotFound) {
+		return err
+	}
+
+	tx, err := storage.pool.Begin(ctx)
+	if err != nil {
+		storage.logger.Error("Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DROP TABLE "+storage.quotedTrackingTable()+";"); err != nil {
+		storage.logger.Error("Failed to drop %s table: %v", table, err)
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, createTrackingTableSQL(storage.quotedTrackingTable())); err != nil {
+		storage.logger.Error("Failed to create %s table: %v", table, err)
+		return err
+	}
+
+	for _, migration := range migrations {
+		_, err := tx.Exec(ctx, upsertMigrationSQL(storage.quotedTrackingTable()),
+			migration.GetVersion(), migration.GetName(), migration.GetStatus(), migration.GetStatusChangeTime(),
+			migration.GetLabels(), migration.GetSourceFile(), migration.GetChecksum(), migration.GetUpSQL(),
+			migration.GetRowsAffected(), migration.GetAuthor())
+		if err != nil {
+			storage.logger.Error("Failed to restore migration %d: %v", migration.GetVersion(), err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		storage.logger.Error("Failed to commit recreated %s table: %v", table, err)
+		return err
+	}
+
+	storage.logger.Info("%s table recreated with %d migrations", table, len(migrations))
+	return nil
+}
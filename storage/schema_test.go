@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissingColumnStatementsUpToDate(t *testing.T) {
+	existing := make([]string, 0, len(trackingColumns))
+	for _, column := range trackingColumns {
+		existing = append(existing, column.name)
+	}
+	assert.Empty(t, missingColumnStatements("schema_migrations", existing))
+}
+
+func TestMissingColumnStatementsAddsNewColumns(t *testing.T) {
+	// Таблица, созданная старой версией мигратора без колонок statuschangetime, labels, source_file, checksum, up_sql, rows_affected и author.
+	existing := []string{"Version", "Name", "Status"}
+
+	assert.Equal(t, []string{
+		"ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS statuschangetime TIMESTAMP;",
+		"ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS labels TEXT[];",
+		"ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS source_file TEXT;",
+		"ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS checksum TEXT;",
+		"ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS up_sql TEXT;",
+		"ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS rows_affected BIGINT;",
+		"ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS author TEXT;",
+	}, missingColumnStatements("schema_migrations", existing))
+}
+
+func TestMissingColumnStatementsNeverAddsPrimaryKey(t *testing.T) {
+	statements := missingColumnStatements("schema_migrations", nil)
+
+	assert.Len(t, statements, len(trackingColumns))
+	for _, statement := range statements {
+		assert.NotContains(t, statement, "PRIMARY KEY")
+	}
+}
+
+func TestCreateTrackingTableSQL(t *testing.T) {
+	sql := createTrackingTableSQL("schema_migrations")
+
+	assert.Contains(t, sql, "CREATE TABLE IF NOT EXISTS schema_migrations (")
+	for _, column := range trackingColumns {
+		assert.Contains(t, sql, column.name+" "+column.definition)
+	}
+}
+
+func TestTrackingTableErrorIncludesDDL(t *testing.T) {
+	ddl := createTrackingTableSQL("schema_migrations")
+	err := trackingTableError("schema_migrations", []string{ddl})
+
+	assert.ErrorIs(t, err, ErrTrackingTableMissing)
+	assert.Contains(t, err.Error(), "schema_migrations must be provisioned before running migrations")
+	assert.Contains(t, err.Error(), "CREATE TABLE IF NOT EXISTS schema_migrations (\n\tversion INTEGER PRIMARY KEY,")
+}
+
+func TestSetTrackingTable(t *testing.T) {
+	storage := NewPostgresStorage("", nil)
+	assert.Equal(t, DefaultTrackingTable, storage.trackingTable())
+
+	assert.NoError(t, storage.SetTrackingTable("audit.custom_migrations"))
+	assert.Equal(t, "audit.custom_migrations", storage.trackingTable())
+	assert.Equal(t, `"audit"."custom_migrations"`, storage.quotedTrackingTable())
+	assert.Contains(t, upsertMigrationSQL(storage.quotedTrackingTable()), `INSERT INTO "audit"."custom_migrations" (Version,`)
+
+	schema, name := splitTrackingTable(storage.trackingTable())
+	assert.Equal(t, "audit", schema)
+	assert.Equal(t, "custom_migrations", name)
+
+	for _, table := range []string{"", "a.b.c", "migrations; DROP TABLE users", `"quoted"`, "my table"} {
+		assert.ErrorIs(t, storage.SetTrackingTable(table), ErrInvalidTable, table)
+	}
+	assert.Equal(t, "audit.custom_migrations", storage.trackingTable())
+}
+
+func TestIsTrackingTableAbsent(t *testing.T) {
+	absent := fmt.Errorf("%w: %w", ErrTrackingTableAbsent, trackingTableError("schema_migrations", nil))
+	assert.True(t, IsTrackingTableAbsent(absent))
+	assert.ErrorIs(t, absent, ErrTrackingTableMissing)
+	assert.True(t, IsTrackingTableAbsent(fmt.Errorf("select: %w", &pgconn.PgError{Code: "42P01"})))
+
+	assert.False(t, IsTrackingTableAbsent(nil))
+	assert.False(t, IsTrackingTableAbsent(trackingTableError("schema_migrations", nil)))
+	assert.False(t, IsTrackingTableAbsent(&pgconn.PgError{Code: "40P01"}))
+	assert.False(t, IsTrackingTableAbsent(errors.New("connection refused")))
+}
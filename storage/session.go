@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrInvalidSessionStatement возвращается для команды настройки сессии,
+// которая не является простым SET параметра.
+var ErrInvalidSessionStatement = errors.New("invalid session statement, expected SET name = value")
+
+// regSessionStatement допускает только SET [SESSION] параметр = значение
+// (или TO значение) с одним числом, словом или строкой в одинарных кавычках.
+// Группы: имя параметра и значение.
+var regSessionStatement = regexp.MustCompile(
+	`(?i)^\s*SET\s+(?:SESSION\s+)?([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)?)\s*(?:=|\s+TO\s+)\s*('[^';\\]*'|[A-Za-z0-9_.+-]+)\s*;?\s*$`)
+
+// ValidateSessionStatement проверяет, что statement — простой SET параметра
+// сессии, например "SET lock_timeout = '5s'". Роль меняется только через
+// -run-as, поэтому SET role и SET session_authorization не допускаются.
+func ValidateSessionStatement(statement string) error {
+	match := regSessionStatement.FindStringSubmatch(statement)
+	if match == nil {
+		return fmt.Errorf("%w: %q", ErrInvalidSessionStatement, statement)
+	}
+	switch sessionSettingName(match) {
+	case "role", "session_authorization":
+		return fmt.Errorf("%w: %q, use -run-as to switch roles", ErrInvalidSessionStatement, statement)
+	}
+	return nil
+}
+
+// ExecSessionStatement выполняет команду настройки сессии, прошедшую
+// ValidateSessionStatement.
+func (storage *PostgresStorage) ExecSessionStatement(ctx context.Context, statement string) error {
+	if err := ValidateSessionStatement(statement); err != nil {
+		storage.logger.Error("Failed to configure session: %v", err)
+		return err
+	}
+
+	storage.logger.Info("Configuring session: %s", statement)
+	_, err := storage.pool.Exec(ctx, statement)
+	if err != nil {
+		storage.logger.Error("Failed to configure session with %q: %v", statement, err)
+		return err
+	}
+	storage.rememberSetting(sessionSettingName(regSessionStatement.FindStringSubmatch(statement)), statement)
+	return nil
+}
+
+// sessionSettingName возвращает имя параметра из совпадения
+// regSessionStatement; имена параметров в Postgres не зависят от регистра.
+func sessionSettingName(match []string) string {
+	return strings.ToLower(match[1])
+}
+
+// sessionSetting — команда SET, задавшая параметр name основной сессии.
+type sessionSetting struct {
+	name      string
+	statement string
+}
+
+// rememberSetting запоминает statement как текущее значение параметра name,
+// заменяя прежнее; пустой statement означает сброс параметра.
+func (storage *PostgresStorage) rememberSetting(name, statement string) {
+	storage.session.Lock()
+	defer storage.session.Unlock()
+
+	settings := storage.settings[:0]
+	for _, setting := range storage.settings {
+		if setting.name != name {
+			settings = append(settings, setting)
+		}
+	}
+	if statement != "" {
+		settings = append(settings, sessionSetting{name: name, statement: statement})
+	}
+	storage.settings = settings
+}
+
+// sessionStatements возвращает команды, повторяющие на новом соединении
+// настройки основной сессии: SET ROLE из SetRole, затем параметры в порядке
+// установки.
+func (storage *PostgresStorage) sessionStatements() []string {
+	storage.session.Lock()
+	defer storage.session.Unlock()
+
+	var statements []string
+	if storage.role != "" {
+		statements = append(statements, `SET ROLE "`+storage.role+`";`)
+	}
+	for _, setting := range storage.settings {
+		statements = append(statements, setting.statement)
+	}
+	return statements
+}
+
+// sessionConn — соединение, на котором configureSession выполняет команды;
+// ему удовлетворяет *pgx.Conn.
+type sessionConn interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// configureConn повторяет настройки сессии на новом соединении основного
+// пула; это AfterConnect пула, открытого Connect.
+func (storage *PostgresStorage) configureConn(ctx context.Context, conn *pgx.Conn) error {
+	return configureSession(ctx, conn, storage.sessionStatements())
+}
+
+// configureSession выполняет statements на новом соединении conn. Вызывается
+// из AfterConnect пула, поэтому statements снимаются заранее через
+// sessionStatements, а не читаются из storage во время работы пула.
+func configureSession(ctx context.Context, conn sessionConn, statements []string) error {
+	for _, statement := range statements {
+		if _, err := conn.Exec(ctx, statement); err != nil {
+			return fmt.Errorf("configure session with %q: %w", statement, err)
+		}
+	}
+	return nil
+}
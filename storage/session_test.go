@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSessionStatement(t *testing.T) {
+	for _, statement := range []string{
+		"SET lock_timeout = '5s'",
+		"SET lock_timeout = '5s';",
+		"set idle_in_transaction_session_timeout to 60000",
+		"SET SESSION search_path TO app",
+		"SET app.tenant = 'acme'",
+	} {
+		assert.NoError(t, ValidateSessionStatement(statement), statement)
+	}
+
+	for _, statement := range []string{
+		"",
+		"SELECT 1",
+		"SET LOCAL lock_timeout = '5s'",
+		"SET lock_timeout = '5s'; DROP TABLE users",
+		"SET ROLE admin",
+		"SET role = 'admin'",
+		"SET SESSION AUTHORIZATION admin",
+		"RESET lock_timeout",
+	} {
+		assert.ErrorIs(t, ValidateSessionStatement(statement), ErrInvalidSessionStatement, statement)
+	}
+}
+
+// recordingConn запоминает команды, выполненные на соединении.
+type recordingConn struct {
+	statements []string
+}
+
+func (conn *recordingConn) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	conn.statements = append(conn.statements, sql)
+	return nil, nil
+}
+
+func TestSessionStatementsReplayedOnEveryConnection(t *testing.T) {
+	storage := &PostgresStorage{role: "deployer"}
+	storage.rememberSetting("lock_timeout", "SET lock_timeout = '5s'")
+	storage.rememberSetting("statement_timeout", statementTimeoutSQL(time.Minute))
+	storage.rememberSetting("lock_timeout", "SET lock_timeout = '10s'")
+	storage.rememberSetting("search_path", "SET search_path TO app")
+	storage.rememberSetting("search_path", "")
+
+	expected := []string{
+		`SET ROLE "deployer";`,
+		"SET statement_timeout = 60000;",
+		"SET lock_timeout = '10s'",
+	}
+	session := storage.sessionStatements()
+	assert.Equal(t, expected, session)
+
+	for worker := 0; worker < 3; worker++ {
+		conn := &recordingConn{}
+		assert.NoError(t, configureSession(context.Background(), conn, session))
+		assert.Equal(t, expected, conn.statements)
+	}
+}
+
+func TestSessionStatementsEmptyByDefault(t *testing.T) {
+	assert.Empty(t, (&PostgresStorage{}).sessionStatements())
+}
+
+func TestSessionStatementsFollowRole(t *testing.T) {
+	storage := NewPostgresStorage("", logger.New())
+	assert.NoError(t, storage.SetRole(context.Background(), "Deployer"))
+	storage.rememberSetting("statement_timeout", statementTimeoutSQL(time.Second))
+	assert.Equal(t, []string{`SET ROLE "deployer";`, "SET statement_timeout = 1000;"}, storage.sessionStatements(),
+		"A role set before Connect is replayed on every new connection")
+
+	storage.setRole("")
+	assert.Equal(t, []string{"SET statement_timeout = 1000;"}, storage.sessionStatements())
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaSnapshot — снимок схемы базы данных: для каждой таблицы её колонки
+// и их описания (тип и допустимость NULL).
+type SchemaSnapshot struct {
+	Tables map[string]map[string]string `json:"tables"`
+}
+
+// Виды расхождений между ожидаемой и фактической схемой.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// SchemaChange описывает одно расхождение схемы с ожидаемым снимком.
+type SchemaChange struct {
+	Kind string `json:"kind"`
+	// Object — "table users" или "column users.email".
+	Object string `json:"object"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (c SchemaChange) String() string {
+	if c.Detail == "" {
+		return c.Kind + " " + c.Object
+	}
+	return c.Kind + " " + c.Object + ": " + c.Detail
+}
+
+// DiffSchema сравнивает фактическую схему с ожидаемой и возвращает
+// расхождения, отсортированные по таблице и колонке.
+func DiffSchema(expected, actual SchemaSnapshot) []SchemaChange {
+	var changes []SchemaChange
+
+	for table, columns := range actual.Tables {
+		expectedColumns, ok := expected.Tables[table]
+		if !ok {
+			changes = append(changes, SchemaChange{Kind: ChangeAdded, Object: "table " + table})
+			continue
+		}
+		for column, definition := range columns {
+			object := "column " + table + "." + column
+			expectedDefinition, ok := expectedColumns[column]
+			switch {
+			case !ok:
+				changes = append(changes, SchemaChange{Kind: ChangeAdded, Object: object, Detail: definition})
+			case expectedDefinition != definition:
+				changes = append(changes, SchemaChange{Kind: ChangeChanged, Object: object,
+					Detail: expectedDefinition + " -> " + definition})
+			}
+		}
+		for column, definition := range expectedColumns {
+			if _, ok := columns[column]; !ok {
+				changes = append(changes, SchemaChange{Kind: ChangeRemoved,
+					Object: "column " + table + "." + column, Detail: definition})
+			}
+		}
+	}
+	for table := range expected.Tables {
+		if _, ok := actual.Tables[table]; !ok {
+			changes = append(changes, SchemaChange{Kind: ChangeRemoved, Object: "table " + table})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changeKey(changes[i]) < changeKey(changes[j]) })
+	return changes
+}
+
+// changeKey возвращает имя объекта без вида: таблица "users" оказывается
+// перед своими колонками "users.email".
+func changeKey(c SchemaChange) string {
+	_, name, _ := strings.Cut(c.Object, " ")
+	return name
+}
+
+// SchemaSnapshot читает таблицы и колонки текущей схемы из information_schema.
+// Служебные таблицы мигратора в снимок не входят.
+func (storage *PostgresStorage) SchemaSnapshot(ctx context.Context) (SchemaSnapshot, error) {
+	storage.logger.Info("Reading schema from information_schema")
+
+	_, trackingTable := splitTrackingTable(storage.trackingTable())
+	rows, err := storage.pool.Query(ctx, `
+		SELECT table_name, column_name, data_type, character_maximum_length, is_nullable
+		FROM information_schema.columns
+		WHERE table_schema = current_schema()
+			AND table_name NOT IN ($1, 'schema_migrations_lock', 'schema_migrations_tags')
+		ORDER BY table_name, ordinal_position;`, trackingTable)
+	if err != nil {
+		storage.logger.Error("Failed to read schema: %v", err)
+		return SchemaSnapshot{}, err
+	}
+	defer rows.Close()
+
+	snapshot := SchemaSnapshot{Tables: make(map[string]map[string]string)}
+	for rows.Next() {
+		var (
+			table, column, dataType, nullable string
+			maxLength                         *int
+		)
+		if err := rows.Scan(&table, &column, &dataType, &maxLength, &nullable); err != nil {
+			storage.logger.Error("Failed to read schema: %v", err)
+			return SchemaSnapshot{}, err
+		}
+		if snapshot.Tables[table] == nil {
+			snapshot.Tables[table] = make(map[string]string)
+		}
+		snapshot.Tables[table][column] = columnDefinition(dataType, maxLength, nullable == "YES")
+	}
+	if err := rows.Err(); err != nil {
+		storage.logger.Error("Failed to read schema: %v", err)
+		return SchemaSnapshot{}, err
+	}
+	return snapshot, nil
+}
+
+func columnDefinition(dataType string, maxLength *int, nullable bool) string {
+	definition := dataType
+	if maxLength != nil {
+		definition = fmt.Sprintf("%s(%d)", dataType, *maxLength)
+	}
+	if !nullable {
+		definition += " NOT NULL"
+	}
+	return definition
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSchema(t *testing.T) {
+	expected := SchemaSnapshot{Tables: map[string]map[string]string{
+		"users":  {"id": "integer NOT NULL", "email": "character varying(100)"},
+		"orders": {"id": "integer NOT NULL"},
+	}}
+	actual := SchemaSnapshot{Tables: map[string]map[string]string{
+		"users":    {"id": "integer NOT NULL", "email": "text", "nickname": "text"},
+		"sessions": {"id": "integer NOT NULL"},
+	}}
+
+	assert.Equal(t, []SchemaChange{
+		{Kind: ChangeRemoved, Object: "table orders"},
+		{Kind: ChangeAdded, Object: "table sessions"},
+		{Kind: ChangeChanged, Object: "column users.email", Detail: "character varying(100) -> text"},
+		{Kind: ChangeAdded, Object: "column users.nickname", Detail: "text"},
+	}, DiffSchema(expected, actual))
+}
+
+func TestDiffSchemaNoChanges(t *testing.T) {
+	snapshot := SchemaSnapshot{Tables: map[string]map[string]string{"users": {"id": "integer NOT NULL"}}}
+	assert.Empty(t, DiffSchema(snapshot, snapshot))
+}
+
+func TestColumnDefinition(t *testing.T) {
+	length := 100
+	assert.Equal(t, "character varying(100)", columnDefinition("character varying", &length, true))
+	assert.Equal(t, "integer NOT NULL", columnDefinition("integer", nil, false))
+}
@@ -3,9 +3,15 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Edestus789/sql-migrator/logger"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
@@ -17,12 +23,45 @@ type SQLStorage interface {
 	Connect(ctx context.Context) error
 	Close() error
 	Lock(ctx context.Context) error
+	TryLock(ctx context.Context) error
 	Unlock(ctx context.Context) error
+	SetRole(ctx context.Context, role string) error
+	ResetRole(ctx context.Context) error
+	SetStatementTimeout(ctx context.Context, timeout time.Duration) error
+	ResetStatementTimeout(ctx context.Context) error
+	StatementTimeout(ctx context.Context) (string, error)
+	RestoreStatementTimeout(ctx context.Context, value string) error
+	ExecSessionStatement(ctx context.Context, statement string) error
+	InRecovery() bool
+	MissingPrivileges(ctx context.Context) ([]string, error)
 	InsertMigration(ctx context.Context, migration IMigration) error
-	Migrate(ctx context.Context, sql string) error
+	Migrate(ctx context.Context, sql string) (int64, error)
+	XactLock(ctx context.Context) error
+	TryXactLock(ctx context.Context) error
+	MigrateBatches(ctx context.Context, sql string, size int, progress BatchProgress) (int64, error)
+	MigrateParallel(ctx context.Context, statements []string, workers int) error
+	QueryReport(ctx context.Context, sql string) (Report, error)
 	SelectMigrations(ctx context.Context) ([]IMigration, error)
 	SelectLastMigrationByStatus(ctx context.Context, status string) (IMigration, error)
+	SelectAppliedVersions(ctx context.Context) (map[int]string, error)
+	CurrentVersion(ctx context.Context) (int, error)
 	DeleteMigrations(ctx context.Context) error
+	DeleteMigration(ctx context.Context, version int) error
+	DropTrackingTable(ctx context.Context) error
+	Analyze(ctx context.Context, tables []string, vacuum bool) error
+	AcquirePersistentLock(ctx context.Context, key, owner string, ttl time.Duration) error
+	ReleasePersistentLock(ctx context.Context, key string) error
+	InsertTag(ctx context.Context, tag ReleaseTag) error
+	LatestTag(ctx context.Context) (ReleaseTag, error)
+	Begin(ctx context.Context) error
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+	Savepoint(ctx context.Context, name string) error
+	RollbackToSavepoint(ctx context.Context, name string) error
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	ContextWithTx(ctx context.Context) context.Context
+	SchemaSnapshot(ctx context.Context) (SchemaSnapshot, error)
+	ObjectExists(ctx context.Context, object SchemaObject) (bool, error)
 }
 
 const (
@@ -31,19 +70,79 @@ const (
 	StatusError        = "error"
 	StatusCancellation = "cancellation"
 	StatusCancel       = "cancel"
+	StatusSkipped      = "skipped"
+	StatusGated        = "gated"
+	// StatusCancelled — выполнение миграции прервано отменой контекста
+	// (тайм-аут или сигнал). В отличие от StatusCancel (миграция откачена)
+	// изменения нетранзакционной миграции могли примениться частично.
+	StatusCancelled = "cancelled"
 )
 
 type PostgresStorage struct {
 	connString string
 	pool       *pgxpool.Pool
 	logger     logger.Logger
+
+	lockDiagnostics    time.Duration
+	forceRecreateTable bool
+	noAutoCreateTable  bool
+	// table — служебная таблица; пустая строка означает DefaultTrackingTable.
+	table string
+	// applicationName — application_name соединений; пустая строка означает
+	// DefaultApplicationName, если DSN не задаёт своё имя.
+	applicationName string
+
+	// session защищает role и settings: их читает AfterConnect пула, который
+	// может открыть новое соединение из горутины heartbeat.
+	session sync.Mutex
+	// role — роль, заданная SetRole; каждое соединение переключается на неё
+	// до первого запроса, см. configureConn.
+	role string
+	// settings — параметры сессии, заданные ExecSessionStatement
+	// и SetStatementTimeout, в порядке установки; см. sessionStatements.
+	settings []sessionSetting
+
+	// tx — транзакция, открытая Begin; nil, если транзакции нет.
+	tx pgx.Tx
+	// inRecovery — сервер при подключении был в режиме восстановления
+	// (hot standby) и принимает только чтение.
+	inRecovery bool
 }
 
 var (
 	ErrUnexpectedStatus  = errors.New("unexpected status")
 	ErrMigrationNotFound = errors.New("processes not found")
+	ErrInvalidRole       = errors.New("invalid role name")
+
+	regRoleName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]{0,62}$`)
+)
+
+// Коды SQLSTATE ошибок, после которых транзакцию можно безопасно повторить.
+const (
+	sqlStateDeadlock             = "40P01"
+	sqlStateSerializationFailure = "40001"
 )
 
+// IsRetryable сообщает, вызвана ли ошибка взаимоблокировкой или сбоем
+// сериализации. Такая транзакция откатывается Postgres целиком
+// и может завершиться успешно при повторе.
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == sqlStateDeadlock || pgErr.Code == sqlStateSerializationFailure
+}
+
+// ValidateRole проверяет, что имя роли является допустимым идентификатором
+// Postgres без кавычек.
+func ValidateRole(role string) error {
+	if !regRoleName.MatchString(role) {
+		return fmt.Errorf("%w: %q", ErrInvalidRole, role)
+	}
+	return nil
+}
+
 func NewPostgresStorage(connString string, logger logger.Logger) *PostgresStorage {
 	return &PostgresStorage{
 		connString: connString,
@@ -54,57 +153,217 @@ func NewPostgresStorage(connString string, logger logger.Logger) *PostgresStorag
 func (storage *PostgresStorage) Connect(ctx context.Context) error {
 	storage.logger.Info("Connecting to the database")
 
-	pool, err := pgxpool.Connect(ctx, storage.connString)
+	config, err := pgxpool.ParseConfig(storage.connString)
+	if err != nil {
+		storage.logger.Error("Failed to parse connection string: %v", err)
+		return err
+	}
+	// Advisory lock и SET ROLE действуют в рамках сессии, поэтому все запросы
+	// мигратора должны выполняться через одно и то же соединение.
+	config.MaxConns = 1
+	storage.applyApplicationName(config.ConnConfig)
+	// Пул заменяет соединение по истечении MaxConnLifetime и MaxConnIdleTime,
+	// поэтому роль и параметры сессии задаются на каждом новом соединении.
+	// Служебная таблица так же создаётся уже под ролью из SetRole.
+	config.AfterConnect = storage.configureConn
+	if storage.role != "" {
+		storage.logger.Info("Switching to role %s", storage.role)
+	}
+
+	pool, err := pgxpool.ConnectConfig(ctx, config)
 	if err != nil {
 		storage.logger.Error("Failed to connect to the database: %v", err)
 		return err
 	}
 
-	sql := `
-		CREATE TABLE IF NOT EXISTS schema_migrations (
-			Version INTEGER PRIMARY KEY,
-			Name CHARACTER VARYING(100),
-			Status CHARACTER VARYING(20),
-			StatusChangeTime TIMESTAMP
-		);`
+	storage.pool = pool
+	if err := pool.QueryRow(ctx, "SELECT pg_is_in_recovery();").Scan(&storage.inRecovery); err != nil {
+		storage.logger.Error("Failed to check recovery mode: %v", err)
+		pool.Close()
+		storage.pool = nil
+		return err
+	}
+	if storage.inRecovery {
+		storage.logger.Warn("Connected to a read-only replica in recovery mode; "+
+			"the %s table is checked but not created or upgraded", storage.trackingTable())
+	}
 
-	_, err = pool.Exec(ctx, sql)
-	if err != nil {
-		storage.logger.Error("Failed to create schema_migrations table: %v", err)
+	if err := storage.ensureSchema(ctx); err != nil {
 		pool.Close()
+		storage.pool = nil
 		return err
 	}
 
-	storage.pool = pool
-	storage.logger.Info("Connected to the database and " +
-		"ensured schema_migrations table exists")
+	storage.logger.Info("Connected to the database and "+
+		"ensured %s table exists", storage.trackingTable())
 	return nil
 }
 
-func (storage *PostgresStorage) Close() error {
-	storage.logger.Info("Closing database connection pool")
+// InRecovery сообщает, был ли сервер при подключении репликой в режиме
+// восстановления (pg_is_in_recovery), на которой невозможны изменения.
+func (storage *PostgresStorage) InRecovery() bool {
+	return storage.inRecovery
+}
 
-	if storage.pool != nil {
-		storage.pool.Close()
-		storage.logger.Info("Database connection pool closed")
+// Close закрывает пул соединений. Повторный вызов, как и вызов без
+// подключения, ничего не делает и возвращает nil.
+func (storage *PostgresStorage) Close() error {
+	if storage.pool == nil {
+		return nil
 	}
+
+	storage.logger.Info("Closing database connection pool")
+	storage.pool.Close()
+	storage.pool = nil
+	storage.tx = nil
+	storage.logger.Info("Database connection pool closed")
 	return nil
 }
 
+// EnableLockDiagnostics включает периодический вывод информации о сессии,
+// удерживающей advisory lock, пока Lock ожидает его освобождения.
+func (storage *PostgresStorage) EnableLockDiagnostics(interval time.Duration) {
+	storage.lockDiagnostics = interval
+}
+
 func (storage *PostgresStorage) Lock(ctx context.Context) error {
 	storage.logger.Info("Acquiring advisory lock")
-	_, err := storage.pool.Exec(ctx,
-		"SELECT pg_advisory_lock($1);",
-		advisoryLockID)
+
+	if storage.lockDiagnostics > 0 {
+		diagCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go storage.diagnoseLock(diagCtx)
+	}
+
+	err := storage.waitForLock(ctx, "SELECT pg_advisory_lock($1);", false)
 	if err != nil {
 		storage.logger.Error("Failed to acquire advisory lock: %v", err)
 	}
 	return err
 }
 
+// waitForLock выполняет запрос блокировки lockSQL с отключённым
+// statement_timeout: тайм-аут сессии ограничивает запросы миграций, а не
+// ожидание, пока другой процесс освободит блокировку. Прежнее значение
+// восстанавливается после запроса; в транзакции (local) оно задаётся
+// через SET LOCAL и set_config(..., true) и действует до её конца.
+func (storage *PostgresStorage) waitForLock(ctx context.Context, lockSQL string, local bool) error {
+	var previous string
+	if err := storage.db().QueryRow(ctx, "SELECT current_setting('statement_timeout');").Scan(&previous); err != nil {
+		return err
+	}
+
+	disable := "SET statement_timeout = 0;"
+	if local {
+		disable = "SET LOCAL statement_timeout = 0;"
+	}
+	if _, err := storage.db().Exec(ctx, disable); err != nil {
+		return err
+	}
+
+	_, lockErr := storage.db().Exec(ctx, lockSQL, advisoryLockID)
+	if lockErr != nil && local {
+		// Транзакция прервана, и SET LOCAL отменится вместе с ней.
+		return lockErr
+	}
+	if _, err := storage.db().Exec(ctx, "SELECT set_config('statement_timeout', $1, $2);", previous, local); err != nil {
+		storage.logger.Error("Failed to restore statement timeout: %v", err)
+		if lockErr == nil {
+			return err
+		}
+	}
+	return lockErr
+}
+
+// ErrLockBusy возвращается TryLock, когда advisory lock удерживает другая сессия.
+var ErrLockBusy = errors.New("advisory lock is held by another session")
+
+// TryLock берёт advisory lock без ожидания. Если блокировку удерживает
+// другая сессия, возвращается ErrLockBusy; остальные ошибки означают сбой
+// запроса.
+func (storage *PostgresStorage) TryLock(ctx context.Context) error {
+	storage.logger.Info("Trying to acquire advisory lock")
+
+	var acquired bool
+	err := storage.db().QueryRow(ctx,
+		"SELECT pg_try_advisory_lock($1);",
+		advisoryLockID).Scan(&acquired)
+	if err != nil {
+		storage.logger.Error("Failed to acquire advisory lock: %v", err)
+		return err
+	}
+	if !acquired {
+		return ErrLockBusy
+	}
+	return nil
+}
+
+// diagnoseLock периодически сообщает, какая сессия удерживает advisory lock.
+// Основное соединение занято ожиданием блокировки, поэтому запрос
+// к pg_stat_activity выполняется через отдельное соединение.
+func (storage *PostgresStorage) diagnoseLock(ctx context.Context) {
+	ticker := time.NewTicker(storage.lockDiagnostics)
+	defer ticker.Stop()
+
+	var diagPool *pgxpool.Pool
+	defer func() {
+		if diagPool != nil {
+			diagPool.Close()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if diagPool == nil {
+			config, err := pgxpool.ParseConfig(storage.connString)
+			if err != nil {
+				storage.logger.Warn("Lock diagnostics unavailable: %v", err)
+				return
+			}
+			config.MaxConns = 1
+			storage.applyApplicationName(config.ConnConfig)
+
+			diagPool, err = pgxpool.ConnectConfig(ctx, config)
+			if err != nil {
+				storage.logger.Warn("Lock diagnostics unavailable: %v", err)
+				return
+			}
+		}
+
+		sql := `SELECT a.pid, COALESCE(a.application_name, ''), COALESCE(a.state, '')
+			FROM pg_locks l
+			JOIN pg_stat_activity a ON a.pid = l.pid
+			WHERE l.locktype = 'advisory' AND l.granted
+				AND l.classid = 0 AND l.objid = $1 AND l.objsubid = 1;`
+
+		var (
+			pid             int
+			applicationName string
+			state           string
+		)
+
+		err := diagPool.QueryRow(ctx, sql, advisoryLockID).Scan(&pid, &applicationName, &state)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			storage.logger.Warn("Still waiting for advisory lock; holder unknown: %v", err)
+			continue
+		}
+
+		storage.logger.Warn("Still waiting for advisory lock held by pid=%d application_name=%q state=%s",
+			pid, applicationName, state)
+	}
+}
+
 func (storage *PostgresStorage) Unlock(ctx context.Context) error {
 	storage.logger.Info("Releasing advisory lock")
-	_, err := storage.pool.Exec(ctx,
+	_, err := storage.db().Exec(ctx,
 		"SELECT pg_advisory_unlock($1);",
 		advisoryLockID)
 	if err != nil {
@@ -113,18 +372,137 @@ func (storage *PostgresStorage) Unlock(ctx context.Context) error {
 	return err
 }
 
+// SetRole переключает сессию на роль role (SET ROLE). Имя понимается как
+// идентификатор без кавычек и, как в Postgres, приводится к нижнему
+// регистру: Deployer и deployer — одна роль. До Connect роль только
+// запоминается, и Connect переключается на неё сразу после подключения.
+func (storage *PostgresStorage) SetRole(ctx context.Context, role string) error {
+	if err := ValidateRole(role); err != nil {
+		storage.logger.Error("Failed to set role: %v", err)
+		return err
+	}
+
+	storage.setRole(strings.ToLower(role))
+	if storage.pool == nil {
+		return nil
+	}
+	return storage.applyRole(ctx)
+}
+
+// applyRole выполняет SET ROLE для роли, заданной SetRole.
+func (storage *PostgresStorage) applyRole(ctx context.Context) error {
+	storage.logger.Info("Switching to role %s", storage.role)
+	_, err := storage.pool.Exec(ctx, `SET ROLE "`+storage.role+`";`)
+	if err != nil {
+		storage.logger.Error("Failed to set role %s: %v", storage.role, err)
+	}
+	return err
+}
+
+// setRole запоминает роль, которую configureConn задаёт новым соединениям.
+func (storage *PostgresStorage) setRole(role string) {
+	storage.session.Lock()
+	defer storage.session.Unlock()
+	storage.role = role
+}
+
+func (storage *PostgresStorage) ResetRole(ctx context.Context) error {
+	storage.setRole("")
+	storage.logger.Info("Resetting role")
+	_, err := storage.pool.Exec(ctx, "RESET ROLE;")
+	if err != nil {
+		storage.logger.Error("Failed to reset role: %v", err)
+	}
+	return err
+}
+
+// SetStatementTimeout задаёт statement_timeout сессии: Postgres сам прервёт
+// любой запрос, выполняющийся дольше timeout.
+func (storage *PostgresStorage) SetStatementTimeout(ctx context.Context, timeout time.Duration) error {
+	sql := statementTimeoutSQL(timeout)
+	storage.logger.Info("Setting session timeout: %s", sql)
+	_, err := storage.pool.Exec(ctx, sql)
+	if err != nil {
+		storage.logger.Error("Failed to set statement timeout: %v", err)
+		return err
+	}
+	storage.rememberSetting("statement_timeout", sql)
+	return nil
+}
+
+func (storage *PostgresStorage) ResetStatementTimeout(ctx context.Context) error {
+	storage.logger.Info("Resetting statement timeout")
+	_, err := storage.pool.Exec(ctx, "RESET statement_timeout;")
+	if err != nil {
+		storage.logger.Error("Failed to reset statement timeout: %v", err)
+		return err
+	}
+	storage.rememberSetting("statement_timeout", "")
+	return nil
+}
+
+// StatementTimeout возвращает текущий statement_timeout сессии, например
+// заданный -pre-lock-statement, чтобы вернуть его RestoreStatementTimeout.
+func (storage *PostgresStorage) StatementTimeout(ctx context.Context) (string, error) {
+	var value string
+	if err := storage.db().QueryRow(ctx, "SELECT current_setting('statement_timeout');").Scan(&value); err != nil {
+		storage.logger.Error("Failed to read statement timeout: %v", err)
+		return "", err
+	}
+	return value, nil
+}
+
+// RestoreStatementTimeout задаёт statement_timeout значением, прочитанным
+// StatementTimeout.
+func (storage *PostgresStorage) RestoreStatementTimeout(ctx context.Context, value string) error {
+	sql := restoreStatementTimeoutSQL(value)
+	storage.logger.Info("Restoring session timeout: %s", sql)
+	_, err := storage.pool.Exec(ctx, sql)
+	if err != nil {
+		storage.logger.Error("Failed to restore statement timeout: %v", err)
+		return err
+	}
+	storage.rememberSetting("statement_timeout", sql)
+	return nil
+}
+
+// restoreStatementTimeoutSQL формирует SET statement_timeout со значением
+// current_setting, например '5s', в виде строки.
+func restoreStatementTimeoutSQL(value string) string {
+	return "SET statement_timeout = '" + strings.ReplaceAll(value, "'", "''") + "';"
+}
+
+// statementTimeoutSQL формирует SET statement_timeout в миллисекундах:
+// SET не принимает параметры запроса, поэтому значение подставляется числом.
+func statementTimeoutSQL(timeout time.Duration) string {
+	return fmt.Sprintf("SET statement_timeout = %d;", timeout.Milliseconds())
+}
+
 func (storage *PostgresStorage) DeleteMigrations(ctx context.Context) error {
-	storage.logger.Info("Deleting all migrations from schema_migrations table")
-	_, err := storage.pool.Exec(ctx, "TRUNCATE schema_migrations;")
+	storage.logger.Info("Deleting all migrations from %s table", storage.trackingTable())
+	_, err := storage.pool.Exec(ctx, "TRUNCATE "+storage.quotedTrackingTable()+";")
 	if err != nil {
 		storage.logger.Error("Failed to delete migrations: %v", err)
 	}
 	return err
 }
 
+// DeleteMigration удаляет запись о миграции версии version.
+func (storage *PostgresStorage) DeleteMigration(ctx context.Context, version int) error {
+	storage.logger.Info("Deleting migration %d from %s table", version, storage.trackingTable())
+	_, err := storage.db().Exec(ctx, "DELETE FROM "+storage.quotedTrackingTable()+" WHERE Version = $1;", version)
+	if err != nil {
+		storage.logger.Error("Failed to delete migration %d: %v", version, err)
+	}
+	return err
+}
+
 func (storage *PostgresStorage) SelectMigrations(ctx context.Context) ([]IMigration, error) {
-	storage.logger.Info("Selecting all migrations from schema_migrations table")
-	sql := `SELECT Name, Status, Version, StatusChangeTime FROM schema_migrations ORDER BY Version DESC;`
+	storage.logger.Info("Selecting all migrations from %s table", storage.trackingTable())
+	sql := `SELECT Name, Status, Version, StatusChangeTime, COALESCE(Labels, '{}'), COALESCE(Source_File, ''),
+		COALESCE(Checksum, ''), COALESCE(Up_SQL, ''), COALESCE(Rows_Affected, 0),
+		COALESCE(Author, '')
+		FROM ` + storage.quotedTrackingTable() + ` ORDER BY Version DESC;`
 
 	rows, err := storage.pool.Query(ctx, sql)
 	if err != nil {
@@ -140,15 +518,28 @@ func (storage *PostgresStorage) SelectMigrations(ctx context.Context) ([]IMigrat
 			version          int
 			status           string
 			statusChangeTime time.Time
+			labels           []string
+			sourceFile       string
+			checksum         string
+			upSQL            string
+			rowsAffected     int64
+			author           string
 		)
 
-		err = rows.Scan(&name, &status, &version, &statusChangeTime)
+		err = rows.Scan(&name, &status, &version, &statusChangeTime, &labels, &sourceFile, &checksum, &upSQL, &rowsAffected, &author)
 		if err != nil {
 			storage.logger.Error("Failed to scan migration row: %v", err)
 			return nil, err
 		}
 
-		migrations = append(migrations, CreateMigration(name, status, version, statusChangeTime))
+		migration := CreateMigration(name, status, version, statusChangeTime)
+		migration.SetLabels(labels)
+		migration.SetSourceFile(sourceFile)
+		migration.SetChecksum(checksum)
+		migration.SetUpSQL(upSQL)
+		migration.SetRowsAffected(rowsAffected)
+		migration.SetAuthor(author)
+		migrations = append(migrations, migration)
 	}
 
 	if len(migrations) == 0 {
@@ -159,18 +550,66 @@ func (storage *PostgresStorage) SelectMigrations(ctx context.Context) ([]IMigrat
 	return migrations, nil
 }
 
+// SelectAppliedVersions возвращает статусы всех записанных миграций по версиям
+// одним запросом. Для пустой таблицы возвращается пустой словарь без ошибки.
+func (storage *PostgresStorage) SelectAppliedVersions(ctx context.Context) (map[int]string, error) {
+	storage.logger.Info("Selecting migration statuses from %s table", storage.trackingTable())
+
+	rows, err := storage.db().Query(ctx, "SELECT Version, Status FROM "+storage.quotedTrackingTable()+";")
+	if err != nil {
+		storage.logger.Error("Failed to select migration statuses: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := make(map[int]string)
+	for rows.Next() {
+		var (
+			version int
+			status  string
+		)
+		if err := rows.Scan(&version, &status); err != nil {
+			storage.logger.Error("Failed to scan migration status row: %v", err)
+			return nil, err
+		}
+		statuses[version] = status
+	}
+
+	if err := rows.Err(); err != nil {
+		storage.logger.Error("Failed to select migration statuses: %v", err)
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// CurrentVersion возвращает наибольшую успешно применённую версию одним
+// агрегирующим запросом, не загружая саму запись. Для пустой таблицы
+// возвращается 0 без ошибки.
+func (storage *PostgresStorage) CurrentVersion(ctx context.Context) (int, error) {
+	var version int
+	err := storage.pool.QueryRow(ctx,
+		"SELECT COALESCE(MAX(Version), 0) FROM "+storage.quotedTrackingTable()+" WHERE Status = $1;",
+		StatusSuccess,
+	).Scan(&version)
+	if err != nil {
+		storage.logger.Error("Failed to select current version: %v", err)
+		return 0, err
+	}
+	return version, nil
+}
+
 func (storage *PostgresStorage) SelectLastMigrationByStatus(ctx context.Context, status string) (IMigration, error) {
 	storage.logger.Info("Выбор последней миграции со статусом: %s", status)
 
 	switch status {
-	case StatusSuccess, StatusError, StatusProcess, StatusCancellation, StatusCancel:
+	case StatusSuccess, StatusError, StatusProcess, StatusCancellation, StatusCancel, StatusSkipped, StatusGated, StatusCancelled:
 	default:
 		storage.logger.Error("Неожиданный статус: %s", status)
 		return nil, ErrUnexpectedStatus
 	}
 
 	sql := `SELECT Name, Status, Version, StatusChangeTime 
-        FROM schema_migrations 
+        FROM ` + storage.quotedTrackingTable() + ` 
         WHERE Status = $1 
         ORDER BY Version DESC 
         LIMIT 1;`
@@ -186,10 +625,10 @@ func (storage *PostgresStorage) SelectLastMigrationByStatus(ctx context.Context,
 
 	err := row.Scan(&name, &statusStr, &version, &statusChangeTime)
 	if err != nil {
-		// if err == pgx.ErrNoRows {
-		// 	storage.logger.Warn("Миграция со статусом %s не найдена", status)
-		// 	return nil, ErrMigrationNotFound
-		// }
+		if errors.Is(err, pgx.ErrNoRows) {
+			storage.logger.Warn("Миграция со статусом %s не найдена", status)
+			return nil, ErrMigrationNotFound
+		}
 		storage.logger.Error(
 			"Ошибка при получении последней миграции со статусом %s: %v",
 			status,
@@ -201,36 +640,133 @@ func (storage *PostgresStorage) SelectLastMigrationByStatus(ctx context.Context,
 	return CreateMigration(name, statusStr, version, statusChangeTime), nil
 }
 
+// upsertMigrationSQL вставляет запись о миграции в таблицу table или обновляет
+// существующую запись той же версии.
+func upsertMigrationSQL(table string) string {
+	return `
+	INSERT INTO ` + table + ` (Version, Name, Status, StatusChangeTime, Labels, Source_File, Checksum, Up_SQL, Rows_Affected, Author)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT (Version) DO UPDATE
+	SET Name = EXCLUDED.Name, Status = EXCLUDED.Status,
+		StatusChangeTime = EXCLUDED.StatusChangeTime, Labels = EXCLUDED.Labels,
+		Source_File = EXCLUDED.Source_File, Checksum = EXCLUDED.Checksum, Up_SQL = EXCLUDED.Up_SQL,
+		Rows_Affected = EXCLUDED.Rows_Affected, Author = EXCLUDED.Author;`
+}
+
 func (storage *PostgresStorage) InsertMigration(ctx context.Context, migration IMigration) error {
 	storage.logger.Info("Inserting/updating migration: %s", migration.GetName())
 
-	sql := `
-		DO $$ 
-		BEGIN
-			IF EXISTS (SELECT 1 FROM schema_migrations WHERE Version = $1 AND Name = $2) THEN
-				UPDATE schema_migrations 
-				SET Status = $3, StatusChangeTime = $4 
-				WHERE Version = $1 AND Name = $2;
-			ELSE
-				INSERT INTO schema_migrations 
-					(Version, Name, Status, StatusChangeTime)
-				VALUES 
-					($1, $2, $3, $4);
-			END IF;
-		END $$;`
-
-	_, err := storage.pool.Exec(ctx, sql, migration.GetVersion(), migration.GetName(), migration.GetStatus(), migration.GetStatusChangeTime())
+	_, err := storage.db().Exec(ctx, upsertMigrationSQL(storage.quotedTrackingTable()),
+		migration.GetVersion(), migration.GetName(), migration.GetStatus(), migration.GetStatusChangeTime(),
+		migration.GetLabels(), migration.GetSourceFile(), migration.GetChecksum(), migration.GetUpSQL(),
+		migration.GetRowsAffected(), migration.GetAuthor())
 	if err != nil {
 		storage.logger.Error("Failed to insert/update migration: %v", err)
 	}
 	return err
 }
 
-func (storage *PostgresStorage) Migrate(ctx context.Context, sql string) error {
+// Migrate выполняет SQL миграции и возвращает число строк, изменённых
+// всеми его командами. Для DDL это 0.
+func (storage *PostgresStorage) Migrate(ctx context.Context, sql string) (int64, error) {
 	storage.logger.Info("Executing migration SQL")
-	_, err := storage.pool.Exec(ctx, sql)
+	rows, err := storage.execAll(ctx, sql)
 	if err != nil {
 		storage.logger.Error("Failed to execute migration SQL: %v", err)
+		return 0, err
+	}
+	return rows, nil
+}
+
+// execAll выполняет SQL из нескольких команд одним запросом простого
+// протокола и суммирует строки, изменённые каждой командой: Exec драйвера
+// возвращает тег только последней команды.
+func (storage *PostgresStorage) execAll(ctx context.Context, sql string) (int64, error) {
+	var conn *pgconn.PgConn
+	if storage.tx != nil {
+		conn = storage.tx.Conn().PgConn()
+	} else {
+		pooled, err := storage.pool.Acquire(ctx)
+		if err != nil {
+			return 0, err
+		}
+		defer pooled.Release()
+		conn = pooled.Conn().PgConn()
+	}
+
+	results, err := conn.Exec(ctx, sql).ReadAll()
+	if err != nil {
+		return 0, err
+	}
+	var rows int64
+	for _, result := range results {
+		rows += result.CommandTag.RowsAffected()
+	}
+	return rows, nil
+}
+
+// XactLock берёт pg_advisory_xact_lock в транзакции, открытой Begin.
+// Блокировка снимается вместе с завершением транзакции, поэтому всё, что
+// выполнено в транзакции после XactLock, выполняется под блокировкой.
+func (storage *PostgresStorage) XactLock(ctx context.Context) error {
+	if storage.tx == nil {
+		return ErrNoTransaction
+	}
+
+	storage.logger.Info("Acquiring transaction-level advisory lock")
+	if err := storage.waitForLock(ctx, "SELECT pg_advisory_xact_lock($1);", true); err != nil {
+		storage.logger.Error("Failed to acquire transaction-level advisory lock: %v", err)
+		return err
+	}
+	return nil
+}
+
+// TryXactLock берёт pg_advisory_xact_lock в транзакции, открытой Begin,
+// без ожидания. Если блокировку удерживает другая сессия, возвращается
+// ErrLockBusy.
+func (storage *PostgresStorage) TryXactLock(ctx context.Context) error {
+	if storage.tx == nil {
+		return ErrNoTransaction
+	}
+
+	storage.logger.Info("Trying to acquire transaction-level advisory lock")
+	var acquired bool
+	if err := storage.tx.QueryRow(ctx, "SELECT pg_try_advisory_xact_lock($1);", advisoryLockID).Scan(&acquired); err != nil {
+		storage.logger.Error("Failed to acquire transaction-level advisory lock: %v", err)
+		return err
+	}
+	if !acquired {
+		return ErrLockBusy
+	}
+	return nil
+}
+
+// Analyze обновляет статистику планировщика для указанных таблиц
+// или для всей базы данных, если список пуст. При vacuum == true
+// выполняется VACUUM ANALYZE.
+func (storage *PostgresStorage) Analyze(ctx context.Context, tables []string, vacuum bool) error {
+	sql := analyzeStatement(tables, vacuum)
+	storage.logger.Info("Running %s", sql)
+
+	_, err := storage.pool.Exec(ctx, sql)
+	if err != nil {
+		storage.logger.Error("Failed to analyze tables: %v", err)
 	}
 	return err
 }
+
+func analyzeStatement(tables []string, vacuum bool) string {
+	command := "ANALYZE"
+	if vacuum {
+		command = "VACUUM ANALYZE"
+	}
+	if len(tables) == 0 {
+		return command + ";"
+	}
+
+	quoted := make([]string, 0, len(tables))
+	for _, table := range tables {
+		quoted = append(quoted, quoteIdentifier(driverPostgres, table))
+	}
+	return command + " " + strings.Join(quoted, ", ") + ";"
+}
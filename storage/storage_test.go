@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeStatement(t *testing.T) {
+	assert.Equal(t, "ANALYZE;", analyzeStatement(nil, false))
+	assert.Equal(t, "VACUUM ANALYZE;", analyzeStatement(nil, true))
+	assert.Equal(t, `ANALYZE "users", "billing"."invoices";`,
+		analyzeStatement([]string{"users", "billing.invoices"}, false))
+	assert.Equal(t, `VACUUM ANALYZE "orders";`, analyzeStatement([]string{"orders"}, true))
+}
+
+func TestMockSelectAppliedVersions(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockSQLStorage()
+
+	statuses, err := mock.SelectAppliedVersions(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, statuses)
+
+	now := time.Now()
+	require.NoError(t, mock.InsertMigration(ctx, CreateMigration("create_users", StatusSuccess, 1, now)))
+	require.NoError(t, mock.InsertMigration(ctx, CreateMigration("create_orders", StatusCancel, 2, now)))
+	require.NoError(t, mock.InsertMigration(ctx, CreateMigration("create_items", StatusSkipped, 3, now)))
+	require.NoError(t, mock.InsertMigration(ctx, CreateMigration("create_orders", StatusSuccess, 2, now)))
+
+	statuses, err = mock.SelectAppliedVersions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{1: StatusSuccess, 2: StatusSuccess, 3: StatusSkipped}, statuses)
+}
+
+func TestMockRoundTripsSourceFile(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockSQLStorage()
+
+	migration := &Migration{Name: "create_users", Version: 1, Status: StatusProcess, SourceFile: "migrations/00001_create_users_up.sql"}
+	require.NoError(t, mock.InsertMigration(ctx, migration))
+
+	update := &Migration{Name: "create_users", Version: 1, Status: StatusSuccess, SourceFile: "migrations/00001_create_users_up.sql"}
+	require.NoError(t, mock.InsertMigration(ctx, update))
+
+	migrations, err := mock.SelectMigrations(ctx)
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+	assert.Equal(t, "migrations/00001_create_users_up.sql", migrations[0].GetSourceFile())
+	assert.Equal(t, StatusSuccess, migrations[0].GetStatus())
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(&pgconn.PgError{Code: "40P01"}))
+	assert.True(t, IsRetryable(fmt.Errorf("migration: %w", &pgconn.PgError{Code: "40001"})))
+	assert.False(t, IsRetryable(&pgconn.PgError{Code: "42P01"}))
+	assert.False(t, IsRetryable(errors.New("connection refused")))
+}
+
+func TestMockMigrateBatchesReportsProgress(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockSQLStorage()
+	mock.SetBatchRows(500, 500, 120)
+
+	var progress []string
+	total, err := mock.MigrateBatches(ctx, "UPDATE t SET x = 1 WHERE id IN (SELECT id FROM t LIMIT $1);", 500,
+		func(batch int, rows, total int64) {
+			progress = append(progress, fmt.Sprintf("%d:%d/%d", batch, rows, total))
+		})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1120), total)
+	assert.Equal(t, []string{"1:500/500", "2:500/1000", "3:120/1120"}, progress)
+	assert.Len(t, mock.ExecutedSQL(), 4)
+
+	_, err = mock.MigrateBatches(ctx, "UPDATE t SET x = 1;", 0, nil)
+	assert.ErrorIs(t, err, ErrInvalidBatchSize)
+}
+
+func TestApplyApplicationName(t *testing.T) {
+	storage := NewPostgresStorage("", nil)
+
+	config := &pgx.ConnConfig{}
+	storage.applyApplicationName(config)
+	assert.Equal(t, "sql-migrator/"+Version, config.RuntimeParams["application_name"])
+
+	// Имя из DSN сохраняется, если -app-name не задан.
+	config.RuntimeParams["application_name"] = "from-dsn"
+	storage.applyApplicationName(config)
+	assert.Equal(t, "from-dsn", config.RuntimeParams["application_name"])
+
+	storage.SetApplicationName("billing-migrations")
+	storage.applyApplicationName(config)
+	assert.Equal(t, "billing-migrations", config.RuntimeParams["application_name"])
+}
+
+func TestCloseWithoutConnectIsNoOp(t *testing.T) {
+	storage := NewPostgresStorage("", nil)
+	assert.NoError(t, storage.Close())
+	assert.NoError(t, storage.Close())
+}
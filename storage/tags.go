@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrTagNotFound возвращается, когда в истории нет ни одного тега релиза.
+var ErrTagNotFound = errors.New("release tag not found")
+
+// ReleaseTag — отметка о релизе приложения: не миграция, а запись о том, на
+// какой версии была схема и с какими контрольными суммами миграций.
+type ReleaseTag struct {
+	Label   string
+	Version int
+	// Checksums — контрольные суммы успешно применённых миграций по версиям.
+	Checksums map[int]string
+	CreatedAt time.Time
+}
+
+// Теги хранятся в отдельной таблице, чтобы не смешиваться с записями
+// миграций, которые читают Up, Down и Status. Один и тот же тег можно
+// записать повторно: актуальной считается последняя запись.
+const createTagsTableSQL = `CREATE TABLE IF NOT EXISTS schema_migrations_tags (
+	id BIGSERIAL PRIMARY KEY,
+	label TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	checksums JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);`
+
+// InsertTag записывает тег релиза, создавая таблицу тегов при первом вызове.
+func (storage *PostgresStorage) InsertTag(ctx context.Context, tag ReleaseTag) error {
+	storage.logger.Info("Writing release tag %q at version %d", tag.Label, tag.Version)
+
+	if _, err := storage.db().Exec(ctx, createTagsTableSQL); err != nil {
+		storage.logger.Error("Failed to create schema_migrations_tags table: %v", err)
+		return err
+	}
+
+	checksums, err := json.Marshal(tag.Checksums)
+	if err != nil {
+		return err
+	}
+	_, err = storage.db().Exec(ctx,
+		`INSERT INTO schema_migrations_tags (label, version, checksums) VALUES ($1, $2, $3::jsonb);`,
+		tag.Label, tag.Version, string(checksums))
+	if err != nil {
+		storage.logger.Error("Failed to write release tag: %v", err)
+		return err
+	}
+	return nil
+}
+
+// LatestTag возвращает последний записанный тег релиза. Если таблицы тегов
+// ещё нет, возвращается ErrTagNotFound: чтение не создаёт таблицу.
+func (storage *PostgresStorage) LatestTag(ctx context.Context) (ReleaseTag, error) {
+	var exists bool
+	err := storage.db().QueryRow(ctx, `SELECT to_regclass('schema_migrations_tags') IS NOT NULL;`).Scan(&exists)
+	if err != nil {
+		storage.logger.Error("Failed to check schema_migrations_tags table: %v", err)
+		return ReleaseTag{}, err
+	}
+	if !exists {
+		return ReleaseTag{}, ErrTagNotFound
+	}
+
+	var (
+		tag       ReleaseTag
+		checksums string
+	)
+	err = storage.db().QueryRow(ctx, `
+		SELECT label, version, checksums::text, created_at
+		FROM schema_migrations_tags
+		ORDER BY id DESC
+		LIMIT 1;`).Scan(&tag.Label, &tag.Version, &checksums, &tag.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ReleaseTag{}, ErrTagNotFound
+		}
+		storage.logger.Error("Failed to read release tag: %v", err)
+		return ReleaseTag{}, err
+	}
+	if err := json.Unmarshal([]byte(checksums), &tag.Checksums); err != nil {
+		return ReleaseTag{}, err
+	}
+	return tag, nil
+}
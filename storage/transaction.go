@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// ErrNoTransaction возвращается командами транзакции, вызванными вне Begin.
+var ErrNoTransaction = errors.New("no transaction in progress")
+
+// querier — общие методы пула соединений и транзакции.
+type querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// db возвращает открытую транзакцию, если она есть, иначе пул. Пул ограничен
+// одним соединением, поэтому запрос через пул во время транзакции ждал бы
+// освобождения соединения вечно.
+func (storage *PostgresStorage) db() querier {
+	if storage.tx != nil {
+		return storage.tx
+	}
+	return storage.pool
+}
+
+// Begin открывает транзакцию, через которую до Commit или Rollback выполняются
+// SQL миграций, записи в schema_migrations и advisory lock.
+func (storage *PostgresStorage) Begin(ctx context.Context) error {
+	storage.logger.Info("Beginning transaction")
+
+	tx, err := storage.db().Begin(ctx)
+	if err != nil {
+		storage.logger.Error("Failed to begin transaction: %v", err)
+		return err
+	}
+	storage.tx = tx
+	return nil
+}
+
+func (storage *PostgresStorage) Commit(ctx context.Context) error {
+	if storage.tx == nil {
+		return ErrNoTransaction
+	}
+
+	storage.logger.Info("Committing transaction")
+	err := storage.tx.Commit(ctx)
+	storage.tx = nil
+	if err != nil {
+		storage.logger.Error("Failed to commit transaction: %v", err)
+	}
+	return err
+}
+
+func (storage *PostgresStorage) Rollback(ctx context.Context) error {
+	if storage.tx == nil {
+		return ErrNoTransaction
+	}
+
+	storage.logger.Info("Rolling back transaction")
+	err := storage.tx.Rollback(ctx)
+	storage.tx = nil
+	if err != nil {
+		storage.logger.Error("Failed to roll back transaction: %v", err)
+	}
+	return err
+}
+
+// Savepoint создаёт точку сохранения name в открытой транзакции.
+func (storage *PostgresStorage) Savepoint(ctx context.Context, name string) error {
+	return storage.execInTx(ctx, "SAVEPOINT "+pgx.Identifier{name}.Sanitize()+";")
+}
+
+// RollbackToSavepoint отменяет изменения транзакции, сделанные после
+// точки сохранения name. Транзакция и сама точка сохранения остаются открытыми.
+func (storage *PostgresStorage) RollbackToSavepoint(ctx context.Context, name string) error {
+	return storage.execInTx(ctx, "ROLLBACK TO SAVEPOINT "+pgx.Identifier{name}.Sanitize()+";")
+}
+
+func (storage *PostgresStorage) execInTx(ctx context.Context, sql string) error {
+	if storage.tx == nil {
+		return ErrNoTransaction
+	}
+
+	storage.logger.Info("Executing %s", sql)
+	if _, err := storage.tx.Exec(ctx, sql); err != nil {
+		storage.logger.Error("Failed to execute %s: %v", sql, err)
+		return err
+	}
+	return nil
+}
+
+// Executor выполняет запросы в транзакции, открытой WithTx. Go-миграции
+// получают его из контекста через ExecutorFromContext, чтобы их изменения
+// фиксировались вместе с записью статуса миграции.
+type Executor interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+type executorKey struct{}
+
+// ContextWithExecutor возвращает контекст, несущий executor.
+func ContextWithExecutor(ctx context.Context, executor Executor) context.Context {
+	return context.WithValue(ctx, executorKey{}, executor)
+}
+
+// ExecutorFromContext возвращает executor транзакции, открытой WithTx.
+func ExecutorFromContext(ctx context.Context) (Executor, bool) {
+	executor, ok := ctx.Value(executorKey{}).(Executor)
+	return executor, ok
+}
+
+// ContextWithTx возвращает ctx, несущий Executor транзакции, открытой Begin;
+// вне транзакции ctx возвращается без изменений.
+func (storage *PostgresStorage) ContextWithTx(ctx context.Context) context.Context {
+	if storage.tx == nil {
+		return ctx
+	}
+	return ContextWithExecutor(ctx, storage.tx)
+}
+
+// WithTx выполняет fn в транзакции: контекст fn несёт Executor этой
+// транзакции. Ошибка fn откатывает транзакцию, иначе она фиксируется.
+func (storage *PostgresStorage) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := storage.Begin(ctx); err != nil {
+		return err
+	}
+
+	if err := fn(storage.ContextWithTx(ctx)); err != nil {
+		// Ошибка отката уже записана в журнал, вызывающему важнее ошибка fn.
+		_ = storage.Rollback(ctx)
+		return err
+	}
+	return storage.Commit(ctx)
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutorFromContext(t *testing.T) {
+	_, ok := ExecutorFromContext(context.Background())
+	assert.False(t, ok)
+
+	var executor Executor
+	_, ok = ExecutorFromContext(ContextWithExecutor(context.Background(), executor))
+	assert.False(t, ok, "nil executor is not reported")
+}
+
+func TestMockContextWithTx(t *testing.T) {
+	ctx := context.Background()
+	st := NewMockSQLStorage()
+
+	_, ok := ExecutorFromContext(st.ContextWithTx(ctx))
+	assert.False(t, ok, "no executor outside a transaction")
+
+	assert.NoError(t, st.Begin(ctx))
+	executor, ok := ExecutorFromContext(st.ContextWithTx(ctx))
+	assert.True(t, ok)
+	_, err := executor.Exec(ctx, "INSERT INTO users VALUES (1);")
+	assert.NoError(t, err)
+	assert.NoError(t, st.Rollback(ctx))
+	assert.Empty(t, st.ExecutedSQL())
+}
+
+func TestMockWithTxRollsBackOnError(t *testing.T) {
+	ctx := context.Background()
+	st := NewMockSQLStorage()
+
+	err := st.WithTx(ctx, func(ctx context.Context) error {
+		_, err := st.Migrate(ctx, "INSERT INTO users VALUES (1);")
+		assert.NoError(t, err)
+		return ErrNoTransaction
+	})
+	assert.ErrorIs(t, err, ErrNoTransaction)
+	assert.Empty(t, st.ExecutedSQL())
+	assert.Equal(t, []string{"BEGIN", "ROLLBACK"}, st.TxLog())
+}